@@ -5,7 +5,6 @@
 package main
 
 import (
-	"bytes"
 	"encoding/json"
 	"io"
 	"os"
@@ -49,7 +48,9 @@ import (
 // 28: new departure flow
 // 29: TFR cache
 // 30: video map improvements
-const CurrentConfigVersion = 30
+// 31: STARS per-level weather opacity
+// 32: STARS per-audio-effect volume
+const CurrentConfigVersion = panes.ConfigVersion
 
 // Slightly convoluted, but the full Config definition is split into
 // the part with the Sim and the rest of it.  In this way, we can first
@@ -73,6 +74,8 @@ type ConfigNoSim struct {
 	UIFontSize    int
 
 	DisplayRoot *panes.DisplayNode
+	// Named display layouts saved by the user.
+	Layouts map[string]*panes.DisplayNode
 
 	TFRCache av.TFRCache
 
@@ -80,6 +83,19 @@ type ConfigNoSim struct {
 	InhibitDiscordActivity util.AtomicBool
 	NotifiedTargetGenMode  bool
 
+	// If non-zero, the sim is paused or disconnected (if IdleDisconnect
+	// is set) after this many minutes without user input.
+	IdleTimeoutMinutes int
+	IdleDisconnect     bool
+
+	// If set, expensive drawing (e.g., history tracks) is reduced when
+	// frames are taking too long to draw.
+	AdaptiveQuality bool
+
+	// Settings for recording timelapses.
+	TimelapseIntervalSeconds int
+	TimelapseMaxFrames       int
+
 	PrimaryTCP string
 }
 
@@ -109,15 +125,44 @@ func (gc *Config) Encode(w io.Writer) error {
 	return enc.Encode(gc)
 }
 
+func backupConfigFilePath(fn string) string {
+	return fn + ".bak"
+}
+
+// Save writes the config to a temporary file and then renames it into
+// place, so that an interrupted save can't leave a corrupt config file.
+// The previous config file, if valid, is kept as a backup.
 func (c *Config) Save(lg *log.Logger) error {
-	lg.Infof("Saving config to: %s", configFilePath(lg))
-	f, err := os.Create(configFilePath(lg))
+	fn := configFilePath(lg)
+	lg.Infof("Saving config to: %s", fn)
+
+	f, err := os.CreateTemp(filepath.Dir(fn), "config-*.json")
 	if err != nil {
 		return err
 	}
-	defer f.Close()
+	tmp := f.Name()
+	defer os.Remove(tmp) // no-op if the rename succeeds
 
-	return c.Encode(f)
+	if err := c.Encode(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	// Don't replace a good backup with a corrupt config file.
+	if prev, err := os.ReadFile(fn); err == nil && json.Valid(prev) {
+		if err := os.Rename(fn, backupConfigFilePath(fn)); err != nil {
+			lg.Warnf("%s: unable to make backup: %v", fn, err)
+		}
+	}
+
+	return os.Rename(tmp, fn)
 }
 
 func (gc *Config) SaveIfChanged(renderer renderer.Renderer, platform platform.Platform,
@@ -183,14 +228,29 @@ func LoadOrMakeDefaultConfig(lg *log.Logger) (config *Config, configErr error) {
 
 	config = getDefaultConfig()
 
-	if contents, err := os.ReadFile(fn); err == nil {
-		r := bytes.NewReader(contents)
-		d := json.NewDecoder(r)
-
+	contents, err := os.ReadFile(fn)
+	if err != nil {
+		// The primary may be missing if vice exited in the middle of
+		// saving it.
+		contents, err = os.ReadFile(backupConfigFilePath(fn))
+	}
+	if err == nil {
 		config = &Config{}
-		if err := d.Decode(&config.ConfigNoSim); err != nil {
-			configErr = err
-			config = getDefaultConfig()
+		if err := json.Unmarshal(contents, &config.ConfigNoSim); err != nil {
+			// Try the backup before giving up and using the defaults.
+			bak, berr := os.ReadFile(backupConfigFilePath(fn))
+			if berr == nil {
+				config = &Config{}
+				berr = json.Unmarshal(bak, &config.ConfigNoSim)
+			}
+
+			if berr == nil {
+				lg.Warnf("%s: %v; using backup config file", fn, err)
+				contents = bak
+			} else {
+				configErr = err
+				config = getDefaultConfig()
+			}
 		}
 
 		if config.Version < 1 {
@@ -219,8 +279,7 @@ func LoadOrMakeDefaultConfig(lg *log.Logger) (config *Config, configErr error) {
 
 		if config.Version == CurrentConfigVersion {
 			// Go ahead and deserialize the Sim
-			r.Seek(0, io.SeekStart)
-			if err := d.Decode(&config.ConfigSim); err != nil {
+			if err := json.Unmarshal(contents, &config.ConfigSim); err != nil {
 				configErr = err
 			}
 		}
@@ -229,6 +288,12 @@ func LoadOrMakeDefaultConfig(lg *log.Logger) (config *Config, configErr error) {
 	if config.UIFontSize == 0 {
 		config.UIFontSize = 16
 	}
+	if config.TimelapseIntervalSeconds == 0 {
+		config.TimelapseIntervalSeconds = 5
+	}
+	if config.TimelapseMaxFrames == 0 {
+		config.TimelapseMaxFrames = 1000
+	}
 	config.Version = CurrentConfigVersion
 
 	config.TFRCache.UpdateAsync(lg)
@@ -240,9 +305,68 @@ func LoadOrMakeDefaultConfig(lg *log.Logger) (config *Config, configErr error) {
 
 func (gc *Config) Activate(r renderer.Renderer, p platform.Platform, eventStream *sim.EventStream, lg *log.Logger) {
 	if gc.DisplayRoot == nil {
-		gc.DisplayRoot = panes.NewDisplayPanes(stars.NewSTARSPane(), panes.NewMessagesPane(),
-			panes.NewFlightStripPane())
+		gc.DisplayRoot = defaultDisplayRoot()
 	}
 
 	panes.Activate(gc.DisplayRoot, r, p, eventStream, lg)
 }
+
+// SavedFonts returns the font identifiers stored in the configuration so
+// that renderer.FontsInit can remap any that refer to fonts that are no
+// longer available.
+func (gc *Config) SavedFonts() []*renderer.FontIdentifier {
+	var ids []*renderer.FontIdentifier
+	visit := func(p panes.Pane) {
+		if mp, ok := p.(*panes.MessagesPane); ok {
+			ids = append(ids, &mp.FontIdentifier)
+		}
+	}
+	if gc.DisplayRoot != nil {
+		gc.DisplayRoot.VisitPanes(visit)
+	}
+	for _, name := range util.SortedMapKeys(gc.Layouts) {
+		gc.Layouts[name].VisitPanes(visit)
+	}
+	return ids
+}
+
+func defaultDisplayRoot() *panes.DisplayNode {
+	return panes.NewDisplayPanes(stars.NewSTARSPane(), panes.NewMessagesPane(), panes.NewFlightStripPane())
+}
+
+// SaveLayout stores a copy of the current display layout under the given
+// name.
+func (gc *Config) SaveLayout(name string) error {
+	layout, err := panes.CopyDisplayNode(gc.DisplayRoot)
+	if err != nil {
+		return err
+	}
+	if gc.Layouts == nil {
+		gc.Layouts = make(map[string]*panes.DisplayNode)
+	}
+	gc.Layouts[name] = layout
+	return nil
+}
+
+// UseLayout switches the display to the given layout; passing nil resets
+// it to the default layout. Existing panes are reused where possible.
+func (gc *Config) UseLayout(layout *panes.DisplayNode, c *sim.ControlClient, r renderer.Renderer,
+	p platform.Platform, eventStream *sim.EventStream, lg *log.Logger) error {
+	if layout == nil {
+		layout = defaultDisplayRoot()
+	}
+
+	root, newPanes, err := panes.ApplyLayout(layout, gc.DisplayRoot)
+	if err != nil {
+		return err
+	}
+
+	for _, pane := range newPanes {
+		pane.Activate(r, p, eventStream, lg)
+		if c != nil {
+			pane.LoadedSim(c, c.State, p, lg)
+		}
+	}
+	gc.DisplayRoot = root
+	return nil
+}
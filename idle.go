@@ -0,0 +1,83 @@
+// idle.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mmp/vice/pkg/log"
+	"github.com/mmp/vice/pkg/platform"
+	"github.com/mmp/vice/pkg/renderer"
+	"github.com/mmp/vice/pkg/sim"
+	"github.com/mmp/vice/pkg/util"
+)
+
+// How long before the idle timeout expires the user is warned (or half
+// the timeout, if it is shorter).
+const idleWarningTime = time.Minute
+
+// IdleMonitor pauses or disconnects from the sim if there has been no
+// user input for the time given by Config.IdleTimeoutMinutes, so that
+// aircraft don't keep piling up while the user is away.
+type IdleMonitor struct {
+	lastActivity time.Time
+	warning      *ModalDialogBox
+}
+
+func NewIdleMonitor() *IdleMonitor {
+	return &IdleMonitor{lastActivity: time.Now()}
+}
+
+// Activity should be called whenever there is user input.
+func (im *IdleMonitor) Activity() {
+	im.lastActivity = time.Now()
+	im.closeWarning()
+}
+
+func (im *IdleMonitor) closeWarning() {
+	if im.warning != nil {
+		uiCloseModalDialog(im.warning)
+		im.warning = nil
+	}
+}
+
+func (im *IdleMonitor) Update(mgr *sim.ConnectionManager, config *Config, c *sim.ControlClient,
+	r renderer.Renderer, p platform.Platform, lg *log.Logger) {
+	// Pausing a multi-controller sim would pause it for everyone, so in
+	// that case only disconnecting is an option.
+	local := c != nil && mgr.ClientIsLocal()
+	if config.IdleTimeoutMinutes == 0 || c == nil || c.SimIsPaused || (!local && !config.IdleDisconnect) {
+		im.Activity()
+		return
+	}
+
+	timeout := time.Duration(config.IdleTimeoutMinutes) * time.Minute
+	warning := min(idleWarningTime, timeout/2)
+	idle := time.Since(im.lastActivity)
+
+	if idle > timeout {
+		im.closeWarning()
+		im.lastActivity = time.Now()
+
+		if config.IdleDisconnect {
+			lg.Infof("disconnecting after %s idle", idle)
+			config.SaveIfChanged(r, p, c, local, lg)
+			mgr.Disconnect()
+			uiShowConnectDialog(mgr, false, config, p, lg)
+		} else {
+			lg.Infof("pausing after %s idle", idle)
+			c.ToggleSimPause()
+		}
+	} else if idle > timeout-warning && im.warning == nil {
+		im.warning = NewModalDialogBox(&MessageModalClient{
+			title: "Are you still there?",
+			message: fmt.Sprintf("There has been no activity for %s. The simulation will be %s in "+
+				"%d seconds unless there is some input.", idle.Truncate(time.Second),
+				util.Select(config.IdleDisconnect, "disconnected", "paused"), int(warning.Seconds())),
+		}, p)
+		uiShowModalDialog(im.warning, true)
+	}
+}
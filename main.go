@@ -10,6 +10,8 @@ package main
 
 import (
 	_ "embed"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log/slog"
@@ -44,14 +46,23 @@ var (
 	lintScenarios     = flag.Bool("lint", false, "check the validity of the built-in scenarios")
 	server            = flag.Bool("runserver", false, "run vice scenario server")
 	serverPort        = flag.Int("port", sim.ViceServerPort, "port to listen on when running server")
+	metricsPort       = flag.Int("metricsport", 0, "if non-zero, port on which to serve health and metrics endpoints when running server")
+	pprofPort         = flag.Int("pprofport", 0, "if non-zero, port on which to serve pprof endpoints to localhost when running server")
 	serverAddress     = flag.String("server", sim.ViceServerAddress+fmt.Sprintf(":%d", sim.ViceServerPort), "IP address of vice multi-controller server")
 	scenarioFilename  = flag.String("scenario", "", "filename of JSON file with a scenario definition")
 	videoMapFilename  = flag.String("videomap", "", "filename of JSON file with video map definitions")
 	broadcastMessage  = flag.String("broadcast", "", "message to broadcast to all active clients on the server")
 	broadcastPassword = flag.String("password", "", "password to authenticate with server for broadcast message")
+	broadcastTRACON   = flag.String("broadcasttracon", "", "only send the broadcast message to sims for the given TRACON")
+	broadcastDelay    = flag.Duration("broadcastdelay", 0, "delay before the server sends the broadcast message (e.g., 10m)")
+	drainServer       = flag.Duration("drain", 0, "stop the server from accepting new sims and shut it down after the given grace period (e.g., 15m)")
 	resetSim          = flag.Bool("resetsim", false, "discard the saved simulation and do not try to resume it")
 	showRoutes        = flag.String("routes", "", "display the STARS, SIDs, and approaches known for the given airport")
 	listMaps          = flag.String("listmaps", "", "path to a video map file to list maps of (e.g., resources/videomaps/ZNY-videomaps.gob.zst)")
+	jsonOutput        = flag.Bool("json", false, "emit JSON rather than plain text for -routes")
+	recordFilename    = flag.String("record", "", "record the local simulation session to the given file")
+	replayFilename    = flag.String("replay", "", "start the simulation from the given session recording")
+	replayTarget      = flag.String("replayto", "", "when replaying, run to the given time (seconds or HH:MM:SS) or to until:CALLSIGN")
 )
 
 func init() {
@@ -104,11 +115,56 @@ func main() {
 			av.CheckVideoMapManifest(m, &e)
 		}
 
+		// Check the maps each scenario group uses against the contents
+		// of its video map file.
+		libraries := make(map[string]*av.VideoMapLibrary)
+		for _, tracon := range util.SortedMapKeys(scenarioGroups) {
+			for _, name := range util.SortedMapKeys(scenarioGroups[tracon]) {
+				fa := &scenarioGroups[tracon][name].STARSFacilityAdaptation
+				if fa.VideoMapFile == "" {
+					continue
+				}
+
+				lib, ok := libraries[fa.VideoMapFile]
+				if !ok {
+					var err error
+					if lib, err = av.LoadVideoMapLibrary(fa.VideoMapFile); err != nil {
+						// Already reported by CheckVideoMapManifest()
+						lib = nil
+					}
+					libraries[fa.VideoMapFile] = lib
+				}
+				if lib != nil {
+					e.Push(name)
+					fa.CheckVideoMapIds(lib, &e)
+					e.Pop()
+				}
+			}
+		}
+
 		if e.HaveErrors() {
 			e.PrintErrors(nil)
 			os.Exit(1)
 		}
 
+		// Report aircraft types without a CWT category; these are shown as
+		// NOWGT in datablocks.
+		for _, tracon := range util.SortedMapKeys(scenarioGroups) {
+			for _, name := range util.SortedMapKeys(scenarioGroups[tracon]) {
+				counts := scenarioGroups[tracon][name].UnknownWakeCategoryTypes()
+				if len(counts) == 0 {
+					continue
+				}
+				var types []string
+				n := 0
+				for _, ty := range util.SortedMapKeys(counts) {
+					types = append(types, fmt.Sprintf("%s (%d)", ty, counts[ty]))
+					n += counts[ty]
+				}
+				fmt.Fprintf(os.Stderr, "%s: %d NOWGT aircraft: %s\n", name, n, strings.Join(types, ", "))
+			}
+		}
+
 		scenarioAirports := make(map[string]map[string]interface{})
 		for tracon, scenarios := range scenarioGroups {
 			if scenarioAirports[tracon] == nil {
@@ -126,12 +182,25 @@ func main() {
 			fmt.Printf("%s (%s),\n", tracon, strings.Join(airports, ", "))
 		}
 		os.Exit(0)
+	} else if *drainServer > 0 {
+		sim.DrainServer(*serverAddress, *drainServer, *broadcastMessage, *broadcastPassword, lg)
 	} else if *broadcastMessage != "" {
-		sim.BroadcastMessage(*serverAddress, *broadcastMessage, *broadcastPassword, lg)
+		sim.BroadcastMessageWithOptions(*serverAddress, *broadcastMessage, *broadcastPassword,
+			sim.BroadcastOptions{TRACON: *broadcastTRACON, Delay: *broadcastDelay}, lg)
 	} else if *server {
-		sim.RunServer(*scenarioFilename, *videoMapFilename, *serverPort, lg)
+		sim.RunServer(*scenarioFilename, *videoMapFilename, *serverPort, *metricsPort, *pprofPort, lg)
 	} else if *showRoutes != "" {
-		if err := av.PrintCIFPRoutes(*showRoutes); err != nil {
+		if *jsonOutput {
+			if routes, err := av.GetCIFPRoutes(*showRoutes); err != nil {
+				lg.Errorf("%s", err)
+			} else {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(routes); err != nil {
+					lg.Errorf("%s", err)
+				}
+			}
+		} else if err := av.PrintCIFPRoutes(*showRoutes); err != nil {
 			lg.Errorf("%s", err)
 		}
 	} else if *listMaps != "" {
@@ -166,11 +235,29 @@ func main() {
 					panes.ResetSim(config.DisplayRoot, c, c.State, plat, lg)
 				}
 				uiResetControlClient(c)
+				ui.replay = nil // a new sim replaces the one being replayed
 				controlClient = c
 			},
 			func(err error) {
-				switch err {
-				case sim.ErrRPCVersionMismatch:
+				var verr *sim.RPCVersionMismatchError
+				switch {
+				case errors.As(err, &verr) && verr.Client < verr.ServerMin:
+					ShowErrorDialog(plat, lg,
+						"This version of vice is too old for the vice multi-controller server.\n"+
+							"It uses RPC version %d, but the server requires version %d or later.\n"+
+							"Please upgrade to the latest version of vice for multi-controller support.",
+						verr.Client, verr.ServerMin)
+
+				case errors.As(err, &verr):
+					ShowErrorDialog(plat, lg,
+						"This version of vice is newer than the vice multi-controller server supports.\n"+
+							"It uses RPC version %d, but the server only supports up to version %d.\n"+
+							"Please install the latest release version of vice for multi-controller support.\n"+
+							"(If you're using a beta build, then thanks for your help testing vice; when the\n"+
+							"beta is released, the server will be updated as well.)",
+						verr.Client, verr.ServerMax)
+
+				case err == sim.ErrRPCVersionMismatch:
 					ShowErrorDialog(plat, lg,
 						"This version of vice is incompatible with the vice multi-controller server.\n"+
 							"If you're using an older version of vice, please upgrade to the latest\n"+
@@ -178,7 +265,7 @@ func main() {
 							"thanks for your help testing vice; when the beta is released, the server\n"+
 							"will be updated as well.)")
 
-				case sim.ErrServerDisconnected:
+				case err == sim.ErrServerDisconnected:
 					ShowErrorDialog(plat, lg, "Lost connection to the vice server.")
 					uiShowConnectDialog(mgr, false, config, plat, lg)
 
@@ -205,7 +292,7 @@ func main() {
 		if err != nil {
 			panic(fmt.Sprintf("Unable to initialize OpenGL: %v", err))
 		}
-		renderer.FontsInit(render, plat)
+		renderer.FontsInit(render, plat, config.SavedFonts()...)
 
 		eventStream := sim.NewEventStream(lg)
 
@@ -217,15 +304,37 @@ func main() {
 			ShowFatalErrorDialog(render, plat, lg, "%s", simErrorLogger.String())
 		}
 
-		// After config.Activate(), if we have a loaded sim, get configured for it.
-		if config.Sim != nil && !*resetSim {
-			if client, err := mgr.LoadLocalSim(config.Sim, lg); err != nil {
+		loadLocalSim := func(s *sim.Sim) *sim.ControlClient {
+			if client, err := mgr.LoadLocalSim(s, lg); err != nil {
 				lg.Errorf("Error loading local sim: %v", err)
+				return nil
 			} else {
 				panes.LoadedSim(config.DisplayRoot, client, client.State, plat, lg)
 				uiResetControlClient(client)
 				controlClient = client
+				return client
+			}
+		}
+
+		// After config.Activate(), if we have a loaded sim, get configured for it.
+		if *replayFilename != "" {
+			if rec, err := sim.LoadRecording(*replayFilename); err != nil {
+				ShowErrorDialog(plat, lg, "Unable to load session recording: %v", err)
+			} else {
+				lg.Infof("%s: replaying session recorded at %s with %d commands", *replayFilename,
+					rec.StartTime, len(rec.Commands))
+				replay := NewReplayController(*replayFilename, rec, loadLocalSim, lg)
+				if c, err := replay.Start(); err != nil {
+					ShowErrorDialog(plat, lg, "Unable to replay session recording: %v", err)
+				} else {
+					ui.replay = replay
+					if *replayTarget != "" {
+						ui.replay.Seek(c, *replayTarget)
+					}
+				}
 			}
+		} else if config.Sim != nil && !*resetSim {
+			loadLocalSim(config.Sim)
 		}
 
 		if !mgr.Connected() {
@@ -236,6 +345,13 @@ func main() {
 		// Main event / rendering loop
 		lg.Info("Starting main loop")
 
+		idle := NewIdleMonitor()
+		// Only the first sim of a session is recorded, and replays aren't
+		// recorded since starting a recording reseeds the sim.
+		record := *recordFilename != "" && *replayFilename == ""
+		reducedQuality := false
+		var lastCrashContext time.Time
+
 		stats.startTime = time.Now()
 		for {
 			plat.SetWindowTitle("vice: " + controlClient.Status())
@@ -257,27 +373,60 @@ func main() {
 
 			mgr.Update(eventStream, lg)
 
+			// Keep the crash report context current; it's gathered here
+			// rather than when a crash happens since the crash may be in
+			// another goroutine that's racing with this one.
+			if time.Since(lastCrashContext) > 5*time.Second {
+				lg.SetCrashReportContext(crashReportContext(config, controlClient))
+				lastCrashContext = time.Now()
+			}
+
+			if record && controlClient != nil {
+				startRecording(controlClient, mgr, lg)
+				record = false
+			}
+
 			// Inform imgui about input events from the user.
-			plat.ProcessEvents()
+			if plat.ProcessEvents() {
+				idle.Activity()
+			}
+			idle.Update(mgr, config, controlClient, render, plat, lg)
 
 			stats.redraws++
 
 			plat.NewFrame()
 			imgui.NewFrame()
+			drawStart := time.Now()
 
 			// Generate and render vice draw lists
 			stats.drawPanes = panes.DrawPanes(config.DisplayRoot, plat, render, controlClient,
-				ui.menuBarHeight, &config.AudioEnabled, lg)
+				ui.menuBarHeight, &config.AudioEnabled, reducedQuality, lg)
 
 			// Draw the user interface
 			stats.drawUI = uiDraw(mgr, config, plat, render, controlClient, eventStream, lg)
 
+			drawTime := time.Since(drawStart)
+			if rq := stats.UpdateFrameTime(drawTime, config.AdaptiveQuality); rq != reducedQuality {
+				lg.Infof("%s reduced quality rendering: average draw time %s, %.1f fps",
+					util.Select(rq, "enabling", "disabling"), stats.drawTime, stats.FPS())
+				reducedQuality = rq
+			}
+
+			if ui.takeScreenshot {
+				// Capture the frame before it's displayed.
+				ui.takeScreenshot = false
+				uiSaveScreenshot(render, plat, lg)
+			}
+			if ui.timelapse != nil && ui.timelapse.Update(render, plat, lg) {
+				uiStopTimelapse(plat, lg)
+			}
+
 			// Wait for vsync
 			plat.PostRender()
 
 			// Periodically log current memory use, etc.
 			if stats.redraws%18000 == 0 {
-				lg.Info("performance", slog.Any("stats", stats))
+				lg.Info("performance", slog.Any("stats", stats), slog.Any("panes", paneStats(config.DisplayRoot)))
 			}
 
 			if plat.ShouldStop() && len(ui.activeModalDialogs) == 0 {
@@ -290,3 +439,35 @@ func main() {
 		}
 	}
 }
+
+// startRecording starts recording the session to the file given by
+// -record. The recording ends when the controller signs off from the sim,
+// e.g. to start a different one.
+func startRecording(c *sim.ControlClient, mgr *sim.ConnectionManager, lg *log.Logger) {
+	if !mgr.ClientIsLocal() {
+		lg.Warnf("%s: only local sims can be recorded", *recordFilename)
+	} else if err := c.StartRecording(*recordFilename); err != nil {
+		lg.Errorf("%s: %v", *recordFilename, err)
+	} else {
+		lg.Infof("%s: recording session", *recordFilename)
+	}
+}
+
+// crashReportContext returns a short description of the current sim and
+// configuration to include in crash reports. It's called regularly from
+// the main loop, so it only includes a few small fields rather than the
+// whole config.
+func crashReportContext(config *Config, c *sim.ControlClient) string {
+	var b strings.Builder
+	if c == nil {
+		b.WriteString("No active sim\n")
+	} else {
+		fmt.Fprintf(&b, "Sim: TRACON %s, %q, name %q, position %s, paused %v, rate %.1f\n",
+			c.State.TRACON, c.State.SimDescription, c.State.SimName, c.State.PrimaryTCP,
+			c.State.SimIsPaused, c.State.SimRate)
+	}
+	fmt.Fprintf(&b, "Config: version %d, last server %q, last TRACON %s, font size %d, window %dx%d, adaptive quality %v\n",
+		config.Version, config.LastServer, config.LastTRACON, config.UIFontSize,
+		config.InitialWindowSize[0], config.InitialWindowSize[1], config.AdaptiveQuality)
+	return b.String()
+}
@@ -177,10 +177,10 @@ func (ac *Aircraft) transmitResponse(r PilotResponse) []RadioTransmission {
 	}}
 }
 
-func (ac *Aircraft) Update(wind WindModel, simlg *log.Logger) *Waypoint {
+func (ac *Aircraft) Update(wind WindModel, simTime time.Time, simlg *log.Logger) *Waypoint {
 	lg := simlg.With(slog.String("callsign", ac.Callsign))
 
-	passedWaypoint := ac.Nav.Update(wind, lg)
+	passedWaypoint := ac.Nav.Update(wind, simTime, lg)
 	if passedWaypoint != nil {
 		lg.Info("passed", slog.Any("waypoint", passedWaypoint))
 	}
@@ -188,8 +188,8 @@ func (ac *Aircraft) Update(wind WindModel, simlg *log.Logger) *Waypoint {
 	return passedWaypoint
 }
 
-func (ac *Aircraft) GoAround() []RadioTransmission {
-	resp := ac.Nav.GoAround()
+func (ac *Aircraft) GoAround(r *rand.Rand) []RadioTransmission {
+	resp := ac.Nav.GoAround(r)
 	ac.GotContactTower = false
 	return []RadioTransmission{RadioTransmission{
 		Controller: ac.ControllingController,
@@ -198,67 +198,67 @@ func (ac *Aircraft) GoAround() []RadioTransmission {
 	}}
 }
 
-func (ac *Aircraft) AssignAltitude(altitude int, afterSpeed bool) []RadioTransmission {
-	response := ac.Nav.AssignAltitude(float32(altitude), afterSpeed)
+func (ac *Aircraft) AssignAltitude(altitude int, afterSpeed bool, r *rand.Rand) []RadioTransmission {
+	response := ac.Nav.AssignAltitude(float32(altitude), afterSpeed, r)
 	return ac.transmitResponse(response)
 }
 
-func (ac *Aircraft) AssignSpeed(speed int, afterAltitude bool) []RadioTransmission {
-	resp := ac.Nav.AssignSpeed(float32(speed), afterAltitude)
+func (ac *Aircraft) AssignSpeed(speed int, afterAltitude bool, r *rand.Rand) []RadioTransmission {
+	resp := ac.Nav.AssignSpeed(float32(speed), afterAltitude, r)
 	return ac.transmitResponse(resp)
 }
 
-func (ac *Aircraft) MaintainSlowestPractical() []RadioTransmission {
-	return ac.transmitResponse(ac.Nav.MaintainSlowestPractical())
+func (ac *Aircraft) MaintainSlowestPractical(r *rand.Rand) []RadioTransmission {
+	return ac.transmitResponse(ac.Nav.MaintainSlowestPractical(r))
 }
 
-func (ac *Aircraft) MaintainMaximumForward() []RadioTransmission {
-	return ac.transmitResponse(ac.Nav.MaintainMaximumForward())
+func (ac *Aircraft) MaintainMaximumForward(r *rand.Rand) []RadioTransmission {
+	return ac.transmitResponse(ac.Nav.MaintainMaximumForward(r))
 }
 
-func (ac *Aircraft) SaySpeed() []RadioTransmission {
-	return ac.transmitResponse(ac.Nav.SaySpeed())
+func (ac *Aircraft) SaySpeed(r *rand.Rand) []RadioTransmission {
+	return ac.transmitResponse(ac.Nav.SaySpeed(r))
 }
 
 func (ac *Aircraft) SayHeading() []RadioTransmission {
 	return ac.transmitResponse(ac.Nav.SayHeading())
 }
 
-func (ac *Aircraft) SayAltitude() []RadioTransmission {
-	return ac.transmitResponse(ac.Nav.SayAltitude())
+func (ac *Aircraft) SayAltitude(r *rand.Rand) []RadioTransmission {
+	return ac.transmitResponse(ac.Nav.SayAltitude(r))
 }
 
-func (ac *Aircraft) ExpediteDescent() []RadioTransmission {
-	return ac.transmitResponse(ac.Nav.ExpediteDescent())
+func (ac *Aircraft) ExpediteDescent(r *rand.Rand) []RadioTransmission {
+	return ac.transmitResponse(ac.Nav.ExpediteDescent(r))
 }
 
-func (ac *Aircraft) ExpediteClimb() []RadioTransmission {
-	return ac.transmitResponse(ac.Nav.ExpediteClimb())
+func (ac *Aircraft) ExpediteClimb(r *rand.Rand) []RadioTransmission {
+	return ac.transmitResponse(ac.Nav.ExpediteClimb(r))
 }
 
-func (ac *Aircraft) AssignHeading(heading int, turn TurnMethod) []RadioTransmission {
-	resp := ac.Nav.AssignHeading(float32(heading), turn)
+func (ac *Aircraft) AssignHeading(heading int, turn TurnMethod, simTime time.Time, r *rand.Rand) []RadioTransmission {
+	resp := ac.Nav.AssignHeading(float32(heading), turn, simTime, r)
 	return ac.transmitResponse(resp)
 }
 
-func (ac *Aircraft) TurnLeft(deg int) []RadioTransmission {
+func (ac *Aircraft) TurnLeft(deg int, simTime time.Time, r *rand.Rand) []RadioTransmission {
 	hdg := math.NormalizeHeading(ac.Nav.FlightState.Heading - float32(deg))
-	ac.Nav.AssignHeading(hdg, TurnLeft)
-	return ac.readback(rand.Sample("turn %d degrees left", "%d to the left"), deg)
+	ac.Nav.AssignHeading(hdg, TurnLeft, simTime, r)
+	return ac.readback(rand.Sample(r, "turn %d degrees left", "%d to the left"), deg)
 }
 
-func (ac *Aircraft) TurnRight(deg int) []RadioTransmission {
+func (ac *Aircraft) TurnRight(deg int, simTime time.Time, r *rand.Rand) []RadioTransmission {
 	hdg := math.NormalizeHeading(ac.Nav.FlightState.Heading + float32(deg))
-	ac.Nav.AssignHeading(hdg, TurnRight)
-	return ac.readback(rand.Sample("turn %d degrees right", "%d to the right"), deg)
+	ac.Nav.AssignHeading(hdg, TurnRight, simTime, r)
+	return ac.readback(rand.Sample(r, "turn %d degrees right", "%d to the right"), deg)
 }
 
-func (ac *Aircraft) FlyPresentHeading() []RadioTransmission {
-	return ac.transmitResponse(ac.Nav.FlyPresentHeading())
+func (ac *Aircraft) FlyPresentHeading(simTime time.Time, r *rand.Rand) []RadioTransmission {
+	return ac.transmitResponse(ac.Nav.FlyPresentHeading(simTime, r))
 }
 
-func (ac *Aircraft) DirectFix(fix string) []RadioTransmission {
-	return ac.transmitResponse(ac.Nav.DirectFix(strings.ToUpper(fix)))
+func (ac *Aircraft) DirectFix(fix string, simTime time.Time, r *rand.Rand) []RadioTransmission {
+	return ac.transmitResponse(ac.Nav.DirectFix(strings.ToUpper(fix), simTime, r))
 }
 
 func (ac *Aircraft) DepartFixHeading(fix string, hdg int) []RadioTransmission {
@@ -276,8 +276,8 @@ func (ac *Aircraft) CrossFixAt(fix string, ar *AltitudeRestriction, speed int) [
 	return ac.transmitResponse(resp)
 }
 
-func (ac *Aircraft) ExpectApproach(id string, ap *Airport, lg *log.Logger) []RadioTransmission {
-	resp := ac.Nav.ExpectApproach(ap, id, ac.STARRunwayWaypoints, lg)
+func (ac *Aircraft) ExpectApproach(id string, ap *Airport, r *rand.Rand, lg *log.Logger) []RadioTransmission {
+	resp := ac.Nav.ExpectApproach(ap, id, ac.STARRunwayWaypoints, r, lg)
 	return ac.transmitResponse(resp)
 }
 
@@ -285,8 +285,8 @@ func (ac *Aircraft) AssignedApproach() string {
 	return ac.Nav.Approach.AssignedId
 }
 
-func (ac *Aircraft) AtFixCleared(fix, approach string) []RadioTransmission {
-	return ac.transmitResponse(ac.Nav.AtFixCleared(fix, approach))
+func (ac *Aircraft) AtFixCleared(fix, approach string, r *rand.Rand) []RadioTransmission {
+	return ac.transmitResponse(ac.Nav.AtFixCleared(fix, approach, r))
 }
 
 func (ac *Aircraft) ClearedApproach(id string, lg *log.Logger) []RadioTransmission {
@@ -309,12 +309,12 @@ func (ac *Aircraft) CancelApproachClearance() []RadioTransmission {
 	return ac.transmitResponse(ac.Nav.CancelApproachClearance())
 }
 
-func (ac *Aircraft) ClimbViaSID() []RadioTransmission {
-	return ac.transmitResponse(ac.Nav.ClimbViaSID())
+func (ac *Aircraft) ClimbViaSID(simTime time.Time, r *rand.Rand) []RadioTransmission {
+	return ac.transmitResponse(ac.Nav.ClimbViaSID(simTime, r))
 }
 
-func (ac *Aircraft) DescendViaSTAR() []RadioTransmission {
-	return ac.transmitResponse(ac.Nav.DescendViaSTAR())
+func (ac *Aircraft) DescendViaSTAR(simTime time.Time, r *rand.Rand) []RadioTransmission {
+	return ac.transmitResponse(ac.Nav.DescendViaSTAR(simTime, r))
 }
 
 func (ac *Aircraft) ContactTower(controllers map[string]*Controller, lg *log.Logger) []RadioTransmission {
@@ -338,13 +338,13 @@ func (ac *Aircraft) ContactTower(controllers map[string]*Controller, lg *log.Log
 	}
 }
 
-func (ac *Aircraft) InterceptLocalizer() []RadioTransmission {
-	resp := ac.Nav.InterceptLocalizer(ac.FlightPlan.ArrivalAirport)
+func (ac *Aircraft) InterceptLocalizer(r *rand.Rand) []RadioTransmission {
+	resp := ac.Nav.InterceptLocalizer(ac.FlightPlan.ArrivalAirport, r)
 	return ac.transmitResponse(resp)
 }
 
 func (ac *Aircraft) InitializeArrival(ap *Airport, arr *Arrival, arrivalHandoffController string, goAround bool,
-	nmPerLongitude float32, magneticVariation float32, lg *log.Logger) error {
+	nmPerLongitude float32, magneticVariation float32, r *rand.Rand, lg *log.Logger) error {
 	ac.STAR = arr.STAR
 	ac.STARRunwayWaypoints = arr.RunwayWaypoints[ac.FlightPlan.ArrivalAirport]
 	ac.Scratchpad = arr.Scratchpad
@@ -371,7 +371,7 @@ func (ac *Aircraft) InitializeArrival(ap *Airport, arr *Arrival, arrivalHandoffC
 	}
 
 	if goAround {
-		d := 0.1 + .6*rand.Float32()
+		d := 0.1 + .6*r.Float32()
 		ac.GoAroundDistance = &d
 	}
 
@@ -383,11 +383,11 @@ func (ac *Aircraft) InitializeArrival(ap *Airport, arr *Arrival, arrivalHandoffC
 
 	if arr.ExpectApproach.A != nil {
 		lg = lg.With(slog.String("callsign", ac.Callsign), slog.Any("aircraft", ac))
-		ac.ExpectApproach(*arr.ExpectApproach.A, ap, lg)
+		ac.ExpectApproach(*arr.ExpectApproach.A, ap, r, lg)
 	} else if arr.ExpectApproach.B != nil {
 		if app, ok := (*arr.ExpectApproach.B)[ac.FlightPlan.ArrivalAirport]; ok {
 			lg = lg.With(slog.String("callsign", ac.Callsign), slog.Any("aircraft", ac))
-			ac.ExpectApproach(app, ap, lg)
+			ac.ExpectApproach(app, ap, r, lg)
 		}
 	}
 
@@ -397,7 +397,7 @@ func (ac *Aircraft) InitializeArrival(ap *Airport, arr *Arrival, arrivalHandoffC
 func (ac *Aircraft) InitializeDeparture(ap *Airport, departureAirport string, dep *Departure,
 	runway string, exitRoute ExitRoute, nmPerLongitude float32,
 	magneticVariation float32, scratchpads map[string]string,
-	primaryController string, multiControllers SplitConfiguration,
+	primaryController string, multiControllers SplitConfiguration, r *rand.Rand,
 	lg *log.Logger) error {
 	wp := util.DuplicateSlice(exitRoute.Waypoints)
 	wp = append(wp, dep.RouteWaypoints...)
@@ -422,7 +422,7 @@ func (ac *Aircraft) InitializeDeparture(ap *Airport, departureAirport string, de
 	ac.SecondaryScratchpad = dep.SecondaryScratchpad
 	ac.FlightPlan.Exit = dep.Exit
 
-	idx := rand.SampleFiltered(dep.Altitudes, func(alt int) bool { return alt <= int(perf.Ceiling) })
+	idx := rand.SampleFiltered(r, dep.Altitudes, func(alt int) bool { return alt <= int(perf.Ceiling) })
 	if idx == -1 {
 		ac.FlightPlan.Altitude =
 			PlausibleFinalAltitude(ac.FlightPlan, perf, nmPerLongitude, magneticVariation)
@@ -460,7 +460,7 @@ func (ac *Aircraft) InitializeDeparture(ap *Airport, departureAirport string, de
 		}
 
 		ac.DepartureContactAltitude =
-			ac.Nav.FlightState.DepartureAirportElevation + 500 + float32(rand.Intn(500))
+			ac.Nav.FlightState.DepartureAirportElevation + 500 + float32(r.Intn(500))
 		ac.DepartureContactAltitude = math.Min(ac.DepartureContactAltitude, float32(ac.FlightPlan.Altitude))
 		ac.DepartureContactController = ctrl
 	}
@@ -471,7 +471,7 @@ func (ac *Aircraft) InitializeDeparture(ap *Airport, departureAirport string, de
 }
 
 func (ac *Aircraft) InitializeOverflight(of *Overflight, controller string, nmPerLongitude float32,
-	magneticVariation float32, lg *log.Logger) error {
+	magneticVariation float32, r *rand.Rand, lg *log.Logger) error {
 	ac.Scratchpad = of.Scratchpad
 	ac.SecondaryScratchpad = of.SecondaryScratchpad
 	ac.TrackingController = of.InitialController
@@ -491,7 +491,7 @@ func (ac *Aircraft) InitializeOverflight(of *Overflight, controller string, nmPe
 	}
 	ac.FlightPlan.Route = of.Waypoints.RouteString()
 
-	nav := MakeOverflightNav(of, *ac.FlightPlan, perf, nmPerLongitude, magneticVariation, lg)
+	nav := MakeOverflightNav(of, *ac.FlightPlan, perf, nmPerLongitude, magneticVariation, r, lg)
 	if nav == nil {
 		return fmt.Errorf("error initializing Nav")
 	}
@@ -508,11 +508,11 @@ func (ac *Aircraft) ContactMessage(reportingPoints []ReportingPoint) string {
 	return ac.Nav.ContactMessage(reportingPoints, ac.STAR)
 }
 
-func (ac *Aircraft) DepartOnCourse(lg *log.Logger) {
+func (ac *Aircraft) DepartOnCourse(simTime time.Time, r *rand.Rand, lg *log.Logger) {
 	if ac.FlightPlan.Exit == "" {
 		lg.Warn("unset \"exit\" for departure", slog.String("callsign", ac.Callsign))
 	}
-	ac.Nav.DepartOnCourse(float32(ac.FlightPlan.Altitude), ac.FlightPlan.Exit)
+	ac.Nav.DepartOnCourse(float32(ac.FlightPlan.Altitude), ac.FlightPlan.Exit, simTime, r)
 }
 
 func (ac *Aircraft) Check(lg *log.Logger) {
@@ -616,7 +616,13 @@ func (ac *Aircraft) DistanceAlongRoute(fix string) (float32, error) {
 }
 
 func (ac *Aircraft) CWT() string {
-	perf, ok := DB.AircraftPerformance[ac.FlightPlan.BaseType()]
+	return AircraftTypeCWT(ac.FlightPlan.BaseType())
+}
+
+// AircraftTypeCWT returns the CWT category for the given ICAO aircraft
+// type or "NOWGT" if it is unknown.
+func AircraftTypeCWT(actype string) string {
+	perf, ok := DB.AircraftPerformance[actype]
 	if !ok {
 		return "NOWGT"
 	}
@@ -627,6 +633,30 @@ func (ac *Aircraft) CWT() string {
 	return perf.Category.CWT
 }
 
+// WeightClass returns the aircraft's wake turbulence weight class as used
+// before the CWT categories: "J" (super), "H" (heavy), "B" (B757), "L"
+// (large), or "S" (small). "NOWGT" is returned if it is unknown.
+func (ac *Aircraft) WeightClass() string {
+	if strings.HasPrefix(ac.FlightPlan.BaseType(), "B75") {
+		return "B"
+	}
+
+	perf, ok := DB.AircraftPerformance[ac.FlightPlan.BaseType()]
+	if !ok {
+		return "NOWGT"
+	}
+	switch perf.WeightClass {
+	case "J", "H":
+		return perf.WeightClass
+	case "L", "M":
+		return "L"
+	case "S", "S+":
+		return "S"
+	default:
+		return "NOWGT"
+	}
+}
+
 ///////////////////////////////////////////////////////////////////////////
 // RedirectedHandoff methods
 
@@ -24,6 +24,7 @@ import (
 	"github.com/mmp/vice/pkg/rand"
 	"github.com/mmp/vice/pkg/renderer"
 	"github.com/mmp/vice/pkg/util"
+	"github.com/mmp/vice/pkg/wx"
 
 	"github.com/klauspost/compress/zstd"
 )
@@ -141,9 +142,14 @@ type METAR struct {
 	Weather     string
 	Altimeter   string
 	Rmk         string
+	Raw         string // full text of the report, if available
 }
 
 func (m METAR) String() string {
+	if m.Raw != "" {
+		return m.Raw
+	}
+
 	auto := ""
 	if m.Auto {
 		auto = "AUTO"
@@ -151,6 +157,11 @@ func (m METAR) String() string {
 	return strings.Join([]string{m.AirportICAO, m.Time, auto, m.Wind, m.Weather, m.Altimeter, m.Rmk}, " ")
 }
 
+// Decode returns the structured contents of the METAR.
+func (m METAR) Decode() (wx.METAR, error) {
+	return wx.ParseMETAR(m.String())
+}
+
 type ATIS struct {
 	Airport  string
 	AppDep   string
@@ -605,6 +616,18 @@ func TASToIAS(tas, altitude float32) float32 {
 	return tas * math.Sqrt(DensityRatioAtAltitude(altitude))
 }
 
+// TASToMach returns the Mach number corresponding to the given true
+// airspeed (in knots) at the given altitude (in feet), assuming the
+// standard atmosphere.
+func TASToMach(tas, altitude float32) float32 {
+	// ISA temperature lapses 1.98 degrees K per 1,000' up to the
+	// tropopause and is constant above it.
+	tk := math.Max(288.15-0.0019812*altitude, 216.65)
+	// Speed of sound in knots: sqrt(gamma * R_air * T) converted from m/s.
+	a := 38.967854 * math.Sqrt(tk)
+	return tas / a
+}
+
 ///////////////////////////////////////////////////////////////////////////
 // Arrival
 
@@ -930,6 +953,9 @@ type VideoMapManifest struct {
 func CheckVideoMapManifest(filename string, e *util.ErrorLogger) {
 	defer e.CheckDepth(e.CurrentDepth())
 
+	e.Push(filename)
+	defer e.Pop()
+
 	manifest, err := LoadVideoMapManifest(filename)
 	if err != nil {
 		e.Error(err)
@@ -952,6 +978,7 @@ func CheckVideoMapManifest(filename string, e *util.ErrorLogger) {
 			e.ErrorString("%s: map is in video map file but not manifest", m.Name)
 		}
 	}
+
 }
 
 func LoadVideoMapManifest(filename string) (*VideoMapManifest, error) {
@@ -1282,9 +1309,9 @@ func MakeSquawkBankCodePool(bank int) *SquawkCodePool {
 	return makePool(bank*0o100+1, bank*0o100+0o77)
 }
 
-func (p *SquawkCodePool) Get() (Squawk, error) {
-	start := rand.Intn(len(p.AssignedBits)) // random starting point in p.AssignedBits
-	rot := rand.Intn(64)                    // random rotation to randomize search start within each uint64
+func (p *SquawkCodePool) Get(r *rand.Rand) (Squawk, error) {
+	start := r.Intn(len(p.AssignedBits)) // random starting point in p.AssignedBits
+	rot := r.Intn(64)                    // random rotation to randomize search start within each uint64
 
 	for i := range len(p.AssignedBits) {
 		// Start the search at start, then wrap around.
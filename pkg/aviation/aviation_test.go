@@ -7,6 +7,7 @@ package aviation
 import (
 	"testing"
 
+	"github.com/mmp/vice/pkg/math"
 	"github.com/mmp/vice/pkg/rand"
 )
 
@@ -67,8 +68,9 @@ func TestParseAltitudeRestriction(t *testing.T) {
 }
 
 func TestSquawkCodePoolBasics(t *testing.T) {
+	r := rand.New()
 	for _, p := range []*SquawkCodePool{MakeCompleteSquawkCodePool(), MakeSquawkBankCodePool(1), MakeSquawkBankCodePool(6)} {
-		sq, err := p.Get()
+		sq, err := p.Get(r)
 		if err != nil {
 			t.Errorf("unexpected error: %v", err)
 		}
@@ -96,11 +98,12 @@ func TestSquawkCodePoolBasics(t *testing.T) {
 }
 
 func TestSquawkCodePoolRandoms(t *testing.T) {
+	r := rand.New()
 	for _, p := range []*SquawkCodePool{MakeCompleteSquawkCodePool(), MakeSquawkBankCodePool(1), MakeSquawkBankCodePool(6)} {
 		assigned := make(map[Squawk]interface{})
 
 		for i := range 100000 {
-			sq, err := p.Get()
+			sq, err := p.Get(r)
 			if err != nil && p.NumAvailable() > 0 {
 				t.Errorf("unexpected error: %v", err)
 			} else if _, ok := assigned[sq]; ok {
@@ -156,6 +159,33 @@ func TestApproachCWTSeparation(t *testing.T) {
 	}
 }
 
+func TestWeightClassApproachSeparation(t *testing.T) {
+	type testcase struct {
+		front, back string
+		expect      float32
+	}
+	for _, tc := range []testcase{
+		testcase{front: "J", back: "H", expect: 6},
+		testcase{front: "J", back: "S", expect: 8},
+		testcase{front: "H", back: "H", expect: 4},
+		testcase{front: "H", back: "L", expect: 5},
+		testcase{front: "H", back: "J", expect: 0},
+		testcase{front: "B", back: "J", expect: 0},
+		testcase{front: "B", back: "H", expect: 0},
+		testcase{front: "B", back: "B", expect: 0},
+		testcase{front: "B", back: "L", expect: 0},
+		testcase{front: "B", back: "S", expect: 5},
+		testcase{front: "L", back: "S", expect: 4},
+		testcase{front: "L", back: "H", expect: 0},
+		testcase{front: "NOWGT", back: "L", expect: 10},
+		testcase{front: "H", back: "", expect: 10},
+	} {
+		if s := WeightClassApproachSeparation(tc.front, tc.back); s != tc.expect {
+			t.Errorf("WeightClassApproachSeparation(%q, %q) = %f. Expected %f", tc.front, tc.back, s, tc.expect)
+		}
+	}
+}
+
 func TestDirectlyBehindCWTSeparation(t *testing.T) {
 	type testcase struct {
 		front, back string
@@ -178,3 +208,20 @@ func TestDirectlyBehindCWTSeparation(t *testing.T) {
 		}
 	}
 }
+
+func TestTASToMach(t *testing.T) {
+	type testcase struct {
+		tas, alt, expect float32
+	}
+	for _, tc := range []testcase{
+		testcase{tas: 661.5, alt: 0, expect: 1},
+		testcase{tas: 330.7, alt: 0, expect: 0.5},
+		testcase{tas: 573.6, alt: 36089, expect: 1},
+		testcase{tas: 573.6, alt: 45000, expect: 1}, // constant above the tropopause
+		testcase{tas: 470, alt: 35000, expect: 0.815},
+	} {
+		if m := TASToMach(tc.tas, tc.alt); math.Abs(m-tc.expect) > 0.005 {
+			t.Errorf("TASToMach(%f, %f) = %f. Expected %f", tc.tas, tc.alt, m, tc.expect)
+		}
+	}
+}
@@ -826,24 +826,83 @@ func (ap FAAAirport) ValidRunways() string {
 	return strings.Join(util.MapSlice(ap.Runways, func(r Runway) string { return r.Id }), ", ")
 }
 
-func PrintCIFPRoutes(airport string) error {
+// CIFPRoutes summarizes the STARs and approaches that the CIFP defines
+// for an airport in a form that can be marshaled to JSON. Routes are
+// given as encoded waypoint strings, matching the format used in
+// scenario files.
+type CIFPRoutes struct {
+	Airport string `json:"airport"`
+	// STAR name -> routes
+	STARs map[string]CIFPSTARRoutes `json:"stars"`
+	// Approach id -> routes
+	Approaches map[string][]string `json:"approaches"`
+}
+
+// CIFPSTARRoutes holds the routes for a single STAR.
+type CIFPSTARRoutes struct {
+	// Transition -> route
+	Transitions map[string]string `json:"transitions"`
+	// Runway -> route
+	RunwayTransitions map[string]string `json:"runway_transitions"`
+}
+
+// GetCIFPRoutes returns the STARs and approaches for the given airport.
+func GetCIFPRoutes(airport string) (CIFPRoutes, error) {
 	ap, ok := DB.Airports[airport]
 	if !ok {
-		return fmt.Errorf("%s: airport not present in database\n", airport)
+		return CIFPRoutes{}, fmt.Errorf("%s: airport not present in database", airport)
 	}
 
+	r := CIFPRoutes{
+		Airport:    airport,
+		STARs:      make(map[string]CIFPSTARRoutes),
+		Approaches: make(map[string][]string),
+	}
+	for name, star := range ap.STARs {
+		routes := CIFPSTARRoutes{
+			Transitions:       make(map[string]string),
+			RunwayTransitions: make(map[string]string),
+		}
+		for tr, wps := range star.Transitions {
+			routes.Transitions[tr] = wps.Encode()
+		}
+		for rwy, wps := range star.RunwayWaypoints {
+			routes.RunwayTransitions[rwy] = wps.Encode()
+		}
+		r.STARs[name] = routes
+	}
+	for id, appr := range ap.Approaches {
+		r.Approaches[id] = util.MapSlice(appr, func(wps WaypointArray) string { return wps.Encode() })
+	}
+	return r, nil
+}
+
+func PrintCIFPRoutes(airport string) error {
+	r, err := GetCIFPRoutes(airport)
+	if err != nil {
+		return err
+	}
+
+	const routePrintFormat = "%-13s: %s\n"
+
 	fmt.Printf("STARs:\n")
-	for _, s := range util.SortedMapKeys(ap.STARs) {
-		ap.STARs[s].Print(s)
+	for _, s := range util.SortedMapKeys(r.STARs) {
+		star := r.STARs[s]
+		for _, tr := range util.SortedMapKeys(star.Transitions) {
+			fmt.Printf(routePrintFormat, s+"."+tr, star.Transitions[tr])
+		}
+		for _, rwy := range util.SortedMapKeys(star.RunwayTransitions) {
+			fmt.Printf(routePrintFormat, s+".RWY"+rwy, star.RunwayTransitions[rwy])
+		}
 	}
 	fmt.Printf("\nApproaches:\n")
-	for _, appr := range util.SortedMapKeys(ap.Approaches) {
+	for _, appr := range util.SortedMapKeys(r.Approaches) {
 		fmt.Printf("%-5s: ", appr)
-		for i, wp := range ap.Approaches[appr] {
+		for i, wps := range r.Approaches[appr] {
 			if i > 0 {
 				fmt.Printf("       ")
 			}
-			fmt.Println(wp.Encode())
+			fmt.Println(wps)
 		}
 	}
 	return nil
@@ -1226,6 +1285,33 @@ func CWTApproachSeparation(front, back string) float32 {
 	return cwtOnApproachLookUp[f][b]
 }
 
+// WeightClassApproachSeparation returns the required separation on final
+// approach between aircraft of the two given weight classes, as returned
+// by Aircraft.WeightClass, for when CWT isn't being used. If 0 is
+// returned, minimum radar separation should be used.
+func WeightClassApproachSeparation(front, back string) float32 {
+	const classes = "JHBLS"
+	if len(front) != 1 || !strings.Contains(classes, front) {
+		return 10
+	}
+	if len(back) != 1 || !strings.Contains(classes, back) {
+		return 10
+	}
+
+	f, b := strings.Index(classes, front), strings.Index(classes, back)
+
+	// 7110.65Z 5-5-4. There is no wake turbulence requirement for a
+	// super behind a heavy or for anything but a small behind a B757.
+	weightClassLookup := [5][5]float32{ // [front][back]
+		{0, 6, 7, 7, 8}, // Behind J
+		{0, 4, 5, 5, 6}, // Behind H
+		{0, 0, 0, 0, 5}, // Behind B
+		{0, 0, 0, 0, 4}, // Behind L
+		{0, 0, 0, 0, 0}, // Behind S
+	}
+	return weightClassLookup[f][b]
+}
+
 // CWTDirectlyBehindSeparation returns the required separation between
 // aircraft of the two given CWT categories. If 0 is returned, minimum
 // radar separation should be used.
@@ -44,12 +44,8 @@ type Nav struct {
 // seconds after the controller issues it in order to model the delay
 // before pilots start to follow assignments.
 type DeferredHeading struct {
-	// Time is just plain old wallclock time; it should be sim time, but a
-	// lot of replumbing would be required to have that available where
-	// needed. The downsides are minor: 1. On quit and resume, any pending
-	// assignments will generally be followed immediately, and 2. if the
-	// sim rate is increased, the delay will end up being longer than
-	// intended.
+	// Time is in sim time, so the delay is the same regardless of the sim
+	// rate and across a quit and resume.
 	Time    time.Time
 	Heading NavHeading
 }
@@ -200,7 +196,7 @@ func MakeDepartureNav(fp FlightPlan, perf AircraftPerformance, assignedAlt, clea
 }
 
 func MakeOverflightNav(of *Overflight, fp FlightPlan, perf AircraftPerformance,
-	nmPerLongitude float32, magneticVariation float32, lg *log.Logger) *Nav {
+	nmPerLongitude float32, magneticVariation float32, r *rand.Rand, lg *log.Logger) *Nav {
 	if nav := makeNav(fp, perf, of.Waypoints, nmPerLongitude, magneticVariation, lg); nav != nil {
 		spd := of.SpeedRestriction
 		nav.Speed.Restriction = util.Select(spd != 0, &spd, nil)
@@ -213,7 +209,7 @@ func MakeOverflightNav(of *Overflight, fp FlightPlan, perf AircraftPerformance,
 			nav.Speed.Assigned = &spd
 		}
 
-		nav.FlightState.Altitude = float32(rand.SampleSlice(of.InitialAltitudes))
+		nav.FlightState.Altitude = float32(rand.SampleSlice(r, of.InitialAltitudes))
 		nav.FlightState.IAS = of.InitialSpeed
 		// This won't be quite right but it's better than leaving GS to be
 		// 0 for the first nav update tick which leads to various Inf and
@@ -322,11 +318,10 @@ func (nav *Nav) AssignedHeading() (float32, bool) {
 // few seconds in the future. It should only be called for heading changes
 // due to controller instructions to the pilot and never in cases where the
 // autopilot is changing the heading assignment.
-func (nav *Nav) EnqueueHeading(h NavHeading) {
-	delay := 3 + 3*rand.Float32()
-	now := time.Now()
+func (nav *Nav) EnqueueHeading(h NavHeading, simTime time.Time, r *rand.Rand) {
+	delay := 3 + 3*r.Float32()
 	nav.DeferredHeading = &DeferredHeading{
-		Time:    now.Add(time.Duration(delay * float32(time.Second))),
+		Time:    simTime.Add(time.Duration(delay * float32(time.Second))),
 		Heading: h,
 	}
 }
@@ -816,7 +811,7 @@ func (nav *Nav) updatePositionAndGS(wind WindModel, lg *log.Logger) {
 	nav.FlightState.GS = math.Length2f(math.Add2f(flightVector, windVector)) * 3600
 }
 
-func (nav *Nav) DepartOnCourse(alt float32, exit string) {
+func (nav *Nav) DepartOnCourse(alt float32, exit string, simTime time.Time, r *rand.Rand) {
 	if _, ok := nav.AssignedHeading(); !ok {
 		// Don't do anything if they are not on a heading; let them fly the
 		// regular route and don't (potentially) skip waypoints and go
@@ -832,7 +827,7 @@ func (nav *Nav) DepartOnCourse(alt float32, exit string) {
 	}
 	nav.Altitude = NavAltitude{Assigned: &alt}
 	nav.Speed = NavSpeed{}
-	nav.EnqueueHeading(NavHeading{})
+	nav.EnqueueHeading(NavHeading{}, simTime, r)
 }
 
 func (nav *Nav) Check(lg *log.Logger) {
@@ -853,7 +848,21 @@ func (nav *Nav) Check(lg *log.Logger) {
 }
 
 // returns passed waypoint if any
-func (nav *Nav) Update(wind WindModel, lg *log.Logger) *Waypoint {
+func (nav *Nav) Update(wind WindModel, simTime time.Time, lg *log.Logger) *Waypoint {
+	// Is it time to start following a heading given by the controller a
+	// few seconds ago?
+	if dh := nav.DeferredHeading; dh != nil && !simTime.Before(dh.Time) {
+		lg.Debug("initiating deferred heading assignment", slog.Any("heading", dh.Heading))
+		nav.Heading = dh.Heading
+		nav.DeferredHeading = nil
+	}
+
+	return nav.update(wind, lg)
+}
+
+// update advances the aircraft by one second. It is also used to simulate
+// ghost aircraft, which never have a deferred heading.
+func (nav *Nav) update(wind WindModel, lg *log.Logger) *Waypoint {
 	deltaKts, slowingTo250 := nav.updateAirspeed(lg)
 	nav.updateAltitude(lg, deltaKts, slowingTo250)
 	nav.updateHeading(wind, lg)
@@ -872,14 +881,6 @@ func (nav *Nav) Update(wind WindModel, lg *log.Logger) *Waypoint {
 }
 
 func (nav *Nav) TargetHeading(wind WindModel, lg *log.Logger) (heading float32, turn TurnMethod, rate float32) {
-	// Is it time to start following a heading given by the controller a
-	// few seconds ago?
-	if dh := nav.DeferredHeading; dh != nil && time.Now().After(dh.Time) {
-		lg.Debug("initiating deferred heading assignment", slog.Any("heading", dh.Heading))
-		nav.Heading = dh.Heading
-		nav.DeferredHeading = nil
-	}
-
 	heading, turn, rate = nav.FlightState.Heading, TurnClosest, 3 // baseline
 
 	// nav.Heading.Assigned may still be nil pending a deferred turn
@@ -1701,7 +1702,7 @@ func (nav *Nav) shouldTurnForOutbound(p math.Point2LL, hdg float32, turn TurnMet
 	// Don't simulate the turn longer than it will take to do it.
 	n := int(1 + turnAngle/3)
 	for i := 0; i < n; i++ {
-		nav2.Update(wind, nil)
+		nav2.update(wind, nil)
 		curDist := math.SignedPointLineDistance(math.LL2NM(nav2.FlightState.Position,
 			nav2.FlightState.NmPerLongitude),
 			p0, p1)
@@ -1742,7 +1743,7 @@ func (nav *Nav) shouldTurnToIntercept(p0 math.Point2LL, hdg float32, turn TurnMe
 
 	n := int(1 + turnAngle/3)
 	for i := 0; i < n; i++ {
-		nav2.Update(wind, nil)
+		nav2.update(wind, nil)
 		curDist := math.SignedPointLineDistance(math.LL2NM(nav2.FlightState.Position, nav2.FlightState.NmPerLongitude), p0, p1)
 		if math.Sign(initialDist) != math.Sign(curDist) && math.Abs(curDist) < .25 && math.HeadingDifference(hdg, nav2.FlightState.Heading) < 3.5 {
 			lg.Debugf("turning now to intercept radial in %d seconds", i)
@@ -1784,7 +1785,7 @@ func TurnAngle(from, to float32, turn TurnMethod) float32 {
 	}
 }
 
-func (nav *Nav) GoAround() PilotResponse {
+func (nav *Nav) GoAround(r *rand.Rand) PilotResponse {
 	hdg := nav.FlightState.Heading
 	nav.Heading = NavHeading{Assigned: &hdg}
 	nav.DeferredHeading = nil
@@ -1798,22 +1799,22 @@ func (nav *Nav) GoAround() PilotResponse {
 	// Keep the destination airport at the end of the route.
 	nav.Waypoints = []Waypoint{nav.FlightState.ArrivalAirport}
 
-	s := rand.Sample("going around", "on the go")
+	s := rand.Sample(r, "going around", "on the go")
 	return PilotResponse{Message: s}
 }
 
-func (nav *Nav) AssignAltitude(alt float32, afterSpeed bool) PilotResponse {
+func (nav *Nav) AssignAltitude(alt float32, afterSpeed bool, r *rand.Rand) PilotResponse {
 	if alt > nav.Perf.Ceiling {
 		return PilotResponse{Message: "unable. That altitude is above our ceiling.", Unexpected: true}
 	}
 
 	var response string
 	if alt > nav.FlightState.Altitude {
-		response = rand.Sample("climb and maintain ", "up to ") + FormatAltitude(alt)
+		response = rand.Sample(r, "climb and maintain ", "up to ") + FormatAltitude(alt)
 	} else if alt == nav.FlightState.Altitude {
-		response = rand.Sample("maintain ", "we'll keep it at ") + FormatAltitude(alt)
+		response = rand.Sample(r, "maintain ", "we'll keep it at ") + FormatAltitude(alt)
 	} else {
-		response = rand.Sample("descend and maintain ", "down to ") + FormatAltitude(alt)
+		response = rand.Sample(r, "descend and maintain ", "down to ") + FormatAltitude(alt)
 	}
 
 	if afterSpeed && nav.Speed.Assigned != nil && *nav.Speed.Assigned != nav.FlightState.IAS {
@@ -1828,7 +1829,7 @@ func (nav *Nav) AssignAltitude(alt float32, afterSpeed bool) PilotResponse {
 	return PilotResponse{Message: response}
 }
 
-func (nav *Nav) AssignSpeed(speed float32, afterAltitude bool) PilotResponse {
+func (nav *Nav) AssignSpeed(speed float32, afterAltitude bool, r *rand.Rand) PilotResponse {
 	maxIAS := TASToIAS(nav.Perf.Speed.MaxTAS, nav.FlightState.Altitude)
 	maxIAS = 10 * float32(int((maxIAS+5)/10)) // round to 10s
 
@@ -1854,48 +1855,48 @@ func (nav *Nav) AssignSpeed(speed float32, afterAltitude bool) PilotResponse {
 	} else {
 		nav.Speed = NavSpeed{Assigned: &speed}
 		if speed < nav.FlightState.IAS {
-			msg := rand.Sample("reduce speed to %.0f knots", "speed %.0f", "pulling it back to %.0f", "%.0f for the speed", "slow to %.0f")
+			msg := rand.Sample(r, "reduce speed to %.0f knots", "speed %.0f", "pulling it back to %.0f", "%.0f for the speed", "slow to %.0f")
 			response = fmt.Sprintf(msg, speed)
 		} else if speed > nav.FlightState.IAS {
-			msg := rand.Sample("increase speed to %.0f knots", "speed %.0f", "%.0f for the speed", "maintain %.0f knots")
+			msg := rand.Sample(r, "increase speed to %.0f knots", "speed %.0f", "%.0f for the speed", "maintain %.0f knots")
 			response = fmt.Sprintf(msg, speed)
 		} else {
-			msg := rand.Sample("maintain %.0f knots", "keep it at %.0f", "well stay at %.0f")
+			msg := rand.Sample(r, "maintain %.0f knots", "keep it at %.0f", "well stay at %.0f")
 			response = fmt.Sprintf(msg, speed)
 		}
 	}
 	return PilotResponse{Message: response}
 }
 
-func (nav *Nav) MaintainSlowestPractical() PilotResponse {
+func (nav *Nav) MaintainSlowestPractical(r *rand.Rand) PilotResponse {
 	nav.Speed = NavSpeed{MaintainSlowestPractical: true}
-	r := rand.Sample("we'll maintain slowest practical speed", "slowing as much as we can")
-	return PilotResponse{Message: r}
+	msg := rand.Sample(r, "we'll maintain slowest practical speed", "slowing as much as we can")
+	return PilotResponse{Message: msg}
 }
 
-func (nav *Nav) MaintainMaximumForward() PilotResponse {
+func (nav *Nav) MaintainMaximumForward(r *rand.Rand) PilotResponse {
 	nav.Speed = NavSpeed{MaintainMaximumForward: true}
-	r := rand.Sample("we'll keep it at maximum forward speed", "maintaining maximum forward speed")
-	return PilotResponse{Message: r}
+	msg := rand.Sample(r, "we'll keep it at maximum forward speed", "maintaining maximum forward speed")
+	return PilotResponse{Message: msg}
 }
 
-func (nav *Nav) SaySpeed() PilotResponse {
+func (nav *Nav) SaySpeed(r *rand.Rand) PilotResponse {
 	currentSpeed := nav.FlightState.IAS
 	var output string
 
 	if nav.Speed.Assigned != nil {
 		assignedSpeed := *nav.Speed.Assigned
 		if assignedSpeed < currentSpeed {
-			output = rand.Sample(fmt.Sprintf("at %.0f slowing to %.0f", currentSpeed, assignedSpeed),
+			output = rand.Sample(r, fmt.Sprintf("at %.0f slowing to %.0f", currentSpeed, assignedSpeed),
 				fmt.Sprintf("at %.0f and slowing", currentSpeed))
 
 		} else if assignedSpeed > currentSpeed {
 			output = fmt.Sprintf("at %0.f speeding up to %.0f", currentSpeed, assignedSpeed)
 		} else {
-			output = rand.Sample(fmt.Sprintf("maintaining %.0f knots", currentSpeed), fmt.Sprintf("at %.0f knots", currentSpeed))
+			output = rand.Sample(r, fmt.Sprintf("maintaining %.0f knots", currentSpeed), fmt.Sprintf("at %.0f knots", currentSpeed))
 		}
 	} else {
-		output = rand.Sample(fmt.Sprintf("maintaining %.0f knots", currentSpeed), fmt.Sprintf("at %.0f knots", currentSpeed))
+		output = rand.Sample(r, fmt.Sprintf("maintaining %.0f knots", currentSpeed), fmt.Sprintf("at %.0f knots", currentSpeed))
 	}
 	return PilotResponse{Message: output}
 }
@@ -1918,34 +1919,34 @@ func (nav *Nav) SayHeading() PilotResponse {
 	return PilotResponse{Message: output}
 }
 
-func (nav *Nav) SayAltitude() PilotResponse {
+func (nav *Nav) SayAltitude(r *rand.Rand) PilotResponse {
 	currentAltitude := nav.FlightState.Altitude
 	var output string
 
 	if nav.Altitude.Assigned != nil {
 		assignedAltitude := *nav.Altitude.Assigned
 		if assignedAltitude < currentAltitude {
-			output = rand.Sample(fmt.Sprintf("at %s descending to %s", FormatAltitude(currentAltitude), FormatAltitude(assignedAltitude)),
+			output = rand.Sample(r, fmt.Sprintf("at %s descending to %s", FormatAltitude(currentAltitude), FormatAltitude(assignedAltitude)),
 				fmt.Sprintf("at %s and descending", FormatAltitude(currentAltitude)))
 
 		} else if assignedAltitude > currentAltitude {
 			output = fmt.Sprintf("at %s climbing to %s", FormatAltitude(currentAltitude), FormatAltitude(assignedAltitude))
 		} else {
-			output = rand.Sample(fmt.Sprintf("maintaining %s", FormatAltitude(currentAltitude)), fmt.Sprintf("at %s", FormatAltitude(currentAltitude)))
+			output = rand.Sample(r, fmt.Sprintf("maintaining %s", FormatAltitude(currentAltitude)), fmt.Sprintf("at %s", FormatAltitude(currentAltitude)))
 		}
 	} else {
-		output = rand.Sample(fmt.Sprintf("maintaining %s", FormatAltitude(currentAltitude)), fmt.Sprintf("at %s", FormatAltitude(currentAltitude)))
+		output = rand.Sample(r, fmt.Sprintf("maintaining %s", FormatAltitude(currentAltitude)), fmt.Sprintf("at %s", FormatAltitude(currentAltitude)))
 	}
 
 	return PilotResponse{Message: output}
 }
 
-func (nav *Nav) ExpediteDescent() PilotResponse {
+func (nav *Nav) ExpediteDescent(r *rand.Rand) PilotResponse {
 	alt, _ := nav.TargetAltitude(nil)
 	if alt >= nav.FlightState.Altitude {
 		if nav.Altitude.AfterSpeed != nil {
 			nav.Altitude.ExpediteAfterSpeed = true
-			resp := rand.Sample("expediting down to", "expedite to")
+			resp := rand.Sample(r, "expediting down to", "expedite to")
 			return PilotResponse{Message: resp + " " + FormatAltitude(*nav.Altitude.AfterSpeed) + " once we're at " +
 				fmt.Sprintf("%d", int(*nav.Altitude.AfterSpeedSpeed))}
 		} else {
@@ -1953,20 +1954,20 @@ func (nav *Nav) ExpediteDescent() PilotResponse {
 		}
 	}
 	if nav.Altitude.Expedite {
-		return PilotResponse{Message: rand.Sample("we're already expediting", "that's our best rate")}
+		return PilotResponse{Message: rand.Sample(r, "we're already expediting", "that's our best rate")}
 	}
 
 	nav.Altitude.Expedite = true
-	resp := rand.Sample("expediting down to", "expedite to")
+	resp := rand.Sample(r, "expediting down to", "expedite to")
 	return PilotResponse{Message: resp + " " + FormatAltitude(alt)}
 }
 
-func (nav *Nav) ExpediteClimb() PilotResponse {
+func (nav *Nav) ExpediteClimb(r *rand.Rand) PilotResponse {
 	alt, _ := nav.TargetAltitude(nil)
 	if alt <= nav.FlightState.Altitude {
 		if nav.Altitude.AfterSpeed != nil {
 			nav.Altitude.ExpediteAfterSpeed = true
-			resp := rand.Sample("expediting up to", "expedite to")
+			resp := rand.Sample(r, "expediting up to", "expedite to")
 			return PilotResponse{Message: resp + " " + FormatAltitude(*nav.Altitude.AfterSpeed) + " once we're at " +
 				fmt.Sprintf("%d", int(*nav.Altitude.AfterSpeedSpeed))}
 		} else {
@@ -1974,21 +1975,21 @@ func (nav *Nav) ExpediteClimb() PilotResponse {
 		}
 	}
 	if nav.Altitude.Expedite {
-		r := rand.Sample("we're already expediting", "that's our best rate")
-		return PilotResponse{Message: r}
+		msg := rand.Sample(r, "we're already expediting", "that's our best rate")
+		return PilotResponse{Message: msg}
 	}
 
 	nav.Altitude.Expedite = true
-	resp := rand.Sample("expediting up to", "expedite to")
+	resp := rand.Sample(r, "expediting up to", "expedite to")
 	return PilotResponse{Message: resp + " " + FormatAltitude(alt)}
 }
 
-func (nav *Nav) AssignHeading(hdg float32, turn TurnMethod) PilotResponse {
+func (nav *Nav) AssignHeading(hdg float32, turn TurnMethod, simTime time.Time, r *rand.Rand) PilotResponse {
 	if hdg <= 0 || hdg > 360 {
 		return PilotResponse{Message: fmt.Sprintf("unable. %.0f isn't a valid heading", hdg), Unexpected: true}
 	}
 
-	nav.assignHeading(hdg, turn)
+	nav.assignHeading(hdg, turn, simTime, r)
 
 	switch turn {
 	case TurnClosest:
@@ -2004,7 +2005,7 @@ func (nav *Nav) AssignHeading(hdg float32, turn TurnMethod) PilotResponse {
 	}
 }
 
-func (nav *Nav) assignHeading(hdg float32, turn TurnMethod) {
+func (nav *Nav) assignHeading(hdg float32, turn TurnMethod, simTime time.Time, r *rand.Rand) {
 	if _, ok := nav.AssignedHeading(); !ok {
 		// Only cancel approach clearance if the aircraft wasn't on a
 		// heading and now we're giving them one.
@@ -2027,11 +2028,11 @@ func (nav *Nav) assignHeading(hdg float32, turn TurnMethod) {
 
 	// Don't carry this from a waypoint we may have previously passed.
 	nav.Approach.NoPT = false
-	nav.EnqueueHeading(NavHeading{Assigned: &hdg, Turn: &turn})
+	nav.EnqueueHeading(NavHeading{Assigned: &hdg, Turn: &turn}, simTime, r)
 }
 
-func (nav *Nav) FlyPresentHeading() PilotResponse {
-	nav.assignHeading(nav.FlightState.Heading, TurnClosest)
+func (nav *Nav) FlyPresentHeading(simTime time.Time, r *rand.Rand) PilotResponse {
+	nav.assignHeading(nav.FlightState.Heading, TurnClosest, simTime, r)
 	return PilotResponse{Message: "fly present heading"}
 }
 
@@ -2126,9 +2127,38 @@ func (nav *Nav) directFix(fix string) bool {
 	return false
 }
 
-func (nav *Nav) DirectFix(fix string) PilotResponse {
+// Reposition moves the aircraft to the given fix at the given altitude,
+// as is done by instructors setting up training situations. If the fix is
+// in the aircraft's route, it continues along the route from there;
+// otherwise it flies the given heading. A zero heading leaves the
+// aircraft's heading unchanged.
+func (nav *Nav) Reposition(fix string, p math.Point2LL, alt, hdg float32) {
+	nav.FlightState.Position = p
+	nav.FlightState.Altitude = alt
+	nav.Altitude = NavAltitude{Assigned: &alt}
+	nav.DeferredHeading = nil
+	nav.Approach.InterceptState = NotIntercepting
+	if hdg != 0 {
+		nav.FlightState.Heading = hdg
+	}
+
+	if nav.directFix(fix) {
+		nav.Heading = NavHeading{}
+		if hdg == 0 && len(nav.Waypoints) > 1 {
+			// Point toward the next fix so that there's no turn back
+			// toward the one we're at.
+			nav.FlightState.Heading = math.Heading2LL(p, nav.Waypoints[1].Location,
+				nav.FlightState.NmPerLongitude, nav.FlightState.MagneticVariation)
+		}
+	} else {
+		h := nav.FlightState.Heading
+		nav.Heading = NavHeading{Assigned: &h}
+	}
+}
+
+func (nav *Nav) DirectFix(fix string, simTime time.Time, r *rand.Rand) PilotResponse {
 	if nav.directFix(fix) {
-		nav.EnqueueHeading(NavHeading{})
+		nav.EnqueueHeading(NavHeading{}, simTime, r)
 		nav.Approach.NoPT = false
 		nav.Approach.InterceptState = NotIntercepting
 
@@ -2210,7 +2240,7 @@ func (nav *Nav) getApproach(airport *Airport, id string, lg *log.Logger) (*Appro
 	return nil, ErrUnknownApproach
 }
 
-func (nav *Nav) ExpectApproach(airport *Airport, id string, runwayWaypoints map[string]WaypointArray,
+func (nav *Nav) ExpectApproach(airport *Airport, id string, runwayWaypoints map[string]WaypointArray, r *rand.Rand,
 	lg *log.Logger) PilotResponse {
 	ap, err := nav.getApproach(airport, id, lg)
 	if err != nil {
@@ -2280,11 +2310,11 @@ func (nav *Nav) ExpectApproach(airport *Airport, id string, runwayWaypoints map[
 		}
 	}
 
-	opener := rand.Sample("we'll expect the", "expecting the", "we'll plan for the")
+	opener := rand.Sample(r, "we'll expect the", "expecting the", "we'll plan for the")
 	return PilotResponse{Message: opener + " " + ap.FullName + " approach"}
 }
 
-func (nav *Nav) InterceptLocalizer(airport string) PilotResponse {
+func (nav *Nav) InterceptLocalizer(airport string, r *rand.Rand) PilotResponse {
 	if nav.Approach.AssignedId == "" {
 		return PilotResponse{Message: "you never told us to expect an approach", Unexpected: true}
 	}
@@ -2301,12 +2331,12 @@ func (nav *Nav) InterceptLocalizer(airport string) PilotResponse {
 	if err != nil {
 		return resp
 	} else {
-		r := rand.Sample("intercepting the "+ap.FullName+" approach", "intercepting "+ap.FullName)
-		return PilotResponse{Message: r}
+		msg := rand.Sample(r, "intercepting the "+ap.FullName+" approach", "intercepting "+ap.FullName)
+		return PilotResponse{Message: msg}
 	}
 }
 
-func (nav *Nav) AtFixCleared(fix, id string) PilotResponse {
+func (nav *Nav) AtFixCleared(fix, id string, r *rand.Rand) PilotResponse {
 	if nav.Approach.AssignedId == "" {
 		return PilotResponse{Message: "you never told us to expect an approach", Unexpected: true}
 	}
@@ -2331,7 +2361,7 @@ func (nav *Nav) AtFixCleared(fix, id string) PilotResponse {
 		}
 	}
 
-	return PilotResponse{Message: rand.Sample("at "+fix+", cleared "+ap.FullName,
+	return PilotResponse{Message: rand.Sample(r, "at "+fix+", cleared "+ap.FullName,
 		"cleared "+ap.FullName+" at "+fix)}
 }
 
@@ -2521,25 +2551,25 @@ func (nav *Nav) CancelApproachClearance() PilotResponse {
 	return PilotResponse{Message: "cancel approach clearance."}
 }
 
-func (nav *Nav) ClimbViaSID() PilotResponse {
+func (nav *Nav) ClimbViaSID(simTime time.Time, r *rand.Rand) PilotResponse {
 	if len(nav.Waypoints) == 0 || !nav.Waypoints[0].OnSID {
 		return PilotResponse{Message: "unable. We're not flying a departure procedure", Unexpected: true}
 	}
 
 	nav.Altitude = NavAltitude{}
 	nav.Speed = NavSpeed{}
-	nav.EnqueueHeading(NavHeading{})
+	nav.EnqueueHeading(NavHeading{}, simTime, r)
 	return PilotResponse{Message: "climb via the SID"}
 }
 
-func (nav *Nav) DescendViaSTAR() PilotResponse {
+func (nav *Nav) DescendViaSTAR(simTime time.Time, r *rand.Rand) PilotResponse {
 	if len(nav.Waypoints) == 0 || !nav.Waypoints[0].OnSTAR {
 		return PilotResponse{Message: "unable. We're not on a STAR", Unexpected: true}
 	}
 
 	nav.Altitude = NavAltitude{}
 	nav.Speed = NavSpeed{}
-	nav.EnqueueHeading(NavHeading{})
+	nav.EnqueueHeading(NavHeading{}, simTime, r)
 	return PilotResponse{Message: "descend via the STAR"}
 }
 
@@ -8,7 +8,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
-	"maps"
 	"slices"
 	"strconv"
 	"strings"
@@ -835,18 +834,6 @@ func MakeSTAR() *STAR {
 	}
 }
 
-const routePrintFormat = "%-13s: %s\n"
-
-func (s STAR) Print(name string) {
-	for _, tr := range slices.Sorted(maps.Keys(s.Transitions)) {
-		fmt.Printf(routePrintFormat, name+"."+tr, s.Transitions[tr].Encode())
-	}
-
-	for _, rwy := range slices.Sorted(maps.Keys(s.RunwayWaypoints)) {
-		fmt.Printf(routePrintFormat, name+".RWY"+rwy, s.RunwayWaypoints[rwy].Encode())
-	}
-}
-
 ///////////////////////////////////////////////////////////////////////////
 // HILPT
 
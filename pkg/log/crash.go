@@ -0,0 +1,135 @@
+// pkg/log/crash.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package log
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Number of recent log lines included in crash reports.
+const crashReportLogLines = 200
+
+// recentLines is an io.Writer that keeps the most recent lines written to
+// it so that they can be included in crash reports.
+type recentLines struct {
+	mu      sync.Mutex
+	lines   []string
+	next    int
+	partial string
+}
+
+func newRecentLines(n int) *recentLines {
+	return &recentLines{lines: make([]string, 0, n)}
+}
+
+func (r *recentLines) Write(b []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := r.partial + string(b)
+	for {
+		line, rest, ok := strings.Cut(s, "\n")
+		if !ok {
+			break
+		}
+		if len(r.lines) < cap(r.lines) {
+			r.lines = append(r.lines, line)
+		} else {
+			r.lines[r.next] = line
+			r.next = (r.next + 1) % len(r.lines)
+		}
+		s = rest
+	}
+	r.partial = s
+
+	return len(b), nil
+}
+
+// Lines returns the saved lines, oldest first.
+func (r *recentLines) Lines() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append(append([]string(nil), r.lines[r.next:]...), r.lines[:r.next]...)
+}
+
+// crashContext holds state shared between a Logger and the loggers
+// derived from it via With().
+type crashContext struct {
+	mu     sync.Mutex
+	recent *recentLines
+	info   string
+}
+
+// SetCrashReportContext records additional information (e.g., the
+// current configuration and sim) to include in the crash bundle that is
+// saved locally if vice crashes. It is scrubbed of credentials before it
+// is written. Callers should update it periodically from the goroutine
+// that owns the state it describes: a crash may happen in any goroutine,
+// so the state isn't inspected at crash time.
+func (l *Logger) SetCrashReportContext(info string) {
+	if l == nil || l.crash == nil {
+		return
+	}
+	l.crash.mu.Lock()
+	defer l.crash.mu.Unlock()
+	l.crash.info = info
+}
+
+// crashBundle returns the additional information that is saved along with
+// the crash report: the caller-provided context and recent log messages.
+func (l *Logger) crashBundle() string {
+	if l.crash == nil {
+		return ""
+	}
+
+	l.crash.mu.Lock()
+	info := l.crash.info
+	l.crash.mu.Unlock()
+
+	var b strings.Builder
+	if info != "" {
+		b.WriteString("\nContext:\n")
+		b.WriteString(info)
+		b.WriteString("\n")
+	}
+
+	if l.crash.recent != nil {
+		b.WriteString("\nRecent log messages:\n")
+		for _, line := range l.crash.recent.Lines() {
+			b.WriteString(line)
+			b.WriteString("\n")
+		}
+	}
+
+	return ScrubCredentials(b.String())
+}
+
+var (
+	// JSON members whose name suggests they hold a password (the server
+	// password or a sim's) or a controller token.
+	reCredentialJSON = regexp.MustCompile(`(?i)("[a-z_]*(?:password|passwd|token)[a-z_]*"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+	// Command-line arguments of the same, e.g. -password for -broadcast
+	// and -drain.
+	reCredentialFlag = regexp.MustCompile(`(?i)(-{1,2}[a-z_]*(?:password|passwd|token)[a-z_]*(?:=|\s+))\S+`)
+)
+
+// ScrubCredentials replaces anything in the given text that looks like a
+// password or controller token with a placeholder.
+func ScrubCredentials(s string) string {
+	s = reCredentialJSON.ReplaceAllString(s, `$1"[REDACTED]"`)
+	s = reCredentialFlag.ReplaceAllString(s, `${1}[REDACTED]`)
+	return s
+}
+
+// crashReportFilename returns the name of the file to save a crash report
+// in. The time is formatted without colons, which aren't allowed in
+// filenames on Windows.
+func crashReportFilename() string {
+	return "crash-" + time.Now().Format("2006-01-02-150405") + ".txt"
+}
@@ -0,0 +1,71 @@
+// pkg/log/crash_test.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package log
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRecentLines(t *testing.T) {
+	r := newRecentLines(3)
+	if len(r.Lines()) != 0 {
+		t.Errorf("expected no lines initially")
+	}
+
+	fmt.Fprint(r, "one\ntwo\n")
+	if l := r.Lines(); !slices.Equal(l, []string{"one", "two"}) {
+		t.Errorf("got %v", l)
+	}
+
+	// Partial lines are held until they are complete.
+	fmt.Fprint(r, "thr")
+	fmt.Fprint(r, "ee\nfour\nfive\n")
+	if l := r.Lines(); !slices.Equal(l, []string{"three", "four", "five"}) {
+		t.Errorf("got %v", l)
+	}
+}
+
+func TestScrubCredentials(t *testing.T) {
+	for _, test := range []struct{ in, out string }{
+		{`{"Password": "hunter2", "Name": "JFK"}`, `{"Password": "[REDACTED]", "Name": "JFK"}`},
+		{`{"RemoteSimPassword":"a\"b", "x": 1}`, `{"RemoteSimPassword":"[REDACTED]", "x": 1}`},
+		{`{"token": "abc"}`, `{"token": "[REDACTED]"}`},
+		{`{"ControllerToken": "1234"}`, `{"ControllerToken": "[REDACTED]"}`},
+		{`vice -broadcast hi -password secret1 -server x`, `vice -broadcast hi -password [REDACTED] -server x`},
+		{`vice --password=secret1`, `vice --password=[REDACTED]`},
+		{`vice -drain 30m -password secret1`, `vice -drain 30m -password [REDACTED]`},
+		{`{"Callsign": "AAL123"}`, `{"Callsign": "AAL123"}`},
+	} {
+		if got := ScrubCredentials(test.in); got != test.out {
+			t.Errorf("ScrubCredentials(%q) = %q, expected %q", test.in, got, test.out)
+		}
+	}
+
+	if s := ScrubCredentials("password"); !strings.Contains(s, "password") {
+		t.Errorf("plain words should be left alone")
+	}
+}
+
+func TestCrashReportFilename(t *testing.T) {
+	// Colons aren't allowed in Windows filenames.
+	if fn := crashReportFilename(); strings.ContainsAny(fn, `:<>"/\|?*`) {
+		t.Errorf("%q has characters that aren't allowed in filenames", fn)
+	}
+}
+
+func TestCrashBundleContext(t *testing.T) {
+	l := &Logger{crash: &crashContext{}}
+	l.SetCrashReportContext(`{"Password": "hunter2", "TRACON": "PHL"}`)
+	b := l.crashBundle()
+	if !strings.Contains(b, `"TRACON": "PHL"`) {
+		t.Errorf("context missing from crash bundle: %q", b)
+	}
+	if strings.Contains(b, "hunter2") {
+		t.Errorf("crash bundle context wasn't scrubbed: %q", b)
+	}
+}
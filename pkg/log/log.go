@@ -29,6 +29,8 @@ type Logger struct {
 	LogFile string
 	LogDir  string
 	Start   time.Time
+
+	crash *crashContext
 }
 
 func New(server bool, level string, dir string) *Logger {
@@ -79,12 +81,14 @@ func New(server bool, level string, dir string) *Logger {
 		fmt.Fprintf(os.Stderr, "%s: invalid log level", level)
 	}
 
-	h := slog.NewJSONHandler(w, &slog.HandlerOptions{Level: lvl})
+	recent := newRecentLines(crashReportLogLines)
+	h := slog.NewJSONHandler(io.MultiWriter(w, recent), &slog.HandlerOptions{Level: lvl})
 	l := &Logger{
 		Logger:  slog.New(h),
 		LogFile: w.Filename,
 		LogDir:  dir,
 		Start:   time.Now(),
+		crash:   &crashContext{recent: recent},
 	}
 
 	// Start out the logs with some basic information about the system
@@ -193,7 +197,9 @@ func (l *Logger) With(args ...any) *Logger {
 	return &Logger{
 		Logger:  l.Logger.With(args...),
 		LogFile: l.LogFile,
+		LogDir:  l.LogDir,
 		Start:   l.Start,
+		crash:   l.crash,
 	}
 }
 
@@ -221,12 +227,17 @@ func (l *Logger) CatchAndReportCrash() any {
 		// Print it to stdout
 		fmt.Println(report)
 
-		// Try to save it to disk locally
-		fn := filepath.Join(l.LogDir, "crash-"+time.Now().Format(time.RFC3339)+".txt")
-		_ = os.WriteFile(fn, []byte(report), 0o600)
-
-		// And pass it along to the crash report server.
+		// Pass it along to the crash report server first, so that it
+		// gets there even if something goes wrong below.
 		l.postCrashReport(report)
+
+		// Try to save it to disk locally, along with additional context
+		// that makes it self-contained for attaching to a bug report.
+		bundle := report + ScrubCredentials("\nArgs: "+strings.Join(os.Args, " ")+"\n") + l.crashBundle()
+		fn := filepath.Join(l.LogDir, crashReportFilename())
+		if os.WriteFile(fn, []byte(bundle), 0o600) == nil {
+			fmt.Printf("Crash report saved to %s\n", fn)
+		}
 	}
 
 	return err
@@ -274,7 +285,7 @@ func (l *Logger) launchCrashServer() {
 
 		l.Info("Received crash report", slog.String("crash", string(body)))
 
-		fn := filepath.Join(l.LogDir, "crash-"+time.Now().Format(time.RFC3339)+".txt")
+		fn := filepath.Join(l.LogDir, crashReportFilename())
 		_ = os.WriteFile(fn, []byte(body), 0o600)
 	})
 
@@ -79,6 +79,10 @@ var (
 	// https://en.wikipedia.org/wiki/ISO_6709#String_expression_(Annex_H)
 	// e.g. +403527.580-0734452.955
 	reISO6709H = regexp.MustCompile(`^([-+][0-9][0-9])([0-9][0-9])([0-9][0-9])\.([0-9][0-9][0-9])([-+][0-9][0-9][0-9])([0-9][0-9])([0-9][0-9])\.([0-9][0-9][0-9])`)
+	// Compact degrees/minutes and optional seconds with hemisphere
+	// letters, as entered by controllers, e.g. 4012N07345W or
+	// 401230N0734500W.
+	reCompactLatLong = regexp.MustCompile(`^([0-9][0-9])([0-9][0-9])([0-9][0-9])?([NS])([0-9][0-9][0-9])([0-9][0-9])([0-9][0-9])?([EW])$`)
 )
 
 // Parse waypoints of the form "N40.37.58.400, W073.46.17.000".  Previously
@@ -247,6 +251,32 @@ func ParseLatLong(llstr []byte) (Point2LL, error) {
 			return Point2LL{}, err
 		}
 		return p, nil
+	} else if strs := reCompactLatLong.FindStringSubmatch(string(llstr)); len(strs) == 9 {
+		parse := func(deg, min, sec, hemi string) (float32, error) {
+			d, _ := strconv.Atoi(deg) // the regexp ensures these are all digits
+			m, _ := strconv.Atoi(min)
+			s, _ := strconv.Atoi(sec) // 0 if omitted
+			if m >= 60 || s >= 60 {
+				return 0, fmt.Errorf("%s: invalid minutes or seconds", llstr)
+			}
+			v := float32(d) + float32(m)/60 + float32(s)/3600
+			if hemi == "S" || hemi == "W" {
+				v = -v
+			}
+			return v, nil
+		}
+
+		var err error
+		if p[1], err = parse(strs[1], strs[2], strs[3], strs[4]); err != nil {
+			return Point2LL{}, err
+		}
+		if p[0], err = parse(strs[5], strs[6], strs[7], strs[8]); err != nil {
+			return Point2LL{}, err
+		}
+		if p[1] > 90 || p[0] > 180 || p[0] < -180 || p[1] < -90 {
+			return Point2LL{}, fmt.Errorf("%s: latlong out of range", llstr)
+		}
+		return p, nil
 	} else {
 		return Point2LL{}, fmt.Errorf("%s: invalid latlong string", llstr)
 	}
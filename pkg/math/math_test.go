@@ -85,7 +85,10 @@ func TestParseLatLong(t *testing.T) {
 		LL{str: "N40.37.58.400, W073.46.17.000", pos: Point2LL{-73.771385, 40.6328888}}, // JFK VOR
 		LL{str: "N40.37.58.4,W073.46.17.000", pos: Point2LL{-73.771385, 40.6328888}},    // JFK VOR
 		LL{str: "40.6328888, -73.771385", pos: Point2LL{-73.771385, 40.6328888}},        // JFK VOR
-		LL{str: "+403758.400-0734617.000", pos: Point2LL{-73.7713928, 40.632885}}}       // JFK VOR
+		LL{str: "+403758.400-0734617.000", pos: Point2LL{-73.7713928, 40.632885}},       // JFK VOR
+		LL{str: "4012N07345W", pos: Point2LL{-73.75, 40.2}},
+		LL{str: "401230N0734530W", pos: Point2LL{-73.7583313, 40.2083359}},
+		LL{str: "3352S15112E", pos: Point2LL{151.199997, -33.8666649}}}
 
 	for _, ll := range latlongs {
 		p, err := ParseLatLong([]byte(ll.str))
@@ -105,6 +108,10 @@ func TestParseLatLong(t *testing.T) {
 		"40.37.58.400, W073.46.17.000",
 		"N40.37.58.400, -73.22",
 		"N40.37.58.400, W073.46.17",
+		"4012N07345",
+		"4060N07345W",
+		"9512N07345W",
+		"4012N0734W",
 	} {
 		if _, err := ParseLatLong([]byte(invalid)); err == nil {
 			t.Errorf("%s: no error was returned for invalid latlong string!", invalid)
@@ -39,6 +39,11 @@ var (
 
 		focus WMKeyboardFocus
 
+		// Modifications to the display hierarchy requested by Panes
+		// while they are being drawn; these are applied once all of the
+		// Panes have been visited.
+		displayChanges []func(root *DisplayNode)
+
 		lastAircraftResponse string
 	}
 )
@@ -298,10 +303,40 @@ func (d *DisplayNode) SplitY(y float32, newChild *DisplayNode) *DisplayNode {
 	if d.SplitLine.Axis != SplitAxisNone {
 		panic(fmt.Sprintf("DisplayNode splitting a non-leaf node: %v", d))
 	}
-	return &DisplayNode{SplitLine: SplitLine{Axis: SplitAxisX, Pos: y},
+	return &DisplayNode{SplitLine: SplitLine{Axis: SplitAxisY, Pos: y},
 		Children: [2]*DisplayNode{d, newChild}}
 }
 
+// SplitPane splits the leaf node that holds the given pane along the
+// specified axis, with the existing pane as the first child and newPane
+// as the second. The split happens in place, so that references to the
+// node (including the root) remain valid. It returns false if pane
+// wasn't found in the hierarchy.
+func (d *DisplayNode) SplitPane(pane Pane, axis SplitType, pos float32, newPane Pane) bool {
+	node := d.NodeForPane(pane)
+	if node == nil {
+		return false
+	}
+	old := *node
+	*node = DisplayNode{
+		SplitLine: SplitLine{Axis: axis, Pos: pos},
+		Children:  [2]*DisplayNode{&old, &DisplayNode{Pane: newPane}},
+	}
+	return true
+}
+
+// RemovePane removes the given pane from the display hierarchy; its
+// sibling takes over the area of their parent node. It returns false if
+// the pane wasn't found or if it is the only pane in the hierarchy.
+func (d *DisplayNode) RemovePane(pane Pane) bool {
+	parent, idx := d.ParentNodeForPane(pane)
+	if parent == nil {
+		return false
+	}
+	*parent = *parent.Children[1-idx]
+	return true
+}
+
 func splitX(e math.Extent2D, x float32, lineWidth int) (math.Extent2D, math.Extent2D, math.Extent2D) {
 	e0 := e
 	es := e
@@ -414,7 +449,7 @@ func wmPaneIsPresent(pane Pane, root *DisplayNode) bool {
 // and providing mouse and keyboard events only to the Pane that should
 // respectively be receiving them.
 func DrawPanes(root *DisplayNode, p platform.Platform, r renderer.Renderer, controlClient *sim.ControlClient,
-	menuBarHeight float32, audioEnabled *bool, lg *log.Logger) renderer.RendererStats {
+	menuBarHeight float32, audioEnabled *bool, reducedQuality bool, lg *log.Logger) renderer.RendererStats {
 	if controlClient == nil {
 		commandBuffer := renderer.GetCommandBuffer()
 		defer renderer.ReturnCommandBuffer(commandBuffer)
@@ -432,6 +467,7 @@ func DrawPanes(root *DisplayNode, p platform.Platform, r renderer.Renderer, cont
 			return d
 		}
 	}
+	fullRoot := root
 	root = filter(root)
 
 	getKeyboardPanes := func() []Pane {
@@ -541,8 +577,10 @@ func DrawPanes(root *DisplayNode, p platform.Platform, r renderer.Renderer, cont
 				Lg:               lg,
 				MenuBarHeight:    menuBarHeight,
 				AudioEnabled:     audioEnabled,
+				ReducedQuality:   reducedQuality,
 				KeyboardFocus:    &wm.focus,
 				ControlClient:    controlClient,
+				DisplayRoot:      fullRoot,
 			}
 
 			// Similarly make the mouse events available only to the
@@ -573,6 +611,12 @@ func DrawPanes(root *DisplayNode, p platform.Platform, r renderer.Renderer, cont
 			commandBuffer.ResetState()
 		})
 
+	// Now that the traversal is done, it's safe to modify the hierarchy.
+	for _, change := range wm.displayChanges {
+		change(fullRoot)
+	}
+	wm.displayChanges = nil
+
 	// Clear mouseConsumerOverride if the user has stopped dragging;
 	// only do this after visiting the Panes so that the override Pane
 	// still sees the mouse button release event.
@@ -612,6 +656,68 @@ func NewDisplayPanes(stars, messages, fsp Pane) *DisplayNode {
 	}
 }
 
+// CopyDisplayNode returns a deep copy of the given display hierarchy,
+// including the Panes in it, made by round-tripping it through JSON.
+func CopyDisplayNode(d *DisplayNode) (*DisplayNode, error) {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	var c DisplayNode
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// ApplyLayout returns a new display hierarchy with the structure and split
+// positions of layout. Panes from current are reused where one of the
+// same type is available so that their state (e.g., STARS preferences)
+// is preserved. Any panes that had to be newly created are returned so
+// that the caller can activate them. Panes in current that aren't used in
+// the new hierarchy are deactivated.
+func ApplyLayout(layout, current *DisplayNode) (*DisplayNode, []Pane, error) {
+	root, err := CopyDisplayNode(layout)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	available := make(map[string][]Pane)
+	current.VisitPanes(func(p Pane) {
+		if _, ok := p.(*SplitLine); !ok {
+			t := fmt.Sprintf("%T", p)
+			available[t] = append(available[t], p)
+		}
+	})
+
+	var newPanes []Pane
+	var visit func(d *DisplayNode)
+	visit = func(d *DisplayNode) {
+		if d.SplitLine.Axis != SplitAxisNone {
+			visit(d.Children[0])
+			visit(d.Children[1])
+			return
+		}
+
+		t := fmt.Sprintf("%T", d.Pane)
+		if ps := available[t]; len(ps) > 0 {
+			d.Pane = ps[0]
+			available[t] = ps[1:]
+		} else {
+			newPanes = append(newPanes, d.Pane)
+		}
+	}
+	visit(root)
+
+	for _, t := range util.SortedMapKeys(available) {
+		for _, p := range available[t] {
+			p.Deactivate()
+		}
+	}
+
+	return root, newPanes, nil
+}
+
 func Activate(root *DisplayNode, r renderer.Renderer, p platform.Platform, eventStream *sim.EventStream, lg *log.Logger) {
 	// Upgrade old ones without a MessagesPane
 	haveMessages := false
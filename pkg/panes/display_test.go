@@ -0,0 +1,121 @@
+// pkg/panes/display_test.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package panes
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"testing"
+
+	"github.com/mmp/vice/pkg/sim"
+)
+
+func TestDisplayNodeJSONRoundTrip(t *testing.T) {
+	root := NewDisplayPanes(NewEmptyPane(), NewMessagesPane(), NewFlightStripPane())
+	// Adjust the splits as if the user had dragged them.
+	root.SplitLine.Pos = 0.65
+	root.Children[0].SplitLine.Pos = 0.2
+	// And split the flight strip area vertically.
+	root.Children[1] = root.Children[1].SplitY(0.4, &DisplayNode{Pane: NewEmptyPane()})
+
+	b, err := json.Marshal(root)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var loaded DisplayNode
+	if err := json.Unmarshal(b, &loaded); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	var check func(a, b *DisplayNode, path string)
+	check = func(a, b *DisplayNode, path string) {
+		if a.SplitLine != b.SplitLine {
+			t.Errorf("%s: split line %+v after round trip, expected %+v", path, b.SplitLine, a.SplitLine)
+		}
+		if (a.Pane == nil) != (b.Pane == nil) {
+			t.Fatalf("%s: pane mismatch after round trip", path)
+		}
+		if a.Pane != nil {
+			ta, _ := json.Marshal(&DisplayNode{Pane: a.Pane})
+			tb, _ := json.Marshal(&DisplayNode{Pane: b.Pane})
+			if string(ta) != string(tb) {
+				t.Errorf("%s: got pane %s, expected %s", path, tb, ta)
+			}
+		}
+		if a.Children[0] != nil {
+			check(a.Children[0], b.Children[0], path+"/0")
+			check(a.Children[1], b.Children[1], path+"/1")
+		} else if b.Children[0] != nil {
+			t.Errorf("%s: unexpected children after round trip", path)
+		}
+	}
+	check(root, &loaded, "root")
+
+	if loaded.Children[1].SplitLine.Axis != SplitAxisY {
+		t.Errorf("SplitY created a split along axis %d", loaded.Children[1].SplitLine.Axis)
+	}
+}
+
+func TestApplyLayout(t *testing.T) {
+	messages, fsp := NewMessagesPane(), NewFlightStripPane()
+	current := NewDisplayPanes(NewEmptyPane(), messages, fsp)
+
+	// Subscribe the panes directly, as Activate would, since fonts aren't
+	// available here.
+	es := sim.NewEventStream(nil)
+	messages.events = es.Subscribe()
+	fsp.events = es.Subscribe()
+	fspEvents := fsp.events
+
+	// The layout has the messages pane, two empty panes, and no flight
+	// strips.
+	layout := &DisplayNode{
+		SplitLine: SplitLine{Axis: SplitAxisY, Pos: 0.3},
+		Children: [2]*DisplayNode{
+			&DisplayNode{Pane: NewMessagesPane()},
+			(&DisplayNode{Pane: NewEmptyPane()}).SplitX(0.5, &DisplayNode{Pane: NewEmptyPane()}),
+		},
+	}
+
+	root, newPanes, err := ApplyLayout(layout, current)
+	if err != nil {
+		t.Fatalf("ApplyLayout: %v", err)
+	}
+
+	if root.SplitLine != layout.SplitLine || root.Children[1].SplitLine != layout.Children[1].SplitLine {
+		t.Errorf("layout split lines not preserved")
+	}
+	if root.Children[0].Pane != messages {
+		t.Errorf("existing messages pane was not reused")
+	}
+	if root.NodeForPane(fsp) != nil {
+		t.Errorf("flight strip pane should not be in the new layout")
+	}
+	// The flight strip pane was discarded, so its event subscription
+	// should have been removed so that it doesn't keep the EventStream
+	// from reclaiming events.
+	subscribed := func(sub *sim.EventsSubscription) bool {
+		key := fmt.Sprintf("subscriber_%p", sub)
+		return slices.ContainsFunc(es.LogValue().Group(), func(a slog.Attr) bool { return a.Key == key })
+	}
+	if fsp.events != nil || subscribed(fspEvents) {
+		t.Errorf("discarded flight strip pane is still subscribed to the event stream")
+	}
+	if messages.events == nil || !subscribed(messages.events) {
+		t.Errorf("reused messages pane should still be subscribed to the event stream")
+	}
+	// One of the empty panes is reused and the other is new.
+	if len(newPanes) != 1 {
+		t.Fatalf("expected 1 new pane, got %d", len(newPanes))
+	}
+	if _, ok := newPanes[0].(*EmptyPane); !ok {
+		t.Errorf("expected new pane to be an EmptyPane, got %T", newPanes[0])
+	}
+	if newPanes[0] == layout.Children[1].Children[0].Pane || newPanes[0] == layout.Children[1].Children[1].Pane {
+		t.Errorf("the saved layout's panes should not be used directly")
+	}
+}
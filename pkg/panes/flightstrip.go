@@ -90,9 +90,8 @@ func (fsp *FlightStripPane) Activate(r renderer.Renderer, p platform.Platform, e
 	if fsp.FontSize == 0 {
 		fsp.FontSize = 12
 	}
-	if fsp.font = renderer.GetFont(renderer.FontIdentifier{Name: "Flight Strip Printer", Size: fsp.FontSize}); fsp.font == nil {
-		fsp.font = renderer.GetDefaultFont()
-	}
+	fsp.font = renderer.GetClosestFont(renderer.FontIdentifier{Name: "Flight Strip Printer", Size: fsp.FontSize})
+	fsp.FontSize = fsp.font.Size
 	if fsp.addedAircraft == nil {
 		fsp.addedAircraft = make(map[string]interface{})
 	}
@@ -112,6 +111,13 @@ func (fsp *FlightStripPane) Activate(r renderer.Renderer, p platform.Platform, e
 	fsp.events = eventStream.Subscribe()
 }
 
+func (fsp *FlightStripPane) Deactivate() {
+	if fsp.events != nil {
+		fsp.events.Unsubscribe()
+		fsp.events = nil
+	}
+}
+
 func (fsp *FlightStripPane) getCID(callsign string) int {
 	if id, ok := fsp.CIDs[callsign]; ok {
 		return id
@@ -52,16 +52,22 @@ func (mp *MessagesPane) DisplayName() string { return "Messages/Commands" }
 func (mp *MessagesPane) Hide() bool { return false }
 
 func (mp *MessagesPane) Activate(r renderer.Renderer, p platform.Platform, eventStream *sim.EventStream, lg *log.Logger) {
-	if mp.font = renderer.GetFont(mp.FontIdentifier); mp.font == nil {
-		mp.font = renderer.GetDefaultFont()
-		mp.FontIdentifier = mp.font.Id
-	}
+	// The saved font may no longer be available; remap it if so.
+	mp.font = renderer.GetClosestFont(mp.FontIdentifier)
+	mp.FontIdentifier = mp.font.Id
 	if mp.scrollbar == nil {
 		mp.scrollbar = NewVerticalScrollBar(4, true)
 	}
 	mp.events = eventStream.Subscribe()
 }
 
+func (mp *MessagesPane) Deactivate() {
+	if mp.events != nil {
+		mp.events.Unsubscribe()
+		mp.events = nil
+	}
+}
+
 func (mp *MessagesPane) LoadedSim(client *sim.ControlClient, ss sim.State, pl platform.Platform, lg *log.Logger) {
 }
 
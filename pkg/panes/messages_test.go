@@ -0,0 +1,32 @@
+// pkg/panes/messages_test.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package panes
+
+import (
+	"testing"
+
+	"github.com/mmp/vice/pkg/renderer"
+	"github.com/mmp/vice/pkg/sim"
+)
+
+func TestMessagesPaneStaleFont(t *testing.T) {
+	def := renderer.FontIdentifier{Name: "Roboto Regular", Size: 14}
+	renderer.AddFont(&renderer.Font{Size: def.Size, Id: def})
+
+	// A config saved with a font that is no longer available.
+	mp := NewMessagesPane()
+	mp.FontIdentifier = renderer.FontIdentifier{Name: "Bogus Font", Size: 16}
+
+	es := sim.NewEventStream(nil)
+	mp.Activate(nil, nil, es, nil)
+	defer mp.Deactivate()
+
+	if mp.font == nil {
+		t.Fatalf("no font after Activate")
+	}
+	if mp.font.Id != def || mp.FontIdentifier != def {
+		t.Errorf("got font %+v, identifier %+v; expected the default font %+v", mp.font.Id, mp.FontIdentifier, def)
+	}
+}
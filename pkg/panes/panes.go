@@ -7,6 +7,7 @@ package panes
 import (
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"strings"
 	"time"
 
@@ -26,6 +27,11 @@ type Pane interface {
 	// Sim-independent initialization.
 	Activate(r renderer.Renderer, p platform.Platform, eventStream *sim.EventStream, lg *log.Logger)
 
+	// Deactivate is called when the pane is removed from the display; it
+	// should release anything acquired in Activate, such as event stream
+	// subscriptions.
+	Deactivate()
+
 	// LoadedSim is called when vice is restarted and a Sim is loaded from disk.
 	LoadedSim(client *sim.ControlClient, ss sim.State, pl platform.Platform, lg *log.Logger)
 
@@ -53,6 +59,17 @@ type PaneUpgrader interface {
 	Upgrade(prev, current int)
 }
 
+// StatsReporter is implemented by panes that have statistics to include
+// in the periodic performance log.
+type StatsReporter interface {
+	Stats() []slog.Attr
+}
+
+// ConfigVersion is the current version of the saved configuration; see
+// the version history in vice's config.go. It is defined here so that
+// panes can record it with state that they save separately.
+const ConfigVersion = 32
+
 var UIControlColor renderer.RGB = renderer.RGB{R: 0.2754237, G: 0.2754237, B: 0.2754237}
 var UICautionColor renderer.RGB = renderer.RGBFromHex(0xB7B513)
 var UITextColor renderer.RGB = renderer.RGB{R: 0.85, G: 0.85, B: 0.85}
@@ -84,10 +101,25 @@ type Context struct {
 
 	MenuBarHeight float32
 	AudioEnabled  *bool
+	// ReducedQuality is set when frames are taking too long to draw;
+	// Panes should then skip or cut back on expensive optional drawing.
+	ReducedQuality bool
 
 	KeyboardFocus KeyboardFocus
 
 	ControlClient *sim.ControlClient
+
+	// DisplayRoot is the root of the Pane display hierarchy. It should
+	// not be modified directly; use ModifyDisplay instead.
+	DisplayRoot *DisplayNode
+}
+
+// ModifyDisplay registers a function that modifies the Pane display
+// hierarchy, e.g. to add or remove an auxiliary Pane. Since the hierarchy
+// can't be changed while the Panes are being visited, fn is called after
+// all of the Panes have been drawn.
+func (ctx *Context) ModifyDisplay(fn func(root *DisplayNode)) {
+	wm.displayChanges = append(wm.displayChanges, fn)
 }
 
 func (ctx *Context) InitializeMouse(fullDisplayExtent math.Extent2D, p platform.Platform) {
@@ -154,6 +186,7 @@ func init() {
 }
 
 func (ep *EmptyPane) Activate(renderer.Renderer, platform.Platform, *sim.EventStream, *log.Logger) {}
+func (ep *EmptyPane) Deactivate()                                                                  {}
 func (ep *EmptyPane) LoadedSim(client *sim.ControlClient, ss sim.State, pl platform.Platform, lg *log.Logger) {
 }
 func (ep *EmptyPane) ResetSim(client *sim.ControlClient, ss sim.State, pl platform.Platform, lg *log.Logger) {
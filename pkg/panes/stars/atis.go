@@ -0,0 +1,78 @@
+// pkg/panes/stars/atis.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package stars
+
+import (
+	"slices"
+
+	av "github.com/mmp/vice/pkg/aviation"
+	"github.com/mmp/vice/pkg/panes"
+	"github.com/mmp/vice/pkg/wx"
+)
+
+// updateAutoATIS generates a new ATIS for the primary airport and advances
+// the ATIS code whenever the airport's weather changes, if automatic ATIS
+// generation is enabled. A code that has been entered manually is left as
+// is until then.
+func (sp *STARSPane) updateAutoATIS(ctx *panes.Context) {
+	if !sp.AutoATIS {
+		return
+	}
+
+	ap := ctx.ControlClient.PrimaryAirport
+	metar := ctx.ControlClient.METAR[ap]
+	if metar == nil || metar.String() == sp.autoATISMETAR {
+		return
+	}
+	sp.autoATISMETAR = metar.String()
+
+	m, err := metar.Decode()
+	if err != nil {
+		ctx.Lg.Warnf("%s: unable to decode METAR for ATIS: %v", ap, err)
+		return
+	}
+
+	tmpl := ctx.ControlClient.STARSFacilityAdaptation.ATISTemplate
+	if tmpl == "" {
+		tmpl = wx.DefaultATISTemplate
+	}
+	t, err := wx.ParseATISTemplate(tmpl)
+	if err != nil {
+		// This should have been caught when the scenario was loaded.
+		ctx.Lg.Errorf("%s: ATIS template: %v", ap, err)
+		return
+	}
+
+	ps := sp.currentPrefs()
+	data := wx.ATISData{
+		Airport: ap,
+		Code:    wx.NextATISCode(ps.ATIS),
+		METAR:   m,
+	}
+	if info, ok := av.DB.Airports[ap]; ok && info.Name != "" {
+		data.Airport = info.Name
+	}
+	for _, rwy := range ctx.ControlClient.ArrivalRunways {
+		if rwy.Airport == ap && !slices.Contains(data.ArrivalRunways, rwy.Runway) {
+			data.ArrivalRunways = append(data.ArrivalRunways, rwy.Runway)
+		}
+	}
+	for _, rwy := range ctx.ControlClient.DepartureRunways {
+		if rwy.Airport == ap && !slices.Contains(data.DepartureRunways, rwy.Runway) {
+			data.DepartureRunways = append(data.DepartureRunways, rwy.Runway)
+		}
+	}
+	for _, text := range ps.GIText {
+		if text != "" {
+			data.Notices = append(data.Notices, text)
+		}
+	}
+
+	if sp.autoATIS, err = wx.GenerateATIS(t, data); err != nil {
+		ctx.Lg.Errorf("%s: ATIS: %v", ap, err)
+		return
+	}
+	ps.ATIS = data.Code
+}
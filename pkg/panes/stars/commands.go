@@ -16,6 +16,7 @@ import (
 	"github.com/mmp/vice/pkg/math"
 	"github.com/mmp/vice/pkg/panes"
 	"github.com/mmp/vice/pkg/platform"
+	"github.com/mmp/vice/pkg/rand"
 	"github.com/mmp/vice/pkg/renderer"
 	"github.com/mmp/vice/pkg/sim"
 	"github.com/mmp/vice/pkg/util"
@@ -59,6 +60,14 @@ func (sp *STARSPane) processKeyboardInput(ctx *panes.Context) {
 		return
 	}
 
+	if sp.testPatternActive {
+		// Any key dismisses the test pattern but is otherwise ignored.
+		if ctx.Keyboard.Input != "" || len(ctx.Keyboard.Pressed) > 0 {
+			sp.testPatternActive = false
+		}
+		return
+	}
+
 	input := strings.ToUpper(ctx.Keyboard.Input)
 	if sp.commandMode == CommandModeMultiFunc && sp.multiFuncPrefix == "" && len(input) > 0 {
 		sp.multiFuncPrefix = string(input[0])
@@ -69,6 +78,15 @@ func (sp *STARSPane) processKeyboardInput(ctx *panes.Context) {
 		input = input[1:]
 	}
 
+	// If a DCB spinner is active and nothing has been entered yet, + and
+	// - step its value, equivalent to the mouse wheel.
+	if activeSpinner != nil && sp.previewAreaInput == "" {
+		for len(input) > 0 && (input[0] == '+' || input[0] == '-') {
+			activeSpinner.MouseWheel(util.Select(input[0] == '+', 1, -1))
+			input = input[1:]
+		}
+	}
+
 	// Enforce the 32-character-per-line limit
 	if lines := strings.Fields(sp.previewAreaInput); len(lines) > 0 {
 		if len(lines[len(lines)-1]) > 32 {
@@ -97,7 +115,10 @@ func (sp *STARSPane) processKeyboardInput(ctx *panes.Context) {
 			sp.resetInputState()
 			sp.commandMode = CommandModeMin
 		case platform.KeyEnter:
-			if status := sp.executeSTARSCommand(sp.previewAreaInput, ctx); status.err != nil {
+			if ps.DisplayDCB && sp.dcbFocus != -1 && sp.previewAreaInput == "" {
+				// Press the DCB button that has the keyboard focus.
+				sp.dcbFocusPress = true
+			} else if status := sp.executeSTARSCommand(sp.previewAreaInput, ctx); status.err != nil {
 				sp.displayError(status.err, ctx)
 			} else {
 				if status.clear {
@@ -108,6 +129,7 @@ func (sp *STARSPane) processKeyboardInput(ctx *panes.Context) {
 		case platform.KeyEscape:
 			sp.resetInputState()
 			sp.activeDCBMenu = dcbMenuMain
+			sp.dcbFocus = -1
 			// Also disable any mouse capture from spinners, just in case
 			// the user is mashing escape to get out of one.
 			sp.disableMenuSpinner(ctx)
@@ -115,6 +137,14 @@ func (sp *STARSPane) processKeyboardInput(ctx *panes.Context) {
 			sp.wipRBL = nil
 			sp.wipSignificantPoint = nil
 			sp.wipRestrictionArea = nil
+		case platform.KeyLeftArrow, platform.KeyUpArrow:
+			if ps.DisplayDCB {
+				sp.moveDCBFocus(-1)
+			}
+		case platform.KeyRightArrow, platform.KeyDownArrow:
+			if ps.DisplayDCB {
+				sp.moveDCBFocus(1)
+			}
 		case platform.KeyF1:
 			if ctx.Keyboard.WasPressed(platform.KeyControl) {
 				// Recenter
@@ -227,6 +257,78 @@ func (sp *STARSPane) processKeyboardInput(ctx *panes.Context) {
 	}
 }
 
+// saveVideoMapPreset saves the currently-visible video maps under the
+// given name, replacing any existing preset with that name, and returns
+// the number of maps in it.
+func (sp *STARSPane) saveVideoMapPreset(name string) int {
+	ps := sp.currentPrefs()
+	if ps.VideoMapPresets == nil {
+		ps.VideoMapPresets = make(map[string][]int)
+	}
+	ps.VideoMapPresets[name] = util.SortedMapKeys(ps.VideoMapVisible)
+	return len(ps.VideoMapPresets[name])
+}
+
+// recallVideoMapPreset makes exactly the video maps in the named preset
+// visible. Maps in the preset that aren't available in the current
+// scenario are ignored. It returns the number of maps that are now visible
+// and the number that were ignored.
+func (sp *STARSPane) recallVideoMapPreset(name string) (int, int, error) {
+	ps := sp.currentPrefs()
+	ids, ok := ps.VideoMapPresets[name]
+	if !ok {
+		return 0, 0, ErrSTARSIllegalParam
+	}
+
+	clear(ps.VideoMapVisible)
+	ignored := 0
+	for _, id := range ids {
+		if slices.ContainsFunc(sp.allVideoMaps, func(v av.VideoMap) bool { return v.Id == id }) {
+			ps.VideoMapVisible[id] = nil
+		} else {
+			ignored++
+		}
+	}
+	return len(ps.VideoMapVisible), ignored, nil
+}
+
+// setVideoMapsVisible enables or inhibits all of the video maps for which
+// match returns true, according to op: "E" enables them, "I" inhibits
+// them, and "T" inhibits them if any are currently visible and otherwise
+// enables them. It returns the number of maps that were changed and
+// whether they were enabled.
+func (sp *STARSPane) setVideoMapsVisible(match func(av.VideoMap) bool, op string) (int, bool, error) {
+	ps := sp.currentPrefs()
+
+	var ids []int
+	anyVisible := false
+	for _, vm := range sp.allVideoMaps {
+		if match(vm) {
+			ids = append(ids, vm.Id)
+			if _, ok := ps.VideoMapVisible[vm.Id]; ok {
+				anyVisible = true
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return 0, false, ErrSTARSIllegalMap
+	}
+
+	enable := op == "E" || (op == "T" && !anyVisible)
+	n := 0
+	for _, id := range ids {
+		if _, vis := ps.VideoMapVisible[id]; vis != enable {
+			if enable {
+				ps.VideoMapVisible[id] = nil
+			} else {
+				delete(ps.VideoMapVisible, id)
+			}
+			n++
+		}
+	}
+	return n, enable, nil
+}
+
 func (sp *STARSPane) executeSTARSCommand(cmd string, ctx *panes.Context) (status CommandStatus) {
 	// If there's an active spinner, it gets keyboard input.
 	if activeSpinner != nil {
@@ -292,6 +394,14 @@ func (sp *STARSPane) executeSTARSCommand(cmd string, ctx *panes.Context) (status
 			status.clear = true
 			return
 
+		case "*AP":
+			// Display the facility's ATPA parameters
+			atpa := ctx.ControlClient.STARSFacilityAdaptation.ATPA
+			status.output = fmt.Sprintf("ATPA WARN %dS ALERT %dS\nMONITOR %.1fNM", atpa.WarningLookahead,
+				atpa.AlertLookahead, atpa.MonitorDistance)
+			status.clear = true
+			return
+
 		case "*BE":
 			// Enable ATPA monitor cones
 			ps.DisplayATPAMonitorCones = true
@@ -442,21 +552,75 @@ func (sp *STARSPane) executeSTARSCommand(cmd string, ctx *panes.Context) (status
 				} else {
 					status.err = ErrSTARSIllegalParam
 				}
-			} else if p, ok := ctx.ControlClient.Locate(suffix); ok {
-				// Fix name for first or second point of RBL
+			} else if p, _, err := ctx.ControlClient.LocateExtended(suffix); err == nil {
+				// Fix name or lat/long for first or second point of RBL
 				if rbl := sp.wipRBL; rbl != nil {
 					rbl.P[1].Loc = p
+					rbl.P[1].Fix = suffix
 					sp.RangeBearingLines = append(sp.RangeBearingLines, *rbl)
 					sp.wipRBL = nil
 					status.clear = true
 				} else {
 					sp.wipRBL = &STARSRangeBearingLine{}
 					sp.wipRBL.P[0].Loc = p
+					sp.wipRBL.P[0].Fix = suffix
 					sp.scopeClickHandler = rblSecondClickHandler(ctx, sp)
 					sp.previewAreaInput = "*T" // set up for the second point
 				}
 			} else {
-				status.err = ErrSTARSIllegalFix
+				status.err = GetSTARSError(err, ctx.Lg)
+			}
+			return
+		}
+
+		if strings.HasPrefix(cmd, ".FIND ") {
+			// Highlight the location of a fix, airport, navaid, or lat/long
+			// and show what it resolved to.
+			if p, label, err := ctx.ControlClient.LocateExtended(cmd[6:]); err != nil {
+				status.err = GetSTARSError(err, ctx.Lg)
+			} else {
+				sp.highlightedLocation = p
+				sp.highlightedLocationEndTime = time.Now().Add(5 * time.Second)
+				status.output = label
+				status.clear = true
+			}
+			return
+		}
+
+		if strings.HasPrefix(cmd, ".RDRFAIL ") {
+			// Instructor-only: toggle whether a radar site has failed.
+			// The site can be given by character id or name.
+			site := strings.TrimSpace(cmd[9:])
+			for id, rs := range ctx.ControlClient.RadarSites {
+				if site == rs.Char {
+					site = id
+				}
+			}
+			if !ctx.ControlClient.AmInstructor() {
+				status.err = ErrSTARSIllegalFunction
+			} else if _, ok := ctx.ControlClient.RadarSites[site]; !ok {
+				status.err = ErrSTARSIllegalParam
+			} else {
+				failed := !ctx.ControlClient.RadarSiteFailed(site)
+				ctx.ControlClient.SetRadarSiteFailed(site, failed, nil,
+					func(err error) { sp.displayError(err, ctx) })
+				status.clear = true
+			}
+			return
+		}
+
+		if strings.HasPrefix(cmd, ".FINDAC ") {
+			// Locate an aircraft by callsign or beacon code: highlight its
+			// position and flash its datablock.
+			if ac := lookupAircraft(strings.TrimSpace(cmd[8:])); ac == nil {
+				status.err = ErrSTARSNoFlight
+			} else if state, ok := sp.Aircraft[ac.Callsign]; !ok || state.TrackPosition().IsZero() {
+				status.err = ErrSTARSNoFlight
+			} else {
+				sp.highlightedLocation = state.TrackPosition()
+				sp.highlightedLocationEndTime = time.Now().Add(5 * time.Second)
+				state.FindFlashingEndTime = time.Now().Add(5 * time.Second)
+				status.clear = true
 			}
 			return
 		}
@@ -511,8 +675,10 @@ func (sp *STARSPane) executeSTARSCommand(cmd string, ctx *panes.Context) (status
 				return
 			} else {
 				// Is it an abbreviated flight plan?
+				r := rand.New()
+				r.Seed(time.Now().UnixNano())
 				fp, err := sim.MakeSTARSFlightPlanFromAbbreviated(cmd, ctx.ControlClient.STARSComputer(),
-					ctx.ControlClient.STARSFacilityAdaptation)
+					ctx.ControlClient.STARSFacilityAdaptation, r)
 				if fp != nil {
 					ctx.ControlClient.UploadFlightPlan(fp, sim.LocalNonEnroute, nil,
 						func(err error) { sp.displayError(err, ctx) })
@@ -1356,6 +1522,20 @@ func (sp *STARSPane) executeSTARSCommand(cmd string, ctx *panes.Context) (status
 			}
 
 		case "Y":
+			if len(cmd) > 0 && cmd[0] == '*' {
+				// Y* callsign -> revert to the previous scratchpad. This
+				// can't be confused with setting the scratchpad since
+				// callsigns don't start with '*'.
+				if ac := lookupAircraft(strings.TrimSpace(cmd[1:])); ac == nil {
+					status.err = ErrSTARSNoFlight
+				} else if err := sp.revertScratchpad(ctx, ac.Callsign); err != nil {
+					status.err = err
+				} else {
+					status.clear = true
+				}
+				return
+			}
+
 			isSecondary := false
 			if len(cmd) > 0 && cmd[0] == '+' {
 				isSecondary = true
@@ -1403,6 +1583,11 @@ func (sp *STARSPane) executeSTARSCommand(cmd string, ctx *panes.Context) (status
 				status.clear = true
 				return
 
+			case "T": // display test pattern
+				sp.testPatternActive = true
+				status.clear = true
+				return
+
 			case "EE":
 				ps.AudioEffectEnabled[AudioCommandError] = true
 				status.clear = true
@@ -1503,6 +1688,31 @@ func (sp *STARSPane) executeSTARSCommand(cmd string, ctx *panes.Context) (status
 			sp.activeDCBMenu = dcbMenuMain
 			status.clear = true
 			return
+		} else if name, ok := strings.CutPrefix(cmd, "S "); ok {
+			// Save the visible maps as a preset
+			if name = strings.TrimSpace(name); name == "" {
+				status.err = ErrSTARSCommandFormat
+			} else {
+				n := sp.saveVideoMapPreset(name)
+				status.output = fmt.Sprintf("PRESET %s SAVED\n%d MAPS", name, n)
+				status.clear = true
+			}
+			return
+		} else if name, ok := strings.CutPrefix(cmd, "R "); ok {
+			// Recall a saved preset
+			if name = strings.TrimSpace(name); name == "" {
+				status.err = ErrSTARSCommandFormat
+			} else if n, ignored, err := sp.recallVideoMapPreset(name); err != nil {
+				status.err = err
+			} else {
+				sp.activeDCBMenu = dcbMenuMain
+				status.output = fmt.Sprintf("PRESET %s\n%d MAPS", name, n)
+				if ignored > 0 {
+					status.output += fmt.Sprintf(" %d IGNORED", ignored)
+				}
+				status.clear = true
+			}
+			return
 		} else if n := len(cmd); n > 0 {
 			op := "T"            // toggle by default
 			if cmd[n-1] == 'E' { // enable
@@ -1513,6 +1723,39 @@ func (sp *STARSPane) executeSTARSCommand(cmd string, ctx *panes.Context) (status
 				cmd = cmd[:n-1]
 			}
 
+			if len(cmd) > 1 && (cmd[0] == 'C' || cmd[0] == 'G') {
+				// Category (C0-C9) or brightness group (GA/GB)
+				var match func(v av.VideoMap) bool
+				if cmd[0] == 'G' {
+					group := strings.Index("AB", cmd[1:])
+					if group == -1 || len(cmd) != 2 {
+						status.err = ErrSTARSCommandFormat
+						return
+					}
+					match = func(v av.VideoMap) bool { return v.Group == group }
+				} else {
+					cat, err := strconv.Atoi(cmd[1:])
+					if err != nil {
+						status.err = ErrSTARSCommandFormat
+						return
+					} else if cat < 0 || cat >= VideoMapCurrent {
+						status.err = ErrSTARSIllegalMap
+						return
+					}
+					match = func(v av.VideoMap) bool { return v.Category == cat }
+				}
+
+				n, enabled, err := sp.setVideoMapsVisible(match, op)
+				if err != nil {
+					status.err = err
+				} else {
+					sp.activeDCBMenu = dcbMenuMain
+					status.output = fmt.Sprintf("%d MAPS %s", n, util.Select(enabled, "ENABLED", "INHIBITED"))
+					status.clear = true
+				}
+				return
+			}
+
 			if idx, err := strconv.Atoi(cmd); err != nil {
 				status.err = ErrSTARSCommandFormat
 			} else if idx <= 0 {
@@ -2346,6 +2589,30 @@ func (sp *STARSPane) updateQL(ctx *panes.Context, input string) (previewInput st
 }
 
 func (sp *STARSPane) setScratchpad(ctx *panes.Context, callsign string, contents string, isSecondary bool, isImplied bool) error {
+	if err := sp.checkScratchpad(ctx, callsign, contents, isSecondary, isImplied); err != nil {
+		return err
+	}
+
+	if isSecondary {
+		ctx.ControlClient.SetSecondaryScratchpad(callsign, contents, nil,
+			func(err error) { sp.displayError(err, ctx) })
+	} else {
+		state := sp.Aircraft[callsign]
+		prev := sp.getTrack(ctx, ctx.ControlClient.Aircraft[callsign]).SP1
+		sp.setPrimaryScratchpad(ctx, callsign, contents, func() {
+			// Only record changes that were accepted so that a rejected
+			// entry can't be reverted to.
+			if contents != prev {
+				state.pushScratchpadHistory(prev)
+			}
+		})
+	}
+	return nil
+}
+
+// checkScratchpad returns an error if the given scratchpad contents can't
+// be entered for the aircraft.
+func (sp *STARSPane) checkScratchpad(ctx *panes.Context, callsign string, contents string, isSecondary bool, isImplied bool) error {
 	lc := len([]rune(contents))
 
 	ac := ctx.ControlClient.Aircraft[callsign]
@@ -2404,16 +2671,41 @@ func (sp *STARSPane) setScratchpad(ctx *panes.Context, callsign string, contents
 		return ErrSTARSIllegalScratchpad
 	}
 
-	if isSecondary {
-		ctx.ControlClient.SetSecondaryScratchpad(callsign, contents, nil,
-			func(err error) { sp.displayError(err, ctx) })
-	} else {
-		if trk.SP1 == "" {
-			sp.Aircraft[callsign].ClearedScratchpadAlternate = true
-		}
-		ctx.ControlClient.SetScratchpad(callsign, contents, nil,
-			func(err error) { sp.displayError(err, ctx) })
+	return nil
+}
+
+// setPrimaryScratchpad sends the new primary scratchpad to the server,
+// calling success if it is accepted.
+func (sp *STARSPane) setPrimaryScratchpad(ctx *panes.Context, callsign string, contents string, success func()) {
+	if sp.getTrack(ctx, ctx.ControlClient.Aircraft[callsign]).SP1 == "" {
+		sp.Aircraft[callsign].ClearedScratchpadAlternate = true
+	}
+	ctx.ControlClient.SetScratchpad(callsign, contents, func(any) { success() },
+		func(err error) { sp.displayError(err, ctx) })
+}
+
+// revertScratchpad restores the primary scratchpad to the value it had
+// before its most recent change.
+func (sp *STARSPane) revertScratchpad(ctx *panes.Context, callsign string) error {
+	state, ok := sp.Aircraft[callsign]
+	if !ok {
+		return ErrSTARSNoFlight
 	}
+	prev, ok := state.peekScratchpadHistory()
+	if !ok {
+		return ErrSTARSIllegalScratchpad
+	}
+	if err := sp.checkScratchpad(ctx, callsign, prev, false, false); err != nil {
+		return err
+	}
+
+	sp.setPrimaryScratchpad(ctx, callsign, prev, func() {
+		// Remove it once it's been restored so that repeated reverts keep
+		// going back in time.
+		if top, ok := state.peekScratchpadHistory(); ok && top == prev {
+			state.popScratchpadHistory()
+		}
+	})
 	return nil
 }
 
@@ -2862,19 +3154,22 @@ func (sp *STARSPane) executeSTARSClickedCommand(ctx *panes.Context, cmd string,
 				status.clear = true
 				return
 			} else if len(cmd) > 2 && cmd[:2] == "*J" {
-				if r, err := strconv.Atoi(cmd[2:]); err == nil {
+				// A trailing "M" gives the size in minutes of flight rather
+				// than nautical miles.
+				arg, minutes := strings.CutSuffix(cmd[2:], "M")
+				if r, err := strconv.Atoi(arg); err == nil {
 					if r < 1 || r > 30 {
 						status.err = ErrSTARSIllegalValue
 					} else {
-						state.JRingRadius = float32(r)
+						state.JRingRadius, state.JRingMinutes = float32(r), minutes
 						state.ConeLength = 0 // can't have both
 					}
 					status.clear = true
-				} else if r, err := strconv.ParseFloat(cmd[2:], 32); err == nil {
+				} else if r, err := strconv.ParseFloat(arg, 32); err == nil {
 					if r < 1 || r > 30 {
 						status.err = ErrSTARSIllegalValue
 					} else {
-						state.JRingRadius = float32(r)
+						state.JRingRadius, state.JRingMinutes = float32(r), minutes
 						state.ConeLength = 0 // can't have both
 					}
 					status.clear = true
@@ -2883,19 +3178,22 @@ func (sp *STARSPane) executeSTARSClickedCommand(ctx *panes.Context, cmd string,
 				}
 				return
 			} else if len(cmd) > 2 && cmd[:2] == "*P" {
-				if r, err := strconv.Atoi(cmd[2:]); err == nil {
+				// A trailing "M" gives the size in minutes of flight rather
+				// than nautical miles.
+				arg, minutes := strings.CutSuffix(cmd[2:], "M")
+				if r, err := strconv.Atoi(arg); err == nil {
 					if r < 1 || r > 30 {
 						status.err = ErrSTARSIllegalValue
 					} else {
-						state.ConeLength = float32(r)
+						state.ConeLength, state.ConeMinutes = float32(r), minutes
 						state.JRingRadius = 0 // can't have both
 					}
 					status.clear = true
-				} else if r, err := strconv.ParseFloat(cmd[2:], 32); err == nil {
+				} else if r, err := strconv.ParseFloat(arg, 32); err == nil {
 					if r < 1 || r > 30 {
 						status.err = ErrSTARSIllegalValue
 					} else {
-						state.ConeLength = float32(r)
+						state.ConeLength, state.ConeMinutes = float32(r), minutes
 						state.JRingRadius = 0 // can't have both
 					}
 					status.clear = true
@@ -3163,6 +3461,16 @@ func (sp *STARSPane) executeSTARSClickedCommand(ctx *panes.Context, cmd string,
 					return
 				}
 
+			case "U":
+				// Local controller note; an empty note clears it.
+				if note := strings.TrimSpace(cmd); len([]rune(note)) > 16 {
+					status.err = ErrSTARSCommandFormat
+				} else {
+					state.note = note
+					status.clear = true
+				}
+				return
+
 			case "V":
 				if cmd == "" {
 					if trk != nil && trk.TrackOwner != ctx.ControlClient.PrimaryTCP && ac.ControllingController != ctx.ControlClient.PrimaryTCP {
@@ -3177,6 +3485,17 @@ func (sp *STARSPane) executeSTARSClickedCommand(ctx *panes.Context, cmd string,
 				return
 
 			case "Y":
+				if cmd == "*" {
+					// Revert to the previous scratchpad; a scratchpad of
+					// just "*" must be entered with the callsign instead.
+					if err := sp.revertScratchpad(ctx, ac.Callsign); err != nil {
+						status.err = err
+					} else {
+						status.clear = true
+					}
+					return
+				}
+
 				isSecondary := false
 				if len(cmd) > 0 && cmd[0] == '+' {
 					isSecondary = true
@@ -3670,7 +3989,7 @@ func (sp *STARSPane) consumeMouseEvents(ctx *panes.Context, ghosts []*av.GhostAi
 			font := sp.systemFont(ctx, ps.CharSize.Datablocks)
 			style := renderer.TextStyle{
 				Font:        font,
-				Color:       ps.Brightness.FullDatablocks.ScaleRGB(STARSListColor),
+				Color:       ps.Brightness.FullDatablocks.ScaleRGB(sp.palette().List),
 				LineSpacing: 0}
 
 			// Aircraft track position in window coordinates
@@ -3791,6 +4110,10 @@ func (sp *STARSPane) flightPlanSTARS(ctx *panes.Context, ac *av.Aircraft) (strin
 	// Common stuff
 	owner := trk.TrackOwner
 	state := sp.Aircraft[ac.Callsign]
+	altMode := sp.currentPrefs().AltitudeDisplayMode
+	// Unlike datablocks, the readout truncates the current altitude to
+	// hundreds of feet rather than rounding it.
+	altitude := formatAltitude(int(ac.Altitude())/100*100, altMode)
 
 	result := ac.Callsign + " "             // all start with aricraft id
 	if ctx.ControlClient.IsOverflight(ac) { // check this first
@@ -3800,7 +4123,7 @@ func (sp *STARSPane) flightPlanSTARS(ctx *panes.Context, ac *av.Aircraft) (strin
 		// TODO: entry fix
 		result += "E" + fmtTime(state.FirstSeen) + " "
 		// TODO: exit fix
-		result += "R" + fmt.Sprintf("%03d", fp.Altitude/100) + "\n"
+		result += "R" + formatAltitude(fp.Altitude, altMode) + "\n"
 
 		// TODO: [mode S equipage] [target identification] [target address]
 	} else if ctx.ControlClient.IsDeparture(ac) {
@@ -3814,7 +4137,7 @@ func (sp *STARSPane) flightPlanSTARS(ctx *panes.Context, ac *av.Aircraft) (strin
 			}
 			result += ac.Scratchpad + " " // should be exit fix--close enough?
 			result += "P" + fmtTime(state.FirstSeen) + " "
-			result += "R" + fmt.Sprintf("%03d", fp.Altitude/100)
+			result += "R" + formatAltitude(fp.Altitude, altMode)
 		} else {
 			// Active departure
 			result += ac.FlightPlan.AssignedSquawk.String() + " "
@@ -3822,10 +4145,10 @@ func (sp *STARSPane) flightPlanSTARS(ctx *panes.Context, ac *av.Aircraft) (strin
 				result += fp.DepartureAirport[1:] + " "
 			}
 			result += "D" + fmtTime(state.FirstRadarTrack) + " "
-			result += fmt.Sprintf("%03d", int(ac.Altitude())/100) + "\n"
+			result += altitude + "\n"
 
 			result += ac.Scratchpad + " "
-			result += "R" + fmt.Sprintf("%03d", fp.Altitude/100) + " "
+			result += "R" + formatAltitude(fp.Altitude, altMode) + " "
 
 			result += fp.AircraftType
 
@@ -3836,7 +4159,7 @@ func (sp *STARSPane) flightPlanSTARS(ctx *panes.Context, ac *av.Aircraft) (strin
 		result += fp.AircraftType + " "
 		result += ac.FlightPlan.AssignedSquawk.String() + " "
 		result += owner + " "
-		result += fmt.Sprintf("%03d", int(ac.Altitude())/100) + "\n"
+		result += altitude + "\n"
 
 		// Use the last item in the route for the entry fix
 		routeFields := strings.Fields(fp.Route)
@@ -7,6 +7,7 @@ package stars
 import (
 	"fmt"
 	"slices"
+	"strconv"
 	"strings"
 
 	av "github.com/mmp/vice/pkg/aviation"
@@ -51,11 +52,15 @@ type fullDatablock struct {
 	field2 [1]dbChar
 	field8 [4]dbChar
 	// line 2
-	field34 [3][5]dbChar // field 3 and 4 together, since they're connected
+	field34 [3][6]dbChar // field 3 and 4 together, since they're connected
 	field5  [3][7]dbChar
 	// line 3
 	field6 [2][5]dbChar
 	field7 [2][4]dbChar
+	// line 4 (not in manual, but for estimated airspeed)
+	field9 [10]dbChar
+	// line 5 (not in manual, but for local controller notes)
+	field10 [16]dbChar
 }
 
 func (db fullDatablock) draw(td *renderer.TextDrawBuilder, pt [2]float32, font *renderer.Font,
@@ -102,6 +107,12 @@ func (db fullDatablock) draw(td *renderer.TextDrawBuilder, pt [2]float32, font *
 		dbMakeLine(selectMultiplexed([][]dbChar{db.field6[0][:], db.field6[1][:]}),
 			selectMultiplexed([][]dbChar{db.field7[0][:], db.field7[1][:]})),
 	}
+	if !fieldEmpty(db.field9[:]) {
+		lines = append(lines, dbMakeLine(db.field9[:]))
+	}
+	if !fieldEmpty(db.field10[:]) {
+		lines = append(lines, dbMakeLine(db.field10[:]))
+	}
 	pt[1] += float32(font.Size) // align leader with line 1
 	dbDrawLines(lines, td, pt, font, brightness, leaderLineDirection, halfSeconds)
 }
@@ -113,7 +124,7 @@ type partialDatablock struct {
 	// line 0
 	field0 [16]dbChar
 	// line 1
-	field12 [3][5]dbChar
+	field12 [3][6]dbChar
 	field3  [2][4]dbChar
 	field4  [2]dbChar
 }
@@ -172,7 +183,7 @@ type limitedDatablock struct {
 	field1 [7]dbChar
 	field2 [1]dbChar // unused
 	// Line 2
-	field3 [3]dbChar
+	field3 [5]dbChar // 3 characters unless altitudes are shown in feet
 	field4 [2]dbChar // unused
 	field5 [4]dbChar
 	// Line 3 (not in manual, but for beaconator callsign)
@@ -184,7 +195,7 @@ func (db limitedDatablock) draw(td *renderer.TextDrawBuilder, pt [2]float32, fon
 	lines := []dbLine{
 		dbMakeLine(db.field0[:]),
 		dbMakeLine(db.field1[:], db.field2[:]),
-		dbMakeLine(db.field3[:], db.field4[:], db.field5[:]),
+		dbMakeLine(dbChopTrailing(db.field3[:]), db.field4[:], db.field5[:]),
 		dbMakeLine(db.field6[:]),
 	}
 	pt[1] += 2 * float32(font.Size) // align leader with line 2
@@ -410,6 +421,18 @@ func formatDBText(field []dbChar, s string, c renderer.RGB, flashing bool) int {
 	return len(s)
 }
 
+// formatDBWakeCategory formats the given prefix followed by the aircraft's
+// wake category, returning the number of characters used. Unknown
+// categories are drawn in the alert color so that they stand out.
+func (sp *STARSPane) formatDBWakeCategory(field []dbChar, prefix string, state *AircraftState,
+	c renderer.RGB, flashing bool) int {
+	idx := formatDBText(field, prefix, c, flashing)
+	if state.CWTCategory == "NOWGT" {
+		c = sp.palette().TextAlert
+	}
+	return idx + formatDBText(field[idx:], state.CWTCategory, c, flashing)
+}
+
 func (sp *STARSPane) getDatablock(ctx *panes.Context, ac *av.Aircraft) datablock {
 	now := ctx.ControlClient.CurrentTime()
 	state := sp.Aircraft[ac.Callsign]
@@ -425,7 +448,7 @@ func (sp *STARSPane) getDatablock(ctx *panes.Context, ac *av.Aircraft) datablock
 
 	// Alerts are common to all datablock types
 	var alerts [16]dbChar
-	formatDBText(alerts[:], strings.Join(sp.getWarnings(ctx, ac), "/"), STARSTextAlertColor,
+	formatDBText(alerts[:], strings.Join(sp.getWarnings(ctx, ac), "/"), sp.palette().TextAlert,
 		false /* do these ever flash? */)
 
 	trk := sp.getTrack(ctx, ac)
@@ -481,7 +504,8 @@ func (sp *STARSPane) getDatablock(ctx *panes.Context, ac *av.Aircraft) datablock
 	}
 	ident := state.Ident(ctx.Now)
 	squawkingSPC, _ := ac.Squawk.IsSPC()
-	altitude := fmt.Sprintf("%03d", (state.TrackAltitude()+50)/100)
+	findFlashing := ctx.Now.Before(state.FindFlashingEndTime)
+	altitude := formatAltitude(state.TrackAltitude(), sp.currentPrefs().AltitudeDisplayMode)
 	groundspeed := fmt.Sprintf("%02d", (state.TrackGroundspeed()+5)/10)
 	// Note arrivalAirport is only set if it should be shown when there is no scratchpad set
 	arrivalAirport := ""
@@ -572,7 +596,7 @@ func (sp *STARSPane) getDatablock(ctx *panes.Context, ac *av.Aircraft) datablock
 		}
 
 		// Field 3: mode C altitude
-		formatDBText(db.field3[:], altitude, color, false)
+		formatDBText(db.field3[:], altitude, color, findFlashing)
 
 		if extended {
 			// Field 5: groundspeed
@@ -611,7 +635,7 @@ func (sp *STARSPane) getDatablock(ctx *panes.Context, ac *av.Aircraft) datablock
 			}
 			return s
 		}
-		formatDBText(db.field12[0][:], fmt1(altitude)+handoffId, color, false)
+		formatDBText(db.field12[0][:], fmt1(altitude)+handoffId, color, findFlashing)
 		f12Idx := 1
 		if sp1 != "" {
 			formatDBText(db.field12[1][:], fmt1(sp1)+handoffId, color, false)
@@ -623,23 +647,18 @@ func (sp *STARSPane) getDatablock(ctx *panes.Context, ac *av.Aircraft) datablock
 
 		// Field 3: by default, groundspeed and/or "V" for VFR, "E" for overflight, followed by CWT,
 		// but may be adapted.
-		rulesCategory := " "
-		if trk.FlightPlan.Rules == av.VFR {
-			rulesCategory = "V"
-		} else if sp.isOverflight(ctx, trk) {
-			rulesCategory = "E"
-		}
+		rulesCategory := sp.rulesCategory(ctx, trk.FlightPlan.Rules, trk)
 		if fa.PDB.SplitGSAndCWT {
 			// [GS, CWT] timesliced
 			formatDBText(db.field3[0][:], groundspeed, color, false)
-			formatDBText(db.field3[1][:], rulesCategory+state.CWTCategory, color, false)
+			sp.formatDBWakeCategory(db.field3[1][:], rulesCategory, state, color, false)
 		} else {
 			if fa.PDB.HideGroundspeed {
 				// [CWT]
-				formatDBText(db.field3[0][:], rulesCategory+state.CWTCategory, color, false)
+				sp.formatDBWakeCategory(db.field3[0][:], rulesCategory, state, color, false)
 			} else {
 				// [GS CWT]
-				formatDBText(db.field3[0][:], groundspeed+rulesCategory+state.CWTCategory, color, false)
+				sp.formatDBWakeCategory(db.field3[0][:], groundspeed+rulesCategory, state, color, false)
 			}
 			if fa.PDB.ShowAircraftType {
 				// [ACTYPE]
@@ -666,7 +685,7 @@ func (sp *STARSPane) getDatablock(ctx *panes.Context, ac *av.Aircraft) datablock
 		if beaconator {
 			formatDBText(db.field1[:], ac.Squawk.String(), color, false)
 		} else {
-			formatDBText(db.field1[:], ac.Callsign, color, false)
+			formatDBText(db.field1[:], ac.Callsign, color, findFlashing)
 		}
 
 		// Field 2: various symbols for inhibited stuff
@@ -723,26 +742,22 @@ func (sp *STARSPane) getDatablock(ctx *panes.Context, ac *av.Aircraft) datablock
 		}
 
 		// Field 5: groundspeed
-		rulesCategory := " "
-		if ac.FlightPlan.Rules == av.VFR {
-			rulesCategory = "V"
-		} else if sp.isOverflight(ctx, trk) {
-			rulesCategory = "E"
-		}
-		rulesCategory += state.CWTCategory + " "
+		rulesCategory := sp.rulesCategory(ctx, ac.FlightPlan.Rules, trk)
 
 		if state.IFFlashing {
 			if ident {
 				formatDBText(db.field5[0][:], "IF"+"ID", color, true)
 			} else {
-				formatDBText(db.field5[0][:], "IF"+rulesCategory, color, true)
+				idx := sp.formatDBWakeCategory(db.field5[0][:], "IF"+rulesCategory, state, color, true)
+				formatDBText(db.field5[0][idx:], " ", color, true)
 			}
 		} else {
 			idx := formatDBText(db.field5[0][:], groundspeed, color, false)
 			if ident {
 				formatDBText(db.field5[0][idx:], "ID", color, true)
 			} else {
-				formatDBText(db.field5[0][idx:], rulesCategory, color, false)
+				idx += sp.formatDBWakeCategory(db.field5[0][idx:], rulesCategory, state, color, false)
+				formatDBText(db.field5[0][idx:], " ", color, false)
 			}
 		}
 		// Field 5: +aircraft type and possibly requested altitude, if not
@@ -752,7 +767,7 @@ func (sp *STARSPane) getDatablock(ctx *panes.Context, ac *av.Aircraft) datablock
 
 			if (state.DisplayRequestedAltitude != nil && *state.DisplayRequestedAltitude) ||
 				(state.DisplayRequestedAltitude == nil && sp.currentPrefs().DisplayRequestedAltitude) {
-				formatDBText(db.field5[2][:], fmt.Sprintf("R%03d ", ac.FlightPlan.Altitude/100), color, false)
+				formatDBText(db.field5[2][:], "R"+formatAltitude(ac.FlightPlan.Altitude, sp.currentPrefs().AltitudeDisplayMode)+" ", color, false)
 			}
 		}
 
@@ -763,9 +778,9 @@ func (sp *STARSPane) getDatablock(ctx *panes.Context, ac *av.Aircraft) datablock
 		} else if state.IntrailDistance != 0 && sp.currentPrefs().DisplayATPAInTrailDist {
 			distColor := color
 			if state.ATPAStatus == ATPAStatusWarning {
-				distColor = STARSATPAWarningColor
+				distColor = sp.palette().ATPAWarning
 			} else if state.ATPAStatus == ATPAStatusAlert {
-				distColor = STARSATPAAlertColor
+				distColor = sp.palette().ATPAAlert
 			}
 			formatDBText(db.field6[0][:], fmt.Sprintf("%.2f", state.IntrailDistance), distColor, false)
 		}
@@ -784,20 +799,46 @@ func (sp *STARSPane) getDatablock(ctx *panes.Context, ac *av.Aircraft) datablock
 			formatDBText(db.field7[idx][:], trk.FlightPlan.AssignedSquawk.String(), color, true)
 		}
 
+		// Field 9: estimated true airspeed and, in the flight levels, Mach
+		if sp.currentPrefs().DisplayAirspeed {
+			formatDBText(db.field9[:], formatAirspeed(state, ctx.ControlClient, ctx.ControlClient.NmPerLongitude), color, false)
+		}
+
+		// Field 10: controller note
+		if state.note != "" && sp.currentPrefs().DisplayNotes {
+			formatDBText(db.field10[:], state.note, color, false)
+		}
+
 		return db
 	}
 
 	return nil
 }
 
-func (sp *STARSPane) getGhostDatablock(ghost *av.GhostAircraft, color renderer.RGB) ghostDatablock {
+// formatAirspeed returns the datablock text for an aircraft's estimated
+// true airspeed, e.g. "T245", with its Mach number added at and above
+// FL240, e.g. "T460 M.80".
+func formatAirspeed(state *AircraftState, wind av.WindModel, nmPerLongitude float32) string {
+	tas := state.TrackTrueAirspeed(wind, nmPerLongitude)
+	s := fmt.Sprintf("T%03d", int(tas+0.5))
+	if alt := float32(state.TrackAltitude()); alt >= 24000 {
+		m := int(100*av.TASToMach(tas, alt) + 0.5) // hundredths
+		s += fmt.Sprintf(" M%s.%02d", util.Select(m >= 100, strconv.Itoa(m/100), ""), m%100)
+	}
+	return s
+}
+
+// getGhostDatablock returns the datablock for a CRDA ghost; rulesCategory
+// is the parent aircraft's, as returned by rulesCategory().
+func (sp *STARSPane) getGhostDatablock(ghost *av.GhostAircraft, rulesCategory string, color renderer.RGB) ghostDatablock {
 	var db ghostDatablock
 
 	state := sp.Aircraft[ghost.Callsign]
 	groundspeed := fmt.Sprintf("%02d", (ghost.Groundspeed+5)/10)
 	if state.Ghost.PartialDatablock {
-		// Partial datablock is just airspeed and then aircraft CWT type
-		formatDBText(db.field0[:], groundspeed+state.CWTCategory, color, false)
+		// Partial datablock is just groundspeed and then the aircraft's
+		// CWT category, formatted the same as in its own PDB.
+		sp.formatDBWakeCategory(db.field0[:], groundspeed+rulesCategory, state, color, false)
 	} else {
 		// The full datablock ain't much more...
 		formatDBText(db.field0[:], ghost.Callsign, color, false)
@@ -847,55 +888,55 @@ func (sp *STARSPane) trackDatablockColorBrightness(ctx *panes.Context, ac *av.Ai
 	}
 
 	if trk == nil {
-		color = STARSUntrackedAircraftColor
+		color = sp.palette().UntrackedAircraft
 		return
 	}
 
 	for _, controller := range trk.RedirectedHandoff.Redirector {
 		if controller == ctx.ControlClient.PrimaryTCP && trk.RedirectedHandoff.RedirectedTo != ctx.ControlClient.PrimaryTCP {
-			color = STARSUntrackedAircraftColor
+			color = sp.palette().UntrackedAircraft
 		}
 	}
 
 	// Check if we're the controller being ForceQL
 	if _, ok := sp.ForceQLCallsigns[ac.Callsign]; ok {
-		color = STARSInboundPointOutColor
+		color = sp.palette().InboundPointOut
 	}
 
 	if trk.TrackOwner == "" {
-		color = STARSUntrackedAircraftColor
+		color = sp.palette().UntrackedAircraft
 	} else {
 		if _, ok := sp.InboundPointOuts[ac.Callsign]; ok || state.PointedOut || state.ForceQL {
 			// yellow for pointed out by someone else or uncleared after acknowledged.
-			color = STARSInboundPointOutColor
+			color = sp.palette().InboundPointOut
 		} else if state.IsSelected {
 			// middle button selected
-			color = STARSSelectedAircraftColor
+			color = sp.palette().SelectedAircraft
 		} else if trk.TrackOwner == ctx.ControlClient.PrimaryTCP { //change
 			// we own the track track
-			color = STARSTrackedAircraftColor
+			color = sp.palette().TrackedAircraft
 		} else if trk.RedirectedHandoff.OriginalOwner == ctx.ControlClient.PrimaryTCP || trk.RedirectedHandoff.RedirectedTo == ctx.ControlClient.PrimaryTCP {
-			color = STARSTrackedAircraftColor
+			color = sp.palette().TrackedAircraft
 		} else if trk.HandoffController == ctx.ControlClient.PrimaryTCP &&
 			!slices.Contains(trk.RedirectedHandoff.Redirector, ctx.ControlClient.PrimaryTCP) {
 			// flashing white if it's being handed off to us.
-			color = STARSTrackedAircraftColor
+			color = sp.palette().TrackedAircraft
 		} else if state.OutboundHandoffAccepted {
 			// we handed it off, it was accepted, but we haven't yet acknowledged
-			color = STARSTrackedAircraftColor
+			color = sp.palette().TrackedAircraft
 		} else if ps.QuickLookAll && ps.QuickLookAllIsPlus {
 			// quick look all plus
-			color = STARSTrackedAircraftColor
+			color = sp.palette().TrackedAircraft
 		} else if slices.ContainsFunc(ps.QuickLookPositions,
 			func(q QuickLookPosition) bool { return q.Id == trk.TrackOwner && q.Plus }) {
 			// individual quicklook plus controller
-			color = STARSTrackedAircraftColor
+			color = sp.palette().TrackedAircraft
 			/* FIXME(mtrokel): temporarily disabled. This flashes in and out e.g. in JFK scenarios for the LGA water gate departures.
 			} else if trk.AutoAssociateFP {
-				color = STARSTrackedAircraftColor
+				color = sp.palette().TrackedAircraft
 			*/
 		} else {
-			color = STARSUntrackedAircraftColor
+			color = sp.palette().UntrackedAircraft
 		}
 	}
 
@@ -938,6 +979,9 @@ func (sp *STARSPane) datablockVisible(ac *av.Aircraft, ctx *panes.Context) bool
 	} else if trk != nil && slices.Contains(trk.RedirectedHandoff.Redirector, ctx.ControlClient.PrimaryTCP) {
 		// Had it but redirected it
 		return true
+	} else if ctx.Now.Before(sp.Aircraft[ac.Callsign].FindFlashingEndTime) {
+		// Located via .FINDAC
+		return true
 	}
 
 	// Check altitude filters
@@ -5,15 +5,18 @@
 package stars
 
 import (
+	"encoding/json"
 	"fmt"
 	"slices"
 	"strconv"
 	"strings"
 
+	"github.com/mmp/vice/pkg/log"
 	"github.com/mmp/vice/pkg/math"
 	"github.com/mmp/vice/pkg/panes"
 	"github.com/mmp/vice/pkg/platform"
 	"github.com/mmp/vice/pkg/renderer"
+	"github.com/mmp/vice/pkg/sim"
 	"github.com/mmp/vice/pkg/util"
 
 	"github.com/brunoga/deep"
@@ -57,6 +60,8 @@ const (
 	dcbPositionLeft
 	dcbPositionRight
 	dcbPositionBottom
+	// The DCB is drawn in its own DCBPane, separate from the scope.
+	dcbPositionFloating
 )
 
 // dcbSpinner is an interface used to manage the various spinner types in
@@ -100,16 +105,52 @@ func (sp *STARSPane) activateMenuSpinner(spinner dcbSpinner) {
 	activeSpinner = spinner
 }
 
+// moveDCBFocus moves the keyboard focus delta buttons forward or backward
+// in the current DCB menu, wrapping around at the ends.
+func (sp *STARSPane) moveDCBFocus(delta int) {
+	if n := sp.dcbNumButtons; n == 0 {
+		return
+	} else if sp.dcbFocus == -1 {
+		sp.dcbFocus = util.Select(delta > 0, 0, n-1)
+	} else {
+		sp.dcbFocus = (sp.dcbFocus + delta + n) % n
+	}
+}
+
+// dcbLayoutPosition returns the edge that the DCB's buttons should be laid
+// out along. For a floating DCB, this is determined by the aspect ratio
+// of its pane.
+func (sp *STARSPane) dcbLayoutPosition(ctx *panes.Context) int {
+	ps := sp.currentPrefs()
+	if ps.DCBPosition != dcbPositionFloating {
+		return ps.DCBPosition
+	} else if ctx.PaneExtent.Width() >= ctx.PaneExtent.Height() {
+		return dcbPositionTop
+	} else {
+		return dcbPositionLeft
+	}
+}
+
 func (sp *STARSPane) dcbButtonScale(ctx *panes.Context) float32 {
 	ps := sp.currentPrefs()
 	// Sigh; on windows we want the button size in pixels on high DPI displays
 	ds := ctx.DrawPixelScale
 	// Scale based on width or height available depending on DCB position
-	if ps.DCBPosition == dcbPositionTop || ps.DCBPosition == dcbPositionBottom {
-		return math.Min(ds, (ds*ctx.PaneExtent.Width()-4)/(numDCBSlots*dcbButtonSize))
+	pos := sp.dcbLayoutPosition(ctx)
+	var scale float32
+	if pos == dcbPositionTop || pos == dcbPositionBottom {
+		scale = math.Min(ds, (ds*ctx.PaneExtent.Width()-4)/(numDCBSlots*dcbButtonSize))
+		if ps.DCBPosition == dcbPositionFloating {
+			// Also make sure the buttons fit vertically.
+			scale = math.Min(scale, (ds*ctx.PaneExtent.Height()-4)/dcbButtonSize)
+		}
 	} else {
-		return math.Min(ds, (ds*ctx.PaneExtent.Height()-4)/(numDCBSlots*dcbButtonSize))
+		scale = math.Min(ds, (ds*ctx.PaneExtent.Height()-4)/(numDCBSlots*dcbButtonSize))
+		if ps.DCBPosition == dcbPositionFloating {
+			scale = math.Min(scale, (ds*ctx.PaneExtent.Width()-4)/dcbButtonSize)
+		}
 	}
+	return scale
 }
 
 func (sp *STARSPane) drawDCB(ctx *panes.Context, transforms ScopeTransformations, cb *renderer.CommandBuffer) math.Extent2D {
@@ -272,6 +313,10 @@ func (sp *STARSPane) drawDCB(ctx *panes.Context, transforms ScopeTransformations
 		if toggleButton(ctx, "DCB\nBOTTOM", &bottom, buttonHalfVertical, buttonScale) {
 			ps.DCBPosition = dcbPositionBottom
 		}
+		floating := ps.DCBPosition == dcbPositionFloating
+		if toggleButton(ctx, "DCB\nFLOAT", &floating, buttonHalfVertical, buttonScale) {
+			ps.DCBPosition = dcbPositionFloating
+		}
 		sp.drawDCBSpinner(ctx, makePTLLengthSpinner(&ps.PTLLength), CommandModeNone, buttonFull, buttonScale)
 		if ps.PTLLength > 0 {
 			if toggleButton(ctx, "PTL OWN", &ps.PTLOwn, buttonHalfVertical, buttonScale) && ps.PTLOwn {
@@ -398,6 +443,10 @@ func (sp *STARSPane) drawDCB(ctx *panes.Context, transforms ScopeTransformations
 			CommandModeNone, buttonHalfVertical, buttonScale)
 		sp.drawDCBSpinner(ctx, makeBrightnessSpinner("WXC", &ps.Brightness.WxContrast, 5, false),
 			CommandModeNone, buttonHalfVertical, buttonScale)
+		sp.drawDCBSpinner(ctx, makeIntegerRangeSpinner("WXL ", &sp.wxOpacityLevel, 1, numWxLevels),
+			CommandModeNone, buttonHalfVertical, buttonScale)
+		sp.drawDCBSpinner(ctx, makeBrightnessSpinner("WXO", &ps.WeatherLevelOpacity[sp.wxOpacityLevel-1], 5, true),
+			CommandModeNone, buttonHalfVertical, buttonScale)
 		if selectButton(ctx, "DONE", buttonHalfVertical, buttonScale) {
 			sp.activeDCBMenu = dcbMenuMain
 		}
@@ -609,6 +658,80 @@ func (sp *STARSPane) drawDCB(ctx *panes.Context, transforms ScopeTransformations
 	return paneExtent
 }
 
+///////////////////////////////////////////////////////////////////////////
+// DCBPane
+
+// DCBPane is the Pane that the DCB is drawn into when it is floating,
+// which allows it to be positioned and sized independently of the scope
+// in the display hierarchy. It doesn't have any state of its own; the
+// STARSPane that owns the DCB links itself up each time it is drawn.
+type DCBPane struct {
+	stars *STARSPane
+}
+
+func init() {
+	panes.RegisterUnmarshalPane("DCBPane", func(d []byte) (panes.Pane, error) {
+		var p DCBPane
+		err := json.Unmarshal(d, &p)
+		return &p, err
+	})
+}
+
+func (dp *DCBPane) Activate(renderer.Renderer, platform.Platform, *sim.EventStream, *log.Logger) {}
+func (dp *DCBPane) Deactivate()                                                                  {}
+func (dp *DCBPane) LoadedSim(*sim.ControlClient, sim.State, platform.Platform, *log.Logger)      {}
+func (dp *DCBPane) ResetSim(*sim.ControlClient, sim.State, platform.Platform, *log.Logger)       {}
+func (dp *DCBPane) CanTakeKeyboardFocus() bool                                                   { return false }
+func (dp *DCBPane) Hide() bool                                                                   { return false }
+
+func (dp *DCBPane) Draw(ctx *panes.Context, cb *renderer.CommandBuffer) {
+	if dp.stars == nil {
+		// The STARSPane hasn't been drawn yet.
+		cb.ClearRGB(renderer.RGB{})
+		return
+	}
+
+	sp := dp.stars
+	ps := sp.currentPrefs()
+	cb.ClearRGB(ps.Brightness.BackgroundContrast.ScaleRGB(sp.palette().Background))
+
+	transforms := GetScopeTransformations(ctx.PaneExtent, ctx.ControlClient.MagneticVariation, ctx.ControlClient.NmPerLongitude,
+		ps.CurrentCenter, float32(ps.Range), 0)
+	sp.drawDCB(ctx, transforms, cb)
+}
+
+// updateFloatingDCB makes sure that there is a DCBPane in the display
+// hierarchy if and only if the DCB is visible and floating.
+func (sp *STARSPane) updateFloatingDCB(ctx *panes.Context) {
+	if ctx.DisplayRoot == nil {
+		return
+	}
+
+	var dcbPane *DCBPane
+	ctx.DisplayRoot.VisitPanes(func(p panes.Pane) {
+		if dp, ok := p.(*DCBPane); ok {
+			dcbPane = dp
+		}
+	})
+
+	ps := sp.currentPrefs()
+	floating := ps.DisplayDCB && ps.DCBPosition == dcbPositionFloating
+	if floating && dcbPane == nil {
+		// Initially carve it off the top of the scope; the user can then
+		// move the split line as desired.
+		dp := &DCBPane{stars: sp}
+		ctx.ModifyDisplay(func(root *panes.DisplayNode) {
+			root.SplitPane(sp, panes.SplitAxisY, 0.9, dp)
+		})
+	} else if !floating && dcbPane != nil {
+		ctx.ModifyDisplay(func(root *panes.DisplayNode) {
+			root.RemovePane(dcbPane)
+		})
+	} else if dcbPane != nil {
+		dcbPane.stars = sp
+	}
+}
+
 func buttonSize(flags int, scale float32) [2]float32 {
 	bs := func(s float32) float32 { return float32(int(s*dcbButtonSize + 0.5)) }
 
@@ -632,6 +755,14 @@ var dcbDrawState struct {
 	style        renderer.TextStyle
 	brightness   STARSBrightness
 	position     int
+	menu         int
+
+	// Keyboard navigation: buttonIndex counts the buttons drawn so far,
+	// focus is the index of the button with the keyboard focus (or -1),
+	// and press records whether it should be treated as clicked.
+	buttonIndex int
+	focus       int
+	press       bool
 }
 
 func (sp *STARSPane) startDrawDCB(ctx *panes.Context, buttonScale float32, transforms ScopeTransformations,
@@ -641,7 +772,12 @@ func (sp *STARSPane) startDrawDCB(ctx *panes.Context, buttonScale float32, trans
 
 	ps := sp.currentPrefs()
 	dcbDrawState.brightness = ps.Brightness.DCB
-	dcbDrawState.position = ps.DCBPosition
+	dcbDrawState.position = sp.dcbLayoutPosition(ctx)
+	dcbDrawState.menu = sp.activeDCBMenu
+	dcbDrawState.buttonIndex = 0
+	dcbDrawState.focus = sp.dcbFocus
+	dcbDrawState.press = sp.dcbFocusPress
+	sp.dcbFocusPress = false
 	buttonSize := float32(int(sp.dcbButtonScale(ctx)*dcbButtonSize + 0.5))
 	var drawEndPos [2]float32
 	switch dcbDrawState.position {
@@ -683,6 +819,9 @@ func (sp *STARSPane) startDrawDCB(ctx *panes.Context, buttonScale float32, trans
 
 	if ctx.Mouse != nil && ctx.Mouse.Clicked[platform.MouseButtonPrimary] {
 		dcbDrawState.mouseDownPos = ctx.Mouse.Pos[:]
+		// Using the mouse ends keyboard navigation of the DCB.
+		sp.dcbFocus = -1
+		dcbDrawState.focus = -1
 	}
 }
 
@@ -690,6 +829,12 @@ func (sp *STARSPane) endDrawDCB() {
 	// Clear out the scissor et al...
 	dcbDrawState.cb.ResetState()
 
+	sp.dcbNumButtons = dcbDrawState.buttonIndex
+	if sp.dcbFocus != -1 && (sp.activeDCBMenu != dcbDrawState.menu || sp.dcbFocus >= sp.dcbNumButtons) {
+		// Start from the first button when we've switched to a new menu.
+		sp.dcbFocus = 0
+	}
+
 	if mouse := dcbDrawState.mouse; mouse != nil {
 		if mouse.Released[platform.MouseButtonPrimary] {
 			dcbDrawState.mouseDownPos = nil
@@ -744,6 +889,9 @@ func drawDCBButton(ctx *panes.Context, text string, flags int, buttonScale float
 	mouseDownInside := dcbDrawState.mouseDownPos != nil &&
 		ext.Inside([2]float32{dcbDrawState.mouseDownPos[0], dcbDrawState.mouseDownPos[1]})
 
+	focused := dcbDrawState.buttonIndex == dcbDrawState.focus
+	dcbDrawState.buttonIndex++
+
 	var buttonColor, textColor renderer.RGB
 	if disabled {
 		buttonColor = dcbDisabledButtonColor
@@ -761,7 +909,7 @@ func drawDCBButton(ctx *panes.Context, text string, flags int, buttonScale float
 		} else {
 			buttonColor = util.Select(pushedIn, dcbActiveButtonColor, dcbButtonColor)
 		}
-		textColor = util.Select(mouseInside, dcbTextSelectedColor, dcbTextColor)
+		textColor = util.Select(mouseInside || focused, dcbTextSelectedColor, dcbTextColor)
 	}
 	buttonColor = dcbDrawState.brightness.ScaleRGB(buttonColor)
 	//textColor = dcbDrawState.brightness.ScaleRGB(textColor)
@@ -815,6 +963,9 @@ func drawDCBButton(ctx *panes.Context, text string, flags int, buttonScale float
 	if mouse != nil && mouseInside && mouse.Released[platform.MouseButtonPrimary] && mouseDownInside {
 		return ext, true /* clicked and released */
 	}
+	if focused && dcbDrawState.press && !disabled {
+		return ext, true /* pressed via the keyboard */
+	}
 	return ext, false
 }
 
@@ -891,7 +1042,8 @@ func (sp *STARSPane) drawDCBSpinner(ctx *panes.Context, spinner dcbSpinner, comm
 		// window coordinates, so need to both account for the viewport
 		// call that lets us draw things oblivious to the menubar as well
 		// as flip things in y.
-		h := ctx.PaneExtent.Height() + ctx.MenuBarHeight
+		buttonBounds = buttonBounds.Offset(ctx.PaneExtent.P0)
+		h := ctx.Platform.DisplaySize()[1]
 		buttonBounds.P0[1], buttonBounds.P1[1] = h-buttonBounds.P1[1], h-buttonBounds.P0[1]
 		ctx.Platform.StartCaptureMouse(buttonBounds)
 
@@ -26,6 +26,7 @@ func NewSTARSError(msg string) *STARSError {
 
 var (
 	ErrSTARSAmbiguousACID     = NewSTARSError("AMB ACID")
+	ErrSTARSAmbiguousFix      = NewSTARSError("AMB FIX")
 	ErrSTARSBeaconMismatch    = NewSTARSError("BCN MISMATCH")
 	ErrSTARSCapacity          = NewSTARSError("CAPACITY")
 	ErrSTARSCommandFormat     = NewSTARSError("FORMAT")
@@ -75,6 +76,9 @@ var starsErrorRemap = map[error]*STARSError{
 	av.ErrInvalidFacility:              ErrSTARSIllegalTrack,
 	av.ErrInvalidHeading:               ErrSTARSIllegalValue,
 	sim.ErrInvalidRestrictionAreaIndex: ErrSTARSIllegalGeoId,
+	sim.ErrNotInstructor:               ErrSTARSIllegalFunction,
+	sim.ErrLocationAmbiguous:           ErrSTARSAmbiguousFix,
+	sim.ErrLocationNotFound:            ErrSTARSIllegalFix,
 	av.ErrNoAircraftForCallsign:        ErrSTARSNoFlight,
 	av.ErrNoController:                 ErrSTARSIllegalSector,
 	av.ErrNoFlightPlan:                 ErrSTARSIllegalFlight,
@@ -89,6 +93,7 @@ var starsErrorRemap = map[error]*STARSError{
 	av.ErrUnknownAirport:               ErrSTARSIllegalAirport,
 	av.ErrUnknownApproach:              ErrSTARSIllegalValue,
 	sim.ErrUnknownController:           ErrSTARSIllegalPosition,
+	sim.ErrUnknownRadarSite:            ErrSTARSIllegalParam,
 	av.ErrUnknownRunway:                ErrSTARSIllegalValue,
 }
 
@@ -62,6 +62,36 @@ func (sp *STARSPane) initializeFonts(r renderer.Renderer, p platform.Platform) {
 	sp.dcbFontB[2] = get("sddCharFontSetBSize2", 15)
 
 	sp.cursorsFont = get("STARS cursors", 30)
+
+	sp.setFallbackFonts()
+}
+
+// setFallbackFonts sets the fonts that are used for characters that the
+// selected font set is missing according to the user's FontFallback
+// setting.
+func (sp *STARSPane) setFallbackFonts() {
+	set := func(f, other *renderer.Font) {
+		roboto := func() *renderer.Font {
+			return renderer.GetClosestFont(renderer.FontIdentifier{Name: "Roboto Regular", Size: f.Id.Size})
+		}
+		switch sp.FontFallback {
+		case fontFallbackOtherSetThenRoboto:
+			f.SetFallbackFonts(other, roboto())
+		case fontFallbackRoboto:
+			f.SetFallbackFonts(roboto())
+		case fontFallbackNone:
+			f.SetFallbackFonts()
+		default:
+			f.SetFallbackFonts(other)
+		}
+	}
+
+	for i := range sp.systemFontA {
+		set(sp.systemFontA[i], sp.systemFontB[i])
+		set(sp.systemFontB[i], sp.systemFontA[i])
+		set(sp.systemOutlineFontA[i], sp.systemOutlineFontB[i])
+		set(sp.systemOutlineFontB[i], sp.systemOutlineFontA[i])
+	}
 }
 
 func (sp *STARSPane) systemFont(ctx *panes.Context, idx int) *renderer.Font {
@@ -28,7 +28,7 @@ func (sp *STARSPane) drawSystemLists(aircraft []*av.Aircraft, ctx *panes.Context
 	font := sp.systemFont(ctx, ps.CharSize.Lists)
 	listStyle := renderer.TextStyle{
 		Font:  font,
-		Color: ps.Brightness.Lists.ScaleRGB(STARSListColor),
+		Color: ps.Brightness.Lists.ScaleRGB(sp.palette().List),
 	}
 
 	td := renderer.GetTextDrawBuilder()
@@ -115,15 +115,29 @@ func (sp *STARSPane) drawPreviewArea(pw [2]float32, font *renderer.Font, td *ren
 		text.WriteString("\n")
 	}
 	text.WriteString(strings.Join(strings.Fields(sp.previewAreaInput), "\n")) // spaces are rendered as newlines
+	if sp.previewAreaInput == "" && sp.commandMode == CommandModeNone {
+		text.WriteString(sp.dcbKeyboardHelp())
+	}
 	if text.Len() > 0 {
 		style := renderer.TextStyle{
 			Font:  font,
-			Color: ps.Brightness.FullDatablocks.ScaleRGB(STARSListColor),
+			Color: ps.Brightness.FullDatablocks.ScaleRGB(sp.palette().List),
 		}
 		td.AddText(rewriteDelta(text.String()), pw, style)
 	}
 }
 
+// dcbKeyboardHelp returns a summary of the keys that operate the DCB to
+// show in the preview area while the DCB is being used from the keyboard.
+func (sp *STARSPane) dcbKeyboardHelp() string {
+	if activeSpinner != nil {
+		return "+/- ADJUST\nENTER VALUE\nESC CANCEL"
+	} else if sp.currentPrefs().DisplayDCB && sp.dcbFocus != -1 {
+		return "ARROWS MOVE\nENTER PRESS\nESC CANCEL"
+	}
+	return ""
+}
+
 func (sp *STARSPane) getTabListIndex(ac *av.Aircraft) string {
 	state := sp.Aircraft[ac.Callsign]
 	if state.TabListIndex == TabListUnassignedIndex {
@@ -153,11 +167,11 @@ func (sp *STARSPane) drawSSAList(ctx *panes.Context, pw [2]float32, aircraft []*
 	font := sp.systemFont(ctx, ps.CharSize.Lists)
 	listStyle := renderer.TextStyle{
 		Font:  font,
-		Color: ps.Brightness.Lists.ScaleRGB(STARSListColor),
+		Color: ps.Brightness.Lists.ScaleRGB(sp.palette().List),
 	}
 	alertStyle := renderer.TextStyle{
 		Font:  font,
-		Color: ps.Brightness.Lists.ScaleRGB(STARSTextAlertColor),
+		Color: ps.Brightness.Lists.ScaleRGB(sp.palette().TextAlert),
 	}
 
 	stripK := func(airport string) string {
@@ -194,12 +208,12 @@ func (sp *STARSPane) drawSSAList(ctx *panes.Context, pw [2]float32, aircraft []*
 	for i := range tv {
 		tv[i] = math.Add2f(pIndicator, math.Scale2f(tv[i], -scale))
 	}
-	trid.AddTriangle(tv[0], tv[1], tv[2], ps.Brightness.Lists.ScaleRGB(STARSTextAlertColor))
+	trid.AddTriangle(tv[0], tv[1], tv[2], ps.Brightness.Lists.ScaleRGB(sp.palette().TextAlert))
 	trid.GenerateCommands(cb)
 
 	square := [][2]float32{[2]float32{-5, -5}, [2]float32{5, -5}, [2]float32{5, 5}, [2]float32{-5, 5}}
 	square = util.MapSlice(square, func(p [2]float32) [2]float32 { return math.Add2f(math.Scale2f(p, scale), pIndicator) })
-	ld.AddLineLoop(ps.Brightness.Lists.ScaleRGB(STARSListColor), square)
+	ld.AddLineLoop(ps.Brightness.Lists.ScaleRGB(sp.palette().List), square)
 	ld.GenerateCommands(cb)
 
 	pw[1] -= 10 * scale
@@ -754,7 +768,7 @@ func (sp *STARSPane) drawCoordinationLists(ctx *panes.Context, paneExtent math.E
 	font := sp.systemFont(ctx, ps.CharSize.Lists)
 	titleStyle := renderer.TextStyle{
 		Font:  font,
-		Color: ps.Brightness.Lists.ScaleRGB(STARSListColor),
+		Color: ps.Brightness.Lists.ScaleRGB(sp.palette().List),
 	}
 
 	td := renderer.GetTextDrawBuilder()
@@ -769,7 +783,7 @@ func (sp *STARSPane) drawCoordinationLists(ctx *panes.Context, paneExtent math.E
 	for i, cl := range fa.CoordinationLists {
 		listStyle := renderer.TextStyle{
 			Font:  font,
-			Color: ps.Brightness.Lists.ScaleRGB(util.Select(cl.YellowEntries, renderer.RGB{1, 1, 0}, STARSListColor)),
+			Color: ps.Brightness.Lists.ScaleRGB(util.Select(cl.YellowEntries, renderer.RGB{1, 1, 0}, sp.palette().List)),
 		}
 		dimStyle := renderer.TextStyle{
 			Font:  font,
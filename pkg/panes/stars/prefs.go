@@ -5,10 +5,15 @@
 package stars
 
 import (
+	"encoding/json"
+	"errors"
+	"os"
 	"slices"
+	"strconv"
 
 	av "github.com/mmp/vice/pkg/aviation"
 	"github.com/mmp/vice/pkg/math"
+	"github.com/mmp/vice/pkg/panes"
 	"github.com/mmp/vice/pkg/platform"
 	"github.com/mmp/vice/pkg/sim"
 
@@ -47,6 +52,25 @@ func (p *PreferenceSet) SetCurrent(cur Preferences, pl platform.Platform, sp *ST
 	p.Current.Activate(pl, sp)
 }
 
+// nameInUse returns whether one of the saved preference sets has the
+// given name.
+func (p *PreferenceSet) nameInUse(name string) bool {
+	return slices.ContainsFunc(p.Saved[:], func(s *Preferences) bool { return s != nil && s.Name == name })
+}
+
+// uniqueName returns a name based on the given one that isn't used by a
+// saved preference set: the name itself if possible and otherwise with a
+// number appended (IMPORT2, IMPORT3, ...), shortened if necessary to fit
+// in the 7 character limit.
+func (p *PreferenceSet) uniqueName(base string) string {
+	name := base
+	for i := 2; p.nameInUse(name); i++ {
+		n := strconv.Itoa(i)
+		name = base[:min(len(base), 7-len(n))] + n
+	}
+	return name
+}
+
 // Reset ends up being called when a new Sim is started. It is responsible
 // for resetting all of the preference values in the PreferenceSet that we
 // don't expect to persist on a restart (e.g. quick look positions.)
@@ -131,6 +155,9 @@ type Preferences struct {
 	DisableMSAW       bool
 
 	VideoMapVisible map[int]interface{}
+	// Named sets of visible video map ids that can be saved and recalled
+	// in MAPS mode.
+	VideoMapPresets map[string][]int
 
 	DisplayRequestedAltitude bool
 }
@@ -150,11 +177,20 @@ type CommonPreferences struct {
 	// 4-94: 0.5s increments via trackball but 0.1s increments allowed if
 	// keyboard input.
 	RadarTrackHistoryRate float32
+	// How history dot colors fall off beyond the most recent one.
+	RadarTrackHistoryFade HistoryFadeCurve
 
 	AudioEffectEnabled []bool
+	AudioEffectVolume  []int // 0-10, per AudioType
 
 	DisplayWeatherLevel     [numWxLevels]bool
 	LastDisplayWeatherLevel [numWxLevels]bool
+	// Per-level scale applied to the WX brightness, so that e.g. light
+	// precipitation can be dimmed while heavy cells stay bright.
+	WeatherLevelOpacity [numWxLevels]STARSBrightness
+	// Crossfade from the previous weather radar image when a new one
+	// arrives rather than switching immediately.
+	WeatherCrossfade bool
 
 	// For aircraft tracked by the user.
 	LeaderLineDirection math.CardinalOrdinalDirection
@@ -162,6 +198,11 @@ type CommonPreferences struct {
 
 	OverflightFullDatablocks bool
 	AutomaticFDBOffset       bool
+	// Show controller notes as an extra line in full datablocks.
+	DisplayNotes bool
+	// Show the wind-corrected true airspeed (and Mach number at high
+	// altitudes) as an extra line in full datablocks.
+	DisplayAirspeed bool
 
 	DisplayTPASize               bool
 	DisplayATPAInTrailDist       bool `json:"DisplayATPAIntrailDist"`
@@ -173,6 +214,15 @@ type CommonPreferences struct {
 
 	DwellMode DwellMode
 
+	AltitudeDisplayMode AltitudeDisplayMode
+
+	ColorPalette STARSColorPalette
+
+	VisualAlerts VisualAlertMode
+	// Also issue conflict alerts for tracks whose vertical rates will
+	// bring them within the vertical minimum shortly.
+	PredictiveCA bool
+
 	Brightness struct {
 		DCB                STARSBrightness
 		BackgroundContrast STARSBrightness
@@ -283,15 +333,18 @@ func (p *Preferences) Reset(ss sim.State, sp *STARSPane) {
 
 	p.RadarSiteSelected = ""
 
-	// Reset CRDA state
-	p.CRDA.RunwayPairState = nil
-	state := CRDARunwayPairState{}
-	// The first runway is enabled by default
-	state.RunwayState[0].Enabled = true
-	for range sp.ConvergingRunways {
-		p.CRDA.RunwayPairState = append(p.CRDA.RunwayPairState, state)
+	// Use the altitude filters from the facility adaptation, if specified;
+	// the user can still change them afterward.
+	af := ss.STARSFacilityAdaptation.AltitudeFilters
+	if af.Unassociated != [2]int{} {
+		p.AltitudeFilters.Unassociated = af.Unassociated
+	}
+	if af.Associated != [2]int{} {
+		p.AltitudeFilters.Associated = af.Associated
 	}
 
+	p.ResetCRDAState(sp.ConvergingRunways)
+
 	clear(p.RestrictionAreaSettings)
 
 	// Make the scenario's default video maps visible
@@ -307,6 +360,19 @@ func (p *Preferences) Reset(ss sim.State, sp *STARSPane) {
 	}
 }
 
+// ResetCRDAState resets the CRDA runway pair state so that it matches the
+// given converging runway pairs, with the first runway of each pair
+// enabled.
+func (p *Preferences) ResetCRDAState(rwys []STARSConvergingRunways) {
+	p.CRDA.RunwayPairState = nil
+	state := CRDARunwayPairState{}
+	// The first runway is enabled by default
+	state.RunwayState[0].Enabled = true
+	for range rwys {
+		p.CRDA.RunwayPairState = append(p.CRDA.RunwayPairState, state)
+	}
+}
+
 func makeDefaultPreferences() *Preferences {
 	var prefs Preferences
 
@@ -320,8 +386,10 @@ func makeDefaultPreferences() *Preferences {
 
 	prefs.AudioVolume = 10
 	prefs.AudioEffectEnabled = make([]bool, AudioNumTypes)
+	prefs.AudioEffectVolume = make([]int, AudioNumTypes)
 	for i := range AudioNumTypes {
 		prefs.AudioEffectEnabled[i] = true
+		prefs.AudioEffectVolume[i] = 10
 	}
 
 	prefs.VideoMapVisible = make(map[int]interface{})
@@ -360,6 +428,7 @@ func makeDefaultPreferences() *Preferences {
 
 	for i := range prefs.DisplayWeatherLevel {
 		prefs.DisplayWeatherLevel[i] = true
+		prefs.WeatherLevelOpacity[i] = 100
 	}
 
 	prefs.CharSize.DCB = 1
@@ -557,6 +626,16 @@ func (ps *Preferences) Upgrade(from, to int) {
 		ps.RestrictionAreaList.Position = [2]float32{.8, .575}
 		ps.RestrictionAreaSettings = make(map[int]*RestrictionAreaSettings)
 	}
+	if from < 31 {
+		for i := range ps.WeatherLevelOpacity {
+			ps.WeatherLevelOpacity[i] = 100
+		}
+	}
+	if from < 32 {
+		for len(ps.AudioEffectVolume) < AudioNumTypes {
+			ps.AudioEffectVolume = append(ps.AudioEffectVolume, 10)
+		}
+	}
 }
 
 func (sp *STARSPane) initPrefsForLoadedSim(ss sim.State, pl platform.Platform) {
@@ -615,3 +694,76 @@ func (sp *STARSPane) currentPrefs() *Preferences {
 	// up calling initPrefsForLoadedSim().
 	return &sp.prefSet.Current
 }
+
+// exportedPreferences is the file format used for exported preferences.
+type exportedPreferences struct {
+	// Version is the config version when the preferences were exported,
+	// so that they can be upgraded when imported into a later version.
+	Version     int
+	Preferences *Preferences
+}
+
+// exportPreferences writes the current preferences to the given file so
+// that they can be shared with other users.
+func (sp *STARSPane) exportPreferences(filename string) error {
+	b, err := json.MarshalIndent(exportedPreferences{
+		Version:     panes.ConfigVersion,
+		Preferences: sp.prefSet.Current.Duplicate(),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, b, 0o644)
+}
+
+// importPreferences reads preferences that were written by
+// exportPreferences, adds them to the saved preferences, and makes them
+// current. A non-empty warning is returned if the preferences had to be
+// adjusted for the current scenario.
+func (sp *STARSPane) importPreferences(filename string, pl platform.Platform) (warning string, err error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+
+	exp := exportedPreferences{Preferences: makeDefaultPreferences()}
+	if err := json.Unmarshal(b, &exp); err != nil {
+		return "", err
+	}
+	if exp.Version == 0 {
+		return "", errors.New("preferences file is missing its version")
+	} else if exp.Version > panes.ConfigVersion {
+		return "", errors.New("preferences were exported from a newer version of vice")
+	}
+	p := exp.Preferences
+	if exp.Version < panes.ConfigVersion {
+		p.Upgrade(exp.Version, panes.ConfigVersion)
+	}
+
+	if p.Name == "" {
+		// The exported preferences were never saved under a name.
+		p.Name = sp.prefSet.uniqueName("IMPORT")
+	} else if len(p.Name) > 7 {
+		return "", errors.New("preference set name must be at most 7 characters")
+	}
+	if sp.prefSet.nameInUse(p.Name) {
+		return "", ErrSTARSIllegalPrefset
+	}
+	idx := slices.Index(sp.prefSet.Saved[:], nil)
+	if idx == -1 {
+		return "", ErrSTARSCapacity
+	}
+
+	if len(p.CRDA.RunwayPairState) != len(sp.ConvergingRunways) {
+		// The preferences were saved for a different set of converging
+		// runways.
+		p.ResetCRDAState(sp.ConvergingRunways)
+		warning = "CRDA STATE RESET"
+	}
+
+	sp.prefSet.Saved[idx] = p
+	sp.prefSet.Selected = &idx
+	sp.prefSet.SetCurrent(*p.Duplicate(), pl, sp)
+
+	return warning, nil
+}
@@ -5,15 +5,18 @@
 package stars
 
 import (
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"image"
 	"image/color"
 	"image/gif"
-	"image/png"
+	"log/slog"
 	"os"
+	"path/filepath"
 	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -43,31 +46,106 @@ const STARSFilledUpTriangle = string(rune(0x1e))
 const TabListEntries = 100
 const TabListUnassignedIndex = -1
 
-var (
-	STARSBackgroundColor    = renderer.RGB{.2, .2, .2} // at 100 contrast
-	STARSListColor          = renderer.RGB{.1, .9, .1}
-	STARSTextAlertColor     = renderer.RGB{1, 0, 0}
-	STARSCompassColor       = renderer.RGB{.55, .55, .55}
-	STARSRangeRingColor     = renderer.RGB{.55, .55, .55}
-	STARSTrackBlockColor    = renderer.RGB{0.12, 0.48, 1}
-	STARSTrackHistoryColors = [5]renderer.RGB{
-		renderer.RGB{.12, .31, .78},
-		renderer.RGB{.28, .28, .67},
-		renderer.RGB{.2, .2, .51},
-		renderer.RGB{.16, .16, .43},
-		renderer.RGB{.12, .12, .35},
-	}
-	STARSJRingConeColor         = renderer.RGB{.5, .5, 1}
-	STARSTrackedAircraftColor   = renderer.RGB{1, 1, 1}
-	STARSUntrackedAircraftColor = renderer.RGB{0, 1, 0}
-	STARSInboundPointOutColor   = renderer.RGB{1, 1, 0}
-	STARSGhostColor             = renderer.RGB{1, 1, 0}
-	STARSSelectedAircraftColor  = renderer.RGB{0, 1, 1}
-
-	STARSATPAWarningColor = renderer.RGB{1, 1, 0}
-	STARSATPAAlertColor   = renderer.RGB{1, .215, 0}
+// STARSPalette collects the colors used when drawing the scope. Colors
+// are specified at full brightness; they are then scaled by the
+// corresponding brightness setting when drawn.
+type STARSPalette struct {
+	Background        renderer.RGB // at 100 contrast
+	List              renderer.RGB
+	TextAlert         renderer.RGB
+	Compass           renderer.RGB
+	RangeRing         renderer.RGB
+	TrackBlock        renderer.RGB
+	TrackHistory      [5]renderer.RGB
+	JRingCone         renderer.RGB
+	TrackedAircraft   renderer.RGB
+	UntrackedAircraft renderer.RGB
+	InboundPointOut   renderer.RGB
+	Ghost             renderer.RGB
+	SelectedAircraft  renderer.RGB
+	ATPAWarning       renderer.RGB
+	ATPAAlert         renderer.RGB
+}
+
+type STARSColorPalette int
+
+// To add a new palette, add an entry here, a name for it in String(),
+// and its colors in starsPalettes.
+const (
+	STARSPaletteStandard STARSColorPalette = iota
+	// For red-green color blindness (deuteranopia and protanopia): green
+	// is replaced with blue and red with magenta, so that alerts stand
+	// out from untracked aircraft and lists.
+	STARSPaletteRedGreen
+	STARSNumPalettes
 )
 
+func (c STARSColorPalette) String() string {
+	switch c {
+	case STARSPaletteStandard:
+		return "Standard"
+	case STARSPaletteRedGreen:
+		return "Red-green color blindness"
+	default:
+		return "unhandled"
+	}
+}
+
+var starsPalettes = [STARSNumPalettes]STARSPalette{
+	STARSPaletteStandard: {
+		Background: renderer.RGB{.2, .2, .2},
+		List:       renderer.RGB{.1, .9, .1},
+		TextAlert:  renderer.RGB{1, 0, 0},
+		Compass:    renderer.RGB{.55, .55, .55},
+		RangeRing:  renderer.RGB{.55, .55, .55},
+		TrackBlock: renderer.RGB{0.12, 0.48, 1},
+		TrackHistory: [5]renderer.RGB{
+			renderer.RGB{.12, .31, .78},
+			renderer.RGB{.28, .28, .67},
+			renderer.RGB{.2, .2, .51},
+			renderer.RGB{.16, .16, .43},
+			renderer.RGB{.12, .12, .35},
+		},
+		JRingCone:         renderer.RGB{.5, .5, 1},
+		TrackedAircraft:   renderer.RGB{1, 1, 1},
+		UntrackedAircraft: renderer.RGB{0, 1, 0},
+		InboundPointOut:   renderer.RGB{1, 1, 0},
+		Ghost:             renderer.RGB{1, 1, 0},
+		SelectedAircraft:  renderer.RGB{0, 1, 1},
+		ATPAWarning:       renderer.RGB{1, 1, 0},
+		ATPAAlert:         renderer.RGB{1, .215, 0},
+	},
+	STARSPaletteRedGreen: {
+		Background: renderer.RGB{.2, .2, .2},
+		List:       renderer.RGB{.35, .7, 1},
+		TextAlert:  renderer.RGB{1, .25, 1},
+		Compass:    renderer.RGB{.55, .55, .55},
+		RangeRing:  renderer.RGB{.55, .55, .55},
+		TrackBlock: renderer.RGB{0.12, 0.48, 1},
+		TrackHistory: [5]renderer.RGB{
+			renderer.RGB{.12, .31, .78},
+			renderer.RGB{.28, .28, .67},
+			renderer.RGB{.2, .2, .51},
+			renderer.RGB{.16, .16, .43},
+			renderer.RGB{.12, .12, .35},
+		},
+		JRingCone:         renderer.RGB{.7, .7, 1},
+		TrackedAircraft:   renderer.RGB{1, 1, 1},
+		UntrackedAircraft: renderer.RGB{.35, .7, 1},
+		InboundPointOut:   renderer.RGB{1, .9, .2},
+		Ghost:             renderer.RGB{1, .9, .2},
+		SelectedAircraft:  renderer.RGB{0, 1, 1},
+		ATPAWarning:       renderer.RGB{1, .9, .2},
+		ATPAAlert:         renderer.RGB{1, .25, 1},
+	},
+}
+
+// palette returns the colors to use for drawing, according to the current
+// preferences.
+func (sp *STARSPane) palette() *STARSPalette {
+	return &starsPalettes[math.Clamp(sp.currentPrefs().ColorPalette, 0, STARSNumPalettes-1)]
+}
+
 type STARSPane struct {
 	TRACONPreferenceSets map[string]*PreferenceSet
 	prefSet              *PreferenceSet
@@ -93,6 +171,8 @@ type STARSPane struct {
 	wxHistoryDraw int
 	// Time at which to step to the next history snapshot (5s intervals).
 	wxNextHistoryStepTime time.Time
+	// Weather level (1-6) whose opacity is adjusted in the BRITE menu.
+	wxOpacityLevel int
 
 	systemFontA, systemFontB               [6]*renderer.Font
 	systemOutlineFontA, systemOutlineFontB [6]*renderer.Font
@@ -121,6 +201,11 @@ type STARSPane struct {
 	// map[string]interface{}.
 	AutoTrackDepartures bool `json:"autotrack_departures"`
 	LockDisplay         bool
+	// If set, the pre-CWT wake turbulence weight classes are used for
+	// datablocks and ATPA rather than the CWT categories.
+	UseWeightClasses bool
+	// If set, the ATIS is generated from the primary airport's weather.
+	AutoATIS bool
 
 	// callsign -> controller id
 	InboundPointOuts  map[string]string
@@ -148,11 +233,24 @@ type STARSPane struct {
 	TgtGenKey         byte
 
 	FontSelection int
+	// Which fonts, and in which order, draw characters that the selected
+	// font set doesn't have; see the fontFallback* constants.
+	FontFallback int
+
+	// User-provided WAV or MP3 files to use in place of the built-in
+	// audio effects.
+	CustomAudioFiles map[AudioType]string
 
 	scopeClickHandler   func(pw [2]float32, transforms ScopeTransformations) CommandStatus
 	activeDCBMenu       int
 	selectedPlaceButton string
 
+	// DCB keyboard navigation: dcbFocus is the index of the button in
+	// the active menu that has the keyboard focus, or -1 if none does.
+	dcbFocus      int
+	dcbFocusPress bool
+	dcbNumButtons int
+
 	dwellAircraft     string
 	drawRouteAircraft string
 
@@ -165,18 +263,48 @@ type STARSPane struct {
 	lastHistoryTrackUpdate time.Time
 	discardTracks          bool
 
+	// Set when UseWeightClasses changes so that the cached wake categories
+	// are updated.
+	wakeCategoriesChanged bool
+	// Aircraft types with an unknown wake category that have already been
+	// logged, so that each is only reported once.
+	loggedUnknownWake map[string]interface{}
+
+	// The most recent automatically-generated ATIS and the METAR it was
+	// generated from.
+	autoATIS      string
+	autoATISMETAR string
+
 	// The start of a RBL--one click received, waiting for the second.
 	wipRBL *STARSRangeBearingLine
 
 	// First point clicked for display bearing/range to significant point.
 	wipSignificantPoint *math.Point2LL
 
-	audioEffects     map[AudioType]int // to handle from Platform.AddPCM()
-	testAudioEndTime time.Time
+	audioEffects        map[AudioType]int // to handle from Platform.AddPCM()
+	builtinAudioEffects map[AudioType]int
+	// Effects allocated for custom sounds; they are reused when the sound
+	// is changed.
+	customAudioEffects map[AudioType]int
+	testAudioEndTime   time.Time
+	// Whether the monitor test pattern is covering the scope; any
+	// keypress dismisses it.
+	testPatternActive bool
+	// Errors from loading custom audio files at startup; they are posted
+	// as error messages once drawing starts.
+	audioLoadErrors []string
 
 	highlightedLocation        math.Point2LL
 	highlightedLocationEndTime time.Time
 
+	// For exporting and importing preferences from the settings window
+	prefsFilename string
+	prefsIOStatus string
+
+	// For choosing custom audio files from the settings window
+	customAudioType     AudioType
+	customAudioFilename string
+
 	// Built-in screenshots / video captures
 	capture struct {
 		enabled          bool
@@ -210,6 +338,13 @@ const (
 	fontARTS
 )
 
+const (
+	fontFallbackOtherSet           = iota // the other STARS font set
+	fontFallbackOtherSetThenRoboto        // the other set, then Roboto Regular
+	fontFallbackRoboto                    // only Roboto Regular
+	fontFallbackNone                      // missing characters aren't drawn
+)
+
 func init() {
 	panes.RegisterUnmarshalPane("STARSPane", func(d []byte) (panes.Pane, error) {
 		var p STARSPane
@@ -328,6 +463,110 @@ func (d DwellMode) String() string {
 	}
 }
 
+// AltitudeDisplayMode specifies how altitudes are formatted in
+// datablocks and flight plan readouts.
+type AltitudeDisplayMode int
+
+const (
+	// Make 0 be hundreds of feet so zero-initialization gives standard
+	// STARS behavior.
+	AltitudeDisplayHundreds AltitudeDisplayMode = iota
+	// Flight levels above the transition altitude and feet below it.
+	AltitudeDisplayFeetFL
+)
+
+// US transition altitude; altitudes at or above it are displayed as
+// flight levels in AltitudeDisplayFeetFL mode.
+const transitionAltitude = 18000
+
+func (m AltitudeDisplayMode) String() string {
+	switch m {
+	case AltitudeDisplayHundreds:
+		return "Hundreds of feet"
+
+	case AltitudeDisplayFeetFL:
+		return "Feet / flight level"
+
+	default:
+		return "unhandled AltitudeDisplayMode"
+	}
+}
+
+// VisualAlertMode specifies whether unacknowledged conflict alerts and
+// MSAWs are additionally indicated with a flashing border and banner
+// around the scope, which may be used in place of audio alerts.
+type VisualAlertMode int
+
+const (
+	VisualAlertsOff VisualAlertMode = iota
+	VisualAlertsWithAudio
+	VisualAlertsOnly
+)
+
+func (m VisualAlertMode) String() string {
+	switch m {
+	case VisualAlertsOff:
+		return "Off"
+
+	case VisualAlertsWithAudio:
+		return "With audio alerts"
+
+	case VisualAlertsOnly:
+		return "Instead of audio alerts"
+
+	default:
+		return "unhandled VisualAlertMode"
+	}
+}
+
+// HistoryFadeCurve specifies how the colors of radar track history dots
+// fall off from the most recent to the oldest one.
+type HistoryFadeCurve int
+
+const (
+	// The palette's history colors are evenly spread over the dots.
+	HistoryFadeLinear HistoryFadeCurve = iota
+	// Older dots dim quickly.
+	HistoryFadeFast
+	// Recent dots stay bright longer.
+	HistoryFadeSlow
+)
+
+func (c HistoryFadeCurve) String() string {
+	switch c {
+	case HistoryFadeLinear:
+		return "Linear"
+
+	case HistoryFadeFast:
+		return "Fast"
+
+	case HistoryFadeSlow:
+		return "Slow"
+
+	default:
+		return "unhandled HistoryFadeCurve"
+	}
+}
+
+// formatAltitude returns the string to display for the given altitude in
+// feet according to the display mode.
+func formatAltitude(alt int, mode AltitudeDisplayMode) string {
+	// Round to hundreds of feet before deciding whether it's a flight
+	// level so that e.g. 17960 is shown as FL180 rather than 18000.
+	hundreds := (alt + 50) / 100
+
+	switch mode {
+	case AltitudeDisplayFeetFL:
+		if hundreds*100 >= transitionAltitude {
+			return fmt.Sprintf("FL%03d", hundreds)
+		}
+		return strconv.Itoa(hundreds * 100)
+
+	default:
+		return fmt.Sprintf("%03d", hundreds)
+	}
+}
+
 type STARSBrightness int
 
 func (b STARSBrightness) RGB() renderer.RGB {
@@ -348,6 +587,10 @@ func NewSTARSPane() *STARSPane {
 
 func (sp *STARSPane) DisplayName() string { return "STARS" }
 
+func (sp *STARSPane) Stats() []slog.Attr {
+	return []slog.Attr{slog.Any("wx_cache", sp.weatherRadar.CacheStats())}
+}
+
 func (sp *STARSPane) Hide() bool { return false }
 
 func (sp *STARSPane) Activate(r renderer.Renderer, p platform.Platform, eventStream *sim.EventStream, lg *log.Logger) {
@@ -380,6 +623,7 @@ func (sp *STARSPane) Activate(r renderer.Renderer, p platform.Platform, eventStr
 	sp.events = eventStream.Subscribe()
 
 	sp.weatherRadar.Activate(r, lg)
+	sp.wxOpacityLevel = 1
 
 	sp.lastTrackUpdate = time.Time{} // force immediate update at start
 	sp.lastHistoryTrackUpdate = time.Time{}
@@ -389,6 +633,17 @@ func (sp *STARSPane) Activate(r renderer.Renderer, p platform.Platform, eventStr
 	}
 
 	sp.capture.enabled = os.Getenv("VICE_CAPTURE") != ""
+
+	sp.dcbFocus = -1
+}
+
+func (sp *STARSPane) Deactivate() {
+	if sp.events != nil {
+		sp.events.Unsubscribe()
+		sp.events = nil
+	}
+
+	sp.weatherRadar.Deactivate()
 }
 
 func (sp *STARSPane) LoadedSim(client *sim.ControlClient, ss sim.State, pl platform.Platform, lg *log.Logger) {
@@ -420,6 +675,8 @@ func (sp *STARSPane) ResetSim(client *sim.ControlClient, ss sim.State, pl platfo
 	}
 	sp.TabListSearchStart = 0
 
+	sp.autoATIS, sp.autoATISMETAR = "", ""
+
 	// Update maps before resetting the prefs since we may rewrite some map
 	// ids and we want to use the right ones when we're enabling the
 	// default maps.
@@ -575,12 +832,82 @@ func (sp *STARSPane) DrawUI(p platform.Platform, config *platform.Config) {
 	imgui.SameLine()
 	imgui.RadioButtonInt("ARTS", &sp.FontSelection, fontARTS)
 
+	imgui.Text("Missing characters: ")
+	imgui.SameLine()
+	changed := imgui.RadioButtonInt("Other font", &sp.FontFallback, fontFallbackOtherSet)
+	imgui.SameLine()
+	changed = imgui.RadioButtonInt("Other font, then Roboto", &sp.FontFallback, fontFallbackOtherSetThenRoboto) || changed
+	imgui.SameLine()
+	changed = imgui.RadioButtonInt("Roboto", &sp.FontFallback, fontFallbackRoboto) || changed
+	imgui.SameLine()
+	changed = imgui.RadioButtonInt("None", &sp.FontFallback, fontFallbackNone) || changed
+	if changed {
+		sp.setFallbackFonts()
+	}
+
 	imgui.Checkbox("Auto track departures", &sp.AutoTrackDepartures)
 
 	imgui.Checkbox("Lock display", &sp.LockDisplay)
 
+	if imgui.Checkbox("Use pre-CWT wake turbulence weight classes", &sp.UseWeightClasses) {
+		sp.wakeCategoriesChanged = true
+	}
+
 	imgui.Checkbox("Invert numeric keypad", &sp.FlipNumericKeypad)
 
+	if imgui.Checkbox("Automatically generate ATIS", &sp.AutoATIS) {
+		// Generate it from the current weather next time.
+		sp.autoATIS, sp.autoATISMETAR = "", ""
+	}
+	if sp.AutoATIS && sp.autoATIS != "" {
+		text, _ := util.WrapText(sp.autoATIS, 80, 0, true)
+		imgui.Text(text)
+	}
+
+	imgui.Checkbox("Crossfade weather radar updates", &ps.WeatherCrossfade)
+
+	imgui.Checkbox("Display controller notes in datablocks", &ps.DisplayNotes)
+
+	imgui.Checkbox("Display estimated airspeed in datablocks", &ps.DisplayAirspeed)
+
+	if imgui.BeginComboV("Altitude display", ps.AltitudeDisplayMode.String(), imgui.ComboFlagsHeightLarge) {
+		for _, m := range []AltitudeDisplayMode{AltitudeDisplayHundreds, AltitudeDisplayFeetFL} {
+			if imgui.SelectableV(m.String(), m == ps.AltitudeDisplayMode, 0, imgui.Vec2{}) {
+				ps.AltitudeDisplayMode = m
+			}
+		}
+		imgui.EndCombo()
+	}
+
+	if imgui.BeginComboV("Color palette", ps.ColorPalette.String(), imgui.ComboFlagsHeightLarge) {
+		for c := range STARSNumPalettes {
+			if imgui.SelectableV(c.String(), c == ps.ColorPalette, 0, imgui.Vec2{}) {
+				ps.ColorPalette = c
+			}
+		}
+		imgui.EndCombo()
+	}
+
+	if imgui.BeginComboV("Visual CA/MSAW alerts", ps.VisualAlerts.String(), imgui.ComboFlagsHeightLarge) {
+		for _, m := range []VisualAlertMode{VisualAlertsOff, VisualAlertsWithAudio, VisualAlertsOnly} {
+			if imgui.SelectableV(m.String(), m == ps.VisualAlerts, 0, imgui.Vec2{}) {
+				ps.VisualAlerts = m
+			}
+		}
+		imgui.EndCombo()
+	}
+
+	imgui.Checkbox("Predictive conflict alerts", &ps.PredictiveCA)
+
+	if imgui.BeginComboV("Track history fade", ps.RadarTrackHistoryFade.String(), imgui.ComboFlagsHeightLarge) {
+		for _, c := range []HistoryFadeCurve{HistoryFadeLinear, HistoryFadeFast, HistoryFadeSlow} {
+			if imgui.SelectableV(c.String(), c == ps.RadarTrackHistoryFade, 0, imgui.Vec2{}) {
+				ps.RadarTrackHistoryFade = c
+			}
+		}
+		imgui.EndCombo()
+	}
+
 	if imgui.BeginComboV("TGT GEN Key", string(sp.TgtGenKey), imgui.ComboFlagsHeightLarge) {
 		for _, key := range []byte{';', ','} {
 			if imgui.SelectableV(string(key), key == sp.TgtGenKey, 0, imgui.Vec2{}) {
@@ -590,6 +917,35 @@ func (sp *STARSPane) DrawUI(p platform.Platform, config *platform.Config) {
 		imgui.EndCombo()
 	}
 
+	if sp.prefsFilename == "" {
+		sp.prefsFilename = "stars-prefs.json"
+		if d, err := os.UserHomeDir(); err == nil {
+			sp.prefsFilename = filepath.Join(d, sp.prefsFilename)
+		}
+	}
+	imgui.InputTextV("Preferences file", &sp.prefsFilename, 0, nil)
+	if imgui.Button("Export current preferences") {
+		if err := sp.exportPreferences(sp.prefsFilename); err != nil {
+			sp.prefsIOStatus = err.Error()
+		} else {
+			sp.prefsIOStatus = "Exported to " + sp.prefsFilename
+		}
+	}
+	imgui.SameLine()
+	if imgui.Button("Import preferences") {
+		if warning, err := sp.importPreferences(sp.prefsFilename, p); err != nil {
+			sp.prefsIOStatus = err.Error()
+		} else {
+			sp.prefsIOStatus = "Imported " + sp.prefSet.Current.Name
+			if warning != "" {
+				sp.previewAreaOutput = warning
+			}
+		}
+	}
+	if sp.prefsIOStatus != "" {
+		imgui.Text(sp.prefsIOStatus)
+	}
+
 	imgui.Checkbox("Enable additional sound effects", &config.AudioEnabled)
 
 	if !config.AudioEnabled {
@@ -606,6 +962,59 @@ func (sp *STARSPane) DrawUI(p platform.Platform, config *platform.Config) {
 		}
 	}
 
+	imgui.Text("Effect volume:")
+	for i := range AudioType(AudioNumTypes) {
+		if i == AudioTest {
+			continue
+		}
+		imgui.Text("  ")
+		imgui.SameLine()
+		vol := int32(ps.AudioEffectVolume[i])
+		if imgui.SliderInt(i.String()+"##volume", &vol, 0, 10) {
+			ps.AudioEffectVolume[i] = int(vol)
+			p.SetAudioEffectVolume(sp.audioEffects[i], int(vol))
+		}
+		if imgui.IsItemDeactivatedAfterEdit() {
+			sp.playOnce(p, i)
+		}
+	}
+
+	imgui.Text("Custom sound:")
+	imgui.Text("  ")
+	imgui.SameLine()
+	if imgui.BeginCombo("Effect##custom", sp.customAudioType.String()) {
+		for i := range AudioType(AudioNumTypes) {
+			if i != AudioTest && imgui.SelectableV(i.String(), i == sp.customAudioType, 0, imgui.Vec2{}) {
+				sp.customAudioType = i
+				sp.customAudioFilename = sp.CustomAudioFiles[i]
+			}
+		}
+		imgui.EndCombo()
+	}
+	imgui.Text("  ")
+	imgui.SameLine()
+	imgui.InputTextV("WAV or MP3 file", &sp.customAudioFilename, 0, nil)
+	imgui.Text("  ")
+	imgui.SameLine()
+	if imgui.Button("Use file") {
+		if err := sp.loadCustomAudio(p, sp.customAudioType, sp.customAudioFilename); err != nil {
+			sp.events.PostEvent(sim.Event{
+				Type:    sim.ErrorMessageEvent,
+				Message: fmt.Sprintf("Unable to use custom sound %s: %v", sp.customAudioFilename, err),
+			})
+		} else {
+			sp.CustomAudioFiles[sp.customAudioType] = sp.customAudioFilename
+			sp.playOnce(p, sp.customAudioType)
+		}
+	}
+	imgui.SameLine()
+	if imgui.Button("Restore default") {
+		p.StopPlayAudio(sp.audioEffects[sp.customAudioType])
+		sp.audioEffects[sp.customAudioType] = sp.builtinAudioEffects[sp.customAudioType]
+		delete(sp.CustomAudioFiles, sp.customAudioType)
+		sp.customAudioFilename = ""
+	}
+
 	if !config.AudioEnabled {
 		imgui.PopItemFlag()
 		imgui.PopStyleVar()
@@ -637,15 +1046,17 @@ func (sp *STARSPane) Draw(ctx *panes.Context, cb *renderer.CommandBuffer) {
 	ps := sp.currentPrefs()
 
 	// Clear to background color
-	cb.ClearRGB(ps.Brightness.BackgroundContrast.ScaleRGB(STARSBackgroundColor))
+	cb.ClearRGB(ps.Brightness.BackgroundContrast.ScaleRGB(sp.palette().Background))
 
 	sp.processKeyboardInput(ctx)
 
 	transforms := GetScopeTransformations(ctx.PaneExtent, ctx.ControlClient.MagneticVariation, ctx.ControlClient.NmPerLongitude,
 		ps.CurrentCenter, float32(ps.Range), 0)
 
+	sp.updateFloatingDCB(ctx)
+
 	scopeExtent := ctx.PaneExtent
-	if ps.DisplayDCB {
+	if ps.DisplayDCB && ps.DCBPosition != dcbPositionFloating {
 		scopeExtent = sp.drawDCB(ctx, transforms, cb)
 
 		// Update scissor for what's left and to protect the DCB (even
@@ -661,7 +1072,7 @@ func (sp *STARSPane) Draw(ctx *panes.Context, cb *renderer.CommandBuffer) {
 	sp.drawVideoMaps(ctx, transforms, cb)
 
 	sp.drawScenarioRoutes(ctx, transforms, sp.systemFont(ctx, ps.CharSize.Tools),
-		ps.Brightness.Lists.ScaleRGB(STARSListColor), cb)
+		ps.Brightness.Lists.ScaleRGB(sp.palette().List), cb)
 
 	sp.drawCRDARegions(ctx, transforms, cb)
 	sp.drawSelectedRoute(ctx, transforms, cb)
@@ -702,9 +1113,17 @@ func (sp *STARSPane) Draw(ctx *panes.Context, cb *renderer.CommandBuffer) {
 	if ctx.Mouse != nil {
 		sp.drawMouseCursor(ctx, scopeExtent, transforms, cb)
 	}
+	sp.drawVisualAlerts(ctx, scopeExtent, aircraft, transforms, cb)
+	if sp.testPatternActive {
+		sp.drawTestPattern(ctx, transforms, cb)
+	}
 	sp.handleCapture(ctx, transforms, cb)
 
 	sp.updateAudio(ctx, aircraft)
+	for _, msg := range sp.audioLoadErrors {
+		sp.events.PostEvent(sim.Event{Type: sim.ErrorMessageEvent, Message: msg})
+	}
+	sp.audioLoadErrors = nil
 
 	// Do this at the end of drawing so that we hold on to the tracks we
 	// have for rendering the current frame.
@@ -722,6 +1141,10 @@ func (sp *STARSPane) drawWX(ctx *panes.Context, transforms ScopeTransformations,
 	ps := sp.currentPrefs()
 	weatherBrightness := float32(ps.Brightness.Weather) / float32(100)
 	weatherContrast := float32(ps.Brightness.WxContrast) / float32(100)
+	var weatherOpacity [numWxLevels]float32
+	for i, o := range ps.WeatherLevelOpacity {
+		weatherOpacity[i] = float32(o) / float32(100)
+	}
 
 	if !sp.wxNextHistoryStepTime.IsZero() && ctx.Now.After(sp.wxNextHistoryStepTime) {
 		sp.wxHistoryDraw--
@@ -736,7 +1159,7 @@ func (sp *STARSPane) drawWX(ctx *panes.Context, transforms ScopeTransformations,
 	}
 
 	sp.weatherRadar.Draw(ctx, sp.wxHistoryDraw, weatherBrightness, weatherContrast, ps.DisplayWeatherLevel,
-		transforms, cb)
+		weatherOpacity, ps.WeatherCrossfade && !ctx.ReducedQuality, transforms, cb)
 }
 
 const numMapColors = 8
@@ -1043,7 +1466,7 @@ func (sp *STARSPane) drawCRDARegions(ctx *panes.Context, transforms ScopeTransfo
 				line, _ := region.GetLateralGeometry(ctx.ControlClient.NmPerLongitude, ctx.ControlClient.MagneticVariation)
 
 				ld := renderer.GetLinesDrawBuilder()
-				cb.SetRGB(ps.Brightness.OtherTracks.ScaleRGB(STARSGhostColor))
+				cb.SetRGB(ps.Brightness.OtherTracks.ScaleRGB(sp.palette().Ghost))
 				ld.AddLine(line[0], line[1])
 
 				ld.GenerateCommands(cb)
@@ -1055,7 +1478,7 @@ func (sp *STARSPane) drawCRDARegions(ctx *panes.Context, transforms ScopeTransfo
 				_, quad := region.GetLateralGeometry(ctx.ControlClient.NmPerLongitude, ctx.ControlClient.MagneticVariation)
 
 				ld := renderer.GetLinesDrawBuilder()
-				cb.SetRGB(ps.Brightness.OtherTracks.ScaleRGB(STARSGhostColor))
+				cb.SetRGB(ps.Brightness.OtherTracks.ScaleRGB(sp.palette().Ghost))
 				ld.AddLineLoop([][2]float32{quad[0], quad[1], quad[2], quad[3]})
 
 				ld.GenerateCommands(cb)
@@ -1083,7 +1506,7 @@ func (sp *STARSPane) drawMouseCursor(ctx *panes.Context, scopeExtent math.Extent
 	// STARS Operators Manual 4-74: FDB brightness is used for the cursor
 	ps := sp.currentPrefs()
 	cursorStyle := renderer.TextStyle{Font: sp.cursorsFont, Color: ps.Brightness.FullDatablocks.RGB()}
-	background := ps.Brightness.BackgroundContrast.ScaleRGB(STARSBackgroundColor)
+	background := ps.Brightness.BackgroundContrast.ScaleRGB(sp.palette().Background)
 	bgStyle := renderer.TextStyle{Font: sp.cursorsFont, Color: background}
 
 	draw := func(idx int, style renderer.TextStyle) {
@@ -1181,6 +1604,43 @@ func (sp *STARSPane) radarMode(radarSites map[string]*av.RadarSite) int {
 	}
 }
 
+// radarCanTrack returns whether a radar site that hasn't been failed by an
+// instructor can provide track updates for an aircraft at the given
+// position and altitude in the current radar mode.
+func (sp *STARSPane) radarCanTrack(ctx *panes.Context, p math.Point2LL, alt int) bool {
+	sites := ctx.ControlClient.RadarSites
+	seenBy := func(id string) bool {
+		pr, sec, _ := sites[id].CheckVisibility(p, alt)
+		return pr || sec
+	}
+
+	switch sp.radarMode(sites) {
+	case RadarModeSingle:
+		return !ctx.ControlClient.RadarSiteFailed(sp.currentPrefs().RadarSiteSelected)
+
+	case RadarModeFused:
+		// Fused tracks are available everywhere unless a failed site
+		// covers the aircraft; then it depends on the remaining sites.
+		degraded := false
+		for id := range sites {
+			if ctx.ControlClient.RadarSiteFailed(id) && seenBy(id) {
+				degraded = true
+				break
+			}
+		}
+		if !degraded {
+			return true
+		}
+	}
+
+	for id := range sites {
+		if !ctx.ControlClient.RadarSiteFailed(id) && seenBy(id) {
+			return true
+		}
+	}
+	return false
+}
+
 func (sp *STARSPane) visibleAircraft(ctx *panes.Context) []*av.Aircraft {
 	var aircraft []*av.Aircraft
 	ps := sp.currentPrefs()
@@ -1193,7 +1653,7 @@ func (sp *STARSPane) visibleAircraft(ctx *panes.Context) []*av.Aircraft {
 		}
 		// This includes the case of a spawned aircraft for which we don't
 		// yet have a radar track.
-		if state.LostTrack(now) {
+		if state.LostTrack(now) || state.TrackPosition().IsZero() {
 			continue
 		}
 
@@ -1286,11 +1746,133 @@ func (sp *STARSPane) initializeAudio(p platform.Platform, lg *log.Logger) {
 		sp.audioEffects[AudioInboundHandoff] = loadMP3("263124__pan14__sine-octaves-up-beep.mp3")
 		sp.audioEffects[AudioCommandError] = loadMP3("ERROR.mp3")
 		sp.audioEffects[AudioHandoffAccepted] = loadMP3("321104__nsstudios__blip2.mp3")
+
+		sp.builtinAudioEffects = util.DuplicateMap(sp.audioEffects)
+
+		if sp.CustomAudioFiles == nil {
+			sp.CustomAudioFiles = make(map[AudioType]string)
+		}
+		for _, a := range util.SortedMapKeys(sp.CustomAudioFiles) {
+			// Fall back to the built-in effect if there's a problem.
+			if err := sp.loadCustomAudio(p, a, sp.CustomAudioFiles[a]); err != nil {
+				lg.Warnf("%s: %v", sp.CustomAudioFiles[a], err)
+				sp.audioLoadErrors = append(sp.audioLoadErrors,
+					fmt.Sprintf("Unable to load custom %s sound: %v", a, err))
+			}
+		}
+	}
+}
+
+// Custom audio effects must be shorter than this.
+const maxCustomAudioDuration = 10 * time.Second
+
+// loadCustomAudio decodes the given WAV or MP3 file and, if it is
+// suitable, uses it for the given audio effect.
+func (sp *STARSPane) loadCustomAudio(p platform.Platform, a AudioType, filename string) error {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	var pcm []byte
+	var channels, rate int
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".wav":
+		if pcm, channels, rate, err = decodeWAV(b); err != nil {
+			return err
+		}
+	case ".mp3":
+		dec, data, err := minimp3.DecodeFull(b)
+		if err != nil {
+			return fmt.Errorf("unable to decode mp3: %w", err)
+		}
+		pcm, channels, rate = data, dec.Channels, dec.SampleRate
+	default:
+		return fmt.Errorf("%s: unsupported audio file type; must be WAV or MP3", filepath.Ext(filename))
+	}
+
+	if channels != 1 {
+		return fmt.Errorf("audio must be mono, not %d channels", channels)
+	}
+	if rate != platform.AudioSampleRate {
+		return fmt.Errorf("audio must be sampled at %d Hz, not %d Hz", platform.AudioSampleRate, rate)
+	}
+	if len(pcm) == 0 {
+		return fmt.Errorf("audio file is empty")
+	}
+	if d := time.Duration(len(pcm)/2) * time.Second / platform.AudioSampleRate; d > maxCustomAudioDuration {
+		return fmt.Errorf("audio is %s long; maximum is %s", d.Round(time.Millisecond), maxCustomAudioDuration)
+	}
+
+	p.StopPlayAudio(sp.audioEffects[a])
+	if idx, ok := sp.customAudioEffects[a]; ok {
+		if err := p.ReplacePCM(idx, pcm, rate); err != nil {
+			return err
+		}
+		sp.audioEffects[a] = idx
+		return nil
+	}
+
+	idx, err := p.AddPCM(pcm, rate)
+	if err != nil {
+		return err
+	}
+	if sp.customAudioEffects == nil {
+		sp.customAudioEffects = make(map[AudioType]int)
 	}
+	sp.customAudioEffects[a] = idx
+	sp.audioEffects[a] = idx
+	return nil
+}
+
+// decodeWAV returns the sample data from a 16-bit PCM WAV file along with
+// its number of channels and sample rate.
+func decodeWAV(b []byte) (pcm []byte, channels int, rate int, err error) {
+	if len(b) < 12 || string(b[0:4]) != "RIFF" || string(b[8:12]) != "WAVE" {
+		return nil, 0, 0, fmt.Errorf("not a WAV file")
+	}
+
+	haveFormat := false
+	for b = b[12:]; len(b) >= 8; {
+		id, size := string(b[0:4]), int(binary.LittleEndian.Uint32(b[4:8]))
+		b = b[8:]
+		if size > len(b) {
+			return nil, 0, 0, fmt.Errorf("truncated WAV file")
+		}
+		chunk := b[:size]
+
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return nil, 0, 0, fmt.Errorf("invalid WAV format chunk")
+			}
+			if format := binary.LittleEndian.Uint16(chunk[0:2]); format != 1 {
+				return nil, 0, 0, fmt.Errorf("WAV audio must be uncompressed PCM")
+			}
+			channels = int(binary.LittleEndian.Uint16(chunk[2:4]))
+			rate = int(binary.LittleEndian.Uint32(chunk[4:8]))
+			if bits := binary.LittleEndian.Uint16(chunk[14:16]); bits != 16 {
+				return nil, 0, 0, fmt.Errorf("WAV audio must be 16-bit, not %d-bit", bits)
+			}
+			haveFormat = true
+
+		case "data":
+			if !haveFormat {
+				return nil, 0, 0, fmt.Errorf("WAV data chunk precedes format chunk")
+			}
+			return chunk, channels, rate, nil
+		}
+
+		// Chunks are padded to an even number of bytes.
+		b = b[min(size+size%2, len(b)):]
+	}
+	return nil, 0, 0, fmt.Errorf("no audio data found in WAV file")
 }
 
 func (sp *STARSPane) playOnce(p platform.Platform, a AudioType) {
-	if sp.currentPrefs().AudioEffectEnabled[a] {
+	ps := sp.currentPrefs()
+	if ps.AudioEffectEnabled[a] {
+		p.SetAudioEffectVolume(sp.audioEffects[a], ps.AudioEffectVolume[a])
 		p.PlayAudioOnce(sp.audioEffects[a])
 	}
 }
@@ -1307,6 +1889,7 @@ func (sp *STARSPane) updateAudio(ctx *panes.Context, aircraft []*av.Aircraft) {
 
 	updateContinuous := func(play bool, effect AudioType) {
 		if ps.AudioEffectEnabled[effect] && play {
+			ctx.Platform.SetAudioEffectVolume(sp.audioEffects[effect], ps.AudioEffectVolume[effect])
 			ctx.Platform.StartPlayAudioContinuous(sp.audioEffects[effect])
 		} else {
 			ctx.Platform.StopPlayAudio(sp.audioEffects[effect])
@@ -1314,24 +1897,10 @@ func (sp *STARSPane) updateAudio(ctx *panes.Context, aircraft []*av.Aircraft) {
 	}
 
 	// Play the CA sound if any CAs or MSAWs are unacknowledged
-	playCASound := !ps.DisableCAWarnings && slices.ContainsFunc(sp.CAAircraft,
-		func(ca CAAircraft) bool {
-			return !ca.Acknowledged && !sp.Aircraft[ca.Callsigns[0]].DisableCAWarnings &&
-				!sp.Aircraft[ca.Callsigns[1]].DisableCAWarnings && ctx.Now.Before(ca.SoundEnd)
-		})
-	updateContinuous(playCASound, AudioConflictAlert)
-
-	playMSAWSound := !ps.DisableMSAW && func() bool {
-		for _, ac := range aircraft {
-			state := sp.Aircraft[ac.Callsign]
-			if state.MSAW && !state.MSAWAcknowledged && !state.InhibitMSAW && !state.DisableMSAW &&
-				ctx.Now.Before(state.MSAWSoundEnd) {
-				return true
-			}
-		}
-		return false
-	}()
-	updateContinuous(playMSAWSound, AudioMinimumSafeAltitudeWarning)
+	haveCA, haveMSAW := sp.unacknowledgedAlerts(aircraft, ctx.Now, true)
+	audioAlerts := ps.VisualAlerts != VisualAlertsOnly
+	updateContinuous(haveCA && audioAlerts, AudioConflictAlert)
+	updateContinuous(haveMSAW && audioAlerts, AudioMinimumSafeAltitudeWarning)
 
 	// 2-100: play sound if:
 	// - There is an unacknowledged SPC in a track's datablock
@@ -1350,6 +1919,157 @@ func (sp *STARSPane) updateAudio(ctx *panes.Context, aircraft []*av.Aircraft) {
 	updateContinuous(playSPCSound, AudioSquawkSPC)
 }
 
+// unacknowledgedAlerts reports whether there are any unacknowledged
+// conflict alerts and MSAWs. If forAudio is true, only alerts for which
+// the alert sound should still be playing are considered.
+func (sp *STARSPane) unacknowledgedAlerts(aircraft []*av.Aircraft, now time.Time, forAudio bool) (ca, msaw bool) {
+	ps := sp.currentPrefs()
+
+	ca = !ps.DisableCAWarnings && slices.ContainsFunc(sp.CAAircraft,
+		func(ca CAAircraft) bool {
+			return !ca.Acknowledged && !sp.Aircraft[ca.Callsigns[0]].DisableCAWarnings &&
+				!sp.Aircraft[ca.Callsigns[1]].DisableCAWarnings && (!forAudio || now.Before(ca.SoundEnd))
+		})
+
+	msaw = !ps.DisableMSAW && slices.ContainsFunc(aircraft,
+		func(ac *av.Aircraft) bool {
+			state := sp.Aircraft[ac.Callsign]
+			return state.MSAW && !state.MSAWAcknowledged && !state.InhibitMSAW && !state.DisableMSAW &&
+				(!forAudio || now.Before(state.MSAWSoundEnd))
+		})
+
+	return
+}
+
+// drawVisualAlerts draws a flashing border around the scope along with a
+// banner when there are unacknowledged conflict alerts or MSAWs, if
+// visual alerts are enabled.
+func (sp *STARSPane) drawVisualAlerts(ctx *panes.Context, scopeExtent math.Extent2D, aircraft []*av.Aircraft,
+	transforms ScopeTransformations, cb *renderer.CommandBuffer) {
+	ps := sp.currentPrefs()
+	if ps.VisualAlerts == VisualAlertsOff {
+		return
+	}
+
+	ca, msaw := sp.unacknowledgedAlerts(aircraft, ctx.Now, false)
+	if !ca && !msaw {
+		return
+	}
+
+	var alerts []string
+	if ca {
+		alerts = append(alerts, "CONFLICT ALERT")
+	}
+	if msaw {
+		alerts = append(alerts, "LOW ALTITUDE ALERT")
+	}
+
+	// Alternate between full and half intensity each half second.
+	color := ps.Brightness.Lists.ScaleRGB(sp.palette().TextAlert)
+	if halfSeconds := ctx.Now.UnixMilli() / 500; halfSeconds&1 == 0 {
+		color = color.Scale(0.5)
+	}
+
+	trid := renderer.GetTrianglesDrawBuilder()
+	defer renderer.ReturnTrianglesDrawBuilder(trid)
+	td := renderer.GetTextDrawBuilder()
+	defer renderer.ReturnTextDrawBuilder(td)
+
+	w, h := scopeExtent.Width(), scopeExtent.Height()
+	b := 6 * ctx.DrawPixelScale // border width
+	trid.AddQuad([2]float32{0, 0}, [2]float32{w, 0}, [2]float32{w, b}, [2]float32{0, b})
+	trid.AddQuad([2]float32{0, h - b}, [2]float32{w, h - b}, [2]float32{w, h}, [2]float32{0, h})
+	trid.AddQuad([2]float32{0, 0}, [2]float32{b, 0}, [2]float32{b, h}, [2]float32{0, h})
+	trid.AddQuad([2]float32{w - b, 0}, [2]float32{w, 0}, [2]float32{w, h}, [2]float32{w - b, h})
+
+	font := sp.systemFont(ctx, 5)
+	td.AddTextCentered(strings.Join(alerts, "  "), [2]float32{w / 2, h - b - float32(font.Size)},
+		renderer.TextStyle{
+			Font:            font,
+			Color:           color,
+			DrawBackground:  true,
+			BackgroundColor: renderer.RGB{},
+		})
+
+	transforms.LoadWindowViewingMatrices(cb)
+	cb.SetRGB(color)
+	trid.GenerateCommands(cb)
+	td.GenerateCommands(cb)
+}
+
+// drawTestPattern covers the entire pane with a pattern for checking
+// display alignment, grayscale response, and color: a grid with a center
+// crosshair and circles, an 11-step gray ramp, and a set of color bars.
+func (sp *STARSPane) drawTestPattern(ctx *panes.Context, transforms ScopeTransformations, cb *renderer.CommandBuffer) {
+	ctrid := renderer.GetColoredTrianglesDrawBuilder()
+	defer renderer.ReturnColoredTrianglesDrawBuilder(ctrid)
+	ld := renderer.GetLinesDrawBuilder()
+	defer renderer.ReturnLinesDrawBuilder(ld)
+	td := renderer.GetTextDrawBuilder()
+	defer renderer.ReturnTextDrawBuilder(td)
+
+	w, h := ctx.PaneExtent.Width(), ctx.PaneExtent.Height()
+	quad := func(x0, y0, x1, y1 float32, c renderer.RGB) {
+		ctrid.AddQuad([2]float32{x0, y0}, [2]float32{x1, y0}, [2]float32{x1, y1}, [2]float32{x0, y1}, c)
+	}
+
+	// Black background
+	quad(0, 0, w, h, renderer.RGB{})
+
+	// Gray ramp from black to white across the upper part of the screen.
+	const grayLevels = 11
+	for i := range grayLevels {
+		v := float32(i) / (grayLevels - 1)
+		x0, x1 := w*float32(i)/grayLevels, w*float32(i+1)/grayLevels
+		quad(x0, 0.65*h, x1, 0.8*h, renderer.RGB{R: v, G: v, B: v})
+	}
+
+	// Color bars along the bottom.
+	bars := []renderer.RGB{
+		{R: 1, G: 1, B: 1}, {R: 1, G: 1, B: 0}, {R: 0, G: 1, B: 1}, {R: 0, G: 1, B: 0},
+		{R: 1, G: 0, B: 1}, {R: 1, G: 0, B: 0}, {R: 0, G: 0, B: 1},
+	}
+	for i, c := range bars {
+		x0, x1 := w*float32(i)/float32(len(bars)), w*float32(i+1)/float32(len(bars))
+		quad(x0, 0.2*h, x1, 0.35*h, c)
+	}
+
+	// Alignment grid, border, center crosshair, and circles that should
+	// appear round if the display's aspect ratio is correct.
+	const gridCells = 10
+	for i := 1; i < gridCells; i++ {
+		x, y := w*float32(i)/gridCells, h*float32(i)/gridCells
+		ld.AddLine([2]float32{x, 0}, [2]float32{x, h})
+		ld.AddLine([2]float32{0, y}, [2]float32{w, y})
+	}
+	ld.AddLineLoop([][2]float32{{1, 1}, {w - 1, 1}, {w - 1, h - 1}, {1, h - 1}})
+	ld.AddLine([2]float32{0, 0}, [2]float32{w, h})
+	ld.AddLine([2]float32{0, h}, [2]float32{w, 0})
+	center := [2]float32{w / 2, h / 2}
+	r := math.Min(w, h)
+	ld.AddCircle(center, 0.15*r, 90)
+	ld.AddCircle(center, 0.3*r, 180)
+	ld.AddCircle(center, 0.45*r, 270)
+
+	ps := sp.currentPrefs()
+	font := sp.systemFont(ctx, ps.CharSize.Tools)
+	td.AddTextCentered("TEST PATTERN - PRESS ANY KEY TO EXIT", [2]float32{w / 2, 0.5*h + 2*float32(font.Size)},
+		renderer.TextStyle{
+			Font:            font,
+			Color:           renderer.RGB{R: 1, G: 1, B: 1},
+			DrawBackground:  true,
+			BackgroundColor: renderer.RGB{},
+		})
+
+	// Cover the DCB as well as the scope.
+	cb.SetScissorBounds(ctx.PaneExtent, ctx.Platform.FramebufferSize()[1]/ctx.Platform.DisplaySize()[1])
+	transforms.LoadWindowViewingMatrices(cb)
+	ctrid.GenerateCommands(cb)
+	cb.SetRGB(renderer.RGB{R: 0.75, G: 0.75, B: 0.75})
+	ld.GenerateCommands(cb)
+	td.GenerateCommands(cb)
+}
+
 func (sp *STARSPane) handleCapture(ctx *panes.Context, transforms ScopeTransformations, cb *renderer.CommandBuffer) {
 	if !sp.capture.enabled {
 		return
@@ -1365,46 +2085,16 @@ func (sp *STARSPane) handleCapture(ctx *panes.Context, transforms ScopeTransform
 		y := int(math.Min(p0[1], p1[1]))
 		w := int(math.Max(p0[0], p1[0])) - x
 		h := int(math.Max(p0[1], p1[1])) - y
-		px := ctx.Renderer.ReadPixelRGBAs(x, y, w, h)
-
-		// Flip in y
-		for i := range h / 2 {
-			for j := range 4 * w {
-				a, b := 4*w*i+j, 4*w*(h-1-i)+j
-				px[a], px[b] = px[b], px[a]
-			}
-		}
-		// Alpha to 1
-		for i := range h {
-			for j := range w {
-				px[4*w*i+4*j+3] = 255
-			}
-		}
-
-		return &image.RGBA{
-			Pix:    px,
-			Stride: 4 * w,
-			Rect: image.Rectangle{
-				Min: image.Point{X: x, Y: y},
-				Max: image.Point{X: x + w, Y: y + h},
-			},
-		}
+		return renderer.ReadImage(ctx.Renderer, x, y, w, h)
 	}
 
 	if sp.capture.doStill && sp.capture.haveRegion {
 		fn := "capture.png"
 		if d, err := os.UserHomeDir(); err == nil {
-			fn = d + "/" + fn
+			fn = filepath.Join(d, fn)
 		}
-		w, err := os.Create(fn)
-		if err != nil {
+		if err := renderer.WritePNG(fn, readPixels()); err != nil {
 			fmt.Fprintf(os.Stderr, "%v\n", err)
-		} else {
-			img := readPixels()
-			if err = png.Encode(w, img); err != nil {
-				fmt.Fprintf(os.Stderr, "%v\n", err)
-			}
-			w.Close()
 		}
 		sp.capture.doStill = false
 	} else if sp.capture.doVideo && sp.capture.haveRegion {
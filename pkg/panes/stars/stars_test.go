@@ -0,0 +1,616 @@
+// pkg/panes/stars/stars_test.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package stars
+
+import (
+	"bytes"
+	"encoding/binary"
+	gomath "math"
+	"strconv"
+	"testing"
+	"time"
+
+	av "github.com/mmp/vice/pkg/aviation"
+	"github.com/mmp/vice/pkg/math"
+	"github.com/mmp/vice/pkg/panes"
+	"github.com/mmp/vice/pkg/renderer"
+	"github.com/mmp/vice/pkg/sim"
+)
+
+// The resources that the aviation package loads when it is initialized
+// are found through the resources symlink in this directory, since it's
+// too far from the top-level resources directory for them to be found
+// otherwise and initialization happens before any test code runs.
+
+func TestFormatAltitude(t *testing.T) {
+	type testcase struct {
+		alt  int
+		mode AltitudeDisplayMode
+		s    string
+	}
+	for _, test := range []testcase{
+		testcase{alt: 4500, mode: AltitudeDisplayHundreds, s: "045"},
+		testcase{alt: 17949, mode: AltitudeDisplayHundreds, s: "179"},
+		testcase{alt: 25000, mode: AltitudeDisplayHundreds, s: "250"},
+		testcase{alt: 4500, mode: AltitudeDisplayFeetFL, s: "4500"},
+		testcase{alt: 4460, mode: AltitudeDisplayFeetFL, s: "4500"},
+		testcase{alt: 17900, mode: AltitudeDisplayFeetFL, s: "17900"},
+		testcase{alt: 17949, mode: AltitudeDisplayFeetFL, s: "17900"},
+		testcase{alt: 17950, mode: AltitudeDisplayFeetFL, s: "FL180"},
+		testcase{alt: transitionAltitude - 1, mode: AltitudeDisplayFeetFL, s: "FL180"},
+		testcase{alt: transitionAltitude, mode: AltitudeDisplayFeetFL, s: "FL180"},
+		testcase{alt: 25000, mode: AltitudeDisplayFeetFL, s: "FL250"},
+	} {
+		if s := formatAltitude(test.alt, test.mode); s != test.s {
+			t.Errorf("formatAltitude(%d, %s) = %q; expected %q", test.alt, test.mode, s, test.s)
+		}
+	}
+}
+
+func TestLostTrack(t *testing.T) {
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	var state AircraftState
+	if state.LostTrack(start.Add(time.Hour)) {
+		t.Errorf("track reported lost without ever having a radar return")
+	}
+
+	state.track = av.RadarTrack{Position: math.Point2LL{-73.78, 40.64}, Time: start}
+
+	// Default 30s timeout
+	if state.LostTrack(start.Add(30 * time.Second)) {
+		t.Errorf("track lost at 30s with the default timeout")
+	}
+	if !state.LostTrack(start.Add(31 * time.Second)) {
+		t.Errorf("track not lost after 31s with the default timeout")
+	}
+
+	// Adapted timeout
+	state.lostTrackTimeout = 90 * time.Second
+	for now := start; now.Sub(start) <= 2*time.Minute; now = now.Add(5 * time.Second) {
+		lost := now.Sub(start) > state.lostTrackTimeout
+		if state.LostTrack(now) != lost {
+			t.Errorf("at %s: LostTrack() returned %v; expected %v", now.Sub(start), !lost, lost)
+		}
+	}
+}
+
+func TestLostTrackTimeout(t *testing.T) {
+	fa := sim.STARSFacilityAdaptation{CoastSuspendTimeout: 30, SingleSiteCoastSuspendTimeout: 60}
+	for _, test := range []struct {
+		mode    int
+		timeout time.Duration
+	}{
+		{mode: RadarModeFused, timeout: 30 * time.Second},
+		{mode: RadarModeSingle, timeout: 60 * time.Second},
+		{mode: RadarModeMulti, timeout: 60 * time.Second},
+	} {
+		if timeout := lostTrackTimeout(&fa, test.mode); timeout != test.timeout {
+			t.Errorf("radar mode %d: got timeout %s; expected %s", test.mode, timeout, test.timeout)
+		}
+	}
+
+	// A track that has gone 45s without a return is lost in FUSED mode
+	// but not with a single site.
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	state := AircraftState{track: av.RadarTrack{Position: math.Point2LL{-73.78, 40.64}, Time: start}}
+	state.lostTrackTimeout = lostTrackTimeout(&fa, RadarModeFused)
+	if !state.LostTrack(start.Add(45 * time.Second)) {
+		t.Errorf("track not lost after 45s in FUSED mode")
+	}
+	state.lostTrackTimeout = lostTrackTimeout(&fa, RadarModeSingle)
+	if state.LostTrack(start.Add(45 * time.Second)) {
+		t.Errorf("track lost after 45s in single-site mode")
+	}
+}
+
+func TestScratchpadHistory(t *testing.T) {
+	var state AircraftState
+	if _, ok := state.popScratchpadHistory(); ok {
+		t.Errorf("pop from empty history succeeded")
+	}
+
+	// Push more than the ring buffer holds; the oldest should be dropped.
+	pushed := []string{"A", "B", "C", "D", "E", "F", "G"}
+	for _, s := range pushed {
+		state.pushScratchpadHistory(s)
+	}
+	for i := len(pushed) - 1; i >= len(pushed)-len(state.scratchpadHistory); i-- {
+		if s, ok := state.peekScratchpadHistory(); !ok || s != pushed[i] {
+			t.Errorf("peekScratchpadHistory() = %q, %v; expected %q, true", s, ok, pushed[i])
+		}
+		if s, ok := state.popScratchpadHistory(); !ok || s != pushed[i] {
+			t.Errorf("popScratchpadHistory() = %q, %v; expected %q, true", s, ok, pushed[i])
+		}
+	}
+	if s, ok := state.popScratchpadHistory(); ok {
+		t.Errorf("popScratchpadHistory() = %q after history was exhausted", s)
+	}
+}
+
+func makeWAV(format, channels, bits uint16, rate uint32, data []byte) []byte {
+	var b bytes.Buffer
+	b.WriteString("RIFF")
+	binary.Write(&b, binary.LittleEndian, uint32(4+8+16+8+len(data)))
+	b.WriteString("WAVE")
+
+	b.WriteString("fmt ")
+	binary.Write(&b, binary.LittleEndian, uint32(16))
+	binary.Write(&b, binary.LittleEndian, format)
+	binary.Write(&b, binary.LittleEndian, channels)
+	binary.Write(&b, binary.LittleEndian, rate)
+	binary.Write(&b, binary.LittleEndian, rate*uint32(channels*bits/8)) // byte rate
+	binary.Write(&b, binary.LittleEndian, channels*bits/8)              // block align
+	binary.Write(&b, binary.LittleEndian, bits)
+
+	b.WriteString("data")
+	binary.Write(&b, binary.LittleEndian, uint32(len(data)))
+	b.Write(data)
+	return b.Bytes()
+}
+
+func TestDecodeWAV(t *testing.T) {
+	data := []byte{1, 2, 3, 4, 5, 6}
+
+	pcm, channels, rate, err := decodeWAV(makeWAV(1, 1, 16, 44100, data))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(pcm, data) || channels != 1 || rate != 44100 {
+		t.Errorf("got %v %d %d, expected %v 1 44100", pcm, channels, rate, data)
+	}
+
+	type testcase struct {
+		name string
+		wav  []byte
+	}
+	for _, tc := range []testcase{
+		{name: "not a WAV", wav: []byte("ID3 this is an mp3")},
+		{name: "compressed", wav: makeWAV(3, 1, 16, 44100, data)},
+		{name: "8-bit", wav: makeWAV(1, 1, 8, 44100, data)},
+		{name: "truncated", wav: makeWAV(1, 1, 16, 44100, data)[:40]},
+	} {
+		if _, _, _, err := decodeWAV(tc.wav); err == nil {
+			t.Errorf("%s: expected error", tc.name)
+		}
+	}
+}
+
+func TestHistoryTrackColors(t *testing.T) {
+	keys := starsPalettes[STARSPaletteStandard].TrackHistory[:]
+
+	// Up to the number of key colors, a linear fade gives the key colors
+	// in order.
+	for n := range len(keys) + 1 {
+		colors := historyTrackColors(keys, n, HistoryFadeLinear, 100)
+		if len(colors) != n {
+			t.Fatalf("got %d colors, expected %d", len(colors), n)
+		}
+		for i := range colors {
+			if !colors[i].Equals(keys[i]) {
+				t.Errorf("n=%d: color %d = %v, expected %v", n, i, colors[i], keys[i])
+			}
+		}
+	}
+
+	// With more dots, they span the key colors and are all distinct.
+	for _, fade := range []HistoryFadeCurve{HistoryFadeLinear, HistoryFadeFast, HistoryFadeSlow} {
+		colors := historyTrackColors(keys, 10, fade, 100)
+		if !colors[0].Equals(keys[0]) || !colors[9].Equals(keys[len(keys)-1]) {
+			t.Errorf("%s: endpoints %v, %v; expected %v, %v", fade, colors[0], colors[9], keys[0], keys[len(keys)-1])
+		}
+		for i := 1; i < len(colors); i++ {
+			if colors[i].Equals(colors[i-1]) {
+				t.Errorf("%s: colors %d and %d are both %v", fade, i-1, i, colors[i])
+			}
+		}
+	}
+
+	// Brightness scales the result.
+	if c := historyTrackColors(keys, 1, HistoryFadeLinear, 50)[0]; !c.Equals(keys[0].Scale(0.5)) {
+		t.Errorf("got %v at 50 brightness, expected %v", c, keys[0].Scale(0.5))
+	}
+}
+
+func TestPredictedBelowMVA(t *testing.T) {
+	const nmPerLongitude = 45.6
+	ring := [][2]float32{{-74, 40}, {-73, 40}, {-73, 41}, {-74, 41}, {-74, 40}}
+	mvas := []av.MVA{av.MVA{
+		MinimumLimit: 3000,
+		Bounds:       math.Extent2DFromPoints(ring),
+		ExteriorRing: ring,
+	}}
+
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	// Level at 2500' and heading east at 240 knots toward the MVA, about
+	// 0.9nm (roughly 14 seconds) away.
+	var level AircraftState
+	level.previousTrack = av.RadarTrack{Position: math.Point2LL{-74.0273, 40.5}, Altitude: 2500, Groundspeed: 240, Time: start}
+	level.track = av.RadarTrack{Position: math.Point2LL{-74.02, 40.5}, Altitude: 2500, Groundspeed: 240, Time: start.Add(5 * time.Second)}
+	if !level.PredictedBelowMVA(mvas, 30, nmPerLongitude, 0) {
+		t.Errorf("expected predicted MSAW entering the MVA below its minimum altitude")
+	}
+	if level.PredictedBelowMVA(mvas, 10, nmPerLongitude, 0) {
+		t.Errorf("unexpected predicted MSAW with a lookahead shorter than the time to the MVA")
+	}
+	if level.PredictedBelowMVA(mvas, 0, nmPerLongitude, 0) {
+		t.Errorf("unexpected predicted MSAW with no lookahead")
+	}
+
+	// Inside the MVA at 3500' but descending at 40 feet per second.
+	var descending AircraftState
+	descending.previousTrack = av.RadarTrack{Position: math.Point2LL{-73.5073, 40.5}, Altitude: 3700, Groundspeed: 240, Time: start}
+	descending.track = av.RadarTrack{Position: math.Point2LL{-73.5, 40.5}, Altitude: 3500, Groundspeed: 240, Time: start.Add(5 * time.Second)}
+	if !descending.PredictedBelowMVA(mvas, 30, nmPerLongitude, 0) {
+		t.Errorf("expected predicted MSAW when descending below the minimum altitude")
+	}
+	if descending.PredictedBelowMVA(mvas, 10, nmPerLongitude, 0) {
+		t.Errorf("unexpected predicted MSAW before descending below the minimum altitude")
+	}
+
+	// No previous track, so no course to project along.
+	var single AircraftState
+	single.track = level.track
+	if single.PredictedBelowMVA(mvas, 30, nmPerLongitude, 0) {
+		t.Errorf("unexpected predicted MSAW without a previous track")
+	}
+}
+
+func TestVerticalSeparationLost(t *testing.T) {
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	// makeState returns a track that climbed (or descended) from alt0 to
+	// alt1 over the past 5 seconds.
+	makeState := func(alt0, alt1 int) *AircraftState {
+		return &AircraftState{
+			previousTrack: av.RadarTrack{Position: math.Point2LL{-73.5, 40.5}, Altitude: alt0, Time: start},
+			track:         av.RadarTrack{Position: math.Point2LL{-73.5, 40.5}, Altitude: alt1, Time: start.Add(5 * time.Second)},
+		}
+	}
+
+	type testcase struct {
+		name      string
+		a, b      *AircraftState
+		lookahead int
+		lost      bool
+	}
+	for _, tc := range []testcase{
+		{name: "level, separated", a: makeState(5000, 5000), b: makeState(4000, 4000), lookahead: 30, lost: false},
+		{name: "level, not separated", a: makeState(5000, 5000), b: makeState(4500, 4500), lookahead: 30, lost: true},
+		// 1500' apart, closing at 1500'/min (25'/s): within 1000' in 20s.
+		{name: "converging", a: makeState(5625, 5500), b: makeState(4000, 4000), lookahead: 30, lost: true},
+		{name: "converging, short lookahead", a: makeState(5625, 5500), b: makeState(4000, 4000), lookahead: 15, lost: false},
+		{name: "converging, not predictive", a: makeState(5625, 5500), b: makeState(4000, 4000), lookahead: 0, lost: false},
+		// Both moving toward each other quickly enough to cross.
+		{name: "crossing", a: makeState(6500, 6000), b: makeState(4000, 4500), lookahead: 30, lost: true},
+		{name: "diverging", a: makeState(5500, 5625), b: makeState(4000, 4000), lookahead: 30, lost: false},
+	} {
+		if lost := verticalSeparationLost(tc.a, tc.b, tc.lookahead); lost != tc.lost {
+			t.Errorf("%s: got %v, expected %v", tc.name, lost, tc.lost)
+		}
+		if lost := verticalSeparationLost(tc.b, tc.a, tc.lookahead); lost != tc.lost {
+			t.Errorf("%s (swapped): got %v, expected %v", tc.name, lost, tc.lost)
+		}
+	}
+}
+
+func TestVideoMapPresets(t *testing.T) {
+	sp := &STARSPane{
+		prefSet:      &PreferenceSet{Current: *makeDefaultPreferences()},
+		allVideoMaps: []av.VideoMap{av.VideoMap{Id: 1}, av.VideoMap{Id: 2}, av.VideoMap{Id: 3}},
+	}
+	ps := sp.currentPrefs()
+
+	ps.VideoMapVisible[1] = nil
+	ps.VideoMapVisible[3] = nil
+	if n := sp.saveVideoMapPreset("ARR"); n != 2 {
+		t.Errorf("saved %d maps, expected 2", n)
+	}
+
+	clear(ps.VideoMapVisible)
+	ps.VideoMapVisible[2] = nil
+	if n, ignored, err := sp.recallVideoMapPreset("ARR"); err != nil || n != 2 || ignored != 0 {
+		t.Errorf("recall got %d, %d, %v; expected 2, 0, nil", n, ignored, err)
+	}
+	if _, ok := ps.VideoMapVisible[2]; ok || len(ps.VideoMapVisible) != 2 {
+		t.Errorf("unexpected visible maps after recall: %v", ps.VideoMapVisible)
+	}
+
+	// Map 3 isn't available in the new scenario.
+	sp.allVideoMaps = sp.allVideoMaps[:2]
+	if n, ignored, err := sp.recallVideoMapPreset("ARR"); err != nil || n != 1 || ignored != 1 {
+		t.Errorf("recall got %d, %d, %v; expected 1, 1, nil", n, ignored, err)
+	}
+
+	if _, _, err := sp.recallVideoMapPreset("DEP"); err == nil {
+		t.Errorf("expected error recalling a preset that wasn't saved")
+	}
+}
+
+// constantWind is a WindModel with the same wind everywhere.
+type constantWind [2]float32 // nm per second
+
+func (w constantWind) GetWindVector(p math.Point2LL, alt float32) math.Point2LL {
+	return math.Point2LL(w)
+}
+func (w constantWind) AverageWindVector() [2]float32 { return math.Scale2f(w, 3600) }
+
+func TestFormatAirspeed(t *testing.T) {
+	const nmPerLongitude = 45.6
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	// makeState returns a track heading north at the given groundspeed
+	// and altitude.
+	makeState := func(gs, alt int) *AircraftState {
+		return &AircraftState{
+			previousTrack: av.RadarTrack{Position: math.Point2LL{-73.5, 40.5}, Altitude: alt, Groundspeed: gs, Time: start},
+			track:         av.RadarTrack{Position: math.Point2LL{-73.5, 40.51}, Altitude: alt, Groundspeed: gs, Time: start.Add(5 * time.Second)},
+		}
+	}
+	calm := constantWind{}
+	headwind := constantWind{0, -50. / 3600} // 50 knots out of the north
+
+	type testcase struct {
+		name   string
+		state  *AircraftState
+		wind   constantWind
+		expect string
+	}
+	for _, tc := range []testcase{
+		{name: "calm", state: makeState(250, 5000), wind: calm, expect: "T250"},
+		{name: "headwind", state: makeState(300, 10000), wind: headwind, expect: "T350"},
+		{name: "flight level", state: makeState(420, 35000), wind: headwind, expect: "T470 M.82"},
+		{name: "no heading", state: &AircraftState{track: av.RadarTrack{Altitude: 5000, Groundspeed: 180}}, wind: headwind, expect: "T180"},
+	} {
+		if s := formatAirspeed(tc.state, tc.wind, nmPerLongitude); s != tc.expect {
+			t.Errorf("%s: got %q, expected %q", tc.name, s, tc.expect)
+		}
+	}
+}
+
+func TestMinSepText(t *testing.T) {
+	for _, test := range []struct {
+		current, cpa, tmin float32
+		expect             string
+	}{
+		{current: 8, cpa: 1.234, tmin: 1.5, expect: "1.23NM\nCPA 1:30\nNOW 8.00NM"},
+		{current: 3.5, cpa: 0, tmin: 0.99, expect: "0.00NM\nCPA 0:59\nNOW 3.50NM"},
+		{current: 12.25, cpa: 12.25, tmin: -2, expect: "NO XING\n12.25NM"},
+	} {
+		if s := minSepText(test.current, test.cpa, test.tmin); s != test.expect {
+			t.Errorf("minSepText(%v, %v, %v) = %q, expected %q", test.current, test.cpa, test.tmin, s, test.expect)
+		}
+	}
+}
+
+func TestTPASizeMinutes(t *testing.T) {
+	state := &AircraftState{track: av.RadarTrack{Groundspeed: 240}}
+
+	state.JRingRadius = 3
+	if r := state.JRingRadiusNM(); r != 3 {
+		t.Errorf("J-ring radius %f, expected 3", r)
+	}
+	state.JRingMinutes = true
+	if r := state.JRingRadiusNM(); r != 12 {
+		t.Errorf("J-ring radius %f, expected 12 at 240 knots", r)
+	}
+
+	state.ConeLength, state.ConeMinutes = 1.5, true
+	state.track.Groundspeed = 180
+	if l := state.ConeLengthNM(); l != 4.5 {
+		t.Errorf("cone length %f, expected 4.5 at 180 knots", l)
+	}
+}
+
+func TestPredictATPAStatus(t *testing.T) {
+	params := sim.ATPAParameters{WarningLookahead: 45, AlertLookahead: 24, MonitorDistance: 2}
+	// The threshold is far enough away that neither aircraft slows down.
+	threshold := [2]float32{0, 1000}
+	front := ModeledAircraft{p: [2]float32{0, 0}, v: [2]float32{0, 1}, threshold: threshold}
+
+	for _, test := range []struct {
+		dist   float32 // initial in-trail distance
+		expect ATPAStatus
+	}{
+		// The trailing aircraft closes at 1nm/s and 3nm is required, so
+		// separation is lost after dist-3 seconds.
+		{dist: 48.5, expect: ATPAStatusMonitor},
+		{dist: 47.5, expect: ATPAStatusWarning},
+		{dist: 28.5, expect: ATPAStatusWarning},
+		{dist: 27.5, expect: ATPAStatusAlert},
+		{dist: 3.5, expect: ATPAStatusAlert},
+	} {
+		back := ModeledAircraft{p: [2]float32{0, -test.dist}, v: [2]float32{0, 1}, gs: 3600, threshold: threshold}
+		if s := predictATPAStatus(front, back, 3, params); s != test.expect {
+			t.Errorf("in-trail %.1fnm: got status %d, expected %d", test.dist, s, test.expect)
+		}
+	}
+
+	// Shorter lookaheads should move the boundaries accordingly.
+	params = sim.ATPAParameters{WarningLookahead: 30, AlertLookahead: 10, MonitorDistance: 2}
+	for _, test := range []struct {
+		dist   float32
+		expect ATPAStatus
+	}{
+		{dist: 33.5, expect: ATPAStatusMonitor},
+		{dist: 32.5, expect: ATPAStatusWarning},
+		{dist: 14.5, expect: ATPAStatusWarning},
+		{dist: 13.5, expect: ATPAStatusAlert},
+	} {
+		back := ModeledAircraft{p: [2]float32{0, -test.dist}, v: [2]float32{0, 1}, gs: 3600, threshold: threshold}
+		if s := predictATPAStatus(front, back, 3, params); s != test.expect {
+			t.Errorf("in-trail %.1fnm: got status %d with shorter lookahead, expected %d", test.dist, s, test.expect)
+		}
+	}
+}
+
+func TestGhostDatablockCWT(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	const nmPerLongitude = 45
+	isect := math.Point2LL{-73.78, 40.64}
+	regions := [2]*av.ApproachRegion{
+		&av.ApproachRegion{ReferenceLineHeading: 40, ReferenceLineLength: 20, ReferencePoint: isect,
+			NearDistance: 1, RegionLength: 15, NearHalfWidth: 1, FarHalfWidth: 3},
+		&av.ApproachRegion{ReferenceLineHeading: 310, ReferenceLineLength: 20, ReferencePoint: isect,
+			NearDistance: 1, RegionLength: 15, NearHalfWidth: 1, FarHalfWidth: 3},
+	}
+	// Both aircraft are on the first runway's approach.
+	p := math.NM2LL(math.Scale2f(math.Add2f(regions[0].NearPoint(nmPerLongitude, 0),
+		regions[0].FarPoint(nmPerLongitude, 0)), 0.5), nmPerLongitude)
+
+	sp := &STARSPane{
+		prefSet: &PreferenceSet{Current: *makeDefaultPreferences()},
+		Aircraft: map[string]*AircraftState{
+			"AAL1": &AircraftState{CWTCategory: "F"},
+			"N123": &AircraftState{CWTCategory: "I"},
+		},
+		ConvergingRunways: []STARSConvergingRunways{
+			STARSConvergingRunways{
+				ConvergingRunways: av.ConvergingRunways{
+					Runways:            [2]string{"4R", "31R"},
+					StaggerSymbol:      "*",
+					TieSymbol:          "+",
+					TieOffset:          1,
+					RunwayIntersection: isect,
+				},
+				ApproachRegions: regions,
+			},
+		},
+	}
+	var aircraft []*av.Aircraft
+	for _, callsign := range []string{"AAL1", "N123"} {
+		state := sp.Aircraft[callsign]
+		state.Ghost.PartialDatablock = true
+		state.track = av.RadarTrack{Position: p, Altitude: 3000, Groundspeed: 180, Time: now}
+		aircraft = append(aircraft, &av.Aircraft{
+			Callsign: callsign,
+			Nav:      av.Nav{FlightState: av.FlightState{NmPerLongitude: nmPerLongitude}},
+		})
+	}
+	ctx := &panes.Context{ControlClient: &sim.ControlClient{State: sim.State{SimTime: now}}}
+
+	text := func(f []dbChar) string {
+		var s []rune
+		for _, ch := range f {
+			if ch.ch != 0 {
+				s = append(s, ch.ch)
+			}
+		}
+		return string(s)
+	}
+	rules := map[string]string{"AAL1": " ", "N123": "V"}
+
+	// The ghost's datablock should show the parent aircraft's CWT
+	// category regardless of whether it's from a stagger or a tie runway
+	// pair.
+	ps := sp.currentPrefs()
+	ps.CRDA.ForceAllGhosts = true
+	for _, mode := range []CRDAMode{CRDAModeStagger, CRDAModeTie} {
+		ps.CRDA.RunwayPairState = []CRDARunwayPairState{
+			CRDARunwayPairState{Enabled: true, Mode: mode, RunwayState: [2]CRDARunwayState{{Enabled: true}, {}}},
+		}
+
+		ghosts := sp.getGhostAircraft(aircraft, ctx)
+		if len(ghosts) != len(aircraft) {
+			t.Fatalf("mode %d: got %d ghosts, expected %d", mode, len(ghosts), len(aircraft))
+		}
+		for _, ghost := range ghosts {
+			expect := "18" + rules[ghost.Callsign] + sp.Aircraft[ghost.Callsign].CWTCategory
+
+			db := sp.getGhostDatablock(ghost, rules[ghost.Callsign], renderer.RGB{1, 1, 0})
+			if s := text(db.field0[:]); s != expect {
+				t.Errorf("mode %d: %s: ghost PDB %q, expected %q", mode, ghost.Callsign, s, expect)
+			}
+			for _, ch := range db.field0[:] {
+				if ch.ch != 0 && ch.color != (renderer.RGB{1, 1, 0}) {
+					t.Errorf("mode %d: %s: unexpected color %v for %q", mode, ghost.Callsign, ch.color, ch.ch)
+				}
+			}
+		}
+	}
+}
+
+func TestPreferenceSetUniqueName(t *testing.T) {
+	var ps PreferenceSet
+	if name := ps.uniqueName("IMPORT"); name != "IMPORT" {
+		t.Errorf("got %q for an unused name", name)
+	}
+
+	// Each import of unnamed preferences should get a new name.
+	for i, expected := range []string{"IMPORT", "IMPORT2", "IMPORT3"} {
+		name := ps.uniqueName("IMPORT")
+		if name != expected {
+			t.Errorf("got %q, expected %q", name, expected)
+		}
+		ps.Saved[i] = &Preferences{Name: name}
+	}
+
+	// Names must stay within 7 characters.
+	for i := 3; i < 9; i++ {
+		ps.Saved[i] = &Preferences{Name: "IMPORT" + strconv.Itoa(i+1)}
+	}
+	if name := ps.uniqueName("IMPORT"); name != "IMPOR10" {
+		t.Errorf("got %q, expected IMPOR10", name)
+	}
+}
+
+func TestWeatherRadarCrossfade(t *testing.T) {
+	level := func() *renderer.CommandBuffer {
+		return &renderer.CommandBuffer{Buf: []uint32{renderer.RendererResetState}}
+	}
+	w := &WeatherRadar{active: true}
+	w.cb[0][0], w.cb[1][0] = level(), level()
+	var active [numWxLevels]bool
+	active[0] = true // unstippled, so each image is drawn once
+	opacity := [numWxLevels]float32{1, 1, 1, 1, 1, 1}
+
+	// draw returns the alpha values used to draw the weather and whether
+	// blending was enabled.
+	draw := func(crossfade bool) (alpha []float32, blend bool) {
+		var cb renderer.CommandBuffer
+		w.Draw(nil, 0, 1, 1, active, opacity, crossfade, ScopeTransformations{}, &cb)
+
+		for i := 0; i < len(cb.Buf); {
+			cmd := cb.Buf[i]
+			i++
+			switch cmd {
+			case renderer.RendererLoadProjectionMatrix, renderer.RendererLoadModelViewMatrix:
+				i += 16
+			case renderer.RendererBlend:
+				blend = true
+			case renderer.RendererDisableBlend:
+			case renderer.RendererSetRGBA:
+				alpha = append(alpha, gomath.Float32frombits(cb.Buf[i+3]))
+				i += 4
+			case renderer.RendererCallBuffer:
+				i++
+			default:
+				t.Fatalf("unexpected rendering command %d", cmd)
+			}
+		}
+		return
+	}
+
+	// A quarter of the way through the crossfade, the previous image
+	// should be drawn with alpha 1-t and then the new one with alpha t.
+	w.lastUpdate = time.Now().Add(-wxCrossfadeDuration / 4)
+	alpha, blend := draw(true)
+	if !blend {
+		t.Errorf("blending not enabled for crossfade")
+	}
+	if len(alpha) != 2 {
+		t.Fatalf("expected both images to be drawn, got alphas %v", alpha)
+	}
+	if alpha[0] < .7 || alpha[0] > .75 || math.Abs(alpha[0]+alpha[1]-1) > 1e-5 {
+		t.Errorf("got alphas %v, expected approximately [0.75 0.25]", alpha)
+	}
+
+	// Once the crossfade is done, or if it's disabled, only the new image
+	// is drawn.
+	if alpha, _ := draw(false); len(alpha) != 1 || alpha[0] != 1 {
+		t.Errorf("got alphas %v without crossfade, expected [1]", alpha)
+	}
+	w.lastUpdate = time.Now().Add(-2 * wxCrossfadeDuration)
+	if alpha, _ := draw(true); len(alpha) != 1 || alpha[0] != 1 {
+		t.Errorf("got alphas %v after crossfade, expected [1]", alpha)
+	}
+}
@@ -5,11 +5,14 @@
 package stars
 
 import (
+	"bytes"
 	_ "embed"
 	"fmt"
+	"hash/fnv"
 	"image"
 	"image/draw"
 	"image/png"
+	"io"
 	"log/slog"
 	gomath "math"
 	"math/bits"
@@ -19,6 +22,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	av "github.com/mmp/vice/pkg/aviation"
@@ -46,10 +50,23 @@ type WeatherRadar struct {
 	reqChan chan math.Point2LL
 	cbChan  chan [numWxLevels]*renderer.CommandBuffer
 	cb      [numWxHistory][numWxLevels]*renderer.CommandBuffer
+
+	// When the most recent radar image arrived; used to crossfade from
+	// the previous one.
+	lastUpdate time.Time
+
+	// Counts of fetched radar images that were unchanged since the
+	// previous fetch (hits), and so didn't need to be decoded and
+	// converted to command buffers, and those that did (misses).
+	cacheHits, cacheMisses atomic.Int64
 }
 
 const numWxHistory = 3
 
+// If crossfading is enabled, the time over which the previous radar image
+// fades out and the new one fades in.
+const wxCrossfadeDuration = 3 * time.Second
+
 const numWxLevels = 6
 
 // Block size in pixels of the quads in the converted radar image used for
@@ -73,7 +90,17 @@ func (w *WeatherRadar) Activate(r renderer.Renderer, lg *log.Logger) {
 	}
 	w.cbChan = make(chan [numWxLevels]*renderer.CommandBuffer, 8)
 
-	go fetchWeather(w.reqChan, w.cbChan, lg)
+	go w.fetchWeather(w.reqChan, w.cbChan, lg)
+}
+
+// Deactivate stops the goroutine that fetches radar images; Activate may
+// be called again later to restart it.
+func (w *WeatherRadar) Deactivate() {
+	if w.active {
+		close(w.reqChan)
+		w.reqChan = nil
+		w.active = false
+	}
 }
 
 func (w *WeatherRadar) HaveWeather() [numWxLevels]bool {
@@ -248,16 +275,33 @@ func estimateDBZ(rgb [3]byte) float32 {
 	}
 }
 
+// WeatherRadarCacheStats reports how often fetched weather radar images
+// were reused rather than decoded.
+type WeatherRadarCacheStats struct {
+	Hits, Misses int64
+}
+
+func (w *WeatherRadar) CacheStats() WeatherRadarCacheStats {
+	return WeatherRadarCacheStats{Hits: w.cacheHits.Load(), Misses: w.cacheMisses.Load()}
+}
+
+func (s WeatherRadarCacheStats) LogValue() slog.Value {
+	return slog.GroupValue(slog.Int64("hits", s.Hits), slog.Int64("misses", s.Misses))
+}
+
 // fetchWeather runs asynchronously in a goroutine, receiving requests from
 // reqChan, fetching corresponding radar images from the NOAA, and sending
 // the results back on cbChan.  New images are also automatically
 // fetched periodically, with a wait time specified by the delay parameter.
-func fetchWeather(reqChan chan math.Point2LL, cbChan chan [numWxLevels]*renderer.CommandBuffer, lg *log.Logger) {
+func (w *WeatherRadar) fetchWeather(reqChan chan math.Point2LL, cbChan chan [numWxLevels]*renderer.CommandBuffer, lg *log.Logger) {
 	// STARS seems to get new radar roughly every 5 minutes
 	const fetchRate = 5 * time.Minute
 
 	// center stores the current center position of the radar image
 	var center math.Point2LL
+	// Identifies the most recently decoded image so that we don't redo
+	// that work if NOAA hasn't issued a new radar product.
+	var lastImageKey string
 	fetchTimer := time.NewTimer(fetchRate)
 	for {
 		var ok bool
@@ -312,13 +356,36 @@ func fetchWeather(reqChan chan math.Point2LL, cbChan chan [numWxLevels]*renderer
 			lg.Infof("Weather error: %s", err)
 			continue
 		}
-		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			lg.Infof("Weather error: %s", err)
+			continue
+		}
 
-		img, err := png.Decode(resp.Body)
+		// Key the image by its product timestamp if the server provides
+		// one and otherwise by its contents.
+		imageKey := resp.Header.Get("Last-Modified")
+		if imageKey == "" {
+			h := fnv.New64a()
+			h.Write(b)
+			imageKey = strconv.FormatUint(h.Sum64(), 16)
+		}
+		imageKey = fmt.Sprintf("%v:%s", rb, imageKey)
+		if imageKey == lastImageKey {
+			// The command buffers we previously sent are still valid.
+			w.cacheHits.Add(1)
+			lg.Debug("weather unchanged")
+			continue
+		}
+		w.cacheMisses.Add(1)
+
+		img, err := png.Decode(bytes.NewReader(b))
 		if err != nil {
 			lg.Infof("Weather error: %s", err)
 			continue
 		}
+		lastImageKey = imageKey
 
 		cbChan <- makeWeatherCommandBuffers(img, rb)
 
@@ -517,17 +584,27 @@ func reverseStippleBytes(stipple [32]uint32) [32]uint32 {
 }
 
 // Draw draws the current weather radar image, if available. (If none is yet
-// available, it returns rather than stalling waiting for it).
+// available, it returns rather than stalling waiting for it). The
+// intensity and contrast for each level are scaled by the corresponding
+// entry of opacity. If crossfade is true, the previous image is blended
+// into the new one over a few seconds after a new image arrives.
 func (w *WeatherRadar) Draw(ctx *panes.Context, hist int, intensity float32, contrast float32,
-	active [numWxLevels]bool, transforms ScopeTransformations, cb *renderer.CommandBuffer) {
+	active [numWxLevels]bool, opacity [numWxLevels]float32, crossfade bool, transforms ScopeTransformations,
+	cb *renderer.CommandBuffer) {
 	select {
-	case cb := <-w.cbChan:
+	case cb, ok := <-w.cbChan:
 		// Got updated command buffers, yaay.  Note that we always drain
 		// the cbChan, even if if the WeatherRadar is inactive.
+		if !ok {
+			// The fetch goroutine has exited after Deactivate.
+			w.cbChan = nil
+			break
+		}
 
 		// Shift history down before storing the latest
 		w.cb[2], w.cb[1] = w.cb[1], w.cb[0]
 		w.cb[0] = cb
+		w.lastUpdate = time.Now()
 
 	default:
 		// no message
@@ -539,13 +616,28 @@ func (w *WeatherRadar) Draw(ctx *panes.Context, hist int, intensity float32, con
 
 	hist = math.Clamp(hist, 0, len(w.cb)-1)
 	transforms.LoadLatLongViewingMatrices(cb)
-	for i := range w.cb[hist] {
-		if active[i] && w.cb[hist][i] != nil {
+
+	if fade := time.Since(w.lastUpdate); crossfade && hist == 0 && fade < wxCrossfadeDuration {
+		t := float32(fade.Seconds() / wxCrossfadeDuration.Seconds())
+		cb.Blend()
+		w.drawLevels(w.cb[1], 1-t, intensity, contrast, active, opacity, cb)
+		w.drawLevels(w.cb[0], t, intensity, contrast, active, opacity, cb)
+		cb.DisableBlend()
+	} else {
+		w.drawLevels(w.cb[hist], 1, intensity, contrast, active, opacity, cb)
+	}
+}
+
+func (w *WeatherRadar) drawLevels(levels [numWxLevels]*renderer.CommandBuffer, alpha float32, intensity float32,
+	contrast float32, active [numWxLevels]bool, opacity [numWxLevels]float32, cb *renderer.CommandBuffer) {
+	for i := range levels {
+		if active[i] && levels[i] != nil {
 			// RGBs from STARS Manual, B-5
 			baseColor := util.Select(i < 3,
 				renderer.RGBFromUInt8(37, 77, 77), renderer.RGBFromUInt8(100, 100, 51))
-			cb.SetRGB(baseColor.Scale(intensity))
-			cb.Call(*w.cb[hist][i])
+			c := baseColor.Scale(intensity * opacity[i])
+			cb.SetRGBA(renderer.RGBA{R: c.R, G: c.G, B: c.B, A: alpha})
+			cb.Call(*levels[i])
 
 			if i == 0 || i == 3 {
 				// No stipple
@@ -559,8 +651,9 @@ func (w *WeatherRadar) Draw(ctx *panes.Context, hist int, intensity float32, con
 				cb.PolygonStipple(reverseStippleBytes(wxStippleDense))
 			}
 			// Draw the same quads again, just with a different color and stippled.
-			cb.SetRGB(renderer.RGB{contrast, contrast, contrast})
-			cb.Call(*w.cb[hist][i])
+			sc := contrast * opacity[i]
+			cb.SetRGBA(renderer.RGBA{R: sc, G: sc, B: sc, A: alpha})
+			cb.Call(*levels[i])
 			cb.DisablePolygonStipple()
 		}
 	}
@@ -587,7 +680,7 @@ func (sp *STARSPane) drawCompass(ctx *panes.Context, scopeExtent math.Extent2D,
 	pw := transforms.WindowFromLatLongP(ps.CurrentCenter)
 	bounds := math.Extent2D{P1: [2]float32{scopeExtent.Width(), scopeExtent.Height()}}
 	font := sp.systemFont(ctx, ps.CharSize.Tools)
-	color := ps.Brightness.Compass.ScaleRGB(STARSCompassColor)
+	color := ps.Brightness.Compass.ScaleRGB(sp.palette().Compass)
 
 	td := renderer.GetTextDrawBuilder()
 	defer renderer.ReturnTextDrawBuilder(td)
@@ -683,7 +776,7 @@ func (sp *STARSPane) drawRangeRings(ctx *panes.Context, transforms ScopeTransfor
 	}
 
 	cb.LineWidth(1, ctx.DPIScale)
-	color := ps.Brightness.RangeRings.ScaleRGB(STARSRangeRingColor)
+	color := ps.Brightness.RangeRings.ScaleRGB(sp.palette().RangeRing)
 	cb.SetRGB(color)
 	transforms.LoadWindowViewingMatrices(cb)
 	ld.GenerateCommands(cb)
@@ -800,7 +893,7 @@ func (sp *STARSPane) drawHighlighted(ctx *panes.Context, transforms ScopeTransfo
 	// "The color of the blinking square is the same as that for blinking
 	// data block information"(?)
 	ps := sp.currentPrefs()
-	color := ps.Brightness.FullDatablocks.ScaleRGB(STARSUntrackedAircraftColor)
+	color := ps.Brightness.FullDatablocks.ScaleRGB(sp.palette().UntrackedAircraft)
 	halfSeconds := ctx.Now.UnixMilli() / 500
 	blinkDim := halfSeconds&1 == 0
 	if blinkDim {
@@ -833,7 +926,7 @@ func (sp *STARSPane) drawRBLs(aircraft []*av.Aircraft, ctx *panes.Context, trans
 		Color: color,
 	}
 
-	drawRBL := func(p0 math.Point2LL, p1 math.Point2LL, idx int, gs float32) {
+	drawRBL := func(p0 math.Point2LL, p1 math.Point2LL, idx int, gs float32, label string) {
 		// Format the range-bearing line text for the two positions.
 		hdg := math.Heading2LL(p0, p1, ctx.ControlClient.NmPerLongitude, ctx.ControlClient.MagneticVariation)
 		dist := math.NMDistance2LL(p0, p1)
@@ -844,6 +937,9 @@ func (sp *STARSPane) drawRBLs(aircraft []*av.Aircraft, ctx *panes.Context, trans
 			text += fmt.Sprintf("/%d", int(eta+.5))
 		}
 		text += fmt.Sprintf("-%d", idx)
+		if label != "" {
+			text += " " + label
+		}
 
 		// And draw the line and the text.
 		pText := transforms.WindowFromLatLongP(p1) // draw at right endpoint
@@ -861,11 +957,11 @@ func (sp *STARSPane) drawRBLs(aircraft []*av.Aircraft, ctx *panes.Context, trans
 				if ac := ctx.ControlClient.Aircraft[wp.Callsign]; ac != nil && sp.datablockVisible(ac, ctx) &&
 					slices.Contains(aircraft, ac) {
 					if state, ok := sp.Aircraft[wp.Callsign]; ok {
-						drawRBL(state.TrackPosition(), p1, len(sp.RangeBearingLines)+1, ac.GS())
+						drawRBL(state.TrackPosition(), p1, len(sp.RangeBearingLines)+1, ac.GS(), "")
 					}
 				}
 			} else {
-				drawRBL(wp.Loc, p1, len(sp.RangeBearingLines)+1, 0)
+				drawRBL(wp.Loc, p1, len(sp.RangeBearingLines)+1, 0, sp.wipRBL.FixLabel())
 			}
 		}
 	}
@@ -890,7 +986,7 @@ func (sp *STARSPane) drawRBLs(aircraft []*av.Aircraft, ctx *panes.Context, trans
 				}
 			}
 
-			drawRBL(p0, p1, i+1, gs)
+			drawRBL(p0, p1, i+1, gs, rbl.FixLabel())
 		}
 	}
 
@@ -915,20 +1011,19 @@ func (sp *STARSPane) drawMinSep(ctx *panes.Context, transforms ScopeTransformati
 	}
 	ac0, ok0 := ctx.ControlClient.Aircraft[cs0]
 	ac1, ok1 := ctx.ControlClient.Aircraft[cs1]
-	if !ok0 || !ok1 {
-		// Missing aircraft
+	s0, ok2 := sp.Aircraft[cs0]
+	s1, ok3 := sp.Aircraft[cs1]
+	now := ctx.ControlClient.CurrentTime()
+	if !ok0 || !ok1 || !ok2 || !ok3 || s0.LostTrack(now) || s1.LostTrack(now) {
+		// One of the aircraft has been dropped; clear the min sep display.
+		sp.MinSepAircraft[0] = ""
+		sp.MinSepAircraft[1] = ""
 		return
 	}
 
 	ps := sp.currentPrefs()
 	color := ps.Brightness.Lines.RGB()
 
-	s0, ok0 := sp.Aircraft[ac0.Callsign]
-	s1, ok1 := sp.Aircraft[ac1.Callsign]
-	if !ok0 || !ok1 {
-		return
-	}
-
 	// Go ahead and draw the minimum separation lines and text.
 	p0ll, p1ll := s0.TrackPosition(), s1.TrackPosition()
 	d0ll := s0.HeadingVector(ac0.NmPerLongitude(), ac0.MagneticVariation())
@@ -991,10 +1086,7 @@ func (sp *STARSPane) drawMinSep(ctx *panes.Context, transforms ScopeTransformati
 		DrawBackground:  true,
 		BackgroundColor: renderer.RGB{},
 	}
-	text := fmt.Sprintf("%.2fNM", math.NMDistance2LL(p0tmin, p1tmin))
-	if tmin < 0 {
-		text = "NO XING\n" + text
-	}
+	text := minSepText(math.NMDistance2LL(p0ll, p1ll), math.NMDistance2LL(p0tmin, p1tmin), tmin)
 	td.AddTextCentered(text, pText, style)
 
 	// Add the corresponding drawing commands to the CommandBuffer.
@@ -1007,6 +1099,18 @@ func (sp *STARSPane) drawMinSep(ctx *panes.Context, transforms ScopeTransformati
 	td.GenerateCommands(cb)
 }
 
+// minSepText returns the text for the min sep readout given the current
+// separation between the two aircraft, the separation at their closest
+// point of approach, and the time until then in minutes. (tmin is
+// negative if they are diverging.)
+func minSepText(current, cpa, tmin float32) string {
+	if tmin < 0 {
+		return fmt.Sprintf("NO XING\n%.2fNM", current)
+	}
+	sec := int(tmin*60 + 0.5)
+	return fmt.Sprintf("%.2fNM\nCPA %d:%02d\nNOW %.2fNM", cpa, sec/60, sec%60, current)
+}
+
 func (sp *STARSPane) drawScenarioRoutes(ctx *panes.Context, transforms ScopeTransformations, font *renderer.Font, color renderer.RGB,
 	cb *renderer.CommandBuffer) {
 	drawArrivals := ctx.ControlClient.ScopeDrawArrivals()
@@ -1143,7 +1247,7 @@ func (sp *STARSPane) drawScenarioRoutes(ctx *panes.Context, transforms ScopeTran
 
 	if drawAirspace != nil {
 		ps := sp.currentPrefs()
-		rgb := ps.Brightness.Lists.ScaleRGB(STARSListColor)
+		rgb := ps.Brightness.Lists.ScaleRGB(sp.palette().List)
 
 		for _, ctrl := range util.SortedMapKeys(drawAirspace) {
 			for _, volname := range util.SortedMapKeys(drawAirspace[ctrl]) {
@@ -1576,7 +1680,7 @@ func (sp *STARSPane) drawRingsAndCones(aircraft []*av.Aircraft, ctx *panes.Conte
 
 	ps := sp.currentPrefs()
 	font := sp.systemFont(ctx, ps.CharSize.Datablocks)
-	color := ps.Brightness.Lines.ScaleRGB(STARSJRingConeColor)
+	color := ps.Brightness.Lines.ScaleRGB(sp.palette().JRingCone)
 
 	for _, ac := range aircraft {
 		state := sp.Aircraft[ac.Callsign]
@@ -1594,10 +1698,10 @@ func (sp *STARSPane) drawRingsAndCones(aircraft []*av.Aircraft, ctx *panes.Conte
 			}
 		}
 
-		if state.JRingRadius > 0 {
+		if state.JRingRadius > 0 && state.JRingRadiusNM() > 0 {
 			const nsegs = 360
 			pc := transforms.WindowFromLatLongP(state.TrackPosition())
-			radius := state.JRingRadius / transforms.PixelDistanceNM(ctx.ControlClient.NmPerLongitude)
+			radius := state.JRingRadiusNM() / transforms.PixelDistanceNM(ctx.ControlClient.NmPerLongitude)
 			ld.AddCircle(pc, radius, nsegs, color)
 
 			if ps.DisplayTPASize || (state.DisplayTPASize != nil && *state.DisplayTPASize) {
@@ -1608,7 +1712,7 @@ func (sp *STARSPane) drawRingsAndCones(aircraft []*av.Aircraft, ctx *panes.Conte
 				v[1] += float32(font.Size) + 3
 				pt := math.Add2f(pc, v)
 				textStyle := renderer.TextStyle{Font: font, Color: color}
-				td.AddText(format(state.JRingRadius), pt, textStyle)
+				td.AddText(format(state.JRingRadius)+util.Select(state.JRingMinutes, "M", ""), pt, textStyle)
 			}
 		}
 		atpaStatus := state.ATPAStatus // this may change
@@ -1622,7 +1726,8 @@ func (sp *STARSPane) drawRingsAndCones(aircraft []*av.Aircraft, ctx *panes.Conte
 
 		drawATPAMonitor := atpaStatus == ATPAStatusMonitor && ps.DisplayATPAMonitorCones &&
 			(state.DisplayATPAMonitor == nil || *state.DisplayATPAMonitor) &&
-			state.IntrailDistance-state.MinimumMIT <= 2 // monitor only if within 2nm of MIT requirement
+			// monitor only if close to the MIT requirement
+			state.IntrailDistance-state.MinimumMIT <= ctx.ControlClient.STARSFacilityAdaptation.ATPA.MonitorDistance
 		drawATPAWarning := atpaStatus == ATPAStatusWarning && ps.DisplayATPAWarningAlertCones &&
 			(state.DisplayATPAWarnAlert == nil || *state.DisplayATPAWarnAlert)
 		drawATPAAlert := atpaStatus == ATPAStatusAlert && ps.DisplayATPAWarningAlertCones &&
@@ -1631,7 +1736,7 @@ func (sp *STARSPane) drawRingsAndCones(aircraft []*av.Aircraft, ctx *panes.Conte
 
 		if state.HaveHeading() && (state.ConeLength > 0 || drawATPACone) {
 			// Find the length of the cone in pixel coordinates)
-			lengthNM := math.Max(state.ConeLength, state.MinimumMIT)
+			lengthNM := math.Max(state.ConeLengthNM(), state.MinimumMIT)
 			length := lengthNM / transforms.PixelDistanceNM(ctx.ControlClient.NmPerLongitude)
 
 			// Form a triangle; the end of the cone is 10 pixels wide
@@ -1656,11 +1761,11 @@ func (sp *STARSPane) drawRingsAndCones(aircraft []*av.Aircraft, ctx *panes.Conte
 				pts[i] = rot(pts[i])
 			}
 
-			coneColor := ps.Brightness.Lines.ScaleRGB(STARSJRingConeColor)
+			coneColor := ps.Brightness.Lines.ScaleRGB(sp.palette().JRingCone)
 			if atpaStatus == ATPAStatusWarning {
-				coneColor = ps.Brightness.Lines.ScaleRGB(STARSATPAWarningColor)
+				coneColor = ps.Brightness.Lines.ScaleRGB(sp.palette().ATPAWarning)
 			} else if atpaStatus == ATPAStatusAlert {
-				coneColor = ps.Brightness.Lines.ScaleRGB(STARSATPAAlertColor)
+				coneColor = ps.Brightness.Lines.ScaleRGB(sp.palette().ATPAAlert)
 			}
 
 			// We've got what we need to draw a polyline with the
@@ -1678,6 +1783,9 @@ func (sp *STARSPane) drawRingsAndCones(aircraft []*av.Aircraft, ctx *panes.Conte
 
 				// Draw a quad in the background color behind the text
 				text := format(lengthNM)
+				if state.ConeMinutes && state.ConeLengthNM() >= state.MinimumMIT {
+					text = format(state.ConeLength) + "M"
+				}
 				bx, by := textStyle.Font.BoundText(" "+text+" ", 0)
 				fbx, fby := float32(bx), float32(by+2)
 				trid.AddQuad(math.Add2f(pCenter, [2]float32{-fbx / 2, -fby / 2}),
@@ -1692,7 +1800,7 @@ func (sp *STARSPane) drawRingsAndCones(aircraft []*av.Aircraft, ctx *panes.Conte
 
 	transforms.LoadWindowViewingMatrices(cb)
 	ld.GenerateCommands(cb)
-	cb.SetRGB(ps.Brightness.BackgroundContrast.ScaleRGB(STARSBackgroundColor))
+	cb.SetRGB(ps.Brightness.BackgroundContrast.ScaleRGB(sp.palette().Background))
 	trid.GenerateCommands(cb)
 	td.GenerateCommands(cb)
 }
@@ -1718,38 +1826,54 @@ func (sp *STARSPane) drawSelectedRoute(ctx *panes.Context, transforms ScopeTrans
 
 	prefs := sp.currentPrefs()
 	cb.LineWidth(1, ctx.DPIScale)
-	cb.SetRGB(prefs.Brightness.Lines.ScaleRGB(STARSJRingConeColor))
+	cb.SetRGB(prefs.Brightness.Lines.ScaleRGB(sp.palette().JRingCone))
 	transforms.LoadLatLongViewingMatrices(cb)
 	ld.GenerateCommands(cb)
 }
 
 type STARSRangeBearingLine struct {
 	P [2]struct {
-		// If callsign is given, use that aircraft's position;
-		// otherwise we have a fixed position.
+		// If callsign is given, use that aircraft's position; otherwise
+		// if a fix is given, use its position, falling back to the fixed
+		// position if the fix can't be found.
 		Loc      math.Point2LL
 		Callsign string
+		Fix      string `json:",omitempty"`
 	}
 }
 
 func (rbl STARSRangeBearingLine) GetPoints(ctx *panes.Context, visibleAircraft []*av.Aircraft, sp *STARSPane) (p0, p1 math.Point2LL) {
 	// Each line endpoint may be specified either by an aircraft's
-	// position or by a fixed position. We'll start with the fixed
-	// position and then override it if there's a valid *Aircraft.
-	p0, p1 = rbl.P[0].Loc, rbl.P[1].Loc
-	if ac := ctx.ControlClient.Aircraft[rbl.P[0].Callsign]; ac != nil {
-		state, ok := sp.Aircraft[ac.Callsign]
-		if ok && !state.LostTrack(ctx.ControlClient.SimTime) && slices.Contains(visibleAircraft, ac) {
-			p0 = state.TrackPosition()
+	// position, by a named fix, or by a fixed position. We'll start with
+	// the fixed position and then override it if there's a valid
+	// *Aircraft or the fix can be found.
+	getPoint := func(i int) math.Point2LL {
+		if ac := ctx.ControlClient.Aircraft[rbl.P[i].Callsign]; ac != nil {
+			state, ok := sp.Aircraft[ac.Callsign]
+			if ok && !state.LostTrack(ctx.ControlClient.SimTime) && slices.Contains(visibleAircraft, ac) {
+				return state.TrackPosition()
+			}
 		}
+		if rbl.P[i].Fix != "" {
+			if p, ok := ctx.ControlClient.Locate(rbl.P[i].Fix); ok {
+				return p
+			}
+		}
+		return rbl.P[i].Loc
 	}
-	if ac := ctx.ControlClient.Aircraft[rbl.P[1].Callsign]; ac != nil {
-		state, ok := sp.Aircraft[ac.Callsign]
-		if ok && !state.LostTrack(ctx.ControlClient.SimTime) && slices.Contains(visibleAircraft, ac) {
-			p1 = state.TrackPosition()
+	return getPoint(0), getPoint(1)
+}
+
+// FixLabel returns the names of the fixes the line's endpoints are
+// anchored to, if any, for display in the readout.
+func (rbl STARSRangeBearingLine) FixLabel() string {
+	var fixes []string
+	for _, p := range rbl.P {
+		if p.Callsign == "" && p.Fix != "" {
+			fixes = append(fixes, p.Fix)
 		}
 	}
-	return
+	return strings.Join(fixes, "/")
 }
 
 func rblSecondClickHandler(ctx *panes.Context, sp *STARSPane) func([2]float32, ScopeTransformations) (status CommandStatus) {
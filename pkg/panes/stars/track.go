@@ -59,6 +59,15 @@ type AircraftState struct {
 	historyTracks      [10]av.RadarTrack
 	historyTracksIndex int
 
+	// How long we go without a track update before the track is
+	// considered lost; set from the facility adaptation according to the
+	// current radar mode. Zero gives the default of 30s.
+	lostTrackTimeout time.Duration
+
+	// Set when no working radar can see the aircraft, so the track is
+	// coasting at its last reported position.
+	coasting bool
+
 	DatablockType            DatablockType
 	FullLDBEndTime           time.Time // If the LDB displays the groundspeed. When to stop
 	DisplayRequestedAltitude *bool     // nil if unspecified
@@ -72,8 +81,12 @@ type AircraftState struct {
 	ReleaseDeleted bool
 
 	// Only drawn if non-zero
-	JRingRadius    float32
-	ConeLength     float32
+	JRingRadius float32
+	ConeLength  float32
+	// If set, the corresponding size above is in minutes of flight at
+	// the track's groundspeed rather than nautical miles.
+	JRingMinutes   bool
+	ConeMinutes    bool
 	DisplayTPASize *bool // unspecified->system default if nil
 
 	DisplayATPAMonitor       *bool // unspecified->system default if nil
@@ -88,6 +101,10 @@ type AircraftState struct {
 	IFFlashing        bool // Will continue to flash unless slewed or a successful handoff
 	NextController    string
 
+	// Set by .FINDAC; the datablock is shown regardless of the altitude
+	// filters and flashes until then.
+	FindFlashingEndTime time.Time
+
 	AcceptedHandoffSector     string
 	AcceptedHandoffDisplayEnd time.Time
 
@@ -105,6 +122,10 @@ type AircraftState struct {
 	displayPilotAltitude bool
 	pilotAltitude        int
 
+	// Free-text controller note; local to this scope and never sent to
+	// the server. Since it's unexported, it isn't saved with the config.
+	note string
+
 	DisplayLDBBeaconCode bool
 	DisplayPTL           bool
 	DisableCAWarnings    bool
@@ -137,6 +158,13 @@ type AircraftState struct {
 	// it is adapted to be shown in the FDB.)
 	ClearedScratchpadAlternate bool
 
+	// Previous primary scratchpad contents, maintained as a ring buffer so
+	// that the controller can revert an incorrect entry. scratchpadHistoryCount
+	// is the number of valid entries, ending at scratchpadHistoryIndex-1.
+	scratchpadHistory      [5]string
+	scratchpadHistoryIndex int
+	scratchpadHistoryCount int
+
 	// This is a little messy: we maintain maps from callsign->sector id
 	// for pointouts that track the global state of them. Here we track
 	// just inbound pointouts to the current controller so that the first
@@ -175,6 +203,15 @@ func (s *AircraftState) TrackDeltaAltitude() int {
 	return s.track.Altitude - s.previousTrack.Altitude
 }
 
+// TrackVerticalRate returns the track's altitude change in feet per
+// second between the two most recent radar returns.
+func (s *AircraftState) TrackVerticalRate() float32 {
+	if dt := s.track.Time.Sub(s.previousTrack.Time).Seconds(); dt > 0 {
+		return float32(s.TrackDeltaAltitude()) / float32(dt)
+	}
+	return 0
+}
+
 func (s *AircraftState) TrackPosition() math.Point2LL {
 	return s.track.Position
 }
@@ -183,6 +220,41 @@ func (s *AircraftState) TrackGroundspeed() int {
 	return s.track.Groundspeed
 }
 
+// JRingRadiusNM returns the radius of the track's J-ring in nautical
+// miles, converting it from minutes of flight if necessary.
+func (s *AircraftState) JRingRadiusNM() float32 {
+	return s.tpaSizeNM(s.JRingRadius, s.JRingMinutes)
+}
+
+// ConeLengthNM returns the length of the track's TPA cone in nautical
+// miles, converting it from minutes of flight if necessary.
+func (s *AircraftState) ConeLengthNM() float32 {
+	return s.tpaSizeNM(s.ConeLength, s.ConeMinutes)
+}
+
+func (s *AircraftState) tpaSizeNM(size float32, minutes bool) float32 {
+	if minutes {
+		return size * float32(s.TrackGroundspeed()) / 60
+	}
+	return size
+}
+
+// TrackTrueAirspeed estimates the aircraft's true airspeed by removing the
+// wind at its position and altitude from the velocity implied by its
+// track's groundspeed and direction of travel.
+func (s *AircraftState) TrackTrueAirspeed(wind av.WindModel, nmPerLongitude float32) float32 {
+	gs := float32(s.TrackGroundspeed())
+	if !s.HaveHeading() {
+		return gs
+	}
+
+	hdg := math.Radians(s.TrackHeading(nmPerLongitude))
+	vg := math.Scale2f([2]float32{math.Sin(hdg), math.Cos(hdg)}, gs)
+	// The wind vector is in nm per second; convert to knots.
+	vw := math.Scale2f(wind.GetWindVector(s.track.Position, float32(s.track.Altitude)), 3600)
+	return math.Length2f(math.Sub2f(vg, vw))
+}
+
 func (s *AircraftState) HaveHeading() bool {
 	return !s.previousTrack.Position.IsZero()
 }
@@ -205,6 +277,36 @@ func (s *AircraftState) HeadingVector(nmPerLongitude, magneticVariation float32)
 	return math.NM2LL(v, nmPerLongitude)
 }
 
+// msawLookaheadInterval is the spacing in seconds between the projected
+// positions that are checked against the MVAs.
+const msawLookaheadInterval = 5
+
+// PredictedBelowMVA reports whether the track, extrapolated along its
+// current course and vertical rate for up to lookahead seconds, will be
+// below the minimum altitude of an MVA that it is inside.
+func (s *AircraftState) PredictedBelowMVA(mvas []av.MVA, lookahead int, nmPerLongitude, magneticVariation float32) bool {
+	if !s.HaveHeading() || lookahead <= 0 {
+		return false
+	}
+
+	// v gives the position change over one minute.
+	v := s.HeadingVector(nmPerLongitude, magneticVariation)
+	rate := s.TrackVerticalRate()
+
+	n := (lookahead + msawLookaheadInterval - 1) / msawLookaheadInterval
+	for i := 1; i <= n; i++ {
+		t := float32(lookahead*i) / float32(n)
+		p := math.Add2LL(s.track.Position, math.Scale2f(v, t/60))
+		alt := s.track.Altitude + int(rate*t)
+		if slices.ContainsFunc(mvas, func(mva av.MVA) bool {
+			return alt < mva.MinimumLimit && mva.Inside(p)
+		}) {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *AircraftState) TrackHeading(nmPerLongitude float32) float32 {
 	if !s.HaveHeading() {
 		return 0
@@ -215,14 +317,83 @@ func (s *AircraftState) TrackHeading(nmPerLongitude float32) float32 {
 func (s *AircraftState) LostTrack(now time.Time) bool {
 	// Only return true if we have at least one valid track from the past
 	// but haven't heard from the aircraft recently.
-	return !s.track.Position.IsZero() && now.Sub(s.track.Time) > 30*time.Second
+	timeout := s.lostTrackTimeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+	return !s.track.Position.IsZero() && now.Sub(s.track.Time) > timeout
+}
+
+func (s *AircraftState) pushScratchpadHistory(sp string) {
+	s.scratchpadHistory[s.scratchpadHistoryIndex] = sp
+	s.scratchpadHistoryIndex = (s.scratchpadHistoryIndex + 1) % len(s.scratchpadHistory)
+	s.scratchpadHistoryCount = min(s.scratchpadHistoryCount+1, len(s.scratchpadHistory))
+}
+
+// peekScratchpadHistory returns the most recent entry in the scratchpad
+// history without removing it.
+func (s *AircraftState) peekScratchpadHistory() (string, bool) {
+	if s.scratchpadHistoryCount == 0 {
+		return "", false
+	}
+	i := (s.scratchpadHistoryIndex + len(s.scratchpadHistory) - 1) % len(s.scratchpadHistory)
+	return s.scratchpadHistory[i], true
+}
+
+func (s *AircraftState) popScratchpadHistory() (string, bool) {
+	if s.scratchpadHistoryCount == 0 {
+		return "", false
+	}
+	s.scratchpadHistoryIndex = (s.scratchpadHistoryIndex + len(s.scratchpadHistory) - 1) % len(s.scratchpadHistory)
+	s.scratchpadHistoryCount--
+	return s.scratchpadHistory[s.scratchpadHistoryIndex], true
 }
 
 func (s *AircraftState) Ident(now time.Time) bool {
 	return !s.IdentStart.IsZero() && s.IdentStart.Before(now) && s.IdentEnd.After(now)
 }
 
+// wakeCategory returns the aircraft's CWT category or weight class,
+// depending on which is being used.
+func (sp *STARSPane) wakeCategory(ac *av.Aircraft) string {
+	if sp.UseWeightClasses {
+		return ac.WeightClass()
+	}
+	return ac.CWT()
+}
+
+// checkUnknownWakeCategory logs aircraft types that don't have a known
+// wake category; these indicate a gap in the aircraft performance
+// database that should be fixed.
+func (sp *STARSPane) checkUnknownWakeCategory(ctx *panes.Context, ac *av.Aircraft, category string) {
+	if category != "NOWGT" {
+		return
+	}
+	actype := ac.FlightPlan.BaseType()
+	if _, ok := sp.loggedUnknownWake[actype]; ok {
+		return
+	}
+	if sp.loggedUnknownWake == nil {
+		sp.loggedUnknownWake = make(map[string]interface{})
+	}
+	sp.loggedUnknownWake[actype] = nil
+	ctx.Lg.Warnf("%s: unknown wake category for aircraft type %q in scenario %q", ac.Callsign, actype,
+		ctx.ControlClient.SimDescription)
+}
+
 func (sp *STARSPane) processEvents(ctx *panes.Context) {
+	if sp.wakeCategoriesChanged {
+		sp.wakeCategoriesChanged = false
+		for callsign, ac := range ctx.ControlClient.Aircraft {
+			if state, ok := sp.Aircraft[callsign]; ok {
+				state.CWTCategory = sp.wakeCategory(ac)
+				sp.checkUnknownWakeCategory(ctx, ac, state.CWTCategory)
+			}
+		}
+	}
+
+	sp.updateAutoATIS(ctx)
+
 	// First handle changes in world.Aircraft
 	for callsign, ac := range ctx.ControlClient.Aircraft {
 		if _, ok := sp.Aircraft[callsign]; !ok {
@@ -234,7 +405,8 @@ func (sp *STARSPane) processEvents(ctx *panes.Context) {
 			sa.GlobalLeaderLineDirection = ac.GlobalLeaderLineDirection
 			sa.UseGlobalLeaderLine = sa.GlobalLeaderLineDirection != nil
 			sa.FirstSeen = ctx.ControlClient.SimTime
-			sa.CWTCategory = ac.CWT()
+			sa.CWTCategory = sp.wakeCategory(ac)
+			sp.checkUnknownWakeCategory(ctx, ac, sa.CWTCategory)
 			sa.TabListIndex = TabListUnassignedIndex
 
 			sp.Aircraft[callsign] = sa
@@ -416,6 +588,7 @@ func (sp *STARSPane) isQuicklooked(ctx *panes.Context, ac *av.Aircraft) bool {
 func (sp *STARSPane) updateMSAWs(ctx *panes.Context) {
 	// See if there are any MVA issues
 	mvas := av.DB.MVAs[ctx.ControlClient.TRACON]
+	fa := ctx.ControlClient.STARSFacilityAdaptation
 	for callsign, ac := range ctx.ControlClient.Aircraft {
 		state := sp.Aircraft[callsign]
 		if !ac.MVAsApply() {
@@ -429,9 +602,11 @@ func (sp *STARSPane) updateMSAWs(ctx *panes.Context) {
 			continue
 		}
 
+		// The current position and altitude are always checked; the
+		// projected ones may raise the warning earlier.
 		warn := slices.ContainsFunc(mvas, func(mva av.MVA) bool {
 			return state.track.Altitude < mva.MinimumLimit && mva.Inside(state.track.Position)
-		})
+		}) || state.PredictedBelowMVA(mvas, *fa.MSAWLookahead, ac.NmPerLongitude(), ac.MagneticVariation())
 
 		if !warn && state.InhibitMSAW {
 			// The warning has cleared, so the inhibit is disabled (p.7-25)
@@ -446,10 +621,20 @@ func (sp *STARSPane) updateMSAWs(ctx *panes.Context) {
 	}
 }
 
+// lostTrackTimeout returns how long a track may go without a radar return
+// before it is lost in the given radar mode.
+func lostTrackTimeout(fa *sim.STARSFacilityAdaptation, radarMode int) time.Duration {
+	if radarMode == RadarModeFused {
+		return time.Duration(fa.CoastSuspendTimeout) * time.Second
+	}
+	return time.Duration(fa.SingleSiteCoastSuspendTimeout) * time.Second
+}
+
 func (sp *STARSPane) updateRadarTracks(ctx *panes.Context) {
 	// FIXME: all aircraft radar tracks are updated at the same time.
 	now := ctx.ControlClient.SimTime
-	if sp.radarMode(ctx.ControlClient.RadarSites) == RadarModeFused {
+	mode := sp.radarMode(ctx.ControlClient.RadarSites)
+	if mode == RadarModeFused {
 		if now.Sub(sp.lastTrackUpdate) < 1*time.Second {
 			return
 		}
@@ -459,6 +644,7 @@ func (sp *STARSPane) updateRadarTracks(ctx *panes.Context) {
 		}
 	}
 	sp.lastTrackUpdate = now
+	timeout := lostTrackTimeout(&ctx.ControlClient.STARSFacilityAdaptation, mode)
 
 	for callsign, state := range sp.Aircraft {
 		ac, ok := ctx.ControlClient.Aircraft[callsign]
@@ -467,6 +653,13 @@ func (sp *STARSPane) updateRadarTracks(ctx *panes.Context) {
 			continue
 		}
 
+		state.lostTrackTimeout = timeout
+		state.coasting = !sp.radarCanTrack(ctx, ac.Position(), int(ac.Altitude()))
+		if state.coasting {
+			// Coast: the track keeps its last position until it times
+			// out or a working radar sees the aircraft again.
+			continue
+		}
 		state.previousTrack = state.track
 		state.track = av.RadarTrack{
 			Position:    ac.Position(),
@@ -543,6 +736,9 @@ func (sp *STARSPane) drawTracks(aircraft []*av.Aircraft, ctx *panes.Context, tra
 				}
 			}
 		}
+		if state.coasting {
+			positionSymbol = "#"
+		}
 
 		// "cheat" by using ac.Heading() if we don't yet have two radar tracks to compute the
 		// heading with; this makes things look better when we first see a track or when
@@ -641,7 +837,7 @@ func (sp *STARSPane) drawGhosts(ghosts []*av.GhostAircraft, ctx *panes.Context,
 
 	ps := sp.currentPrefs()
 	brightness := ps.Brightness.OtherTracks
-	color := brightness.ScaleRGB(STARSGhostColor)
+	color := brightness.ScaleRGB(sp.palette().Ghost)
 	trackFont := sp.systemFont(ctx, ps.CharSize.PositionSymbols)
 	trackStyle := renderer.TextStyle{Font: trackFont, Color: color, LineSpacing: 0}
 	datablockFont := sp.systemFont(ctx, ps.CharSize.Datablocks)
@@ -658,7 +854,11 @@ func (sp *STARSPane) drawGhosts(ghosts []*av.GhostAircraft, ctx *panes.Context,
 		td.AddTextCentered(ghost.TrackId, pw, trackStyle)
 
 		// Draw datablock
-		db := sp.getGhostDatablock(ghost, color)
+		rulesCategory := " "
+		if ac, ok := ctx.ControlClient.Aircraft[ghost.Callsign]; ok {
+			rulesCategory = sp.rulesCategory(ctx, ac.FlightPlan.Rules, sp.getTrack(ctx, ac))
+		}
+		db := sp.getGhostDatablock(ghost, rulesCategory, color)
 		pac := transforms.WindowFromLatLongP(ghost.Position)
 		vll := sp.getLeaderLineVector(ctx, ghost.LeaderLineDirection)
 		pll := math.Add2f(pac, vll)
@@ -706,7 +906,7 @@ func (sp *STARSPane) drawRadarTrack(ac *av.Aircraft, state *AircraftState, headi
 				box[i] = transforms.LatLongFromWindowP(box[i])
 			}
 
-			color := primaryTargetBrightness.ScaleRGB(STARSTrackBlockColor)
+			color := primaryTargetBrightness.ScaleRGB(sp.palette().TrackBlock)
 			if primary {
 				// Draw a filled box
 				trid.AddQuad(box[0], box[1], box[2], box[3], color)
@@ -736,7 +936,7 @@ func (sp *STARSPane) drawRadarTrack(ac *av.Aircraft, state *AircraftState, headi
 				box[i] = transforms.LatLongFromWindowP(box[i])
 			}
 
-			color := primaryTargetBrightness.ScaleRGB(STARSTrackBlockColor)
+			color := primaryTargetBrightness.ScaleRGB(sp.palette().TrackBlock)
 			if primary {
 				// Draw a filled box
 				trid.AddQuad(box[0], box[1], box[2], box[3], color)
@@ -748,7 +948,7 @@ func (sp *STARSPane) drawRadarTrack(ac *av.Aircraft, state *AircraftState, headi
 
 		case RadarModeFused:
 			if ps.Brightness.PrimarySymbols > 0 {
-				color := primaryTargetBrightness.ScaleRGB(STARSTrackBlockColor)
+				color := primaryTargetBrightness.ScaleRGB(sp.palette().TrackBlock)
 				drawTrack(trackBuilder, pw, sp.fusedTrackVertices, color)
 			}
 		}
@@ -835,6 +1035,13 @@ func (sp *STARSPane) drawHistoryTrails(aircraft []*av.Aircraft, ctx *panes.Conte
 	const historyTrackDiameter = 8
 	historyTrackVertices := getTrackVertices(ctx, historyTrackDiameter)
 
+	n := ps.RadarTrackHistory
+	if ctx.ReducedQuality {
+		n = min(n, 2)
+	}
+	trackColors := historyTrackColors(sp.palette().TrackHistory[:], n, ps.RadarTrackHistoryFade,
+		ps.Brightness.History)
+
 	now := ctx.ControlClient.CurrentTime()
 	for _, ac := range aircraft {
 		state := sp.Aircraft[ac.Callsign]
@@ -844,14 +1051,11 @@ func (sp *STARSPane) drawHistoryTrails(aircraft []*av.Aircraft, ctx *panes.Conte
 		}
 
 		// Draw history from new to old
-		for i := range ps.RadarTrackHistory {
-			trackColorNum := math.Min(i, len(STARSTrackHistoryColors)-1)
-			trackColor := ps.Brightness.History.ScaleRGB(STARSTrackHistoryColors[trackColorNum])
-
+		for i := range n {
 			if idx := (state.historyTracksIndex - 1 - i) % len(state.historyTracks); idx >= 0 {
 				if p := state.historyTracks[idx].Position; !p.IsZero() {
 					drawTrack(historyBuilder, transforms.WindowFromLatLongP(p), historyTrackVertices,
-						trackColor)
+						trackColors[i])
 				}
 			}
 		}
@@ -861,6 +1065,32 @@ func (sp *STARSPane) drawHistoryTrails(aircraft []*av.Aircraft, ctx *panes.Conte
 	historyBuilder.GenerateCommands(cb)
 }
 
+// historyTrackColors returns the colors to use for n history dots, from
+// the most recent to the oldest. The given key colors are spread over
+// the dots following the fade curve; when there are no more dots than key
+// colors and the curve is linear, the dots get the key colors in order.
+func historyTrackColors(keys []renderer.RGB, n int, fade HistoryFadeCurve, brightness STARSBrightness) []renderer.RGB {
+	colors := make([]renderer.RGB, n)
+	last := len(keys) - 1
+	for i := range n {
+		// u goes from 0 for the most recent dot to 1 for the oldest one
+		// (or the one that would get the last key color).
+		u := float32(i) / float32(math.Max(n-1, last))
+		switch fade {
+		case HistoryFadeFast:
+			u = 1 - (1-u)*(1-u)
+		case HistoryFadeSlow:
+			u = u * u
+		}
+
+		k := u * float32(last)
+		k0 := math.Min(int(k), last)
+		k1 := math.Min(k0+1, last)
+		colors[i] = brightness.ScaleRGB(renderer.LerpRGB(k-float32(k0), keys[k0], keys[k1]))
+	}
+	return colors
+}
+
 func (sp *STARSPane) WarnOutsideAirspace(ctx *panes.Context, ac *av.Aircraft) ([][2]int, bool) {
 	// Only report on ones that are tracked by us
 	if trk := sp.getTrack(ctx, ac); trk == nil || trk.TrackOwner != ctx.ControlClient.PrimaryTCP {
@@ -884,6 +1114,26 @@ func (sp *STARSPane) WarnOutsideAirspace(ctx *panes.Context, ac *av.Aircraft) ([
 	return nil, false
 }
 
+// caVerticalLookahead is the number of seconds ahead that the tracks'
+// vertical rates are extrapolated when checking for conflicts.
+const caVerticalLookahead = 30
+
+// verticalSeparationLost reports whether two tracks are within the
+// vertical minimum of each other or will be within lookahead seconds,
+// given their current vertical rates.
+func verticalSeparationLost(sa, sb *AircraftState, lookahead int) bool {
+	const minimum = VerticalMinimum - 5 // small slop for fp error
+	d0 := float32(sa.TrackAltitude() - sb.TrackAltitude())
+	if math.Abs(d0) <= minimum {
+		return true
+	}
+
+	// The vertical separation changes linearly, so it's sufficient to
+	// check where it ends up and whether they cross in between.
+	d1 := d0 + (sa.TrackVerticalRate()-sb.TrackVerticalRate())*float32(lookahead)
+	return math.Abs(d1) <= minimum || (d0 > 0) != (d1 > 0)
+}
+
 func (sp *STARSPane) updateCAAircraft(ctx *panes.Context, aircraft []*av.Aircraft) {
 	inCAVolumes := func(state *AircraftState) bool {
 		for _, vol := range ctx.ControlClient.InhibitCAVolumes() {
@@ -919,9 +1169,9 @@ func (sp *STARSPane) updateCAAircraft(ctx *panes.Context, aircraft []*av.Aircraf
 			return false
 		}
 
+		lookahead := util.Select(sp.currentPrefs().PredictiveCA, caVerticalLookahead, 0)
 		return math.NMDistance2LL(sa.TrackPosition(), sb.TrackPosition()) <= LateralMinimum &&
-			/*small slop for fp error*/
-			math.Abs(sa.TrackAltitude()-sb.TrackAltitude()) <= VerticalMinimum-5 &&
+			verticalSeparationLost(sa, sb, lookahead) &&
 			!sp.diverging(ctx.ControlClient.Aircraft[callsigna], ctx.ControlClient.Aircraft[callsignb])
 	}
 
@@ -1084,6 +1334,9 @@ func (ma *ModeledAircraft) NextPosition(p [2]float32) [2]float32 {
 
 func (sp *STARSPane) checkInTrailCwtSeparation(ctx *panes.Context, back, front *av.Aircraft) {
 	cwtSeparation := av.CWTApproachSeparation(front.CWT(), back.CWT())
+	if sp.UseWeightClasses {
+		cwtSeparation = av.WeightClassApproachSeparation(front.WeightClass(), back.WeightClass())
+	}
 
 	state := sp.Aircraft[back.Callsign]
 	vol := back.ATPAVolume()
@@ -1120,25 +1373,31 @@ func (sp *STARSPane) checkInTrailCwtSeparation(ctx *panes.Context, back, front *
 	frontModel := MakeModeledAircraft(front, sp.Aircraft[front.Callsign], vol.Threshold)
 	backModel := MakeModeledAircraft(back, state, vol.Threshold)
 
+	state.ATPAStatus = predictATPAStatus(frontModel, backModel, cwtSeparation,
+		ctx.ControlClient.STARSFacilityAdaptation.ATPA)
+}
+
+// predictATPAStatus returns the ATPA status for the trailing aircraft,
+// given the required separation in nm.
+func predictATPAStatus(front, back ModeledAircraft, separation float32, params sim.ATPAParameters) ATPAStatus {
 	// Will there be a MIT violation s seconds in the future?  (Note that
 	// we don't include altitude separation here since what we need is
 	// distance separation by the threshold...)
-	frontPosition, backPosition := frontModel.p, backModel.p
-	for s := 0; s < 45; s++ {
-		frontPosition, backPosition = frontModel.NextPosition(frontPosition), backModel.NextPosition(backPosition)
+	frontPosition, backPosition := front.p, back.p
+	for s := 0; s < params.WarningLookahead; s++ {
+		frontPosition, backPosition = front.NextPosition(frontPosition), back.NextPosition(backPosition)
 		distance := math.Distance2f(frontPosition, backPosition)
-		if distance < cwtSeparation { // no bueno
-			if s <= 24 {
-				// Error if conflict expected within 24 seconds (6-159).
-				state.ATPAStatus = ATPAStatusAlert
-				return
+		if distance < separation { // no bueno
+			if s <= params.AlertLookahead {
+				// Error if conflict expected within the alert lookahead.
+				return ATPAStatusAlert
 			} else {
-				// Warning if conflict expected within 45 seconds (6-159).
-				state.ATPAStatus = ATPAStatusWarning
-				return
+				// Warning if conflict expected within the warning lookahead.
+				return ATPAStatusWarning
 			}
 		}
 	}
+	return ATPAStatusMonitor
 }
 
 func (sp *STARSPane) diverging(a, b *av.Aircraft) bool {
@@ -1243,6 +1502,17 @@ func (sp *STARSPane) isOverflight(ctx *panes.Context, trk *sim.TrackInformation)
 		ctx.ControlClient.Airports[trk.FlightPlan.ArrivalAirport] == nil
 }
 
+// rulesCategory returns the character that precedes the CWT category in
+// datablocks: "V" for VFR, "E" for overflights, and a space otherwise.
+func (sp *STARSPane) rulesCategory(ctx *panes.Context, rules av.FlightRules, trk *sim.TrackInformation) string {
+	if rules == av.VFR {
+		return "V"
+	} else if sp.isOverflight(ctx, trk) {
+		return "E"
+	}
+	return " "
+}
+
 func (sp *STARSPane) radarVisibility(radarSites map[string]*av.RadarSite, pos math.Point2LL, alt int) (primary, secondary bool, distance float32) {
 	prefs := sp.currentPrefs()
 	distance = 1e30
@@ -32,6 +32,7 @@ type audioEngine struct {
 
 type audioEffect struct {
 	pcm            []byte
+	volume         int
 	playOnceCount  int
 	playContinuous bool
 	playOffset     int
@@ -69,10 +70,25 @@ func (a *audioEngine) AddPCM(pcm []byte, rate int) (int, error) {
 		return 0, fmt.Errorf("%d: sample rate doesn't match audio engine's %d",
 			rate, AudioSampleRate)
 	}
-	a.effects = append(a.effects, audioEffect{pcm: pcm})
+	a.effects = append(a.effects, audioEffect{pcm: pcm, volume: 10})
 	return len(a.effects), nil
 }
 
+func (a *audioEngine) ReplacePCM(index int, pcm []byte, rate int) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if rate != AudioSampleRate {
+		return fmt.Errorf("%d: sample rate doesn't match audio engine's %d",
+			rate, AudioSampleRate)
+	}
+	if index <= 0 || index > len(a.effects) {
+		return fmt.Errorf("%d: invalid audio effect", index)
+	}
+	a.effects[index-1] = audioEffect{pcm: pcm, volume: a.effects[index-1].volume}
+	return nil
+}
+
 func (a *audioEngine) SetAudioVolume(vol int) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
@@ -80,6 +96,17 @@ func (a *audioEngine) SetAudioVolume(vol int) {
 	a.volume = math.Clamp(vol, 0, 10)
 }
 
+func (a *audioEngine) SetAudioEffectVolume(index int, vol int) {
+	if index == 0 {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.effects[index-1].volume = math.Clamp(vol, 0, 10)
+}
+
 func (a *audioEngine) PlayAudioOnce(index int) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
@@ -144,7 +171,7 @@ func audioCallback(user unsafe.Pointer, ptr *C.uint8, size C.int) {
 		}
 
 		for i := 0; i < len(buf)/2; i++ {
-			accum[i] += int(int16(buf[2*i])|int16(buf[2*i+1])<<8) / 2
+			accum[i] += int(int16(buf[2*i])|int16(buf[2*i+1])<<8) * e.volume / 20
 		}
 	}
 
@@ -7,6 +7,7 @@ package platform
 import (
 	"strings"
 
+	"github.com/go-gl/glfw/v3.3/glfw"
 	"github.com/mmp/imgui-go/v4"
 )
 
@@ -96,6 +97,7 @@ const (
 	KeyF16
 	KeyV
 	KeyInsert
+	KeyPrintScreen
 )
 
 type KeyboardState struct {
@@ -184,6 +186,11 @@ func (g *glfwPlatform) GetKeyboard() *KeyboardState {
 	if imgui.IsKeyPressed(imgui.GetKeyIndex(imgui.KeyInsert)) {
 		keyboard.Pressed[KeyInsert] = nil
 	}
+	// imgui doesn't have a key index for it, but it tracks key state
+	// using the GLFW key codes.
+	if imgui.IsKeyPressed(int(glfw.KeyPrintScreen)) {
+		keyboard.Pressed[KeyPrintScreen] = nil
+	}
 
 	return keyboard
 }
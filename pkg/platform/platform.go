@@ -93,10 +93,19 @@ type Platform interface {
 	// the audio playing entrypoints.
 	AddPCM(pcm []byte, rate int) (int, error)
 
+	// ReplacePCM replaces the audio of an effect previously registered
+	// with AddPCM, stopping it if it is playing.
+	ReplacePCM(id int, pcm []byte, rate int) error
+
 	// SetAudioVolume sets the volume for audio playback; the value passed
 	// should be between 0 and 10.
 	SetAudioVolume(vol int)
 
+	// SetAudioEffectVolume sets the volume of a single audio effect,
+	// which is applied in addition to the overall volume. The value
+	// passed should be between 0 and 10.
+	SetAudioEffectVolume(id int, vol int)
+
 	// PlayAudioOnce plays the audio effect identified by the given identifier
 	// once. Multiple audio effects may be played simultaneously.
 	PlayAudioOnce(id int)
@@ -14,12 +14,15 @@ import (
 ///////////////////////////////////////////////////////////////////////////
 // Random numbers.
 
+// Rand is a random number generator. Each Sim has its own so that its
+// simulation is reproducible given the seed, independent of other sims
+// and of the random numbers used by the UI.
 type Rand struct {
 	r *pcg.PCG32
 }
 
-func New() Rand {
-	return Rand{r: pcg.NewPCG32()}
+func New() *Rand {
+	return &Rand{r: pcg.NewPCG32()}
 }
 
 func (r *Rand) Seed(s int64) {
@@ -39,7 +42,7 @@ func (r *Rand) Float32() float32 {
 }
 
 // Drop-in replacement for the subset of math/rand that we use...
-var r Rand
+var r *Rand
 
 func init() {
 	r = New()
@@ -99,26 +102,26 @@ func PermutationElement(i int, n int, p uint32) int {
 }
 
 // SampleSlice uniformly randomly samples an element of a non-empty slice.
-func SampleSlice[T any](slice []T) T {
-	return slice[Intn(len(slice))]
+func SampleSlice[T any](r *Rand, slice []T) T {
+	return slice[r.Intn(len(slice))]
 }
 
-func Sample[T any](t ...T) T {
-	return t[Intn(len(t))]
+func Sample[T any](r *Rand, t ...T) T {
+	return t[r.Intn(len(t))]
 }
 
 // SampleFiltered uniformly randomly samples a slice, returning the index
 // of the sampled item, using provided predicate function to filter the
 // items that may be sampled.  An index of -1 is returned if the slice is
 // empty or the predicate returns false for all items.
-func SampleFiltered[T any](slice []T, pred func(T) bool) int {
+func SampleFiltered[T any](r *Rand, slice []T, pred func(T) bool) int {
 	idx := -1
 	candidates := 0
 	for i, v := range slice {
 		if pred(v) {
 			candidates++
 			p := float32(1) / float32(candidates)
-			if Float32() < p {
+			if r.Float32() < p {
 				idx = i
 			}
 		}
@@ -129,7 +132,7 @@ func SampleFiltered[T any](slice []T, pred func(T) bool) int {
 // SampleWeighted randomly samples an element from the given slice with the
 // probability of choosing each element proportional to the value returned
 // by the provided callback.
-func SampleWeighted[T any](slice []T, weight func(T) int) int {
+func SampleWeighted[T any](r *Rand, slice []T, weight func(T) int) int {
 	// Weighted reservoir sampling...
 	idx := -1
 	sumWt := 0
@@ -141,7 +144,7 @@ func SampleWeighted[T any](slice []T, weight func(T) int) int {
 
 		sumWt += w
 		p := float32(w) / float32(sumWt)
-		if Float32() < p {
+		if r.Float32() < p {
 			idx = i
 		}
 	}
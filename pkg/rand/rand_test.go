@@ -23,19 +23,20 @@ func TestPermutationElement(t *testing.T) {
 }
 
 func TestSampleFiltered(t *testing.T) {
-	if SampleFiltered([]int{}, func(int) bool { return true }) != -1 {
+	r := New()
+	if SampleFiltered(r, []int{}, func(int) bool { return true }) != -1 {
 		t.Errorf("Returned non-zero for empty slice")
 	}
-	if SampleFiltered([]int{0, 1, 2, 3, 4}, func(int) bool { return false }) != -1 {
+	if SampleFiltered(r, []int{0, 1, 2, 3, 4}, func(int) bool { return false }) != -1 {
 		t.Errorf("Returned non-zero for fully filtered")
 	}
-	if idx := SampleFiltered([]int{0, 1, 2, 3, 4}, func(v int) bool { return v == 3 }); idx != 3 {
+	if idx := SampleFiltered(r, []int{0, 1, 2, 3, 4}, func(v int) bool { return v == 3 }); idx != 3 {
 		t.Errorf("Returned %d rather than 3 for filtered slice", idx)
 	}
 
 	var counts [5]int
 	for i := 0; i < 9000; i++ {
-		idx := SampleFiltered([]int{0, 1, 2, 3, 4}, func(v int) bool { return v&1 == 0 })
+		idx := SampleFiltered(r, []int{0, 1, 2, 3, 4}, func(v int) bool { return v&1 == 0 })
 		counts[idx]++
 	}
 	if counts[1] != 0 || counts[3] != 0 {
@@ -53,10 +54,11 @@ func TestSampleFiltered(t *testing.T) {
 func TestSampleWeighted(t *testing.T) {
 	a := []int{1, 2, 3, 4, 5, 0, 10, 13}
 	counts := make([]int, len(a))
+	r := New()
 
 	n := 100000
 	for i := 0; i < n; i++ {
-		idx := SampleWeighted(a, func(v int) int { return v })
+		idx := SampleWeighted(r, a, func(v int) int { return v })
 		counts[idx]++
 	}
 
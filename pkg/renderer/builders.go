@@ -577,8 +577,6 @@ func (td *TextDrawBuilder) AddTextMulti(text []string, p [2]float32, styles []Te
 		}
 
 		for _, ch := range text[i] {
-			glyph := style.Font.LookupGlyph(ch)
-
 			if ch == '\n' {
 				// End of line handling. First emit the background quad, if
 				// selected.
@@ -598,6 +596,10 @@ func (td *TextDrawBuilder) AddTextMulti(text []string, p [2]float32, styles []Te
 				continue
 			}
 
+			// The glyph may come from one of the font's fallback fonts,
+			// which may use a different texture.
+			font, glyph := style.Font.ResolveGlyph(ch)
+
 			// Don't do any drawing if the glyph is marked as invisible;
 			// beyond the small perf. cost, we'll end up getting "?" and
 			// the like if we do this anyway.
@@ -605,10 +607,10 @@ func (td *TextDrawBuilder) AddTextMulti(text []string, p [2]float32, styles []Te
 				if td.regular == nil {
 					td.regular = make(map[uint32]*TextBuffers)
 				}
-				if _, ok := td.regular[style.Font.TexId]; !ok {
-					td.regular[style.Font.TexId] = &TextBuffers{}
+				if _, ok := td.regular[font.TexId]; !ok {
+					td.regular[font.TexId] = &TextBuffers{}
 				}
-				td.regular[style.Font.TexId].Add([2]float32{px, py}, glyph, style.Color)
+				td.regular[font.TexId].Add([2]float32{px, py}, glyph, style.Color)
 			}
 
 			// Visible or not, advance the x cursor position to move to the next character.
@@ -15,6 +15,7 @@ import (
 	"unicode/utf8"
 	"unsafe"
 
+	"github.com/mmp/vice/pkg/math"
 	"github.com/mmp/vice/pkg/platform"
 	"github.com/mmp/vice/pkg/util"
 
@@ -36,6 +37,15 @@ type Font struct {
 	Ifont imgui.Font
 	Id    FontIdentifier
 	TexId uint32 // texture that holds the glyph texture atlas
+
+	// Fonts that are used, in order, to draw characters that this font
+	// doesn't have a glyph for; see SetFallbackFonts.
+	fallbacks []*Font
+	// Is the font backed by an imgui font? If not, all of its glyphs must
+	// be provided via AddGlyph.
+	hasIfont bool
+	// Characters that imgui doesn't have a glyph for in this font.
+	missing map[rune]interface{}
 }
 
 func MakeFont(size int, mono bool, id FontIdentifier, ifont *imgui.Font) *Font {
@@ -47,6 +57,7 @@ func MakeFont(size int, mono bool, id FontIdentifier, ifont *imgui.Font) *Font {
 	}
 	if ifont != nil {
 		f.Ifont = *ifont
+		f.hasIfont = true
 	}
 	return f
 }
@@ -115,6 +126,56 @@ func (f *Font) LookupGlyph(ch rune) *Glyph {
 	}
 }
 
+// SetFallbackFonts specifies fonts to use, in order, for characters that
+// the font doesn't have a glyph for. Only the given fonts are consulted;
+// their own fallback fonts are not.
+func (f *Font) SetFallbackFonts(fallbacks ...*Font) {
+	f.fallbacks = fallbacks
+}
+
+// HasGlyph reports whether the font has a glyph for the specified rune.
+func (f *Font) HasGlyph(ch rune) bool {
+	if int(ch) < len(f.lowGlyphs) {
+		if f.lowGlyphs[ch] != nil {
+			return true
+		}
+	} else if _, ok := f.glyphs[ch]; ok {
+		return true
+	}
+
+	if !f.hasIfont {
+		return false
+	}
+	if _, ok := f.missing[ch]; ok {
+		return false
+	}
+	// imgui returns the fallback glyph, '?', for characters the font
+	// doesn't have.
+	if ch != '?' && f.Ifont.FindGlyph(ch) == f.Ifont.FindGlyph('?') {
+		if f.missing == nil {
+			f.missing = make(map[rune]interface{})
+		}
+		f.missing[ch] = nil
+		return false
+	}
+	return true
+}
+
+// ResolveGlyph returns the glyph for the specified rune along with the
+// font it comes from: the font itself if it has a glyph for it, and
+// otherwise the first of its fallback fonts that does. The glyph must be
+// drawn using the returned font's texture.
+func (f *Font) ResolveGlyph(ch rune) (*Font, *Glyph) {
+	if len(f.fallbacks) > 0 && !f.HasGlyph(ch) {
+		for _, fb := range f.fallbacks {
+			if fb.HasGlyph(ch) {
+				return fb, fb.LookupGlyph(ch)
+			}
+		}
+	}
+	return f, f.LookupGlyph(ch)
+}
+
 // Returns the bound of the specified text in the given font, assuming the
 // given pixel spacing between lines.
 func (font *Font) BoundText(s string, spacing int) (int, int) {
@@ -126,7 +187,7 @@ func (font *Font) BoundText(s string, spacing int) (int, int) {
 			px = 0
 			py += dy
 		} else {
-			glyph := font.LookupGlyph(ch)
+			_, glyph := font.ResolveGlyph(ch)
 			px += glyph.AdvanceX
 			if px > xmax {
 				xmax = px
@@ -151,6 +212,7 @@ var (
 	FontAwesomeIconArrowUp             = faUsedIcons["ArrowUp"]
 	FontAwesomeIconBook                = faUsedIcons["Book"]
 	FontAwesomeIconBug                 = faUsedIcons["Bug"]
+	FontAwesomeIconCamera              = faUsedIcons["Camera"]
 	FontAwesomeIconCaretDown           = faUsedIcons["CaretDown"]
 	FontAwesomeIconCaretRight          = faUsedIcons["CaretRight"]
 	FontAwesomeIconCheckSquare         = faUsedIcons["CheckSquare"]
@@ -176,6 +238,7 @@ var (
 	FontAwesomeIconPlaneDeparture      = faUsedIcons["PlaneDeparture"]
 	FontAwesomeIconRedo                = faUsedIcons["Redo"]
 	FontAwesomeIconSquare              = faUsedIcons["Square"]
+	FontAwesomeIconStopCircle          = faUsedIcons["StopCircle"]
 	FontAwesomeIconTrash               = faUsedIcons["Trash"]
 )
 
@@ -193,6 +256,7 @@ var (
 		"ArrowUp":             FontAwesomeString("ArrowUp"),
 		"Book":                FontAwesomeString("Book"),
 		"Bug":                 FontAwesomeString("Bug"),
+		"Camera":              FontAwesomeString("Camera"),
 		"CaretDown":           FontAwesomeString("CaretDown"),
 		"CaretRight":          FontAwesomeString("CaretRight"),
 		"CheckSquare":         FontAwesomeString("CheckSquare"),
@@ -216,6 +280,7 @@ var (
 		"PlaneDeparture":      FontAwesomeString("PlaneDeparture"),
 		"Redo":                FontAwesomeString("Redo"),
 		"Square":              FontAwesomeString("Square"),
+		"StopCircle":          FontAwesomeString("StopCircle"),
 		"Trash":               FontAwesomeString("Trash"),
 	}
 	faBrandsUsedIcons map[string]string = map[string]string{
@@ -234,7 +299,10 @@ func ptrToUint16Slice(p unsafe.Pointer) []uint16 {
 	return (*[unrealisticLargePointer / 2]uint16)(p)[:]
 }
 
-func FontsInit(r Renderer, p platform.Platform) {
+// FontsInit loads all of the fonts. The provided font identifiers should
+// be those from the saved configuration; any that refer to fonts that are
+// no longer available are remapped to the closest available font.
+func FontsInit(r Renderer, p platform.Platform, saved ...*FontIdentifier) {
 	lg.Info("Starting to initialize fonts")
 	fonts = make(map[FontIdentifier]*Font)
 	io := imgui.CurrentIO()
@@ -295,7 +363,7 @@ func FontsInit(r Renderer, p platform.Platform) {
 			io.Fonts().AddFontFromMemoryTTFV(fabrTTF, .8*sp, config, faBrandsGlyphRange)
 
 			id := FontIdentifier{Name: name, Size: size}
-			fonts[id] = MakeFont(int(sp), mono, id, &ifont)
+			AddFont(MakeFont(int(sp), mono, id, &ifont))
 		}
 	}
 
@@ -323,9 +391,38 @@ func FontsInit(r Renderer, p platform.Platform) {
 		font.TexId = atlasId
 	}
 
+	// Draw characters that are missing from the other fonts using Roboto
+	// Regular, which has the widest coverage.
+	for id, font := range fonts {
+		if id.Name != "Roboto Regular" {
+			font.SetFallbackFonts(GetClosestFont(FontIdentifier{Name: "Roboto Regular", Size: id.Size}))
+		}
+	}
+
+	remapFonts(saved)
+
 	lg.Info("Finished initializing fonts")
 }
 
+// AddFont makes the font available via GetFont and GetClosestFont.
+func AddFont(f *Font) {
+	if fonts == nil {
+		fonts = make(map[FontIdentifier]*Font)
+	}
+	fonts[f.Id] = f
+}
+
+// remapFonts updates the given font identifiers to refer to the closest
+// available font if the font they refer to isn't available.
+func remapFonts(ids []*FontIdentifier) {
+	for _, id := range ids {
+		if f := GetClosestFont(*id); f.Id != *id {
+			lg.Infof("%+v: remapping unavailable font to %+v", *id, f.Id)
+			*id = f.Id
+		}
+	}
+}
+
 // getAllFonts returns a FontIdentifier slice that gives identifiers for
 // all of the available fonts, sorted by font name and then within each
 // name, by font size.
@@ -355,12 +452,15 @@ func DrawFontPicker(id *FontIdentifier, label string) (newFont *Font, changed bo
 			if font.Name != lastFontName {
 				lastFontName = font.Name
 				// Use the 14pt version of the font in the combo box.
-				displayFont := GetFont(FontIdentifier{Name: font.Name, Size: 14})
+				// (Or whatever size is closest, if there isn't one.)
+				displayFont := GetClosestFont(FontIdentifier{Name: font.Name, Size: 14})
 				imgui.PushFont(displayFont.Ifont)
 				if imgui.SelectableV(font.Name, id.Name == font.Name, 0, imgui.Vec2{}) {
-					id.Name = font.Name
+					// The new font may not be available at the current
+					// size, in which case the closest one is used.
+					newFont = GetClosestFont(FontIdentifier{Name: font.Name, Size: id.Size})
+					*id = newFont.Id
 					changed = true
-					newFont = GetFont(*id)
 				}
 				imgui.PopFont()
 			}
@@ -400,6 +500,39 @@ func GetFont(id FontIdentifier) *Font {
 	}
 }
 
+// GetClosestFont returns the font with the given identifier if it is
+// available. Otherwise it returns the font with the same name and the
+// closest size, or the default font if there is no font with that name.
+// It should be used for font identifiers that come from saved
+// configurations, which may refer to fonts that are no longer included
+// with vice.
+func GetClosestFont(id FontIdentifier) *Font {
+	if font, ok := fonts[id]; ok {
+		return font
+	}
+
+	var closest *Font
+	for fid, font := range fonts {
+		if fid.Name != id.Name {
+			continue
+		}
+		if closest == nil {
+			closest = font
+		} else {
+			d, dc := math.Abs(fid.Size-id.Size), math.Abs(closest.Id.Size-id.Size)
+			// Prefer the smaller size in the event of a tie.
+			if d < dc || (d == dc && fid.Size < closest.Id.Size) {
+				closest = font
+			}
+		}
+	}
+	if closest == nil {
+		lg.Warnf("%s: font not available; using default", id.Name)
+		return GetDefaultFont()
+	}
+	return closest
+}
+
 func GetDefaultFont() *Font {
 	return GetFont(FontIdentifier{Name: "Roboto Regular", Size: 14})
 }
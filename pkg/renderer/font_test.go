@@ -0,0 +1,114 @@
+// pkg/renderer/font_test.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package renderer
+
+import (
+	"testing"
+)
+
+func TestGetClosestFont(t *testing.T) {
+	saved := fonts
+	defer func() { fonts = saved }()
+
+	fonts = make(map[FontIdentifier]*Font)
+	for _, id := range []FontIdentifier{
+		{Name: "Roboto Regular", Size: 12},
+		{Name: "Roboto Regular", Size: 14},
+		{Name: "Inconsolata Condensed Regular", Size: 16},
+		{Name: "Inconsolata Condensed Regular", Size: 20},
+	} {
+		fonts[id] = &Font{Size: id.Size, Id: id}
+	}
+
+	for _, test := range []struct {
+		id, expected FontIdentifier
+	}{
+		// Available fonts are returned as is.
+		{FontIdentifier{Name: "Inconsolata Condensed Regular", Size: 20}, FontIdentifier{Name: "Inconsolata Condensed Regular", Size: 20}},
+		// Unavailable sizes map to the closest one, preferring smaller.
+		{FontIdentifier{Name: "Inconsolata Condensed Regular", Size: 17}, FontIdentifier{Name: "Inconsolata Condensed Regular", Size: 16}},
+		{FontIdentifier{Name: "Inconsolata Condensed Regular", Size: 18}, FontIdentifier{Name: "Inconsolata Condensed Regular", Size: 16}},
+		{FontIdentifier{Name: "Inconsolata Condensed Regular", Size: 48}, FontIdentifier{Name: "Inconsolata Condensed Regular", Size: 20}},
+		// Unknown fonts (e.g., from an old config) give the default.
+		{FontIdentifier{Name: "Bogus Font", Size: 16}, FontIdentifier{Name: "Roboto Regular", Size: 14}},
+		{FontIdentifier{}, FontIdentifier{Name: "Roboto Regular", Size: 14}},
+	} {
+		f := GetClosestFont(test.id)
+		if f == nil {
+			t.Errorf("%+v: got nil font", test.id)
+		} else if f.Id != test.expected {
+			t.Errorf("%+v: got %+v, expected %+v", test.id, f.Id, test.expected)
+		}
+	}
+}
+
+func TestRemapFonts(t *testing.T) {
+	saved := fonts
+	defer func() { fonts = saved }()
+
+	fonts = nil
+	AddFont(&Font{Size: 14, Id: FontIdentifier{Name: "Roboto Regular", Size: 14}})
+	AddFont(&Font{Size: 16, Id: FontIdentifier{Name: "Inconsolata Condensed Regular", Size: 16}})
+
+	ok := FontIdentifier{Name: "Inconsolata Condensed Regular", Size: 16}
+	size := FontIdentifier{Name: "Inconsolata Condensed Regular", Size: 15}
+	stale := FontIdentifier{Name: "Bogus Font", Size: 16}
+	remapFonts([]*FontIdentifier{&ok, &size, &stale})
+
+	if ok != (FontIdentifier{Name: "Inconsolata Condensed Regular", Size: 16}) {
+		t.Errorf("available font was remapped to %+v", ok)
+	}
+	if size != (FontIdentifier{Name: "Inconsolata Condensed Regular", Size: 16}) {
+		t.Errorf("unavailable size was remapped to %+v", size)
+	}
+	if stale != (FontIdentifier{Name: "Roboto Regular", Size: 14}) {
+		t.Errorf("unavailable font was remapped to %+v", stale)
+	}
+}
+
+func TestFontFallback(t *testing.T) {
+	glyph := func(adv float32) *Glyph { return &Glyph{AdvanceX: adv, Visible: true} }
+
+	// Fonts without an imgui font only have the glyphs added to them.
+	a := MakeFont(10, true, FontIdentifier{Name: "A", Size: 10}, nil)
+	a.TexId = 1
+	a.AddGlyph('A', glyph(1))
+	a.AddGlyph(0x80, glyph(1))
+
+	b := MakeFont(10, true, FontIdentifier{Name: "B", Size: 10}, nil)
+	b.TexId = 2
+	b.AddGlyph('A', glyph(2))
+	b.AddGlyph(0x1e, glyph(2))
+
+	if !b.HasGlyph(0x1e) || b.HasGlyph(0x80) {
+		t.Errorf("HasGlyph returned incorrect results")
+	}
+
+	b.SetFallbackFonts(a)
+	a.SetFallbackFonts(b) // make sure cycles don't cause trouble
+
+	for _, test := range []struct {
+		font     *Font
+		ch       rune
+		expected *Font
+	}{
+		{b, 'A', b},
+		{b, 0x1e, b},
+		{b, 0x80, a},
+		{a, 0x1e, b},
+		{a, 'A', a},
+	} {
+		if f, g := test.font.ResolveGlyph(test.ch); f != test.expected {
+			t.Errorf("%s %q: got glyph from font %s, expected %s", test.font.Id.Name, test.ch, f.Id.Name, test.expected.Id.Name)
+		} else if g != f.LookupGlyph(test.ch) {
+			t.Errorf("%s %q: glyph mismatch", test.font.Id.Name, test.ch)
+		}
+	}
+
+	// The bounds should account for the advance of the fallback glyph.
+	if w, _ := b.BoundText("A\u0080\x1e", 0); w != 2+1+2 {
+		t.Errorf("BoundText: got width %d, expected 5", w)
+	}
+}
@@ -7,7 +7,9 @@ package renderer
 import (
 	"fmt"
 	"image"
+	"image/png"
 	"log/slog"
+	"os"
 )
 
 // Renderer defines an interface for all of the various drawing that happens in vice.
@@ -48,6 +50,38 @@ type Renderer interface {
 	Dispose()
 }
 
+// ReadImage returns the contents of the given region of the framebuffer,
+// where (x,y) is the region's lower-left corner, as an image with the
+// same bounds. It should be called after the frame has been drawn but
+// before it's displayed.
+func ReadImage(r Renderer, x, y, width, height int) *image.RGBA {
+	px := r.ReadPixelRGBAs(x, y, width, height)
+	img := image.NewRGBA(image.Rect(x, y, x+width, y+height))
+
+	// The rows are returned from bottom to top.
+	for i := range height {
+		copy(img.Pix[i*img.Stride:(i+1)*img.Stride], px[(height-1-i)*4*width:(height-i)*4*width])
+	}
+	// The framebuffer's alpha isn't meaningful for what's on the screen.
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 255
+	}
+	return img
+}
+
+// WritePNG saves the image to the given file in PNG format.
+func WritePNG(fn string, img image.Image) error {
+	f, err := os.Create(fn)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
 // RendererStats encapsulates assorted statistics from rendering.
 type RendererStats struct {
 	nBuffers, bufferBytes               int
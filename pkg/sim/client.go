@@ -6,10 +6,12 @@ package sim
 
 import (
 	"fmt"
+	"log/slog"
 	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	av "github.com/mmp/vice/pkg/aviation"
@@ -455,6 +457,16 @@ func (c *ControlClient) DeleteRestrictionArea(idx int, success func(any), err fu
 		})
 }
 
+func (c *ControlClient) SetRadarSiteFailed(site string, failed bool, success func(any), err func(error)) {
+	c.pendingCalls = append(c.pendingCalls,
+		&util.PendingCall{
+			Call:      c.proxy.SetRadarSiteFailed(site, failed),
+			IssueTime: time.Now(),
+			OnSuccess: success,
+			OnErr:     err,
+		})
+}
+
 func (c *ControlClient) GetVideoMapLibrary(filename string) (*av.VideoMapLibrary, error) {
 	var vmf av.VideoMapLibrary
 	err := c.proxy.GetVideoMapLibrary(filename, &vmf)
@@ -478,6 +490,7 @@ func (c *ControlClient) GetUpdates(eventStream *EventStream, onErr func(error))
 
 	if c.updateCall != nil {
 		if c.updateCall.CheckFinished() {
+			recordRPCTiming(c.updateCall) // slow updates are logged in OnSuccess
 			c.updateCall = nil
 			return
 		}
@@ -531,6 +544,7 @@ func (c *ControlClient) UpdateWorld(wu *WorldUpdate, eventStream *EventStream) {
 	c.State.TotalArrivals = wu.TotalArrivals
 	c.State.TotalOverflights = wu.TotalOverflights
 	c.State.Instructors = wu.Instructors
+	c.State.FailedRadarSites = wu.FailedRadarSites
 
 	// Important: do this after updating aircraft, controllers, etc.,
 	// so that they reflect any changes the events are flagging.
@@ -541,7 +555,15 @@ func (c *ControlClient) UpdateWorld(wu *WorldUpdate, eventStream *EventStream) {
 
 func (c *ControlClient) checkPendingRPCs(eventStream *EventStream, onErr func(error)) {
 	c.pendingCalls = util.FilterSlice(c.pendingCalls,
-		func(call *util.PendingCall) bool { return !call.CheckFinished() })
+		func(call *util.PendingCall) bool {
+			if call.CheckFinished() {
+				if d := recordRPCTiming(call); d > slowRPCThreshold {
+					c.lg.Warnf("%s: slow RPC response %s", call.Call.ServiceMethod, d)
+				}
+				return false
+			}
+			return true
+		})
 
 	for _, call := range c.pendingCalls {
 		if checkTimeout(call, eventStream, onErr) {
@@ -594,6 +616,34 @@ func (c *ControlClient) SetSimRate(r float32) {
 	c.SimRate = r // so the UI is well-behaved...
 }
 
+// AdvanceTime immediately runs the simulation forward by the given
+// amount of simulated time, regardless of whether it is paused.
+func (c *ControlClient) AdvanceTime(d time.Duration, success func(any), err func(error)) {
+	c.pendingCalls = append(c.pendingCalls, &util.PendingCall{
+		Call:      c.proxy.AdvanceTime(d),
+		IssueTime: time.Now(),
+		OnSuccess: success,
+		OnErr:     err,
+	})
+}
+
+// StartRecording starts recording the session to the given file, which
+// can later be replayed using StartReplay.
+func (c *ControlClient) StartRecording(filename string) error {
+	return c.proxy.StartRecording(filename)
+}
+
+func (c *ControlClient) StopRecording() error {
+	return c.proxy.StopRecording()
+}
+
+// StartReplay starts replaying the given recording; the client must be
+// connected to a Sim that was loaded from the recording's header and
+// that is paused. paused gives whether the Sim should remain paused.
+func (c *ControlClient) StartReplay(rec *Recording, paused bool) error {
+	return c.proxy.StartReplay(rec.Seed, rec.Commands, paused)
+}
+
 func (c *ControlClient) SetLaunchConfig(lc LaunchConfig) {
 	c.pendingCalls = append(c.pendingCalls, &util.PendingCall{
 		Call:      c.proxy.SetLaunchConfig(lc),
@@ -1112,3 +1162,86 @@ func (c *ControlClient) DrawScenarioInfoWindow(lg *log.Logger) (show bool) {
 	imgui.End()
 	return
 }
+
+///////////////////////////////////////////////////////////////////////////
+// RPC timing
+
+// slowRPCThreshold is the response time above which individual RPCs are
+// logged. (World updates have their own, lower, threshold.)
+const slowRPCThreshold = 500 * time.Millisecond
+
+// rpcLatencyBuckets gives the upper bounds of the latency histogram
+// buckets; there is an additional bucket for anything slower than the
+// last one.
+var rpcLatencyBuckets = [...]time.Duration{50 * time.Millisecond, 100 * time.Millisecond,
+	250 * time.Millisecond, 500 * time.Millisecond, time.Second}
+
+type rpcMethodTimings struct {
+	count     int
+	total     time.Duration
+	max       time.Duration
+	histogram [len(rpcLatencyBuckets) + 1]int
+}
+
+func (t rpcMethodTimings) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Int("count", t.count),
+		slog.Duration("mean", t.total/time.Duration(max(t.count, 1))),
+		slog.Duration("max", t.max),
+		slog.Any("histogram", t.histogram[:]))
+}
+
+// RPCTimingStats holds the latency statistics for the RPCs issued by
+// ControlClients, keyed by service method.
+type RPCTimingStats map[string]rpcMethodTimings
+
+func (s RPCTimingStats) LogValue() slog.Value {
+	var attrs []slog.Attr
+	for _, method := range util.SortedMapKeys(s) {
+		attrs = append(attrs, slog.Any(method, s[method]))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+var rpcTimings struct {
+	mu    sync.Mutex
+	stats RPCTimingStats
+}
+
+// GetRPCTimingStats returns a copy of the RPC latency statistics gathered
+// so far.
+func GetRPCTimingStats() RPCTimingStats {
+	rpcTimings.mu.Lock()
+	defer rpcTimings.mu.Unlock()
+	return maps.Clone(rpcTimings.stats)
+}
+
+// recordRPCTiming should be called once a pending call has finished; it
+// returns the call's latency. Note that since calls are polled once per
+// frame, the recorded latency includes up to a frame's worth of delay.
+func recordRPCTiming(call *util.PendingCall) time.Duration {
+	method := call.Call.ServiceMethod
+	d := time.Since(call.IssueTime)
+
+	rpcTimings.mu.Lock()
+	defer rpcTimings.mu.Unlock()
+
+	if rpcTimings.stats == nil {
+		rpcTimings.stats = make(RPCTimingStats)
+	}
+	t := rpcTimings.stats[method]
+	t.count++
+	t.total += d
+	t.max = max(t.max, d)
+	bucket := len(rpcLatencyBuckets)
+	for i, b := range rpcLatencyBuckets {
+		if d <= b {
+			bucket = i
+			break
+		}
+	}
+	t.histogram[bucket]++
+	rpcTimings.stats[method] = t
+
+	return d
+}
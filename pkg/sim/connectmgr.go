@@ -5,6 +5,7 @@
 package sim
 
 import (
+	"errors"
 	"log/slog"
 	"time"
 
@@ -61,6 +62,9 @@ func (cm *ConnectionManager) LoadLocalSim(s *Sim, lg *log.Logger) (*ControlClien
 		return nil, err
 	}
 
+	if cm.client != nil {
+		cm.client.Disconnect()
+	}
 	cm.client = NewControlClient(*result.SimState, result.ControllerToken, cm.localServer.RPCClient, lg)
 	cm.connectionStartTime = time.Now()
 
@@ -119,10 +123,10 @@ func (cm *ConnectionManager) Update(es *EventStream, lg *log.Logger) {
 		if err := remoteServerConn.Err; err != nil {
 			lg.Warn("Unable to connect to remote server", slog.Any("error", err))
 
-			if err.Error() == ErrRPCVersionMismatch.Error() {
+			if errors.Is(err, ErrRPCVersionMismatch) {
 				cm.serverRPCVersionMismatch = true
 				if cm.onError != nil {
-					cm.onError(ErrRPCVersionMismatch)
+					cm.onError(err)
 				}
 			}
 			cm.remoteServer = nil
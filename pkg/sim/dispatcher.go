@@ -7,6 +7,7 @@ package sim
 import (
 	"strconv"
 	"strings"
+	"time"
 
 	av "github.com/mmp/vice/pkg/aviation"
 	"github.com/mmp/vice/pkg/math"
@@ -15,6 +16,22 @@ import (
 
 type Dispatcher struct {
 	sm *SimManager
+	// replaying is set for the Dispatcher that a Sim uses to reissue
+	// the commands from a recorded session.
+	replaying bool
+}
+
+// command should be called by the methods that modify the Sim, with the
+// command's type and arguments, before calling into the Sim; the function
+// it returns must be called with the method's error result when the
+// method is done. See Sim.command.
+func (sd *Dispatcher) command(sim *Sim, cmd RecordedCommandType, args any) func(*error) {
+	if sd.replaying {
+		// The Sim is running the command itself and is already
+		// holding its command lock.
+		return func(*error) {}
+	}
+	return sim.command(cmd, args)
 }
 
 func (sd *Dispatcher) GetWorldUpdate(token string, update *WorldUpdate) error {
@@ -37,6 +54,9 @@ func (sd *Dispatcher) SignOff(token string, _ *struct{}) error {
 	if sim, ok := sd.sm.ControllerTokenToSim(token); !ok {
 		return ErrNoSimForControllerToken
 	} else {
+		// Only local sims are recorded, so the recording is over once
+		// their controller has signed off.
+		defer sim.finishRecording()
 		return sim.SignOff(token)
 	}
 }
@@ -47,22 +67,24 @@ type ChangeControlPositionArgs struct {
 	KeepTracks      bool
 }
 
-func (sd *Dispatcher) ChangeControlPosition(cs *ChangeControlPositionArgs, _ *struct{}) error {
+func (sd *Dispatcher) ChangeControlPosition(cs *ChangeControlPositionArgs, _ *struct{}) (err error) {
 	defer sd.sm.lg.CatchAndReportCrash()
 
 	if sim, ok := sd.sm.ControllerTokenToSim(cs.ControllerToken); !ok {
 		return ErrNoSimForControllerToken
 	} else {
+		defer sd.command(sim, ChangeControlPositionCommand, cs)(&err)
 		return sim.ChangeControlPosition(cs.ControllerToken, cs.Callsign, cs.KeepTracks)
 	}
 }
 
-func (sd *Dispatcher) TakeOrReturnLaunchControl(token string, _ *struct{}) error {
+func (sd *Dispatcher) TakeOrReturnLaunchControl(token string, _ *struct{}) (err error) {
 	defer sd.sm.lg.CatchAndReportCrash()
 
 	if sim, ok := sd.sm.ControllerTokenToSim(token); !ok {
 		return ErrNoSimForControllerToken
 	} else {
+		defer sd.command(sim, TakeOrReturnLaunchControlCommand, token)(&err)
 		return sim.TakeOrReturnLaunchControl(token)
 	}
 }
@@ -82,17 +104,76 @@ func (sd *Dispatcher) SetSimRate(r *SetSimRateArgs, _ *struct{}) error {
 	}
 }
 
+type AdvanceTimeArgs struct {
+	ControllerToken string
+	Duration        time.Duration
+}
+
+func (sd *Dispatcher) AdvanceTime(a *AdvanceTimeArgs, _ *struct{}) error {
+	defer sd.sm.lg.CatchAndReportCrash()
+
+	if sim, ok := sd.sm.controllerTokenToSim[a.ControllerToken]; !ok {
+		return ErrNoSimForControllerToken
+	} else {
+		return sim.AdvanceTime(a.ControllerToken, a.Duration)
+	}
+}
+
+type RecordingArgs struct {
+	ControllerToken string
+	Filename        string
+}
+
+func (sd *Dispatcher) StartRecording(r *RecordingArgs, _ *struct{}) error {
+	defer sd.sm.lg.CatchAndReportCrash()
+
+	if sim, ok := sd.sm.ControllerTokenToSim(r.ControllerToken); !ok {
+		return ErrNoSimForControllerToken
+	} else {
+		return sim.StartRecording(r.ControllerToken, r.Filename)
+	}
+}
+
+func (sd *Dispatcher) StopRecording(token string, _ *struct{}) error {
+	defer sd.sm.lg.CatchAndReportCrash()
+
+	if sim, ok := sd.sm.ControllerTokenToSim(token); !ok {
+		return ErrNoSimForControllerToken
+	} else {
+		return sim.StopRecording(token)
+	}
+}
+
+type StartReplayArgs struct {
+	ControllerToken string
+	Seed            int64
+	Commands        []RecordedCommand
+	Paused          bool
+}
+
+func (sd *Dispatcher) StartReplay(r *StartReplayArgs, _ *struct{}) error {
+	defer sd.sm.lg.CatchAndReportCrash()
+
+	if sim, ok := sd.sm.ControllerTokenToSim(r.ControllerToken); !ok {
+		return ErrNoSimForControllerToken
+	} else {
+		rd := &Dispatcher{sm: sd.sm, replaying: true}
+		return sim.StartReplay(r.ControllerToken, rd, r.Seed, r.Commands, r.Paused)
+	}
+}
+
 type SetLaunchConfigArgs struct {
 	ControllerToken string
 	Config          LaunchConfig
 }
 
-func (sd *Dispatcher) SetLaunchConfig(lc *SetLaunchConfigArgs, _ *struct{}) error {
+func (sd *Dispatcher) SetLaunchConfig(lc *SetLaunchConfigArgs, _ *struct{}) (err error) {
 	defer sd.sm.lg.CatchAndReportCrash()
 
 	if sim, ok := sd.sm.controllerTokenToSim[lc.ControllerToken]; !ok {
 		return ErrNoSimForControllerToken
 	} else {
+		defer sd.command(sim, SetLaunchConfigCommand, lc)(&err)
 		return sim.SetLaunchConfig(lc.ControllerToken, lc.Config)
 	}
 }
@@ -113,32 +194,35 @@ type SetScratchpadArgs struct {
 	Scratchpad      string
 }
 
-func (sd *Dispatcher) SetScratchpad(a *SetScratchpadArgs, _ *struct{}) error {
+func (sd *Dispatcher) SetScratchpad(a *SetScratchpadArgs, _ *struct{}) (err error) {
 	defer sd.sm.lg.CatchAndReportCrash()
 
 	if sim, ok := sd.sm.controllerTokenToSim[a.ControllerToken]; !ok {
 		return ErrNoSimForControllerToken
 	} else {
+		defer sd.command(sim, SetScratchpadCommand, a)(&err)
 		return sim.SetScratchpad(a.ControllerToken, a.Callsign, a.Scratchpad)
 	}
 }
 
-func (sd *Dispatcher) SetSecondaryScratchpad(a *SetScratchpadArgs, _ *struct{}) error {
+func (sd *Dispatcher) SetSecondaryScratchpad(a *SetScratchpadArgs, _ *struct{}) (err error) {
 	defer sd.sm.lg.CatchAndReportCrash()
 
 	if sim, ok := sd.sm.controllerTokenToSim[a.ControllerToken]; !ok {
 		return ErrNoSimForControllerToken
 	} else {
+		defer sd.command(sim, SetSecondaryScratchpadCommand, a)(&err)
 		return sim.SetSecondaryScratchpad(a.ControllerToken, a.Callsign, a.Scratchpad)
 	}
 }
 
-func (sd *Dispatcher) AutoAssociateFP(it *InitiateTrackArgs, _ *struct{}) error {
+func (sd *Dispatcher) AutoAssociateFP(it *InitiateTrackArgs, _ *struct{}) (err error) {
 	defer sd.sm.lg.CatchAndReportCrash()
 
 	if sim, ok := sd.sm.controllerTokenToSim[it.ControllerToken]; !ok {
 		return ErrNoSimForControllerToken
 	} else {
+		defer sd.command(sim, AutoAssociateFPCommand, it)(&err)
 		return sim.AutoAssociateFP(it.ControllerToken, it.Callsign, it.Plan)
 	}
 }
@@ -149,12 +233,13 @@ type SetGlobalLeaderLineArgs struct {
 	Direction       *math.CardinalOrdinalDirection
 }
 
-func (sd *Dispatcher) SetGlobalLeaderLine(a *SetGlobalLeaderLineArgs, _ *struct{}) error {
+func (sd *Dispatcher) SetGlobalLeaderLine(a *SetGlobalLeaderLineArgs, _ *struct{}) (err error) {
 	defer sd.sm.lg.CatchAndReportCrash()
 
 	if sim, ok := sd.sm.controllerTokenToSim[a.ControllerToken]; !ok {
 		return ErrNoSimForControllerToken
 	} else {
+		defer sd.command(sim, SetGlobalLeaderLineCommand, a)(&err)
 		return sim.SetGlobalLeaderLine(a.ControllerToken, a.Callsign, a.Direction)
 	}
 }
@@ -164,12 +249,13 @@ type InitiateTrackArgs struct {
 	Plan *STARSFlightPlan
 }
 
-func (sd *Dispatcher) InitiateTrack(it *InitiateTrackArgs, _ *struct{}) error {
+func (sd *Dispatcher) InitiateTrack(it *InitiateTrackArgs, _ *struct{}) (err error) {
 	defer sd.sm.lg.CatchAndReportCrash()
 
 	if sim, ok := sd.sm.controllerTokenToSim[it.ControllerToken]; !ok {
 		return ErrNoSimForControllerToken
 	} else {
+		defer sd.command(sim, InitiateTrackCommand, it)(&err)
 		return sim.InitiateTrack(it.ControllerToken, it.Callsign, it.Plan)
 	}
 }
@@ -180,12 +266,13 @@ type CreateUnsupportedTrackArgs struct {
 	UnsupportedTrack *UnsupportedTrack
 }
 
-func (sd *Dispatcher) CreateUnsupportedTrack(it *CreateUnsupportedTrackArgs, _ *struct{}) error {
+func (sd *Dispatcher) CreateUnsupportedTrack(it *CreateUnsupportedTrackArgs, _ *struct{}) (err error) {
 	defer sd.sm.lg.CatchAndReportCrash()
 
 	if sim, ok := sd.sm.controllerTokenToSim[it.ControllerToken]; !ok {
 		return ErrNoSimForControllerToken
 	} else {
+		defer sd.command(sim, CreateUnsupportedTrackCommand, it)(&err)
 		return sim.CreateUnsupportedTrack(it.ControllerToken, it.Callsign, it.UnsupportedTrack)
 	}
 }
@@ -196,12 +283,13 @@ type UploadPlanArgs struct {
 	Plan            *STARSFlightPlan
 }
 
-func (sd *Dispatcher) UploadFlightPlan(it *UploadPlanArgs, _ *struct{}) error {
+func (sd *Dispatcher) UploadFlightPlan(it *UploadPlanArgs, _ *struct{}) (err error) {
 	defer sd.sm.lg.CatchAndReportCrash()
 
 	if sim, ok := sd.sm.controllerTokenToSim[it.ControllerToken]; !ok {
 		return ErrNoSimForControllerToken
 	} else {
+		defer sd.command(sim, UploadFlightPlanCommand, it)(&err)
 		return sim.UploadFlightPlan(it.ControllerToken, it.Type, it.Plan)
 	}
 }
@@ -213,12 +301,13 @@ type AircraftSpecifier struct {
 
 type DropTrackArgs AircraftSpecifier
 
-func (sd *Dispatcher) DropTrack(dt *DropTrackArgs, _ *struct{}) error {
+func (sd *Dispatcher) DropTrack(dt *DropTrackArgs, _ *struct{}) (err error) {
 	defer sd.sm.lg.CatchAndReportCrash()
 
 	if sim, ok := sd.sm.controllerTokenToSim[dt.ControllerToken]; !ok {
 		return ErrNoSimForControllerToken
 	} else {
+		defer sd.command(sim, DropTrackCommand, dt)(&err)
 		return sim.DropTrack(dt.ControllerToken, dt.Callsign)
 	}
 }
@@ -229,56 +318,61 @@ type HandoffArgs struct {
 	Controller      string
 }
 
-func (sd *Dispatcher) HandoffTrack(h *HandoffArgs, _ *struct{}) error {
+func (sd *Dispatcher) HandoffTrack(h *HandoffArgs, _ *struct{}) (err error) {
 	defer sd.sm.lg.CatchAndReportCrash()
 
 	if sim, ok := sd.sm.controllerTokenToSim[h.ControllerToken]; !ok {
 		return ErrNoSimForControllerToken
 	} else {
+		defer sd.command(sim, HandoffTrackCommand, h)(&err)
 		return sim.HandoffTrack(h.ControllerToken, h.Callsign, h.Controller)
 	}
 }
 
-func (sd *Dispatcher) RedirectHandoff(h *HandoffArgs, _ *struct{}) error {
+func (sd *Dispatcher) RedirectHandoff(h *HandoffArgs, _ *struct{}) (err error) {
 	defer sd.sm.lg.CatchAndReportCrash()
 
 	if sim, ok := sd.sm.controllerTokenToSim[h.ControllerToken]; !ok {
 		return ErrNoSimForControllerToken
 	} else {
+		defer sd.command(sim, RedirectHandoffCommand, h)(&err)
 		return sim.RedirectHandoff(h.ControllerToken, h.Callsign, h.Controller)
 	}
 }
 
-func (sd *Dispatcher) AcceptRedirectedHandoff(po *AcceptHandoffArgs, _ *struct{}) error {
+func (sd *Dispatcher) AcceptRedirectedHandoff(po *AcceptHandoffArgs, _ *struct{}) (err error) {
 	defer sd.sm.lg.CatchAndReportCrash()
 
 	if sim, ok := sd.sm.controllerTokenToSim[po.ControllerToken]; !ok {
 		return ErrNoSimForControllerToken
 	} else {
+		defer sd.command(sim, AcceptRedirectedHandoffCommand, po)(&err)
 		return sim.AcceptRedirectedHandoff(po.ControllerToken, po.Callsign)
 	}
 }
 
 type AcceptHandoffArgs AircraftSpecifier
 
-func (sd *Dispatcher) AcceptHandoff(ah *AcceptHandoffArgs, _ *struct{}) error {
+func (sd *Dispatcher) AcceptHandoff(ah *AcceptHandoffArgs, _ *struct{}) (err error) {
 	defer sd.sm.lg.CatchAndReportCrash()
 
 	if sim, ok := sd.sm.controllerTokenToSim[ah.ControllerToken]; !ok {
 		return ErrNoSimForControllerToken
 	} else {
+		defer sd.command(sim, AcceptHandoffCommand, ah)(&err)
 		return sim.AcceptHandoff(ah.ControllerToken, ah.Callsign)
 	}
 }
 
 type CancelHandoffArgs AircraftSpecifier
 
-func (sd *Dispatcher) CancelHandoff(ch *CancelHandoffArgs, _ *struct{}) error {
+func (sd *Dispatcher) CancelHandoff(ch *CancelHandoffArgs, _ *struct{}) (err error) {
 	defer sd.sm.lg.CatchAndReportCrash()
 
 	if sim, ok := sd.sm.controllerTokenToSim[ch.ControllerToken]; !ok {
 		return ErrNoSimForControllerToken
 	} else {
+		defer sd.command(sim, CancelHandoffCommand, ch)(&err)
 		return sim.CancelHandoff(ch.ControllerToken, ch.Callsign)
 	}
 }
@@ -295,12 +389,13 @@ type ForceQLArgs struct {
 	Controller      string
 }
 
-func (sd *Dispatcher) ForceQL(ql *ForceQLArgs, _ *struct{}) error {
+func (sd *Dispatcher) ForceQL(ql *ForceQLArgs, _ *struct{}) (err error) {
 	defer sd.sm.lg.CatchAndReportCrash()
 
 	if sim, ok := sd.sm.controllerTokenToSim[ql.ControllerToken]; !ok {
 		return ErrNoSimForControllerToken
 	} else {
+		defer sd.command(sim, ForceQLCommand, ql)(&err)
 		return sim.ForceQL(ql.ControllerToken, ql.Callsign, ql.Controller)
 	}
 }
@@ -311,42 +406,46 @@ type GlobalMessageArgs struct {
 	Message         string
 }
 
-func (sd *Dispatcher) GlobalMessage(po *GlobalMessageArgs, _ *struct{}) error {
+func (sd *Dispatcher) GlobalMessage(po *GlobalMessageArgs, _ *struct{}) (err error) {
 	defer sd.sm.lg.CatchAndReportCrash()
 
 	if sim, ok := sd.sm.controllerTokenToSim[po.ControllerToken]; !ok {
 		return ErrNoSimForControllerToken
 	} else {
+		defer sd.command(sim, GlobalMessageCommand, po)(&err)
 		return sim.GlobalMessage(*po)
 	}
 }
 
-func (sd *Dispatcher) PointOut(po *PointOutArgs, _ *struct{}) error {
+func (sd *Dispatcher) PointOut(po *PointOutArgs, _ *struct{}) (err error) {
 	defer sd.sm.lg.CatchAndReportCrash()
 
 	if sim, ok := sd.sm.controllerTokenToSim[po.ControllerToken]; !ok {
 		return ErrNoSimForControllerToken
 	} else {
+		defer sd.command(sim, PointOutCommand, po)(&err)
 		return sim.PointOut(po.ControllerToken, po.Callsign, po.Controller)
 	}
 }
 
-func (sd *Dispatcher) AcknowledgePointOut(po *PointOutArgs, _ *struct{}) error {
+func (sd *Dispatcher) AcknowledgePointOut(po *PointOutArgs, _ *struct{}) (err error) {
 	defer sd.sm.lg.CatchAndReportCrash()
 
 	if sim, ok := sd.sm.controllerTokenToSim[po.ControllerToken]; !ok {
 		return ErrNoSimForControllerToken
 	} else {
+		defer sd.command(sim, AcknowledgePointOutCommand, po)(&err)
 		return sim.AcknowledgePointOut(po.ControllerToken, po.Callsign)
 	}
 }
 
-func (sd *Dispatcher) RejectPointOut(po *PointOutArgs, _ *struct{}) error {
+func (sd *Dispatcher) RejectPointOut(po *PointOutArgs, _ *struct{}) (err error) {
 	defer sd.sm.lg.CatchAndReportCrash()
 
 	if sim, ok := sd.sm.controllerTokenToSim[po.ControllerToken]; !ok {
 		return ErrNoSimForControllerToken
 	} else {
+		defer sd.command(sim, RejectPointOutCommand, po)(&err)
 		return sim.RejectPointOut(po.ControllerToken, po.Callsign)
 	}
 }
@@ -357,24 +456,26 @@ type ToggleSPCArgs struct {
 	SPC             string
 }
 
-func (sd *Dispatcher) ToggleSPCOverride(ts *ToggleSPCArgs, _ *struct{}) error {
+func (sd *Dispatcher) ToggleSPCOverride(ts *ToggleSPCArgs, _ *struct{}) (err error) {
 	defer sd.sm.lg.CatchAndReportCrash()
 
 	if sim, ok := sd.sm.controllerTokenToSim[ts.ControllerToken]; !ok {
 		return ErrNoSimForControllerToken
 	} else {
+		defer sd.command(sim, ToggleSPCOverrideCommand, ts)(&err)
 		return sim.ToggleSPCOverride(ts.ControllerToken, ts.Callsign, ts.SPC)
 	}
 }
 
 type HeldDepartureArgs AircraftSpecifier
 
-func (sd *Dispatcher) ReleaseDeparture(hd *HeldDepartureArgs, _ *struct{}) error {
+func (sd *Dispatcher) ReleaseDeparture(hd *HeldDepartureArgs, _ *struct{}) (err error) {
 	defer sd.sm.lg.CatchAndReportCrash()
 
 	if sim, ok := sd.sm.controllerTokenToSim[hd.ControllerToken]; !ok {
 		return ErrNoSimForControllerToken
 	} else {
+		defer sd.command(sim, ReleaseDepartureCommand, hd)(&err)
 		return sim.ReleaseDeparture(hd.ControllerToken, hd.Callsign)
 	}
 }
@@ -385,24 +486,26 @@ type AssignAltitudeArgs struct {
 	Altitude        int
 }
 
-func (sd *Dispatcher) SetTemporaryAltitude(alt *AssignAltitudeArgs, _ *struct{}) error {
+func (sd *Dispatcher) SetTemporaryAltitude(alt *AssignAltitudeArgs, _ *struct{}) (err error) {
 	defer sd.sm.lg.CatchAndReportCrash()
 
 	if sim, ok := sd.sm.controllerTokenToSim[alt.ControllerToken]; !ok {
 		return ErrNoSimForControllerToken
 	} else {
+		defer sd.command(sim, SetTemporaryAltitudeCommand, alt)(&err)
 		return sim.SetTemporaryAltitude(alt.ControllerToken, alt.Callsign, alt.Altitude)
 	}
 }
 
 type DeleteAircraftArgs AircraftSpecifier
 
-func (sd *Dispatcher) DeleteAllAircraft(da *DeleteAircraftArgs, _ *struct{}) error {
+func (sd *Dispatcher) DeleteAllAircraft(da *DeleteAircraftArgs, _ *struct{}) (err error) {
 	defer sd.sm.lg.CatchAndReportCrash()
 
 	if sim, ok := sd.sm.controllerTokenToSim[da.ControllerToken]; !ok {
 		return ErrNoSimForControllerToken
 	} else {
+		defer sd.command(sim, DeleteAllAircraftCommand, da)(&err)
 		return sim.DeleteAllAircraft(da.ControllerToken)
 	}
 }
@@ -420,7 +523,7 @@ type AircraftCommandsResult struct {
 	RemainingInput string
 }
 
-func (sd *Dispatcher) RunAircraftCommands(cmds *AircraftCommandsArgs, result *AircraftCommandsResult) error {
+func (sd *Dispatcher) RunAircraftCommands(cmds *AircraftCommandsArgs, result *AircraftCommandsResult) (err error) {
 	defer sd.sm.lg.CatchAndReportCrash()
 
 	token, callsign := cmds.ControllerToken, cmds.Callsign
@@ -428,6 +531,7 @@ func (sd *Dispatcher) RunAircraftCommands(cmds *AircraftCommandsArgs, result *Ai
 	if !ok {
 		return ErrNoSimForControllerToken
 	}
+	defer sd.command(sim, RunAircraftCommandsCommand, cmds)(&err)
 
 	commands := strings.Fields(cmds.Commands)
 
@@ -440,6 +544,30 @@ func (sd *Dispatcher) RunAircraftCommands(cmds *AircraftCommandsArgs, result *Ai
 		}
 
 		switch command[0] {
+		case '@':
+			// Instructor reposition: @FIX[/Aalt][/Hhdg]
+			components := strings.Split(command[1:], "/")
+			alt, hdg := -1, 0
+			for _, c := range components[1:] {
+				var err error
+				if len(c) > 1 && c[0] == 'A' {
+					alt, err = strconv.Atoi(c[1:])
+					alt *= 100
+				} else if len(c) > 1 && c[0] == 'H' {
+					hdg, err = strconv.Atoi(c[1:])
+				} else {
+					err = ErrInvalidCommandSyntax
+				}
+				if err != nil {
+					rewriteError(ErrInvalidCommandSyntax)
+					return nil
+				}
+			}
+			if err := sim.RepositionAircraft(token, callsign, components[0], alt, hdg); err != nil {
+				rewriteError(err)
+				return nil
+			}
+
 		case 'A', 'C':
 			if command == "CAC" {
 				// Cancel approach clearance
@@ -829,13 +957,14 @@ type LaunchAircraftArgs struct {
 	Aircraft        av.Aircraft
 }
 
-func (sd *Dispatcher) LaunchAircraft(ls *LaunchAircraftArgs, _ *struct{}) error {
+func (sd *Dispatcher) LaunchAircraft(ls *LaunchAircraftArgs, _ *struct{}) (err error) {
 	defer sd.sm.lg.CatchAndReportCrash()
 
 	sim, ok := sd.sm.controllerTokenToSim[ls.ControllerToken]
 	if !ok {
 		return ErrNoSimForControllerToken
 	}
+	defer sd.command(sim, LaunchAircraftCommand, ls)(&err)
 	sim.LaunchAircraft(ls.Aircraft)
 	return nil
 }
@@ -847,13 +976,14 @@ type CreateDepartureArgs struct {
 	Category        string
 }
 
-func (sd *Dispatcher) CreateDeparture(da *CreateDepartureArgs, depAc *av.Aircraft) error {
+func (sd *Dispatcher) CreateDeparture(da *CreateDepartureArgs, depAc *av.Aircraft) (err error) {
 	defer sd.sm.lg.CatchAndReportCrash()
 
 	sim, ok := sd.sm.controllerTokenToSim[da.ControllerToken]
 	if !ok {
 		return ErrNoSimForControllerToken
 	}
+	defer sd.command(sim, CreateDepartureCommand, da)(&err)
 	ac, err := sim.CreateDeparture(da.Airport, da.Runway, da.Category)
 	if err == nil {
 		*depAc = *ac
@@ -867,13 +997,14 @@ type CreateArrivalArgs struct {
 	Airport         string
 }
 
-func (sd *Dispatcher) CreateArrival(aa *CreateArrivalArgs, arrAc *av.Aircraft) error {
+func (sd *Dispatcher) CreateArrival(aa *CreateArrivalArgs, arrAc *av.Aircraft) (err error) {
 	defer sd.sm.lg.CatchAndReportCrash()
 
 	sim, ok := sd.sm.controllerTokenToSim[aa.ControllerToken]
 	if !ok {
 		return ErrNoSimForControllerToken
 	}
+	defer sd.command(sim, CreateArrivalCommand, aa)(&err)
 	ac, err := sim.CreateArrival(aa.Group, aa.Airport)
 	if err == nil {
 		*arrAc = *ac
@@ -886,13 +1017,14 @@ type CreateOverflightArgs struct {
 	Group           string
 }
 
-func (sd *Dispatcher) CreateOverflight(oa *CreateOverflightArgs, ofAc *av.Aircraft) error {
+func (sd *Dispatcher) CreateOverflight(oa *CreateOverflightArgs, ofAc *av.Aircraft) (err error) {
 	defer sd.sm.lg.CatchAndReportCrash()
 
 	sim, ok := sd.sm.controllerTokenToSim[oa.ControllerToken]
 	if !ok {
 		return ErrNoSimForControllerToken
 	}
+	defer sd.command(sim, CreateOverflightCommand, oa)(&err)
 	ac, err := sim.CreateOverflight(oa.Group)
 	if err == nil {
 		*ofAc = *ac
@@ -906,13 +1038,14 @@ type RestrictionAreaArgs struct {
 	RestrictionArea RestrictionArea
 }
 
-func (sd *Dispatcher) CreateRestrictionArea(ra *RestrictionAreaArgs, idx *int) error {
+func (sd *Dispatcher) CreateRestrictionArea(ra *RestrictionAreaArgs, idx *int) (err error) {
 	defer sd.sm.lg.CatchAndReportCrash()
 
 	sim, ok := sd.sm.controllerTokenToSim[ra.ControllerToken]
 	if !ok {
 		return ErrNoSimForControllerToken
 	}
+	defer sd.command(sim, CreateRestrictionAreaCommand, ra)(&err)
 	i, err := sim.CreateRestrictionArea(ra.RestrictionArea)
 	if err == nil {
 		*idx = i
@@ -920,26 +1053,45 @@ func (sd *Dispatcher) CreateRestrictionArea(ra *RestrictionAreaArgs, idx *int) e
 	return err
 }
 
-func (sd *Dispatcher) UpdateRestrictionArea(ra *RestrictionAreaArgs, _ *struct{}) error {
+func (sd *Dispatcher) UpdateRestrictionArea(ra *RestrictionAreaArgs, _ *struct{}) (err error) {
 	defer sd.sm.lg.CatchAndReportCrash()
 
 	sim, ok := sd.sm.controllerTokenToSim[ra.ControllerToken]
 	if !ok {
 		return ErrNoSimForControllerToken
 	}
+	defer sd.command(sim, UpdateRestrictionAreaCommand, ra)(&err)
 	return sim.UpdateRestrictionArea(ra.Index, ra.RestrictionArea)
 }
 
-func (sd *Dispatcher) DeleteRestrictionArea(ra *RestrictionAreaArgs, _ *struct{}) error {
+func (sd *Dispatcher) DeleteRestrictionArea(ra *RestrictionAreaArgs, _ *struct{}) (err error) {
 	defer sd.sm.lg.CatchAndReportCrash()
 
 	sim, ok := sd.sm.controllerTokenToSim[ra.ControllerToken]
 	if !ok {
 		return ErrNoSimForControllerToken
 	}
+	defer sd.command(sim, DeleteRestrictionAreaCommand, ra)(&err)
 	return sim.DeleteRestrictionArea(ra.Index)
 }
 
+type SetRadarSiteFailedArgs struct {
+	ControllerToken string
+	Site            string
+	Failed          bool
+}
+
+func (sd *Dispatcher) SetRadarSiteFailed(a *SetRadarSiteFailedArgs, _ *struct{}) (err error) {
+	defer sd.sm.lg.CatchAndReportCrash()
+
+	sim, ok := sd.sm.controllerTokenToSim[a.ControllerToken]
+	if !ok {
+		return ErrNoSimForControllerToken
+	}
+	defer sd.command(sim, SetRadarSiteFailedCommand, a)(&err)
+	return sim.SetRadarSiteFailed(a.ControllerToken, a.Site, a.Failed)
+}
+
 type VideoMapsArgs struct {
 	ControllerToken string
 	Filename        string
@@ -6,6 +6,7 @@ package sim
 
 import (
 	"errors"
+	"fmt"
 
 	av "github.com/mmp/vice/pkg/aviation"
 )
@@ -23,23 +24,50 @@ var (
 	ErrInvalidCommandSyntax        = errors.New("Invalid command syntax")
 	ErrInvalidControllerToken      = errors.New("Invalid controller token")
 	ErrInvalidDepartureController  = errors.New("Invalid departure controller")
+	ErrInvalidDuration             = errors.New("Invalid duration")
 	ErrInvalidPassword             = errors.New("Invalid password")
 	ErrInvalidRestrictionAreaIndex = errors.New("Invalid restriction area index")
+	ErrLocationAmbiguous           = errors.New("Location matches multiple places")
+	ErrLocationNotFound            = errors.New("Location not found")
 	ErrNoCoordinationFix           = errors.New("No coordination fix found")
 	ErrNoMatchingFlight            = errors.New("No matching flight")
 	ErrNoNamedSim                  = errors.New("No Sim with that name")
 	ErrNoSimForControllerToken     = errors.New("No Sim running for controller token")
+	ErrNotInstructor               = errors.New("Not signed in as an instructor")
 	ErrNotLaunchController         = errors.New("Not signed in as the launch controller")
+	ErrRecordingUnavailable        = errors.New("Only local sims can be recorded")
 	ErrRPCTimeout                  = errors.New("RPC call timed out")
 	ErrRPCVersionMismatch          = errors.New("Client and server RPC versions don't match")
 	ErrRestoringSavedState         = errors.New("Errors during state restoration")
 	ErrServerDisconnected          = errors.New("Server disconnected")
+	ErrServerDraining              = errors.New("Server is shutting down and not accepting new sims")
 	ErrTooManyRestrictionAreas     = errors.New("Too many restriction areas specified")
 	ErrUnknownController           = errors.New("Unknown controller")
 	ErrUnknownFacility             = errors.New("Unknown facility (ARTCC/TRACON)")
 	ErrUnknownControllerFacility   = errors.New("Unknown controller facility")
+	ErrUnknownRadarSite            = errors.New("Unknown radar site")
 )
 
+// RPCVersionMismatchError is returned when version negotiation with the
+// server finds that it doesn't support the client's RPC version; it
+// records the range of versions that the server does support. errors.Is
+// reports it as ErrRPCVersionMismatch. (The server itself still returns
+// ErrRPCVersionMismatch from SignOn, since older clients check for its
+// text.)
+type RPCVersionMismatchError struct {
+	Client               int
+	ServerMin, ServerMax int
+}
+
+func (e *RPCVersionMismatchError) Error() string {
+	return fmt.Sprintf("%s: client RPC version %d, server supports %d-%d", ErrRPCVersionMismatch,
+		e.Client, e.ServerMin, e.ServerMax)
+}
+
+func (e *RPCVersionMismatchError) Is(target error) bool {
+	return target == ErrRPCVersionMismatch
+}
+
 var errorStringToError = map[string]error{
 	av.ErrClearedForUnexpectedApproach.Error(): av.ErrClearedForUnexpectedApproach,
 	av.ErrFixNotInRoute.Error():                av.ErrFixNotInRoute,
@@ -77,23 +105,30 @@ var errorStringToError = map[string]error{
 	ErrInvalidCommandSyntax.Error():        ErrInvalidCommandSyntax,
 	ErrInvalidControllerToken.Error():      ErrInvalidControllerToken,
 	ErrInvalidDepartureController.Error():  ErrInvalidDepartureController,
+	ErrInvalidDuration.Error():             ErrInvalidDuration,
 	ErrInvalidPassword.Error():             ErrInvalidPassword,
 	ErrInvalidRestrictionAreaIndex.Error(): ErrInvalidRestrictionAreaIndex,
+	ErrLocationAmbiguous.Error():           ErrLocationAmbiguous,
+	ErrLocationNotFound.Error():            ErrLocationNotFound,
 	ErrNoCoordinationFix.Error():           ErrNoCoordinationFix,
 	ErrNoMatchingFlight.Error():            ErrNoMatchingFlight,
 	ErrNoNamedSim.Error():                  ErrNoNamedSim,
 	ErrNoSimForControllerToken.Error():     ErrNoSimForControllerToken,
+	ErrNotInstructor.Error():               ErrNotInstructor,
+	ErrRecordingUnavailable.Error():        ErrRecordingUnavailable,
 	ErrRPCTimeout.Error():                  ErrRPCTimeout,
 	ErrRPCVersionMismatch.Error():          ErrRPCVersionMismatch,
 	ErrRestoringSavedState.Error():         ErrRestoringSavedState,
 	ErrServerDisconnected.Error():          ErrServerDisconnected,
+	ErrServerDraining.Error():              ErrServerDraining,
 	ErrTooManyRestrictionAreas.Error():     ErrTooManyRestrictionAreas,
 	ErrUnknownFacility.Error():             ErrUnknownFacility,
 	ErrUnknownControllerFacility.Error():   ErrUnknownControllerFacility,
+	ErrUnknownRadarSite.Error():            ErrUnknownRadarSite,
 }
 
 func TryDecodeError(e error) error {
-	if err, ok := errorStringToError[e.Error()]; ok {
+	if err := TryDecodeErrorString(e.Error()); err != nil {
 		return err
 	}
 	return e
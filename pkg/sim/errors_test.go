@@ -0,0 +1,93 @@
+// pkg/sim/errors_test.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package sim
+
+import (
+	"errors"
+	"net"
+	"net/rpc"
+	"testing"
+
+	"github.com/mmp/vice/pkg/util"
+)
+
+func TestSignOnRPCVersionMismatch(t *testing.T) {
+	// Deployed clients compare the error text against
+	// ErrRPCVersionMismatch, so it must be sent unchanged.
+	var sm SimManager
+	var so SignOnResult
+	if err := sm.SignOn(ViceRPCVersion+1, &so); err == nil || err.Error() != ErrRPCVersionMismatch.Error() {
+		t.Errorf("got %v; expected %q", err, ErrRPCVersionMismatch)
+	}
+
+	var vr RPCVersionRange
+	if err := sm.NegotiateVersion(ViceRPCVersion+1, &vr); err != nil {
+		t.Errorf("NegotiateVersion: %v", err)
+	} else if vr.Min != MinSupportedRPCVersion || vr.Max != MaxSupportedRPCVersion {
+		t.Errorf("got range %d-%d; expected %d-%d", vr.Min, vr.Max, MinSupportedRPCVersion, MaxSupportedRPCVersion)
+	}
+}
+
+// testVersionServer stands in for the SimManager of a server that supports
+// the given range of RPC versions.
+type testVersionServer struct {
+	RPCVersionRange
+}
+
+func (s *testVersionServer) NegotiateVersion(version int, result *RPCVersionRange) error {
+	*result = s.RPCVersionRange
+	return nil
+}
+
+// testLegacyServer stands in for a server that predates NegotiateVersion.
+type testLegacyServer struct{}
+
+func (s *testLegacyServer) SignOn(version int, result *SignOnResult) error {
+	return ErrRPCVersionMismatch
+}
+
+func TestNegotiateRPCVersion(t *testing.T) {
+	connect := func(rcvr any) *util.RPCClient {
+		server := rpc.NewServer()
+		if err := server.RegisterName("SimManager", rcvr); err != nil {
+			t.Fatalf("RegisterName: %v", err)
+		}
+		sc, cc := net.Pipe()
+		go server.ServeConn(sc)
+		return &util.RPCClient{rpc.NewClient(cc)}
+	}
+
+	client := connect(&testVersionServer{RPCVersionRange{Min: ViceRPCVersion + 1, Max: ViceRPCVersion + 3}})
+	defer client.Close()
+	err := negotiateRPCVersion(client, nil)
+	var verr *RPCVersionMismatchError
+	if !errors.As(err, &verr) {
+		t.Fatalf("got %T %v; expected *RPCVersionMismatchError", err, err)
+	}
+	if verr.Client != ViceRPCVersion || verr.ServerMin != ViceRPCVersion+1 || verr.ServerMax != ViceRPCVersion+3 {
+		t.Errorf("got %+v; unexpected versions", *verr)
+	}
+	if !errors.Is(err, ErrRPCVersionMismatch) {
+		t.Errorf("expected errors.Is to match ErrRPCVersionMismatch")
+	}
+
+	client = connect(&testVersionServer{RPCVersionRange{Min: ViceRPCVersion - 1, Max: ViceRPCVersion}})
+	defer client.Close()
+	if err := negotiateRPCVersion(client, nil); err != nil {
+		t.Errorf("unexpected error for supported version: %v", err)
+	}
+
+	// Servers without NegotiateVersion fall back to the check in SignOn,
+	// which returns the legacy error.
+	client = connect(&testLegacyServer{})
+	defer client.Close()
+	if err := negotiateRPCVersion(client, nil); err != nil {
+		t.Errorf("unexpected error from legacy server: %v", err)
+	}
+	var so SignOnResult
+	if err := client.CallWithTimeout("SimManager.SignOn", ViceRPCVersion, &so); TryDecodeError(err) != ErrRPCVersionMismatch {
+		t.Errorf("got %v; expected ErrRPCVersionMismatch", err)
+	}
+}
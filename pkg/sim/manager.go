@@ -6,8 +6,10 @@ package sim
 
 import (
 	"errors"
+	"fmt"
 	"log/slog"
 	"os"
+	"slices"
 	"sort"
 	"strings"
 	"time"
@@ -29,6 +31,13 @@ type SimManager struct {
 	mapManifests         map[string]*av.VideoMapManifest
 	startTime            time.Time
 	lg                   *log.Logger
+
+	// When draining, no new sims may be created or joined and the server
+	// exits once all controllers have signed off.
+	draining bool
+	// shutdown is closed when draining has finished and the server should
+	// exit.
+	shutdown chan struct{}
 }
 
 func NewSimManager(scenarioGroups map[string]map[string]*ScenarioGroup,
@@ -42,6 +51,7 @@ func NewSimManager(scenarioGroups map[string]map[string]*ScenarioGroup,
 		mapManifests:         manifests,
 		startTime:            time.Now(),
 		lg:                   lg,
+		shutdown:             make(chan struct{}),
 	}
 }
 
@@ -51,6 +61,10 @@ type NewSimResult struct {
 }
 
 func (sm *SimManager) New(config *NewSimConfiguration, result *NewSimResult) error {
+	if sm.Draining() {
+		return ErrServerDraining
+	}
+
 	if config.NewSimType == NewSimCreateLocal || config.NewSimType == NewSimCreateRemote {
 		sim := NewSim(*config, sm.scenarioGroups, config.NewSimType == NewSimCreateLocal, sm.mapManifests, sm.lg)
 		sim.prespawn()
@@ -126,6 +140,7 @@ func (sm *SimManager) Add(sim *Sim, result *NewSimResult) error {
 		}
 
 		sm.lg.Infof("%s: terminating sim after %s idle", sim.Name, sim.IdleTime())
+		sim.finishRecording()
 		sm.mu.Lock(sm.lg)
 		defer sm.mu.Unlock(sm.lg)
 		delete(sm.activeSims, sim.Name)
@@ -151,7 +166,8 @@ type SignOnResult struct {
 }
 
 func (sm *SimManager) SignOn(version int, result *SignOnResult) error {
-	if version != ViceRPCVersion {
+	if version < MinSupportedRPCVersion || version > MaxSupportedRPCVersion {
+		// Clients check for this error's text, so it must not change.
 		return ErrRPCVersionMismatch
 	}
 
@@ -168,6 +184,21 @@ func (sm *SimManager) SignOn(version int, result *SignOnResult) error {
 	return nil
 }
 
+type RPCVersionRange struct {
+	Min, Max int
+}
+
+// NegotiateVersion returns the range of RPC versions that the server
+// supports so that clients can tell the user which version of vice to
+// install if theirs isn't supported.
+func (sm *SimManager) NegotiateVersion(version int, result *RPCVersionRange) error {
+	if version < MinSupportedRPCVersion || version > MaxSupportedRPCVersion {
+		sm.lg.Infof("Client with unsupported RPC version %d", version)
+	}
+	*result = RPCVersionRange{Min: MinSupportedRPCVersion, Max: MaxSupportedRPCVersion}
+	return nil
+}
+
 func (sm *SimManager) GetRunningSims(_ int, result *map[string]*RemoteSim) error {
 	sm.mu.Lock(sm.lg)
 	defer sm.mu.Unlock(sm.lg)
@@ -301,38 +332,77 @@ func (sm *SimManager) getSimStatus() []simStatus {
 type SimBroadcastMessage struct {
 	Password string
 	Message  string
+	TRACON   string        // If non-empty, only sims for this TRACON get the message.
+	Delay    time.Duration // If non-zero, the message is sent after this delay.
 }
 
-func (sm *SimManager) Broadcast(m *SimBroadcastMessage, _ *struct{}) error {
-	pw, err := os.ReadFile("password")
+func checkServerPassword(pw string) error {
+	b, err := os.ReadFile("password")
 	if err != nil {
 		return err
 	}
 
-	password := strings.TrimRight(string(pw), "\n\r")
-	if password != m.Password {
+	password := strings.TrimRight(string(b), "\n\r")
+	if password != pw {
 		return ErrInvalidPassword
 	}
+	return nil
+}
+
+func (sm *SimManager) Broadcast(m *SimBroadcastMessage, _ *struct{}) error {
+	if err := checkServerPassword(m.Password); err != nil {
+		return err
+	}
+
+	if m.Delay > 0 {
+		sm.lg.Infof("Scheduling broadcast in %s: %s", m.Delay, m.Message)
+
+		msg, tracon := m.Message, m.TRACON
+		time.AfterFunc(m.Delay, func() {
+			defer sm.lg.CatchAndReportCrash()
+			sm.broadcast(msg, tracon)
+		})
+	} else {
+		sm.broadcast(m.Message, m.TRACON)
+	}
+	return nil
+}
 
+func (sm *SimManager) broadcast(msg, tracon string) {
 	sm.mu.Lock(sm.lg)
 	defer sm.mu.Unlock(sm.lg)
 
-	sm.lg.Infof("Broadcasting message: %s", m.Message)
+	if tracon != "" {
+		sm.lg.Infof("Broadcasting message to %s: %s", tracon, msg)
+	} else {
+		sm.lg.Infof("Broadcasting message: %s", msg)
+	}
 
-	for _, sim := range sm.activeSims {
+	for _, sim := range sm.signedInSims() {
 		sim.mu.Lock(sim.lg)
 
-		sim.eventStream.Post(Event{
-			Type:    ServerBroadcastMessageEvent,
-			Message: m.Message,
-		})
+		if tracon == "" || sim.State.TRACON == tracon {
+			sim.eventStream.Post(Event{
+				Type:    ServerBroadcastMessageEvent,
+				Message: msg,
+			})
+		}
 
 		sim.mu.Unlock(sim.lg)
 	}
-	return nil
+}
+
+// BroadcastOptions specifies optional parameters for a broadcast message.
+type BroadcastOptions struct {
+	TRACON string        // Limit the message to sims for this TRACON.
+	Delay  time.Duration // Delay before the server sends the message.
 }
 
 func BroadcastMessage(hostname, msg, password string, lg *log.Logger) {
+	BroadcastMessageWithOptions(hostname, msg, password, BroadcastOptions{}, lg)
+}
+
+func BroadcastMessageWithOptions(hostname, msg, password string, opts BroadcastOptions, lg *log.Logger) {
 	client, err := getClient(hostname, lg)
 	if err != nil {
 		lg.Errorf("unable to get client for broadcast: %v", err)
@@ -342,9 +412,168 @@ func BroadcastMessage(hostname, msg, password string, lg *log.Logger) {
 	err = client.CallWithTimeout("SimManager.Broadcast", &SimBroadcastMessage{
 		Password: password,
 		Message:  msg,
+		TRACON:   opts.TRACON,
+		Delay:    opts.Delay,
 	}, nil)
 
 	if err != nil {
 		lg.Errorf("broadcast error: %v", err)
 	}
 }
+
+///////////////////////////////////////////////////////////////////////////
+// Draining
+
+type SimDrainRequest struct {
+	Password    string
+	Message     string        // Warning sent to all active sims; a default is used if empty.
+	GracePeriod time.Duration // Maximum time to wait for controllers to sign off.
+}
+
+// Drain puts the server into draining mode: new sims can no longer be
+// created or joined, all active sims are warned, and the server shuts
+// down once there are no signed-in controllers or the grace period
+// expires, in which case the remaining controllers are signed off first.
+func (sm *SimManager) Drain(m *SimDrainRequest, _ *struct{}) error {
+	if err := checkServerPassword(m.Password); err != nil {
+		return err
+	}
+
+	sm.mu.Lock(sm.lg)
+	if sm.draining {
+		sm.mu.Unlock(sm.lg)
+		return nil
+	}
+	sm.draining = true
+	deadline := time.Now().Add(m.GracePeriod)
+	sm.mu.Unlock(sm.lg)
+
+	msg := m.Message
+	if msg == "" {
+		msg = fmt.Sprintf("The vice server will shut down for maintenance in %s. Please finish up your session.",
+			m.GracePeriod.Round(time.Minute))
+	}
+	sm.lg.Infof("Draining server; grace period %s", m.GracePeriod)
+	sm.broadcast(msg, "")
+
+	go func() {
+		defer sm.lg.CatchAndReportCrash()
+
+		for {
+			if n := sm.signedInControllers(); n == 0 {
+				sm.lg.Info("All controllers have signed off; shutting down")
+				break
+			} else if time.Now().After(deadline) {
+				sm.lg.Infof("Drain grace period expired with %d controllers signed in; shutting down", n)
+				sm.signOffAll()
+				break
+			}
+			time.Sleep(5 * time.Second)
+		}
+
+		close(sm.shutdown)
+	}()
+
+	return nil
+}
+
+// drainNotifyDelay is how long the clients of controllers that are still
+// signed in when the server shuts down have to save their sessions before
+// the controllers are signed off.
+const drainNotifyDelay = 10 * time.Second
+
+// signOffAll tells all remaining controllers that the server is shutting
+// down and then signs them off once their clients have had a chance to
+// save.
+func (sm *SimManager) signOffAll() {
+	sm.mu.Lock(sm.lg)
+	sims := sm.signedInSims()
+	sm.mu.Unlock(sm.lg)
+
+	sm.lg.Info("Broadcasting server shutdown")
+	for _, sim := range sims {
+		sim.mu.Lock(sim.lg)
+		sim.eventStream.Post(Event{
+			Type:    ServerShutdownEvent,
+			Message: "The vice server is shutting down now.",
+		})
+		sim.mu.Unlock(sim.lg)
+	}
+
+	// sm.mu mustn't be held while we wait, since clients need it to get
+	// their sims in order to save them.
+	time.Sleep(drainNotifyDelay)
+
+	sm.mu.Lock(sm.lg)
+	defer sm.mu.Unlock(sm.lg)
+
+	for token, sim := range sm.controllerTokenToSim {
+		if err := sim.SignOff(token); err != nil && err != ErrInvalidControllerToken {
+			sm.lg.Errorf("%s: sign off: %v", sim.Name, err)
+		}
+		delete(sm.controllerTokenToSim, token)
+	}
+}
+
+// Shutdown returns a channel that is closed once the server has finished
+// draining and should exit.
+func (sm *SimManager) Shutdown() <-chan struct{} {
+	return sm.shutdown
+}
+
+func (sm *SimManager) Draining() bool {
+	sm.mu.Lock(sm.lg)
+	defer sm.mu.Unlock(sm.lg)
+	return sm.draining
+}
+
+func (sm *SimManager) signedInControllers() int {
+	sm.mu.Lock(sm.lg)
+	defer sm.mu.Unlock(sm.lg)
+
+	n := 0
+	for _, sim := range sm.signedInSims() {
+		sim.mu.Lock(sim.lg)
+		n += len(sim.controllers)
+		sim.mu.Unlock(sim.lg)
+	}
+	return n
+}
+
+// signedInSims returns all of the sims that controllers may be signed in
+// to. Local sims are all named "", so only the most recent one is in
+// activeSims; the others can only be found through their controllers'
+// tokens. sm.mu must be held when it is called.
+func (sm *SimManager) signedInSims() []*Sim {
+	var sims []*Sim
+	for _, sim := range sm.activeSims {
+		sims = append(sims, sim)
+	}
+	for _, sim := range sm.controllerTokenToSim {
+		if !slices.Contains(sims, sim) {
+			sims = append(sims, sim)
+		}
+	}
+	return sims
+}
+
+// DrainServer asks the server at the given address to stop accepting new
+// sims and to shut down once its controllers have signed off or the grace
+// period has passed.
+func DrainServer(hostname string, gracePeriod time.Duration, msg, password string, lg *log.Logger) {
+	client, err := getClient(hostname, lg)
+	if err != nil {
+		lg.Errorf("unable to get client for drain: %v", err)
+		return
+	}
+
+	err = client.CallWithTimeout("SimManager.Drain", &SimDrainRequest{
+		Password:    password,
+		Message:     msg,
+		GracePeriod: gracePeriod,
+	}, nil)
+
+	if err != nil {
+		lg.Errorf("drain error: %v", err)
+	}
+}
@@ -0,0 +1,35 @@
+// pkg/sim/manager_test.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package sim
+
+import (
+	"testing"
+)
+
+func TestSignedInControllers(t *testing.T) {
+	sm := NewSimManager(nil, nil, nil, nil)
+	newSim := func(name string, tokens ...string) *Sim {
+		s := &Sim{Name: name, controllers: make(map[string]*ServerController)}
+		for _, tok := range tokens {
+			s.controllers[tok] = &ServerController{}
+			sm.controllerTokenToSim[tok] = s
+		}
+		sm.activeSims[name] = s
+		return s
+	}
+
+	newSim("remote", "r1", "r2")
+	// Local sims are all named "", so the second replaces the first in
+	// activeSims, but its controller is still signed in.
+	newSim("", "l1")
+	newSim("", "l2")
+
+	if n := sm.signedInControllers(); n != 4 {
+		t.Errorf("got %d signed in controllers; expected 4", n)
+	}
+	if n := len(sm.signedInSims()); n != 3 {
+		t.Errorf("got %d signed in sims; expected 3", n)
+	}
+}
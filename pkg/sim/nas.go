@@ -15,6 +15,7 @@ import (
 	av "github.com/mmp/vice/pkg/aviation"
 	"github.com/mmp/vice/pkg/log"
 	"github.com/mmp/vice/pkg/math"
+	"github.com/mmp/vice/pkg/rand"
 	"github.com/mmp/vice/pkg/util"
 )
 
@@ -106,8 +107,8 @@ func (comp *ERAMComputer) Activate(ec *ERAMComputers) {
 }
 
 // For NAS codes
-func (comp *ERAMComputer) CreateSquawk() (av.Squawk, error) {
-	return comp.SquawkCodePool.Get()
+func (comp *ERAMComputer) CreateSquawk(r *rand.Rand) (av.Squawk, error) {
+	return comp.SquawkCodePool.Get(r)
 }
 
 func (comp *ERAMComputer) SendFlightPlans(tracon string, simTime time.Time, lg *log.Logger) {
@@ -446,8 +447,8 @@ func (comp *STARSComputer) Activate(pool *av.SquawkCodePool) {
 }
 
 // For local codes
-func (comp *STARSComputer) CreateSquawk() (av.Squawk, error) {
-	return comp.SquawkCodePool.Get()
+func (comp *STARSComputer) CreateSquawk(r *rand.Rand) (av.Squawk, error) {
+	return comp.SquawkCodePool.Get(r)
 }
 
 func (comp *STARSComputer) SendTrackInfo(receivingFacility string, msg FlightPlanMessage, simTime time.Time) {
@@ -1259,7 +1260,8 @@ func (ec ERAMComputers) GetSTARSFlightPlan(tracon string, identifier string) (*S
 	return starsComputer.GetFlightPlan(identifier)
 }
 
-func (ec *ERAMComputers) AddArrival(ac *av.Aircraft, facility string, fa STARSFacilityAdaptation, simTime time.Time) error {
+func (ec *ERAMComputers) AddArrival(ac *av.Aircraft, facility string, fa STARSFacilityAdaptation, simTime time.Time,
+	r *rand.Rand) error {
 	starsFP := MakeSTARSFlightPlan(ac.FlightPlan)
 	if err := starsFP.SetCoordinationFix(fa, ac, simTime); err != nil {
 		return err
@@ -1270,7 +1272,7 @@ func (ec *ERAMComputers) AddArrival(ac *av.Aircraft, facility string, fa STARSFa
 		return err
 	}
 
-	sq, err := artcc.CreateSquawk()
+	sq, err := artcc.CreateSquawk(r)
 	if err != nil {
 		return err
 	}
@@ -1452,7 +1454,8 @@ func FlightPlanDepartureMessage(fp av.FlightPlan, sendingFacility string, simTim
 	}
 }
 
-func MakeSTARSFlightPlanFromAbbreviated(abbr string, stars *STARSComputer, facilityAdaptation STARSFacilityAdaptation) (*STARSFlightPlan, error) {
+func MakeSTARSFlightPlanFromAbbreviated(abbr string, stars *STARSComputer, facilityAdaptation STARSFacilityAdaptation,
+	r *rand.Rand) (*STARSFlightPlan, error) {
 	if strings.Contains(abbr, "*") {
 		// VFR FP; it's a required field
 		// TODO(mtrokel)
@@ -1470,7 +1473,7 @@ func MakeSTARSFlightPlanFromAbbreviated(abbr string, stars *STARSComputer, facil
 		} else {
 			if info.BCN == av.Squawk(0) {
 				var err error
-				if info.BCN, err = stars.CreateSquawk(); err != nil {
+				if info.BCN, err = stars.CreateSquawk(r); err != nil {
 					return nil, err
 				}
 			}
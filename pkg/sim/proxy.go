@@ -6,6 +6,7 @@ package sim
 
 import (
 	"net/rpc"
+	"time"
 
 	av "github.com/mmp/vice/pkg/aviation"
 	"github.com/mmp/vice/pkg/math"
@@ -57,6 +58,36 @@ func (s *proxy) SetSimRate(r float32) *rpc.Call {
 		}, nil, nil)
 }
 
+func (s *proxy) AdvanceTime(d time.Duration) *rpc.Call {
+	return s.Client.Go("Sim.AdvanceTime",
+		&AdvanceTimeArgs{
+			ControllerToken: s.ControllerToken,
+			Duration:        d,
+		}, nil, nil)
+}
+
+func (s *proxy) StartRecording(filename string) error {
+	return s.Client.CallWithTimeout("Sim.StartRecording",
+		&RecordingArgs{
+			ControllerToken: s.ControllerToken,
+			Filename:        filename,
+		}, nil)
+}
+
+func (s *proxy) StopRecording() error {
+	return s.Client.CallWithTimeout("Sim.StopRecording", s.ControllerToken, nil)
+}
+
+func (s *proxy) StartReplay(seed int64, commands []RecordedCommand, paused bool) error {
+	return s.Client.CallWithTimeout("Sim.StartReplay",
+		&StartReplayArgs{
+			ControllerToken: s.ControllerToken,
+			Seed:            seed,
+			Commands:        commands,
+			Paused:          paused,
+		}, nil)
+}
+
 func (s *proxy) SetLaunchConfig(lc LaunchConfig) *rpc.Call {
 	return s.Client.Go("Sim.SetLaunchConfig",
 		&SetLaunchConfigArgs{
@@ -315,6 +346,14 @@ func (p *proxy) DeleteRestrictionArea(idx int) *rpc.Call {
 	}, nil, nil)
 }
 
+func (p *proxy) SetRadarSiteFailed(site string, failed bool) *rpc.Call {
+	return p.Client.Go("Sim.SetRadarSiteFailed", &SetRadarSiteFailedArgs{
+		ControllerToken: p.ControllerToken,
+		Site:            site,
+		Failed:          failed,
+	}, nil, nil)
+}
+
 func (p *proxy) GetVideoMapLibrary(filename string, vmf *av.VideoMapLibrary) error {
 	// Synchronous call
 	return p.Client.Call("Sim.GetVideoMapLibrary", &VideoMapsArgs{
@@ -0,0 +1,614 @@
+// pkg/sim/recorder.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package sim
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	av "github.com/mmp/vice/pkg/aviation"
+)
+
+// Session recordings are stored as JSON lines: the first line is a
+// RecordingHeader that holds the complete initial state of the Sim and
+// the seed for the random number generator; each subsequent line holds
+// either a RecordedEvent or a RecordedCommand. The Sim and the command
+// arguments are gob-encoded, since their JSON encodings round positions
+// and the replay must start from exactly the same state. Entries are written as they
+// happen so that if vice crashes, everything up to the last complete line
+// is still usable.
+//
+// The Sim is deterministic given its initial state, the random seed, and
+// the controller commands it receives, so replaying a session starts from
+// the recorded Sim and reissues the recorded commands at the same
+// simulation times they were originally given; the events are recorded
+// so that the replay can be searched, e.g. for an aircraft's first
+// appearance.
+
+type RecordingHeader struct {
+	Version    int
+	PrimaryTCP string
+	StartTime  time.Time // wall clock
+	Seed       int64
+	EncodedSim []byte
+	Sim        *Sim `json:"-"` // decoded from EncodedSim
+}
+
+type RecordedEvent struct {
+	SimTime time.Time
+	Event   Event
+}
+
+// RecordedCommandType identifies the Dispatcher method that a
+// RecordedCommand calls. The values are stored in recordings, so new
+// commands must be added at the end.
+type RecordedCommandType int
+
+const (
+	ChangeControlPositionCommand RecordedCommandType = iota
+	TakeOrReturnLaunchControlCommand
+	SetLaunchConfigCommand
+	SetScratchpadCommand
+	SetSecondaryScratchpadCommand
+	AutoAssociateFPCommand
+	SetGlobalLeaderLineCommand
+	InitiateTrackCommand
+	CreateUnsupportedTrackCommand
+	UploadFlightPlanCommand
+	DropTrackCommand
+	HandoffTrackCommand
+	RedirectHandoffCommand
+	AcceptRedirectedHandoffCommand
+	AcceptHandoffCommand
+	CancelHandoffCommand
+	ForceQLCommand
+	GlobalMessageCommand
+	PointOutCommand
+	AcknowledgePointOutCommand
+	RejectPointOutCommand
+	ToggleSPCOverrideCommand
+	ReleaseDepartureCommand
+	SetTemporaryAltitudeCommand
+	DeleteAllAircraftCommand
+	RunAircraftCommandsCommand
+	LaunchAircraftCommand
+	CreateDepartureCommand
+	CreateArrivalCommand
+	CreateOverflightCommand
+	CreateRestrictionAreaCommand
+	UpdateRestrictionAreaCommand
+	DeleteRestrictionAreaCommand
+	SetRadarSiteFailedCommand
+	NumRecordedCommandTypes
+)
+
+func (t RecordedCommandType) String() string {
+	if t < 0 || t >= NumRecordedCommandTypes {
+		return fmt.Sprintf("RecordedCommandType(%d)", int(t))
+	}
+	return []string{
+		"ChangeControlPosition", "TakeOrReturnLaunchControl", "SetLaunchConfig",
+		"SetScratchpad", "SetSecondaryScratchpad", "AutoAssociateFP",
+		"SetGlobalLeaderLine", "InitiateTrack", "CreateUnsupportedTrack",
+		"UploadFlightPlan", "DropTrack", "HandoffTrack", "RedirectHandoff",
+		"AcceptRedirectedHandoff", "AcceptHandoff", "CancelHandoff", "ForceQL",
+		"GlobalMessage", "PointOut", "AcknowledgePointOut", "RejectPointOut",
+		"ToggleSPCOverride", "ReleaseDeparture", "SetTemporaryAltitude",
+		"DeleteAllAircraft", "RunAircraftCommands", "LaunchAircraft",
+		"CreateDeparture", "CreateArrival", "CreateOverflight",
+		"CreateRestrictionArea", "UpdateRestrictionArea", "DeleteRestrictionArea",
+		"SetRadarSiteFailed"}[t]
+}
+
+// RecordedCommand is a call to a Dispatcher method that changed the Sim;
+// Args holds the method's gob-encoded arguments and Error the error it
+// returned, if any, which a replay of the command should match.
+type RecordedCommand struct {
+	SimTime time.Time
+	Type    RecordedCommandType
+	Args    []byte
+	Error   string `json:",omitempty"`
+}
+
+type Recording struct {
+	RecordingHeader
+	Events   []RecordedEvent
+	Commands []RecordedCommand
+}
+
+// recordingEntry is a line of a recording after the header; only one of
+// its members is set.
+type recordingEntry struct {
+	Event   *RecordedEvent   `json:",omitempty"`
+	Command *RecordedCommand `json:",omitempty"`
+}
+
+const RecordingVersion = 3
+
+var ErrInvalidRecording = errors.New("Invalid session recording")
+
+// recorder writes a Sim's commands and events to a session recording.
+type recorder struct {
+	f   io.WriteCloser
+	w   *bufio.Writer
+	enc *json.Encoder
+	sub *EventsSubscription
+}
+
+// newRecorder writes the header for a recording of the given Sim to f.
+// The caller must hold the Sim's locks and have seeded the random number
+// generator with seed.
+func newRecorder(f io.WriteCloser, s *Sim, primaryTCP string, seed int64) (*recorder, error) {
+	r := &recorder{f: f, w: bufio.NewWriter(f)}
+	r.enc = json.NewEncoder(r.w)
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(s); err != nil {
+		return nil, err
+	}
+	if err := r.enc.Encode(RecordingHeader{
+		Version:    RecordingVersion,
+		PrimaryTCP: primaryTCP,
+		StartTime:  time.Now(),
+		Seed:       seed,
+		EncodedSim: buf.Bytes(),
+	}); err != nil {
+		return nil, err
+	}
+	if err := r.w.Flush(); err != nil {
+		return nil, err
+	}
+
+	r.sub = s.eventStream.Subscribe()
+	return r, nil
+}
+
+func (r *recorder) recordCommand(cmd RecordedCommand) error {
+	if err := r.enc.Encode(recordingEntry{Command: &cmd}); err != nil {
+		return err
+	}
+	return r.w.Flush()
+}
+
+// recordEvents appends all of the events posted since the last call to
+// recordEvents to the recording, tagged with the given simulation time.
+func (r *recorder) recordEvents(simTime time.Time) error {
+	events := r.sub.Get()
+	if len(events) == 0 {
+		return nil
+	}
+
+	for _, e := range events {
+		if err := r.enc.Encode(recordingEntry{Event: &RecordedEvent{SimTime: simTime, Event: e}}); err != nil {
+			return err
+		}
+	}
+	return r.w.Flush()
+}
+
+func (r *recorder) Close() error {
+	r.sub.Unsubscribe()
+	err := r.w.Flush()
+	if cerr := r.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// StartRecording starts recording the Sim to the given file, replacing
+// any recording that is already in progress. Only local sims can be
+// recorded, since the file is written by the server.
+func (s *Sim) StartRecording(token, filename string) error {
+	s.cmdMu.Lock(s.lg)
+	defer s.cmdMu.Unlock(s.lg)
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	ctrl, ok := s.controllers[token]
+	if !ok {
+		return ErrInvalidControllerToken
+	} else if s.Name != "" {
+		return ErrRecordingUnavailable
+	}
+	s.stopRecording()
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	// Reseed so that the recording has everything needed to reproduce
+	// the Sim's use of random numbers from here on.
+	seed := time.Now().UnixNano()
+	s.rand.Seed(seed)
+
+	if s.recorder, err = newRecorder(f, s, ctrl.Id, seed); err != nil {
+		f.Close()
+		return err
+	}
+	s.lg.Infof("%s: recording session", filename)
+	return nil
+}
+
+func (s *Sim) StopRecording(token string) error {
+	s.cmdMu.Lock(s.lg)
+	defer s.cmdMu.Unlock(s.lg)
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	if _, ok := s.controllers[token]; !ok {
+		return ErrInvalidControllerToken
+	}
+	s.stopRecording()
+	return nil
+}
+
+// finishRecording closes the recording, if any, when the Sim exits.
+func (s *Sim) finishRecording() {
+	s.cmdMu.Lock(s.lg)
+	defer s.cmdMu.Unlock(s.lg)
+	s.stopRecording()
+}
+
+func (s *Sim) stopRecording() {
+	if s.recorder != nil {
+		if err := s.recorder.Close(); err != nil {
+			s.lg.Errorf("closing recording: %v", err)
+		}
+		s.recorder = nil
+	}
+}
+
+// command is called by Dispatcher methods that modify the Sim before they
+// call into it; the function it returns must be called with their error
+// result when they are done. It keeps the Sim from advancing while the
+// command runs and, if the session is being recorded, records the command
+// and its result so that it can be replayed at the same simulation time.
+func (s *Sim) command(cmdType RecordedCommandType, args any) func(*error) {
+	s.cmdMu.Lock(s.lg)
+
+	var cmd *RecordedCommand
+	if s.recorder != nil {
+		// Encode the arguments now in case the command modifies them.
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(args); err != nil {
+			s.lg.Errorf("%s: error encoding command: %v", cmdType, err)
+			s.stopRecording()
+		} else {
+			cmd = &RecordedCommand{SimTime: s.SimTime, Type: cmdType, Args: buf.Bytes()}
+		}
+	}
+
+	return func(result *error) {
+		if cmd != nil && s.recorder != nil {
+			if *result != nil {
+				cmd.Error = (*result).Error()
+			}
+			if err := s.recorder.recordCommand(*cmd); err != nil {
+				s.lg.Errorf("%s: error recording command: %v", cmdType, err)
+				s.stopRecording()
+			}
+		}
+		s.recordEvents()
+		s.cmdMu.Unlock(s.lg)
+	}
+}
+
+// recordEvents records any pending events if the Sim is being recorded;
+// s.cmdMu must be held.
+func (s *Sim) recordEvents() {
+	if s.recorder != nil {
+		if err := s.recorder.recordEvents(s.SimTime); err != nil {
+			s.lg.Errorf("error recording events: %v", err)
+			s.stopRecording()
+		}
+	}
+}
+
+// replayer reissues the commands from a session recording.
+type replayer struct {
+	sd       *Dispatcher
+	token    string
+	commands []RecordedCommand
+}
+
+// StartReplay reseeds the Sim's random number generator with the
+// recording's seed and then arranges for the recorded commands to be run at the
+// appropriate times as the Sim advances. It should be called right after
+// the Sim from the recording's header has been added, with the Sim paused;
+// paused gives whether the Sim should then remain paused.
+func (s *Sim) StartReplay(token string, sd *Dispatcher, seed int64, commands []RecordedCommand, paused bool) error {
+	s.cmdMu.Lock(s.lg)
+	defer s.cmdMu.Unlock(s.lg)
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	if _, ok := s.controllers[token]; !ok {
+		return ErrInvalidControllerToken
+	}
+
+	s.rand.Seed(seed)
+	s.replay = &replayer{sd: sd, token: token, commands: commands}
+	// Commands may have been issued before the first step.
+	s.replayCommands()
+
+	s.Paused = paused
+	s.lastUpdateTime = time.Now()
+	s.updateTimeSlop = 0
+
+	return nil
+}
+
+// replayCommands runs any recorded commands that were issued at or before
+// the current simulation time. s.cmdMu and s.mu must both be held; s.mu
+// is released while the commands run, since the Dispatcher methods
+// acquire it.
+func (s *Sim) replayCommands() {
+	if s.replay == nil {
+		return
+	}
+
+	for len(s.replay.commands) > 0 && !s.replay.commands[0].SimTime.After(s.SimTime) {
+		cmd := s.replay.commands[0]
+		s.replay.commands = s.replay.commands[1:]
+
+		s.mu.Unlock(s.lg)
+		err := s.replay.run(cmd)
+		s.mu.Lock(s.lg)
+
+		// Commands that failed originally should fail in the same way
+		// when replayed; any other difference means that the replay no
+		// longer matches the recording, so there's no point in going on.
+		if result := errorString(err); result != cmd.Error {
+			s.lg.Errorf("%s: replayed command returned %q but %q was recorded; stopping replay",
+				cmd.Type, result, cmd.Error)
+			s.eventStream.Post(Event{
+				Type: ErrorMessageEvent,
+				Message: fmt.Sprintf("The replay no longer matches the recording and has been stopped. "+
+					"The %s command at %s returned %q but %q was recorded.", cmd.Type,
+					cmd.SimTime.Format("15:04:05"), result, cmd.Error),
+			})
+			s.replay = nil
+			return
+		}
+	}
+	if len(s.replay.commands) == 0 {
+		s.replay = nil
+	}
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// run calls the Dispatcher method for a recorded command with the
+// recorded arguments, using the replaying controller's token.
+func (r *replayer) run(cmd RecordedCommand) error {
+	sd := r.sd
+	// call decodes the recorded arguments into args, replaces the
+	// recorded controller token with the replaying controller's, and then
+	// calls the method.
+	call := func(args any, token *string, method func() error) error {
+		if err := gob.NewDecoder(bytes.NewReader(cmd.Args)).Decode(args); err != nil {
+			return fmt.Errorf("%s: %w", cmd.Type, ErrInvalidRecording)
+		}
+		*token = r.token
+		return method()
+	}
+
+	switch cmd.Type {
+	case ChangeControlPositionCommand:
+		var a ChangeControlPositionArgs
+		return call(&a, &a.ControllerToken, func() error { return sd.ChangeControlPosition(&a, nil) })
+	case TakeOrReturnLaunchControlCommand:
+		return sd.TakeOrReturnLaunchControl(r.token, nil)
+	case SetLaunchConfigCommand:
+		var a SetLaunchConfigArgs
+		return call(&a, &a.ControllerToken, func() error { return sd.SetLaunchConfig(&a, nil) })
+	case SetScratchpadCommand:
+		var a SetScratchpadArgs
+		return call(&a, &a.ControllerToken, func() error { return sd.SetScratchpad(&a, nil) })
+	case SetSecondaryScratchpadCommand:
+		var a SetScratchpadArgs
+		return call(&a, &a.ControllerToken, func() error { return sd.SetSecondaryScratchpad(&a, nil) })
+	case AutoAssociateFPCommand:
+		var a InitiateTrackArgs
+		return call(&a, &a.ControllerToken, func() error { return sd.AutoAssociateFP(&a, nil) })
+	case SetGlobalLeaderLineCommand:
+		var a SetGlobalLeaderLineArgs
+		return call(&a, &a.ControllerToken, func() error { return sd.SetGlobalLeaderLine(&a, nil) })
+	case InitiateTrackCommand:
+		var a InitiateTrackArgs
+		return call(&a, &a.ControllerToken, func() error { return sd.InitiateTrack(&a, nil) })
+	case CreateUnsupportedTrackCommand:
+		var a CreateUnsupportedTrackArgs
+		return call(&a, &a.ControllerToken, func() error { return sd.CreateUnsupportedTrack(&a, nil) })
+	case UploadFlightPlanCommand:
+		var a UploadPlanArgs
+		return call(&a, &a.ControllerToken, func() error { return sd.UploadFlightPlan(&a, nil) })
+	case DropTrackCommand:
+		var a DropTrackArgs
+		return call(&a, &a.ControllerToken, func() error { return sd.DropTrack(&a, nil) })
+	case HandoffTrackCommand:
+		var a HandoffArgs
+		return call(&a, &a.ControllerToken, func() error { return sd.HandoffTrack(&a, nil) })
+	case RedirectHandoffCommand:
+		var a HandoffArgs
+		return call(&a, &a.ControllerToken, func() error { return sd.RedirectHandoff(&a, nil) })
+	case AcceptRedirectedHandoffCommand:
+		var a AcceptHandoffArgs
+		return call(&a, &a.ControllerToken, func() error { return sd.AcceptRedirectedHandoff(&a, nil) })
+	case AcceptHandoffCommand:
+		var a AcceptHandoffArgs
+		return call(&a, &a.ControllerToken, func() error { return sd.AcceptHandoff(&a, nil) })
+	case CancelHandoffCommand:
+		var a CancelHandoffArgs
+		return call(&a, &a.ControllerToken, func() error { return sd.CancelHandoff(&a, nil) })
+	case ForceQLCommand:
+		var a ForceQLArgs
+		return call(&a, &a.ControllerToken, func() error { return sd.ForceQL(&a, nil) })
+	case GlobalMessageCommand:
+		var a GlobalMessageArgs
+		return call(&a, &a.ControllerToken, func() error { return sd.GlobalMessage(&a, nil) })
+	case PointOutCommand:
+		var a PointOutArgs
+		return call(&a, &a.ControllerToken, func() error { return sd.PointOut(&a, nil) })
+	case AcknowledgePointOutCommand:
+		var a PointOutArgs
+		return call(&a, &a.ControllerToken, func() error { return sd.AcknowledgePointOut(&a, nil) })
+	case RejectPointOutCommand:
+		var a PointOutArgs
+		return call(&a, &a.ControllerToken, func() error { return sd.RejectPointOut(&a, nil) })
+	case ToggleSPCOverrideCommand:
+		var a ToggleSPCArgs
+		return call(&a, &a.ControllerToken, func() error { return sd.ToggleSPCOverride(&a, nil) })
+	case ReleaseDepartureCommand:
+		var a HeldDepartureArgs
+		return call(&a, &a.ControllerToken, func() error { return sd.ReleaseDeparture(&a, nil) })
+	case SetTemporaryAltitudeCommand:
+		var a AssignAltitudeArgs
+		return call(&a, &a.ControllerToken, func() error { return sd.SetTemporaryAltitude(&a, nil) })
+	case DeleteAllAircraftCommand:
+		var a DeleteAircraftArgs
+		return call(&a, &a.ControllerToken, func() error { return sd.DeleteAllAircraft(&a, nil) })
+	case RunAircraftCommandsCommand:
+		var a AircraftCommandsArgs
+		return call(&a, &a.ControllerToken, func() error { return sd.RunAircraftCommands(&a, &AircraftCommandsResult{}) })
+	case LaunchAircraftCommand:
+		var a LaunchAircraftArgs
+		return call(&a, &a.ControllerToken, func() error { return sd.LaunchAircraft(&a, nil) })
+	case CreateDepartureCommand:
+		var a CreateDepartureArgs
+		return call(&a, &a.ControllerToken, func() error { return sd.CreateDeparture(&a, &av.Aircraft{}) })
+	case CreateArrivalCommand:
+		var a CreateArrivalArgs
+		return call(&a, &a.ControllerToken, func() error { return sd.CreateArrival(&a, &av.Aircraft{}) })
+	case CreateOverflightCommand:
+		var a CreateOverflightArgs
+		return call(&a, &a.ControllerToken, func() error { return sd.CreateOverflight(&a, &av.Aircraft{}) })
+	case CreateRestrictionAreaCommand:
+		var a RestrictionAreaArgs
+		return call(&a, &a.ControllerToken, func() error { return sd.CreateRestrictionArea(&a, new(int)) })
+	case UpdateRestrictionAreaCommand:
+		var a RestrictionAreaArgs
+		return call(&a, &a.ControllerToken, func() error { return sd.UpdateRestrictionArea(&a, nil) })
+	case DeleteRestrictionAreaCommand:
+		var a RestrictionAreaArgs
+		return call(&a, &a.ControllerToken, func() error { return sd.DeleteRestrictionArea(&a, nil) })
+	case SetRadarSiteFailedCommand:
+		var a SetRadarSiteFailedArgs
+		return call(&a, &a.ControllerToken, func() error { return sd.SetRadarSiteFailed(&a, nil) })
+	default:
+		return fmt.Errorf("%s: unknown command: %w", cmd.Type, ErrInvalidRecording)
+	}
+}
+
+// SimStartTime returns the simulation time at which the recording began.
+func (rec *Recording) SimStartTime() time.Time {
+	return rec.Sim.SimTime
+}
+
+// ResolveTarget converts a replay target to a simulation time. The target
+// may be a number of seconds after the start of the recording, an offset
+// given as MM:SS or HH:MM:SS, or "until:CALLSIGN", which gives the time of
+// the first recorded event for the given aircraft.
+func (rec *Recording) ResolveTarget(target string) (time.Time, error) {
+	target = strings.TrimSpace(target)
+
+	if cs, ok := strings.CutPrefix(target, "until:"); ok {
+		cs = strings.ToUpper(strings.TrimSpace(cs))
+		for _, ev := range rec.Events {
+			if ev.Event.Callsign == cs {
+				return ev.SimTime, nil
+			}
+		}
+		return time.Time{}, av.ErrNoAircraftForCallsign
+	}
+
+	fields := strings.Split(target, ":")
+	if len(fields) > 3 {
+		return time.Time{}, ErrInvalidDuration
+	}
+	var sec int
+	for _, f := range fields {
+		if n, err := strconv.Atoi(f); err != nil || n < 0 {
+			return time.Time{}, ErrInvalidDuration
+		} else {
+			sec = 60*sec + n
+		}
+	}
+
+	return rec.SimStartTime().Add(time.Duration(sec) * time.Second), nil
+}
+
+// LoadRecording reads the session recording stored in the given file.
+func LoadRecording(filename string) (*Recording, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rec, err := ReadRecording(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return rec, nil
+}
+
+// ReadRecording reads a session recording. A truncated final line, as may
+// be left if vice exited unexpectedly while recording, is ignored.
+func ReadRecording(r io.Reader) (*Recording, error) {
+	br := bufio.NewReader(r)
+
+	line, err := br.ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	var rec Recording
+	if err := json.Unmarshal(line, &rec.RecordingHeader); err != nil {
+		return nil, ErrInvalidRecording
+	}
+	if rec.Version != RecordingVersion {
+		return nil, fmt.Errorf("unsupported recording version %d", rec.Version)
+	}
+	if err := gob.NewDecoder(bytes.NewReader(rec.EncodedSim)).Decode(&rec.Sim); err != nil || rec.Sim == nil {
+		return nil, ErrInvalidRecording
+	}
+
+	for {
+		line, err := br.ReadBytes('\n')
+		if err == io.EOF {
+			// A partial line at the end without a newline was never fully
+			// written; drop it.
+			return &rec, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		var entry recordingEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return nil, fmt.Errorf("entry %d: %w", len(rec.Events)+len(rec.Commands), ErrInvalidRecording)
+		}
+		if entry.Event != nil {
+			rec.Events = append(rec.Events, *entry.Event)
+		}
+		if entry.Command != nil {
+			rec.Commands = append(rec.Commands, *entry.Command)
+		}
+	}
+}
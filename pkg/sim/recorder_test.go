@@ -0,0 +1,294 @@
+// pkg/sim/recorder_test.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package sim
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	av "github.com/mmp/vice/pkg/aviation"
+	"github.com/mmp/vice/pkg/log"
+	"github.com/mmp/vice/pkg/rand"
+	"github.com/mmp/vice/pkg/util"
+)
+
+type nopWriteCloser struct {
+	*bytes.Buffer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+func TestRecorder(t *testing.T) {
+	es := NewEventStream(nil)
+	var buf bytes.Buffer
+
+	r, err := newRecorder(nopWriteCloser{&buf}, &Sim{Name: "test sim", eventStream: es}, "2K", 42)
+	if err != nil {
+		t.Fatalf("newRecorder: %v", err)
+	}
+
+	t0 := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	es.Post(Event{Type: RadioTransmissionEvent, Callsign: "AAL123", Message: "climb and maintain 8000"})
+	es.Post(Event{Type: AcceptedHandoffEvent, Callsign: "AAL123", FromController: "2K", ToController: "4P"})
+	if err := r.recordEvents(t0); err != nil {
+		t.Fatalf("recordEvents: %v", err)
+	}
+	var args bytes.Buffer
+	if err := gob.NewEncoder(&args).Encode(&HandoffArgs{Callsign: "AAL123", Controller: "4P"}); err != nil {
+		t.Fatalf("encoding arguments: %v", err)
+	}
+	if err := r.recordCommand(RecordedCommand{SimTime: t0.Add(2 * time.Second), Type: HandoffTrackCommand,
+		Args: args.Bytes(), Error: av.ErrOtherControllerHasTrack.Error()}); err != nil {
+		t.Fatalf("recordCommand: %v", err)
+	}
+	es.Post(Event{Type: StatusMessageEvent, Message: "hello"})
+	if err := r.recordEvents(t0.Add(5 * time.Second)); err != nil {
+		t.Fatalf("recordEvents: %v", err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	check := func(rec *Recording, n int) {
+		t.Helper()
+		if rec.Sim == nil || rec.Sim.Name != "test sim" {
+			t.Errorf("header sim not restored: %+v", rec.Sim)
+		}
+		if rec.PrimaryTCP != "2K" || rec.Seed != 42 {
+			t.Errorf("expected primary TCP 2K and seed 42, got %q and %d", rec.PrimaryTCP, rec.Seed)
+		}
+		if len(rec.Commands) != 1 || rec.Commands[0].Type != HandoffTrackCommand ||
+			rec.Commands[0].Error != av.ErrOtherControllerHasTrack.Error() ||
+			!rec.Commands[0].SimTime.Equal(t0.Add(2*time.Second)) {
+			t.Errorf("unexpected recorded commands %+v", rec.Commands)
+		} else {
+			var h HandoffArgs
+			if err := gob.NewDecoder(bytes.NewReader(rec.Commands[0].Args)).Decode(&h); err != nil {
+				t.Errorf("decoding recorded command arguments: %v", err)
+			} else if h.Callsign != "AAL123" || h.Controller != "4P" {
+				t.Errorf("unexpected recorded command arguments %+v", h)
+			}
+		}
+		if len(rec.Events) != n {
+			t.Fatalf("expected %d events, got %d", n, len(rec.Events))
+		}
+		if rec.Events[0].Event.Callsign != "AAL123" || !rec.Events[0].SimTime.Equal(t0) {
+			t.Errorf("unexpected first event %+v", rec.Events[0])
+		}
+	}
+
+	rec, err := ReadRecording(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("ReadRecording: %v", err)
+	}
+	check(rec, 3)
+	if rec.Events[2].Event.Message != "hello" || !rec.Events[2].SimTime.Equal(t0.Add(5*time.Second)) {
+		t.Errorf("unexpected last event %+v", rec.Events[2])
+	}
+
+	// Simulate a crash in the middle of writing the last event.
+	contents := buf.String()
+	truncated := contents[:len(contents)-10]
+	rec, err = ReadRecording(strings.NewReader(truncated))
+	if err != nil {
+		t.Fatalf("ReadRecording of truncated recording: %v", err)
+	}
+	check(rec, 2)
+
+	if _, err := ReadRecording(strings.NewReader("")); err == nil {
+		t.Errorf("expected error from empty recording")
+	}
+	if _, err := ReadRecording(strings.NewReader("not json\n")); err == nil {
+		t.Errorf("expected error from invalid recording")
+	}
+}
+
+func TestRecordingResolveTarget(t *testing.T) {
+	t0 := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	rec := &Recording{
+		RecordingHeader: RecordingHeader{Sim: &Sim{SimTime: t0}},
+		Events: []RecordedEvent{
+			{SimTime: t0.Add(30 * time.Second), Event: Event{Callsign: "DAL88"}},
+			{SimTime: t0.Add(95 * time.Second), Event: Event{Callsign: "JBU12"}},
+			{SimTime: t0.Add(200 * time.Second), Event: Event{Callsign: "JBU12"}},
+		},
+	}
+
+	for _, test := range []struct {
+		target string
+		offset time.Duration
+		err    bool
+	}{
+		{target: "90", offset: 90 * time.Second},
+		{target: "2:05", offset: 125 * time.Second},
+		{target: "1:00:10", offset: time.Hour + 10*time.Second},
+		{target: "until:jbu12", offset: 95 * time.Second},
+		{target: "until:DAL88", offset: 30 * time.Second},
+		{target: "until:AAL1", err: true},
+		{target: "", err: true},
+		{target: "1:2:3:4", err: true},
+		{target: "-5", err: true},
+		{target: "soon", err: true},
+	} {
+		tm, err := rec.ResolveTarget(test.target)
+		if test.err {
+			if err == nil {
+				t.Errorf("%q: expected error", test.target)
+			}
+		} else if err != nil {
+			t.Errorf("%q: unexpected error %v", test.target, err)
+		} else if !tm.Equal(t0.Add(test.offset)) {
+			t.Errorf("%q: expected %s, got %s", test.target, t0.Add(test.offset), tm)
+		}
+	}
+}
+
+func TestReplayMatchesRecording(t *testing.T) {
+	lg := &log.Logger{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	var e util.ErrorLogger
+	groups, configs, manifests := LoadScenarioGroups(true, "", "", &e, lg)
+	if e.HaveErrors() {
+		t.Fatalf("loading scenarios: %s", e.String())
+	}
+
+	nsc := NewSimConfiguration{TRACONName: "PHL", TRACON: configs["PHL"]}
+	nsc.GroupName = util.SortedMapKeys(nsc.TRACON)[0]
+	nsc.ScenarioName = nsc.TRACON[nsc.GroupName].DefaultScenario
+	nsc.Scenario = nsc.TRACON[nsc.GroupName].ScenarioConfigs[nsc.ScenarioName]
+
+	sm := NewSimManager(groups, configs, manifests, lg)
+	sd := &Dispatcher{sm: sm}
+	signOn := func(s *Sim) string {
+		s.Activate(lg)
+		_, token, err := s.SignOn(s.State.PrimaryController, false)
+		if err != nil {
+			t.Fatalf("SignOn: %v", err)
+		}
+		sm.controllerTokenToSim[token] = s
+		return token
+	}
+	// AdvanceTime only queues up the time to run; Update runs it. The
+	// sims are paused so that Update doesn't also run them in real time.
+	advance := func(s *Sim, token string, d time.Duration) {
+		t0 := s.SimTime
+		if err := sd.AdvanceTime(&AdvanceTimeArgs{ControllerToken: token, Duration: d}, nil); err != nil {
+			t.Fatalf("AdvanceTime: %v", err)
+		}
+		if !s.SimTime.Equal(t0) {
+			t.Fatalf("AdvanceTime ran the sim synchronously")
+		}
+		for s.advanceRemaining > 0 {
+			s.Update()
+		}
+		if got := s.SimTime.Sub(t0); got != d {
+			t.Fatalf("AdvanceTime(%s) advanced %s", d, got)
+		}
+	}
+
+	s := NewSim(nsc, groups, true, manifests, lg)
+	s.Paused = true
+	token := signOn(s)
+	filename := filepath.Join(t.TempDir(), "session.vice")
+	if err := sd.StartRecording(&RecordingArgs{ControllerToken: token, Filename: filename}, nil); err != nil {
+		t.Fatalf("StartRecording: %v", err)
+	}
+
+	// Work the traffic for a while: release departures, accept handoffs,
+	// and vector the aircraft we're talking to.
+	const steps, stepTime = 90, 10 * time.Second
+	for i := range steps {
+		advance(s, token, stepTime)
+		for _, callsign := range util.SortedMapKeys(s.State.Aircraft) {
+			ac := s.State.Aircraft[callsign]
+			switch {
+			case ac.HoldForRelease && !ac.Released:
+				sd.ReleaseDeparture(&HeldDepartureArgs{ControllerToken: token, Callsign: callsign}, nil)
+			case ac.HandoffTrackController == s.State.PrimaryController:
+				sd.AcceptHandoff(&AcceptHandoffArgs{ControllerToken: token, Callsign: callsign}, nil)
+			case ac.ControllingController == s.State.PrimaryController && i%6 == 0:
+				cmd := fmt.Sprintf("H%03d D40", 10+(37*i)%350)
+				sd.RunAircraftCommands(&AircraftCommandsArgs{ControllerToken: token, Callsign: callsign,
+					Commands: cmd}, &AircraftCommandsResult{})
+			}
+		}
+	}
+	if err := sd.StopRecording(token, nil); err != nil {
+		t.Fatalf("StopRecording: %v", err)
+	}
+
+	rec, err := LoadRecording(filename)
+	if err != nil {
+		t.Fatalf("LoadRecording: %v", err)
+	}
+	if len(rec.Commands) == 0 {
+		t.Fatalf("no commands were recorded")
+	}
+
+	// Consume some random numbers from the process-wide generator; the
+	// replay must only depend on the Sim's own generator and the recorded
+	// seed.
+	for range 100 {
+		rand.Intn(1000)
+	}
+
+	rs := rec.Sim
+	rtoken := signOn(rs)
+	if err := sd.StartReplay(&StartReplayArgs{ControllerToken: rtoken, Seed: rec.Seed,
+		Commands: rec.Commands, Paused: true}, nil); err != nil {
+		t.Fatalf("StartReplay: %v", err)
+	}
+	advance(rs, rtoken, steps*stepTime)
+
+	if !rs.SimTime.Equal(s.SimTime) {
+		t.Fatalf("replay ended at %s, recording at %s", rs.SimTime, s.SimTime)
+	}
+	if len(rs.State.Aircraft) != len(s.State.Aircraft) {
+		t.Errorf("replay has %d aircraft, recording has %d", len(rs.State.Aircraft), len(s.State.Aircraft))
+	}
+	for callsign, ac := range s.State.Aircraft {
+		rac, ok := rs.State.Aircraft[callsign]
+		if !ok {
+			t.Errorf("%s: missing from replay", callsign)
+			continue
+		}
+		want, _ := json.Marshal(ac)
+		got, _ := json.Marshal(rac)
+		if !bytes.Equal(want, got) {
+			t.Errorf("%s: replayed aircraft differs:\nrecorded %s\nreplayed %s", callsign, want, got)
+		}
+	}
+
+	// A replayed command whose result differs from the recorded one
+	// should stop the replay and tell the controller.
+	rec, err = LoadRecording(filename)
+	if err != nil {
+		t.Fatalf("LoadRecording: %v", err)
+	}
+	rec.Commands[0].Error = "not what happened"
+	ds := rec.Sim
+	dtoken := signOn(ds)
+	sub := ds.eventStream.Subscribe()
+	defer sub.Unsubscribe()
+	if err := sd.StartReplay(&StartReplayArgs{ControllerToken: dtoken, Seed: rec.Seed,
+		Commands: rec.Commands, Paused: true}, nil); err != nil {
+		t.Fatalf("StartReplay: %v", err)
+	}
+	advance(ds, dtoken, rec.Commands[0].SimTime.Sub(ds.SimTime)+time.Second)
+	if ds.replay != nil {
+		t.Errorf("replay continued after a command's result differed from the recording")
+	}
+	if !slices.ContainsFunc(sub.Get(), func(e Event) bool { return e.Type == ErrorMessageEvent }) {
+		t.Errorf("no error was reported when the replay stopped")
+	}
+}
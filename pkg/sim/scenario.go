@@ -20,6 +20,7 @@ import (
 	"github.com/mmp/vice/pkg/log"
 	"github.com/mmp/vice/pkg/math"
 	"github.com/mmp/vice/pkg/util"
+	"github.com/mmp/vice/pkg/wx"
 
 	"github.com/brunoga/deep"
 	"github.com/mmp/earcut-go"
@@ -87,6 +88,29 @@ type STARSFacilityAdaptation struct {
 	DisplayHOFacilityOnly      bool `json:"display_handoff_facility_only"`
 	HOSectorDisplayDuration    int  `json:"handoff_sector_display_duration"`
 
+	// Seconds without a radar return before a track is considered lost,
+	// for FUSED and single-site radar modes, respectively.
+	CoastSuspendTimeout           int `json:"coast_suspend_timeout"`
+	SingleSiteCoastSuspendTimeout int `json:"single_site_coast_suspend_timeout"`
+
+	// Seconds ahead that tracks are projected along their current course
+	// and vertical rate when checking for MSAW conflicts; 0 disables the
+	// projection. Always set after the adaptation is loaded.
+	MSAWLookahead *int `json:"msaw_lookahead"`
+
+	ATPA ATPAParameters `json:"atpa"`
+
+	// Template for automatically-generated ATIS broadcasts; see
+	// wx.DefaultATISTemplate, which is used if it is empty.
+	ATISTemplate string `json:"atis_template"`
+
+	// Initial STARS altitude filters, given as [low, high] in feet. Unset
+	// (all zero) filters leave the user's current settings as they are.
+	AltitudeFilters struct {
+		Unassociated [2]int `json:"unassociated"`
+		Associated   [2]int `json:"associated"`
+	} `json:"altitude_filters"`
+
 	PDB struct {
 		ShowScratchpad2  bool `json:"show_scratchpad2"`
 		HideGroundspeed  bool `json:"hide_gs"`
@@ -104,6 +128,20 @@ type STARSFacilityAdaptation struct {
 	UseLegacyFont     bool               `json:"use_legacy_font"`
 }
 
+// ATPAParameters specifies the criteria for ATPA monitor cones, warnings,
+// and alerts.
+type ATPAParameters struct {
+	// Seconds ahead that in-trail separation is predicted; a warning is
+	// issued if it will be lost within this time.
+	WarningLookahead int `json:"warning_lookahead"`
+	// An alert is issued instead if separation will be lost within this
+	// many seconds.
+	AlertLookahead int `json:"alert_lookahead"`
+	// Monitor cones are shown if the in-trail distance is within this many
+	// nm of the required separation.
+	MonitorDistance float32 `json:"monitor_distance"`
+}
+
 type STARSControllerConfig struct {
 	VideoMapNames []string      `json:"video_maps"`
 	DefaultMaps   []string      `json:"default_maps"`
@@ -733,6 +771,44 @@ var (
 	reFixHeadingDistance = regexp.MustCompile(`^([\w-]{3,})@([\d]{3})/(\d+(\.\d+)?)$`)
 )
 
+// UnknownWakeCategoryTypes returns the aircraft types used by the
+// scenario group's departures, arrivals, and overflights that don't have
+// a known CWT category, along with the number of fleet entries that
+// include each one.
+func (sg *ScenarioGroup) UnknownWakeCategoryTypes() map[string]int {
+	counts := make(map[string]int)
+	check := func(al av.AirlineSpecifier) {
+		for _, ac := range al.Aircraft() {
+			if av.AircraftTypeCWT(ac.ICAO) == "NOWGT" {
+				counts[ac.ICAO]++
+			}
+		}
+	}
+
+	for _, ap := range sg.Airports {
+		for _, dep := range ap.Departures {
+			for _, al := range dep.Airlines {
+				check(al.AirlineSpecifier)
+			}
+		}
+	}
+	for _, flow := range sg.InboundFlows {
+		for _, ar := range flow.Arrivals {
+			for _, airlines := range ar.Airlines {
+				for _, al := range airlines {
+					check(al.AirlineSpecifier)
+				}
+			}
+		}
+		for _, of := range flow.Overflights {
+			for _, al := range of.Airlines {
+				check(al.AirlineSpecifier)
+			}
+		}
+	}
+	return counts
+}
+
 func (sg *ScenarioGroup) PostDeserialize(multiController bool, e *util.ErrorLogger, simConfigurations map[string]map[string]*Configuration,
 	manifest *av.VideoMapManifest) {
 	defer e.CheckDepth(e.CurrentDepth())
@@ -1014,6 +1090,45 @@ func (sg *ScenarioGroup) rewriteControllers(e *util.ErrorLogger) {
 	sg.ControlPositions = pos
 }
 
+// CheckVideoMapIds reports video maps used by the facility adaptation that
+// are missing from the given video map library as well as maps that share
+// a STARS map id in the same list of maps, since the DCB can't
+// distinguish between them.
+func (s *STARSFacilityAdaptation) CheckVideoMapIds(lib *av.VideoMapLibrary, e *util.ErrorLogger) {
+	defer e.CheckDepth(e.CurrentDepth())
+
+	maps := make(map[string]av.VideoMap)
+	for _, m := range lib.Maps {
+		maps[m.Name] = m
+	}
+
+	check := func(what string, names []string) {
+		ids := make(map[int]string)
+		for _, name := range names {
+			if name == "" {
+				continue
+			}
+			if m, ok := maps[name]; !ok {
+				e.ErrorString("video map %q in %s is not in %q", name, what, s.VideoMapFile)
+			} else if prev, ok := ids[m.Id]; ok && prev != name {
+				// A map that is listed more than once is still only one map.
+				e.ErrorString("video maps %q and %q in %s both have id %d in %q", prev, name, what,
+					m.Id, s.VideoMapFile)
+			} else {
+				ids[m.Id] = name
+			}
+		}
+	}
+
+	e.Push("stars_config")
+	defer e.Pop()
+
+	check("\"stars_maps\"", s.VideoMapNames)
+	for _, ctrl := range util.SortedMapKeys(s.ControllerConfigs) {
+		check(fmt.Sprintf("\"video_maps\" for controller %q", ctrl), s.ControllerConfigs[ctrl].VideoMapNames)
+	}
+}
+
 func (s *STARSFacilityAdaptation) PostDeserialize(e *util.ErrorLogger, sg *ScenarioGroup, manifest *av.VideoMapManifest) {
 	defer e.CheckDepth(e.CurrentDepth())
 
@@ -1100,6 +1215,49 @@ func (s *STARSFacilityAdaptation) PostDeserialize(e *util.ErrorLogger, sg *Scena
 	if s.HandoffAcceptFlashDuration == 0 {
 		s.HandoffAcceptFlashDuration = 5
 	}
+	if s.CoastSuspendTimeout == 0 {
+		s.CoastSuspendTimeout = 30
+	}
+	if s.SingleSiteCoastSuspendTimeout == 0 {
+		// Single-site radars only update every 5 seconds, so allow more
+		// missed returns before the track is lost.
+		s.SingleSiteCoastSuspendTimeout = 60
+	}
+	if s.MSAWLookahead == nil {
+		lookahead := 30
+		s.MSAWLookahead = &lookahead
+	}
+
+	if s.ATPA.WarningLookahead == 0 {
+		s.ATPA.WarningLookahead = 45 // 6-159
+	}
+	if s.ATPA.AlertLookahead == 0 {
+		s.ATPA.AlertLookahead = 24 // 6-159
+	}
+	if s.ATPA.MonitorDistance == 0 {
+		s.ATPA.MonitorDistance = 2
+	}
+	if s.ATPA.WarningLookahead < 0 || s.ATPA.AlertLookahead < 0 || s.ATPA.MonitorDistance < 0 {
+		e.ErrorString("\"atpa\" parameters must not be negative")
+	} else if s.ATPA.AlertLookahead > s.ATPA.WarningLookahead {
+		e.ErrorString("\"atpa\" \"alert_lookahead\" %d must not be greater than \"warning_lookahead\" %d",
+			s.ATPA.AlertLookahead, s.ATPA.WarningLookahead)
+	}
+
+	if s.ATISTemplate != "" {
+		if _, err := wx.ParseATISTemplate(s.ATISTemplate); err != nil {
+			e.ErrorString("\"atis_template\": %v", err)
+		}
+	}
+
+	checkAltitudeFilter := func(name string, f [2]int) {
+		if f != [2]int{} && (f[0] < 0 || f[1] > 60000 || f[0] >= f[1]) {
+			e.ErrorString("\"altitude_filters\" %q range %d-%d invalid: must have low < high and be between 0 and 60000",
+				name, f[0], f[1])
+		}
+	}
+	checkAltitudeFilter("unassociated", s.AltitudeFilters.Unassociated)
+	checkAltitudeFilter("associated", s.AltitudeFilters.Associated)
 
 	for name, rs := range s.RadarSites {
 		e.Push("Radar site " + name)
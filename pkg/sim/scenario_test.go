@@ -0,0 +1,89 @@
+// pkg/sim/scenario_test.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package sim
+
+import (
+	"strings"
+	"testing"
+
+	av "github.com/mmp/vice/pkg/aviation"
+	"github.com/mmp/vice/pkg/util"
+)
+
+func TestCheckVideoMapIds(t *testing.T) {
+	lib := &av.VideoMapLibrary{
+		Maps: []av.VideoMap{
+			{Name: "JFK 4S", Id: 100},
+			{Name: "JFK 31", Id: 101},
+			{Name: "LGA 4", Id: 101},
+			{Name: "EWR 22", Id: 200},
+		},
+	}
+
+	fa := &STARSFacilityAdaptation{
+		VideoMapFile:  "ZNY-videomaps.gob.zst",
+		VideoMapNames: []string{"JFK 4S", "", "EWR 22", "JFK 31", "JFK 4S"},
+		ControllerConfigs: map[string]*STARSControllerConfig{
+			"2K": {VideoMapNames: []string{"JFK 4S", "JFK 31", "LGA 4"}},
+			"4P": {VideoMapNames: []string{"EWR 22", "EWR 4"}},
+		},
+	}
+
+	var e util.ErrorLogger
+	fa.CheckVideoMapIds(lib, &e)
+
+	errs := strings.Split(e.String(), "\n")
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got %d: %s", len(errs), e.String())
+	}
+	if !strings.Contains(errs[0], `"JFK 31" and "LGA 4"`) || !strings.Contains(errs[0], `"2K"`) ||
+		!strings.Contains(errs[0], "id 101") {
+		t.Errorf("unexpected duplicate id error %q", errs[0])
+	}
+	if !strings.Contains(errs[1], `"EWR 4"`) || !strings.Contains(errs[1], `"4P"`) ||
+		!strings.Contains(errs[1], "ZNY-videomaps.gob.zst") {
+		t.Errorf("unexpected missing map error %q", errs[1])
+	}
+
+	fa.ControllerConfigs = nil
+	e = util.ErrorLogger{}
+	fa.CheckVideoMapIds(lib, &e)
+	if e.HaveErrors() {
+		t.Errorf("unexpected errors: %s", e.String())
+	}
+}
+
+func TestUnknownWakeCategoryTypes(t *testing.T) {
+	saved := av.DB
+	defer func() { av.DB = saved }()
+	var a320, b738 av.AircraftPerformance
+	a320.Category.CWT = "F"
+	b738.Category.CWT = "Z" // not a valid category
+	av.DB = &av.StaticDatabase{
+		AircraftPerformance: map[string]av.AircraftPerformance{"A320": a320, "B738": b738},
+	}
+
+	types := func(ty ...string) av.AirlineSpecifier { return av.AirlineSpecifier{ICAO: "AAL", AircraftTypes: ty} }
+	sg := &ScenarioGroup{
+		Airports: map[string]*av.Airport{
+			"KJFK": &av.Airport{Departures: []av.Departure{
+				{Airlines: []av.DepartureAirline{{AirlineSpecifier: types("A320", "B738")}, {AirlineSpecifier: types("XXXX")}}},
+			}},
+		},
+		InboundFlows: map[string]*InboundFlow{
+			"flow": &InboundFlow{
+				Arrivals: []av.Arrival{{Airlines: map[string][]av.ArrivalAirline{
+					"KJFK": {{AirlineSpecifier: types("A320", "XXXX")}},
+				}}},
+				Overflights: []av.Overflight{{Airlines: []av.OverflightAirline{{AirlineSpecifier: types("B738")}}}},
+			},
+		},
+	}
+
+	counts := sg.UnknownWakeCategoryTypes()
+	if len(counts) != 2 || counts["B738"] != 2 || counts["XXXX"] != 2 {
+		t.Errorf("got %v, expected B738 and XXXX twice each", counts)
+	}
+}
@@ -12,10 +12,12 @@ import (
 	gomath "math"
 	"net"
 	"net/http"
+	"net/http/pprof"
 	"net/rpc"
 	"os"
 	"os/exec"
 	"runtime"
+	"sync"
 	"time"
 
 	"github.com/mmp/vice/pkg/log"
@@ -24,8 +26,18 @@ import (
 )
 
 const ViceServerAddress = "vice.pharr.org"
-const ViceServerPort = 8080 - 21 + ViceRPCVersion
-const ViceRPCVersion = 21
+
+// Clients of all versions connect to the same port so that the server can
+// tell those it doesn't support which version of vice to install.
+const ViceServerPort = 8080
+const ViceRPCVersion = 22
+
+// The range of client RPC versions that this server accepts; these are
+// reported to clients by SimManager.NegotiateVersion. A server only
+// speaks its own version's wire format, so the range is a single version
+// until the server can also speak an older one.
+const MinSupportedRPCVersion = ViceRPCVersion
+const MaxSupportedRPCVersion = ViceRPCVersion
 
 type Server struct {
 	*util.RPCClient
@@ -43,7 +55,12 @@ func (s *Server) Close() error {
 	return s.RPCClient.Close()
 }
 
-func RunServer(extraScenario string, extraVideoMap string, serverPort int, lg *log.Logger) {
+// RunServer runs the multi-controller server on the given port. If
+// metricsPort is non-zero, health and metrics endpoints are served via
+// HTTP on that port. If pprofPort is non-zero, pprof's endpoints are
+// served on that port, only to the local host.
+func RunServer(extraScenario string, extraVideoMap string, serverPort int, metricsPort int, pprofPort int,
+	lg *log.Logger) {
 	l, err := net.Listen("tcp", fmt.Sprintf(":%d", serverPort))
 	if err != nil {
 		lg.Errorf("tcp listen: %v", err)
@@ -53,7 +70,7 @@ func RunServer(extraScenario string, extraVideoMap string, serverPort int, lg *l
 	// If we're just running the server, we don't care about the returned
 	// configs...
 	var e util.ErrorLogger
-	if runServer(l, false, extraScenario, extraVideoMap, &e, lg) == nil && e.HaveErrors() {
+	if runServer(l, false, extraScenario, extraVideoMap, metricsPort, pprofPort, &e, lg) == nil && e.HaveErrors() {
 		e.PrintErrors(lg)
 		os.Exit(1)
 	}
@@ -82,10 +99,15 @@ func TryConnectRemoteServer(hostname string, lg *log.Logger) chan *serverConnect
 			ch <- &serverConnection{Err: err}
 			return
 		} else {
+			if err := negotiateRPCVersion(client, lg); err != nil {
+				ch <- &serverConnection{Err: err}
+				return
+			}
+
 			var so SignOnResult
 			start := time.Now()
 			if err := client.CallWithTimeout("SimManager.SignOn", ViceRPCVersion, &so); err != nil {
-				ch <- &serverConnection{Err: err}
+				ch <- &serverConnection{Err: TryDecodeError(err)}
 			} else {
 				lg.Debugf("%s: server returned configuration in %s", hostname, time.Since(start))
 				ch <- &serverConnection{
@@ -103,6 +125,23 @@ func TryConnectRemoteServer(hostname string, lg *log.Logger) chan *serverConnect
 	return ch
 }
 
+// negotiateRPCVersion checks that the server supports our RPC version,
+// returning an *RPCVersionMismatchError with the server's supported range
+// if not. If the version can't be negotiated, e.g. because the server
+// predates NegotiateVersion, it returns nil and SignOn does the version
+// check as before.
+func negotiateRPCVersion(client *util.RPCClient, lg *log.Logger) error {
+	var vr RPCVersionRange
+	if err := client.CallWithTimeout("SimManager.NegotiateVersion", ViceRPCVersion, &vr); err != nil {
+		lg.Infof("Unable to negotiate RPC version: %v", err)
+		return nil
+	}
+	if ViceRPCVersion < vr.Min || ViceRPCVersion > vr.Max {
+		return &RPCVersionMismatchError{Client: ViceRPCVersion, ServerMin: vr.Min, ServerMax: vr.Max}
+	}
+	return nil
+}
+
 func LaunchLocalServer(extraScenario string, extraVideoMap string, e *util.ErrorLogger, lg *log.Logger) (chan *Server, error) {
 	l, err := net.Listen("tcp", ":0")
 	if err != nil {
@@ -111,7 +150,7 @@ func LaunchLocalServer(extraScenario string, extraVideoMap string, e *util.Error
 
 	port := l.Addr().(*net.TCPAddr).Port
 
-	configsChan := runServer(l, true, extraScenario, extraVideoMap, e, lg)
+	configsChan := runServer(l, true, extraScenario, extraVideoMap, 0, 0, e, lg)
 	if e.HaveErrors() {
 		return nil, nil
 	}
@@ -136,8 +175,8 @@ func LaunchLocalServer(extraScenario string, extraVideoMap string, e *util.Error
 	return ch, nil
 }
 
-func runServer(l net.Listener, isLocal bool, extraScenario string, extraVideoMap string,
-	e *util.ErrorLogger, lg *log.Logger) chan map[string]map[string]*Configuration {
+func runServer(l net.Listener, isLocal bool, extraScenario string, extraVideoMap string, metricsPort int,
+	pprofPort int, e *util.ErrorLogger, lg *log.Logger) chan map[string]map[string]*Configuration {
 	scenarioGroups, simConfigurations, mapManifests :=
 		LoadScenarioGroups(isLocal, extraScenario, extraVideoMap, e, lg)
 	if e.HaveErrors() {
@@ -160,19 +199,36 @@ func runServer(l net.Listener, isLocal bool, extraScenario string, extraVideoMap
 		}
 
 		go launchHTTPStats(sm)
+		if metricsPort != 0 {
+			go launchHTTPMetrics(sm, metricsPort)
+		}
+		if pprofPort != 0 {
+			go launchHTTPPprof(sm, pprofPort)
+		}
 
 		ch <- simConfigurations
 
 		lg.Infof("Listening on %+v", l)
 
+		go func() {
+			<-sm.Shutdown()
+			l.Close()
+		}()
+
 		for {
 			conn, err := l.Accept()
-			lg.Infof("%s: new connection", conn.RemoteAddr())
 			if err != nil {
-				lg.Errorf("Accept error: %v", err)
+				select {
+				case <-sm.Shutdown():
+					lg.Info("Server shut down")
+					return
+				default:
+					lg.Errorf("Accept error: %v", err)
+				}
 			} else if cc, err := util.MakeCompressedConn(util.MakeLoggingConn(conn, lg)); err != nil {
 				lg.Errorf("MakeCompressedConn: %v", err)
 			} else {
+				lg.Infof("%s: new connection", conn.RemoteAddr())
 				codec := util.MakeGOBServerCodec(cc, lg)
 				codec = util.MakeLoggingServerCodec(conn.RemoteAddr().String(), codec, lg)
 				go server.ServeCodec(codec)
@@ -195,11 +251,14 @@ var launchTime time.Time
 
 func launchHTTPStats(sm *SimManager) {
 	launchTime = time.Now()
-	http.HandleFunc("/sup", func(w http.ResponseWriter, r *http.Request) {
+	// Use a separate mux rather than http.DefaultServeMux, where importing
+	// net/http/pprof registers its handlers.
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sup", func(w http.ResponseWriter, r *http.Request) {
 		statsHandler(w, r, sm)
 		sm.lg.Infof("%s: served stats request", r.URL.String())
 	})
-	http.HandleFunc("/vice-logs/", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/vice-logs/", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/plain")
 		if f, err := os.Open("." + r.URL.String()); err == nil {
 			if n, err := io.Copy(w, f); err != nil {
@@ -210,7 +269,7 @@ func launchHTTPStats(sm *SimManager) {
 		}
 	})
 
-	if err := http.ListenAndServe(":6502", nil); err != nil {
+	if err := http.ListenAndServe(":6502", mux); err != nil {
 		sm.lg.Errorf("Failed to start HTTP server for stats: %v\n", err)
 	}
 }
@@ -362,3 +421,96 @@ func statsHandler(w http.ResponseWriter, r *http.Request, sm *SimManager) {
 
 	statsTemplate.Execute(w, stats)
 }
+
+///////////////////////////////////////////////////////////////////////////
+// Health and metrics via HTTP
+
+type serverMetrics struct {
+	ActiveSims           int
+	ConnectedControllers int
+	Aircraft             int
+}
+
+func (sm *SimManager) getMetrics() serverMetrics {
+	sm.mu.Lock(sm.lg)
+	defer sm.mu.Unlock(sm.lg)
+
+	m := serverMetrics{ActiveSims: len(sm.activeSims)}
+	for _, sim := range sm.activeSims {
+		sim.mu.Lock(sim.lg)
+		m.ConnectedControllers += len(sim.controllers)
+		m.Aircraft += len(sim.State.Aircraft)
+		sim.mu.Unlock(sim.lg)
+	}
+	return m
+}
+
+// launchHTTPMetrics serves /healthz and /metrics endpoints on the given
+// port. /metrics uses the Prometheus text format.
+func launchHTTPMetrics(sm *SimManager, port int) {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		if sm.Draining() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintln(w, "draining")
+		} else {
+			fmt.Fprintln(w, "ok")
+		}
+	})
+
+	var mu sync.Mutex
+	lastRequests, lastTime := util.GetLoggedRPCRequests(), time.Now()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		m := sm.getMetrics()
+		rx, tx := util.GetLoggedRPCBandwidth()
+
+		// The RPC rate is computed over the interval since the previous
+		// request to /metrics.
+		mu.Lock()
+		requests, now := util.GetLoggedRPCRequests(), time.Now()
+		rate := float64(requests-lastRequests) / now.Sub(lastTime).Seconds()
+		lastRequests, lastTime = requests, now
+		mu.Unlock()
+
+		draining := 0
+		if sm.Draining() {
+			draining = 1
+		}
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		fmt.Fprintf(w, "vice_uptime_seconds %d\n", int64(time.Since(sm.startTime).Seconds()))
+		fmt.Fprintf(w, "vice_draining %d\n", draining)
+		fmt.Fprintf(w, "vice_active_sims %d\n", m.ActiveSims)
+		fmt.Fprintf(w, "vice_connected_controllers %d\n", m.ConnectedControllers)
+		fmt.Fprintf(w, "vice_aircraft %d\n", m.Aircraft)
+		fmt.Fprintf(w, "vice_rpc_requests_total %d\n", requests)
+		fmt.Fprintf(w, "vice_rpc_requests_per_second %.2f\n", rate)
+		fmt.Fprintf(w, "vice_rpc_received_bytes_total %d\n", rx)
+		fmt.Fprintf(w, "vice_rpc_sent_bytes_total %d\n", tx)
+		fmt.Fprintf(w, "vice_goroutines %d\n", runtime.NumGoroutine())
+	})
+
+	sm.lg.Infof("Serving metrics on port %d", port)
+	if err := http.ListenAndServe(fmt.Sprintf(":%d", port), mux); err != nil {
+		sm.lg.Errorf("Failed to start HTTP server for metrics: %v", err)
+	}
+}
+
+// launchHTTPPprof serves pprof's /debug/pprof/ endpoints on the given
+// port. They expose the server's command line and allow arbitrarily long
+// profiles to be run, so they are only served to the local host.
+func launchHTTPPprof(sm *SimManager, port int) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	sm.lg.Infof("Serving pprof on localhost port %d", port)
+	if err := http.ListenAndServe(fmt.Sprintf("localhost:%d", port), mux); err != nil {
+		sm.lg.Errorf("Failed to start HTTP server for pprof: %v", err)
+	}
+}
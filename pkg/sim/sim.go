@@ -5,6 +5,7 @@
 package sim
 
 import (
+	"cmp"
 	crand "crypto/rand"
 	"encoding/base64"
 	"errors"
@@ -16,7 +17,6 @@ import (
 	"sort"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	av "github.com/mmp/vice/pkg/aviation"
@@ -33,10 +33,9 @@ import (
 
 const initialSimSeconds = 45
 
-var (
-	airportWind sync.Map
-	windRequest = make(map[string]chan struct{})
-)
+// advanceTimeBudget is the most wallclock time that Update spends running
+// the sim forward for AdvanceTime before releasing the sim's lock.
+const advanceTimeBudget = 50 * time.Millisecond
 
 type Configuration struct {
 	ScenarioConfigs  map[string]*SimScenarioConfiguration
@@ -628,17 +627,20 @@ func (c *NewSimConfiguration) DrawUI(p platform.Platform) bool {
 				sort.Strings(a)
 				imgui.Text(strings.Join(a, ", "))
 			}
-			validAirport := c.Scenario.PrimaryAirport != "KAAC" && c.mgr.remoteServer != nil
+			// All of the live weather controls go by this.
+			online := c.mgr.remoteServer != nil
+			weatherAvailable, weatherStatus := liveWeatherAvailable(c.Scenario.PrimaryAirport, online)
 
 			imgui.TableNextRow()
 			imgui.TableNextColumn()
 			imgui.Text("Wind:")
-			uiStartDisable(!validAirport)
+			uiStartDisable(!weatherAvailable)
 			imgui.Checkbox("Live Weather", &c.LiveWeather)
-			if !validAirport {
+			if !weatherAvailable {
 				c.LiveWeather = false
 			}
-			uiEndDisable(!validAirport)
+			uiEndDisable(!weatherAvailable)
+			uiDisabledTooltip(weatherStatus)
 
 			if c.NewSimType == NewSimCreateRemote {
 				imgui.Checkbox("Require Password", &c.RequirePassword)
@@ -655,14 +657,11 @@ func (c *NewSimConfiguration) DrawUI(p platform.Platform) bool {
 
 			imgui.TableNextColumn()
 			wind := c.Scenario.Wind
-			if c.LiveWeather {
-				if w, ok := airportWind.Load(c.Scenario.PrimaryAirport); !ok {
-					primary := c.Scenario.PrimaryAirport
-					if wind, ok = getWind(primary, c.lg); !ok {
-						wind = c.Scenario.Wind
-					}
-				} else {
-					wind = w.(av.Wind)
+			altimeter := ""
+			if c.LiveWeather && weatherAvailable {
+				if metar, ok := WeatherCache.Get(c.Scenario.PrimaryAirport, c.lg); ok {
+					wind = windFromMETAR(metar)
+					altimeter = " " + altimeterText(metar)
 				}
 			}
 
@@ -674,17 +673,30 @@ func (c *NewSimConfiguration) DrawUI(p platform.Platform) bool {
 			}
 
 			if wind.Gust > wind.Speed {
-				imgui.Text(fmt.Sprintf("%s at %d gust %d", dir, wind.Speed, wind.Gust))
+				imgui.Text(fmt.Sprintf("%s at %d gust %d%s", dir, wind.Speed, wind.Gust, altimeter))
 			} else {
-				imgui.Text(fmt.Sprintf("%s at %d", dir, wind.Speed))
+				imgui.Text(fmt.Sprintf("%s at %d%s", dir, wind.Speed, altimeter))
+			}
+			if !weatherAvailable {
+				uiDisabledTooltip("The scenario's wind is being used. " + weatherStatus)
 			}
 
-			uiStartDisable(!c.LiveWeather)
-			refresh := imgui.Button("Refresh Weather")
-			if refresh {
-				refreshWeather()
+			if !weatherAvailable && c.Scenario.PrimaryAirport != "KAAC" && online {
+				// The last fetch failed; allow trying again.
+				if imgui.Button("Retry") {
+					WeatherCache.Retry(c.Scenario.PrimaryAirport, c.lg)
+				}
+				uiDisabledTooltip(weatherStatus)
+			} else {
+				disable := !weatherAvailable || !c.LiveWeather
+				uiStartDisable(disable)
+				refresh := imgui.Button("Refresh Weather")
+				if refresh {
+					WeatherCache.Clear()
+				}
+				uiEndDisable(disable)
+				uiDisabledTooltip(weatherStatus)
 			}
-			uiEndDisable(!c.LiveWeather)
 			imgui.EndTable()
 		}
 	} else {
@@ -804,64 +816,6 @@ func (c *NewSimConfiguration) DrawRatesUI(p platform.Platform) bool {
 	return false
 }
 
-func getWind(airport string, lg *log.Logger) (av.Wind, bool) {
-	for key, done := range windRequest {
-		select {
-		case <-done:
-			delete(windRequest, key)
-		default:
-			// no wind yet
-		}
-	}
-
-	if wind, ok := airportWind.Load(airport); ok {
-		// The wind is in the map
-		return wind.(av.Wind), true
-	} else if _, ok := windRequest[airport]; ok {
-		// it's been requested, but we don't have it yet
-		return av.Wind{}, false
-	} else {
-		// It hasn't been requested nor is in airportWind
-		done := make(chan struct{}, 1)
-		windRequest[airport] = done
-		go func(done chan<- struct{}, airport string) {
-			defer close(done)
-
-			weather, err := getWeather(airport)
-			if err != nil {
-				lg.Errorf("%v", err)
-				return
-			}
-
-			airportWind.Store(airport, av.Wind{
-				Direction: int32(weather[0].GetWindDirection()),
-				Speed:     int32(weather[0].Wspd),
-				Gust:      int32(weather[0].Wgst),
-			})
-		}(done, airport)
-
-		return av.Wind{}, false
-	}
-}
-
-func refreshWeather() {
-	var wg sync.WaitGroup
-
-	// Wait for all active requests to complete.
-	wg.Add(len(windRequest))
-	for _, ch := range windRequest {
-		go func(done <-chan struct{}) {
-			defer wg.Done()
-
-			<-done
-		}(ch)
-	}
-	wg.Wait()
-	clear(windRequest)
-
-	airportWind.Clear()
-}
-
 func (c *NewSimConfiguration) OkDisabled() bool {
 	return c.NewSimType == NewSimCreateRemote && (c.NewSimName == "" || (c.RequirePassword && c.Password == ""))
 }
@@ -907,6 +861,11 @@ type Sim struct {
 	Name string
 
 	mu util.LoggingMutex
+	// cmdMu is held while controller commands run and while the Sim
+	// advances so that the two never interleave, which allows recorded
+	// sessions to be replayed exactly. It also protects recorder and
+	// replay and must be acquired before mu.
+	cmdMu util.LoggingMutex
 
 	ScenarioGroup string
 	Scenario      string
@@ -920,6 +879,13 @@ type Sim struct {
 	lg          *log.Logger
 	mapManifest *av.VideoMapManifest
 
+	// rand is used for all of the Sim's random choices. It isn't shared
+	// with other Sims so that a session can be reproduced from its seed.
+	rand *rand.Rand
+
+	recorder *recorder // non-nil if the session is being recorded
+	replay   *replayer // non-nil while replaying a recorded session
+
 	LaunchConfig LaunchConfig
 
 	// For each airport, at what time we would like to launch a departure,
@@ -965,6 +931,10 @@ type Sim struct {
 	SimRate        float32
 	Paused         bool
 
+	// advanceRemaining is the number of seconds that AdvanceTime has
+	// asked for that Update has not yet run.
+	advanceRemaining int
+
 	NextPushStart time.Time // both w.r.t. sim time
 	PushEnd       time.Time
 
@@ -1058,13 +1028,16 @@ func NewSim(ssc NewSimConfiguration, scenarioGroups map[string]map[string]*Scena
 		Instructors:       make(map[string]bool),
 	}
 
+	s.rand = rand.New()
+	s.rand.Seed(time.Now().UnixNano())
+
 	if !isLocal {
 		s.Name = ssc.NewSimName
 	}
 
 	if s.LaunchConfig.ArrivalPushes {
 		// Figure out when the next arrival push will start
-		m := 1 + rand.Intn(s.LaunchConfig.ArrivalPushFrequencyMinutes)
+		m := 1 + s.rand.Intn(s.LaunchConfig.ArrivalPushFrequencyMinutes)
 		s.NextPushStart = time.Now().Add(time.Duration(m) * time.Minute)
 	}
 
@@ -1265,6 +1238,44 @@ func (s *Sim) TogglePause(token string) error {
 	}
 }
 
+// privilegedTCW returns true if the given controller may use
+// instructor-only commands: anyone can in a local sim, but only
+// instructors can in multi-controller sims. s.mu must be held.
+func (s *Sim) privilegedTCW(id string) bool {
+	return s.Name == "" || s.Instructors[id]
+}
+
+// SetRadarSiteFailed marks a radar site as failed or restores it; only
+// instructors may do so.
+func (s *Sim) SetRadarSiteFailed(token, site string, failed bool) error {
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	if ctrl, ok := s.controllers[token]; !ok {
+		return ErrInvalidControllerToken
+	} else if !s.Instructors[ctrl.Id] {
+		return ErrNotInstructor
+	} else if _, ok := s.State.RadarSites[site]; !ok {
+		return ErrUnknownRadarSite
+	} else {
+		if failed {
+			if s.State.FailedRadarSites == nil {
+				s.State.FailedRadarSites = make(map[string]bool)
+			}
+			s.State.FailedRadarSites[site] = true
+		} else {
+			delete(s.State.FailedRadarSites, site)
+		}
+
+		s.lg.Infof("%s: radar site %s failed: %v", ctrl.Id, site, failed)
+		s.eventStream.Post(Event{
+			Type:    StatusMessageEvent,
+			Message: "Radar site " + site + util.Select(failed, " has failed", " has been restored"),
+		})
+		return nil
+	}
+}
+
 func (s *Sim) PostEvent(e Event) {
 	s.eventStream.Post(e)
 }
@@ -1292,6 +1303,7 @@ type WorldUpdate struct {
 	TotalArrivals    int
 	TotalOverflights int
 	Instructors      map[string]bool
+	FailedRadarSites map[string]bool
 }
 
 func (s *Sim) GetWorldUpdate(token string, update *WorldUpdate) error {
@@ -1326,6 +1338,7 @@ func (s *Sim) GetWorldUpdate(token string, update *WorldUpdate) error {
 			TotalOverflights:     s.TotalOverflights,
 			UserRestrictionAreas: s.State.UserRestrictionAreas,
 			Instructors:          s.Instructors,
+			FailedRadarSites:     s.State.FailedRadarSites,
 		})
 
 		return err
@@ -1345,6 +1358,10 @@ func (s *Sim) Activate(lg *log.Logger) {
 	if s.eventStream == nil {
 		s.eventStream = NewEventStream(lg)
 	}
+	if s.rand == nil {
+		s.rand = rand.New()
+		s.rand.Seed(time.Now().UnixNano())
+	}
 
 	now := time.Now()
 	s.lastUpdateTime = now
@@ -1356,6 +1373,8 @@ func (s *Sim) Activate(lg *log.Logger) {
 // Simulation
 
 func (s *Sim) Update() {
+	s.cmdMu.Lock(s.lg)
+	defer s.cmdMu.Unlock(s.lg)
 	s.mu.Lock(s.lg)
 	defer s.mu.Unlock(s.lg)
 
@@ -1398,6 +1417,18 @@ func (s *Sim) Update() {
 		}
 	}
 
+	if s.advanceRemaining > 0 {
+		// Run the sim forward for AdvanceTime, but only for a bounded
+		// amount of time so that the lock is released regularly for
+		// other requests; the rest happens in subsequent calls.
+		for s.advanceRemaining > 0 && time.Since(startUpdate) < advanceTimeBudget {
+			s.step()
+			s.advanceRemaining--
+		}
+		s.lastUpdateTime = time.Now()
+		return
+	}
+
 	if s.Paused {
 		return
 	}
@@ -1419,8 +1450,7 @@ func (s *Sim) Update() {
 			slog.Int("steps", ns), slog.Duration("slop", s.updateTimeSlop))
 	}
 	for i := 0; i < ns; i++ {
-		s.SimTime = s.SimTime.Add(time.Second)
-		s.updateState()
+		s.step()
 	}
 	s.updateTimeSlop = elapsed - elapsed.Truncate(time.Second)
 	s.State.SimTime = s.SimTime
@@ -1434,6 +1464,19 @@ func (s *Sim) Update() {
 	}
 }
 
+// step advances the simulation by one second and then runs any commands
+// from a recorded session that are due. Both s.cmdMu and s.mu must be
+// held.
+func (s *Sim) step() {
+	s.SimTime = s.SimTime.Add(time.Second)
+	// Keep State's time current as well since the wind model is
+	// evaluated using it.
+	s.State.SimTime = s.SimTime
+	s.updateState()
+	s.recordEvents()
+	s.replayCommands()
+}
+
 // separate so time management can be outside this so we can do the prespawn stuff...
 func (s *Sim) updateState() {
 	now := s.SimTime
@@ -1496,7 +1539,14 @@ func (s *Sim) updateState() {
 	// Update the simulation state once a second.
 	if now.Sub(s.lastSimUpdate) >= time.Second {
 		s.lastSimUpdate = now
-		for callsign, ac := range s.State.Aircraft {
+		// Update the aircraft in a fixed order so that their use of
+		// random numbers, and thus the whole simulation, is reproducible.
+		for _, callsign := range util.SortedMapKeys(s.State.Aircraft) {
+			ac, ok := s.State.Aircraft[callsign]
+			if !ok {
+				// Deleted while updating an earlier aircraft.
+				continue
+			}
 			if ac.HoldForRelease && !ac.Released {
 				// nvm...
 				continue
@@ -1505,7 +1555,7 @@ func (s *Sim) updateState() {
 				continue
 			}
 
-			passedWaypoint := ac.Update(s.State, s.lg)
+			passedWaypoint := ac.Update(s.State, s.SimTime, s.lg)
 			if passedWaypoint != nil {
 				if passedWaypoint.Handoff {
 					// Handoff from virtual controller to a human controller.
@@ -1559,7 +1609,7 @@ func (s *Sim) updateState() {
 					// Update controller before calling GoAround so the
 					// transmission goes to the right controller.
 					ac.ControllingController = s.State.DepartureController(ac, s.lg)
-					rt := ac.GoAround()
+					rt := ac.GoAround(s.rand)
 					PostRadioEvents(ac.Callsign, rt, s)
 
 					// If it was handed off to tower, hand it back to us
@@ -1706,7 +1756,7 @@ func (s *Sim) setInitialSpawnTimes() {
 			return time.Now().Add(365 * 24 * time.Hour)
 		}
 		avgWait := int(3600 / rate)
-		delta := rand.Intn(avgWait) - avgWait/2 - initialSimSeconds
+		delta := s.rand.Intn(avgWait) - avgWait/2 - initialSimSeconds
 		return time.Now().Add(time.Duration(delta) * time.Second)
 	}
 
@@ -1749,33 +1799,36 @@ func sumRateMap2(rates map[string]map[string]float32, scale float32) float32 {
 
 // sampleRateMap randomly samples elements from a map of some type T to a
 // rate with probability proportional to the element's rate.
-func sampleRateMap[T comparable](rates map[T]float32, scale float32) (T, float32) {
+func sampleRateMap[T cmp.Ordered](rates map[T]float32, scale float32, r *rand.Rand) (T, float32) {
 	var rateSum float32
 	var result T
-	for item, rate := range rates {
-		rate = scaleRate(rate, scale)
+	// Visit the items in a fixed order so that the same random numbers
+	// give the same result; replays depend on this.
+	for _, item := range util.SortedMapKeys(rates) {
+		rate := scaleRate(rates[item], scale)
 		rateSum += rate
 		// Weighted reservoir sampling...
-		if rateSum == 0 || rand.Float32() < rate/rateSum {
+		if rateSum == 0 || r.Float32() < rate/rateSum {
 			result = item
 		}
 	}
 	return result, rateSum
 }
 
-func sampleRateMap2(rates map[string]map[string]float32, scale float32) (string, string, float32) {
+func sampleRateMap2(rates map[string]map[string]float32, scale float32, r *rand.Rand) (string, string, float32) {
 	// Choose randomly in proportion to the rates in the map
 	var rateSum float32
 	var result0, result1 string
-	for item0, rateMap := range rates {
-		for item1, rate := range rateMap {
-			rate = scaleRate(rate, scale)
+	for _, item0 := range util.SortedMapKeys(rates) {
+		rateMap := rates[item0]
+		for _, item1 := range util.SortedMapKeys(rateMap) {
+			rate := scaleRate(rateMap[item1], scale)
 			if rate == 0 {
 				continue
 			}
 			rateSum += rate
 			// Weighted reservoir sampling...
-			if rand.Float32() < rate/rateSum {
+			if r.Float32() < rate/rateSum {
 				result0 = item0
 				result1 = item1
 			}
@@ -1784,7 +1837,7 @@ func sampleRateMap2(rates map[string]map[string]float32, scale float32) (string,
 	return result0, result1, rateSum
 }
 
-func randomWait(rate float32, pushActive bool) time.Duration {
+func randomWait(rate float32, pushActive bool, r *rand.Rand) time.Duration {
 	if rate == 0 {
 		return 365 * 24 * time.Hour
 	}
@@ -1793,7 +1846,7 @@ func randomWait(rate float32, pushActive bool) time.Duration {
 	}
 
 	avgSeconds := 3600 / rate
-	seconds := math.Lerp(rand.Float32(), .85*avgSeconds, 1.15*avgSeconds)
+	seconds := math.Lerp(r.Float32(), .85*avgSeconds, 1.15*avgSeconds)
 	return time.Duration(seconds * float32(time.Second))
 }
 
@@ -1813,7 +1866,7 @@ func (s *Sim) spawnArrivalsAndOverflights() {
 	}
 	if !s.PushEnd.IsZero() && now.After(s.PushEnd) {
 		// end push
-		m := -2 + rand.Intn(4) + s.LaunchConfig.ArrivalPushFrequencyMinutes
+		m := -2 + s.rand.Intn(4) + s.LaunchConfig.ArrivalPushFrequencyMinutes
 		s.NextPushStart = now.Add(time.Duration(m) * time.Minute)
 		s.lg.Info("arrival push ending", slog.Time("next_start", s.NextPushStart))
 		s.PushEnd = time.Time{}
@@ -1821,9 +1874,10 @@ func (s *Sim) spawnArrivalsAndOverflights() {
 
 	pushActive := now.Before(s.PushEnd)
 
-	for group, rates := range s.LaunchConfig.InboundFlowRates {
+	for _, group := range util.SortedMapKeys(s.LaunchConfig.InboundFlowRates) {
+		rates := s.LaunchConfig.InboundFlowRates[group]
 		if now.After(s.NextInboundSpawn[group]) {
-			flow, rateSum := sampleRateMap(rates, s.LaunchConfig.InboundFlowRateScale)
+			flow, rateSum := sampleRateMap(rates, s.LaunchConfig.InboundFlowRateScale, s.rand)
 
 			var ac *av.Aircraft
 			var err error
@@ -1837,7 +1891,7 @@ func (s *Sim) spawnArrivalsAndOverflights() {
 				s.lg.Errorf("create inbound error: %v", err)
 			} else if ac != nil {
 				s.addAircraftNoLock(*ac)
-				s.NextInboundSpawn[group] = now.Add(randomWait(rateSum, pushActive))
+				s.NextInboundSpawn[group] = now.Add(randomWait(rateSum, pushActive, s.rand))
 			}
 		}
 	}
@@ -1849,8 +1903,8 @@ func (s *Sim) spawnDepartures() {
 	// Make sure we have a few departing aircraft to work with.
 	s.refreshDeparturePool()
 
-	for airport, launchTime := range s.NextDepartureLaunch {
-		if !now.After(launchTime) {
+	for _, airport := range util.SortedMapKeys(s.NextDepartureLaunch) {
+		if !now.After(s.NextDepartureLaunch[airport]) {
 			// Don't bother going any further: wait to match the desired
 			// overall launch rate.
 			continue
@@ -1887,7 +1941,7 @@ func (s *Sim) spawnDepartures() {
 
 		// And figure out when we want to ask for the next departure.
 		r := sumRateMap2(s.LaunchConfig.DepartureRates[airport], s.LaunchConfig.DepartureRateScale)
-		s.NextDepartureLaunch[airport] = now.Add(randomWait(r, false))
+		s.NextDepartureLaunch[airport] = now.Add(randomWait(r, false, s.rand))
 	}
 }
 
@@ -1939,7 +1993,8 @@ func (s *Sim) launchInterval(prev, cur DepartureAircraft) time.Duration {
 
 func (s *Sim) refreshDeparturePool() {
 loop:
-	for airport, rates := range s.LaunchConfig.DepartureRates {
+	for _, airport := range util.SortedMapKeys(s.LaunchConfig.DepartureRates) {
+		rates := s.LaunchConfig.DepartureRates[airport]
 		pool := s.DeparturePool[airport]
 		// Keep a pool of 2-5 around.
 		if len(pool) >= 2 {
@@ -1948,7 +2003,7 @@ loop:
 
 		for len(pool) < 5 {
 			// Figure out which category to generate.
-			runway, category, rateSum := sampleRateMap2(rates, s.LaunchConfig.DepartureRateScale)
+			runway, category, rateSum := sampleRateMap2(rates, s.LaunchConfig.DepartureRateScale, s.rand)
 			if rateSum == 0 {
 				// The airport currently has a 0 departure rate.
 				continue loop
@@ -1960,7 +2015,7 @@ loop:
 				s.addAircraftNoLock(*ac)
 
 				pool = append(pool, makeDepartureAircraft(ac, runway, s.DepartureIndex[airport],
-					s.State, s.lg))
+					s.State, s.SimTime, s.lg))
 				s.DepartureIndex[airport]++
 			}
 		}
@@ -1971,7 +2026,8 @@ loop:
 	}
 }
 
-func makeDepartureAircraft(ac *av.Aircraft, runway string, idx int, wind av.WindModel, lg *log.Logger) DepartureAircraft {
+func makeDepartureAircraft(ac *av.Aircraft, runway string, idx int, wind av.WindModel, simTime time.Time,
+	lg *log.Logger) DepartureAircraft {
 	d := DepartureAircraft{
 		Callsign: ac.Callsign,
 		Runway:   runway,
@@ -1984,7 +2040,7 @@ func makeDepartureAircraft(ac *av.Aircraft, runway string, idx int, wind av.Wind
 	start := ac.Position()
 	d.MinSeparation = 120 * time.Second // just in case
 	for i := range 120 {
-		simAc.Update(wind, lg)
+		simAc.Update(wind, simTime.Add(time.Duration(i)*time.Second), lg)
 		// We need 6,000' and airborne, but we'll add a bit of slop
 		if simAc.IsAirborne() && math.NMDistance2LL(start, simAc.Position()) > 7500*math.FeetToNauticalMiles {
 			d.MinSeparation = time.Duration(i) * time.Second
@@ -2060,6 +2116,32 @@ func (s *Sim) SetSimRate(token string, rate float32) error {
 	}
 }
 
+// AdvanceTime runs the simulation forward by the given amount of time as
+// quickly as possible, regardless of whether it is paused; it is used to
+// step and seek when replaying a recorded session. It returns
+// immediately and the time is run in subsequent calls to Update, so
+// seeking a long way doesn't hold the lock or the caller's RPC. In
+// multi-controller sims, only instructors may do so.
+func (s *Sim) AdvanceTime(token string, d time.Duration) error {
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	if ctrl, ok := s.controllers[token]; !ok {
+		return ErrInvalidControllerToken
+	} else if !s.privilegedTCW(ctrl.Id) {
+		return ErrNotInstructor
+	}
+
+	ns := int(d.Truncate(time.Second).Seconds())
+	if ns <= 0 {
+		return ErrInvalidDuration
+	}
+	s.lg.Infof("advancing sim by %d seconds", ns)
+	s.advanceRemaining += ns
+
+	return nil
+}
+
 func (s *Sim) SetLaunchConfig(token string, lc LaunchConfig) error {
 	s.mu.Lock(s.lg)
 	defer s.mu.Unlock(s.lg)
@@ -2081,7 +2163,7 @@ func (s *Sim) SetLaunchConfig(token string, lc LaunchConfig) error {
 
 			if newSum != oldSum {
 				s.lg.Infof("%s: departure rate changed %f -> %f", ap, oldSum, newSum)
-				s.NextDepartureLaunch[ap] = s.SimTime.Add(randomWait(newSum, false))
+				s.NextDepartureLaunch[ap] = s.SimTime.Add(randomWait(newSum, false, s.rand))
 			}
 		}
 		for group, groupRates := range lc.InboundFlowRates {
@@ -2096,7 +2178,7 @@ func (s *Sim) SetLaunchConfig(token string, lc LaunchConfig) error {
 			if newSum != oldSum {
 				pushActive := s.SimTime.Before(s.PushEnd)
 				s.lg.Infof("%s: inbound flow rate changed %f -> %f", group, oldSum, newSum)
-				s.NextInboundSpawn[group] = s.SimTime.Add(randomWait(newSum, pushActive))
+				s.NextInboundSpawn[group] = s.SimTime.Add(randomWait(newSum, pushActive, s.rand))
 			}
 		}
 
@@ -2527,7 +2609,7 @@ func (s *Sim) HandoffTrack(token, callsign, controller string) error {
 			// Add them to the auto-accept map even if the target is
 			// covered; this way, if they sign off in the interim, we still
 			// end up accepting it automatically.
-			acceptDelay := 4 + rand.Intn(10)
+			acceptDelay := 4 + s.rand.Intn(10)
 			s.Handoffs[ac.Callsign] = Handoff{
 				Time: s.SimTime.Add(time.Duration(acceptDelay) * time.Second),
 			}
@@ -2559,8 +2641,8 @@ func (s *Sim) HandoffControl(token, callsign string) error {
 					})
 					return radioTransmissions
 				}
-				bye := rand.Sample("good day", "seeya")
-				contact := rand.Sample("contact ", "over to ", "")
+				bye := rand.Sample(s.rand, "good day", "seeya")
+				contact := rand.Sample(s.rand, "contact ", "over to ", "")
 				goodbye := contact + octrl.RadioName + " on " + octrl.Frequency.String() + ", " + bye
 				radioTransmissions = append(radioTransmissions, av.RadioTransmission{
 					Controller: ac.ControllingController,
@@ -2791,7 +2873,7 @@ func (s *Sim) pointOut(callsign string, from *av.Controller, to *av.Controller)
 		//s.lg.Errorf("PointOut: %v", err)
 	}
 
-	acceptDelay := 4 + rand.Intn(10)
+	acceptDelay := 4 + s.rand.Intn(10)
 	if s.PointOuts[callsign] == nil {
 		s.PointOuts[callsign] = make(map[string]PointOut)
 	}
@@ -2912,7 +2994,7 @@ func (s *Sim) AssignAltitude(token, callsign string, altitude int, afterSpeed bo
 
 	return s.dispatchControllingCommand(token, callsign,
 		func(ctrl *av.Controller, ac *av.Aircraft) []av.RadioTransmission {
-			return ac.AssignAltitude(altitude, afterSpeed)
+			return ac.AssignAltitude(altitude, afterSpeed, s.rand)
 		})
 }
 
@@ -2944,13 +3026,13 @@ func (s *Sim) AssignHeading(hdg *HeadingArgs) error {
 	return s.dispatchControllingCommand(hdg.ControllerToken, hdg.Callsign,
 		func(ctrl *av.Controller, ac *av.Aircraft) []av.RadioTransmission {
 			if hdg.Present {
-				return ac.FlyPresentHeading()
+				return ac.FlyPresentHeading(s.SimTime, s.rand)
 			} else if hdg.LeftDegrees != 0 {
-				return ac.TurnLeft(hdg.LeftDegrees)
+				return ac.TurnLeft(hdg.LeftDegrees, s.SimTime, s.rand)
 			} else if hdg.RightDegrees != 0 {
-				return ac.TurnRight(hdg.RightDegrees)
+				return ac.TurnRight(hdg.RightDegrees, s.SimTime, s.rand)
 			} else {
-				return ac.AssignHeading(hdg.Heading, hdg.Turn)
+				return ac.AssignHeading(hdg.Heading, hdg.Turn, s.SimTime, s.rand)
 			}
 		})
 }
@@ -2961,7 +3043,7 @@ func (s *Sim) AssignSpeed(token, callsign string, speed int, afterAltitude bool)
 
 	return s.dispatchControllingCommand(token, callsign,
 		func(ctrl *av.Controller, ac *av.Aircraft) []av.RadioTransmission {
-			return ac.AssignSpeed(speed, afterAltitude)
+			return ac.AssignSpeed(speed, afterAltitude, s.rand)
 		})
 }
 
@@ -2971,7 +3053,7 @@ func (s *Sim) MaintainSlowestPractical(token, callsign string) error {
 
 	return s.dispatchControllingCommand(token, callsign,
 		func(ctrl *av.Controller, ac *av.Aircraft) []av.RadioTransmission {
-			return ac.MaintainSlowestPractical()
+			return ac.MaintainSlowestPractical(s.rand)
 		})
 }
 
@@ -2981,7 +3063,7 @@ func (s *Sim) MaintainMaximumForward(token, callsign string) error {
 
 	return s.dispatchControllingCommand(token, callsign,
 		func(ctrl *av.Controller, ac *av.Aircraft) []av.RadioTransmission {
-			return ac.MaintainMaximumForward()
+			return ac.MaintainMaximumForward(s.rand)
 		})
 }
 
@@ -2991,7 +3073,7 @@ func (s *Sim) SaySpeed(token, callsign string) error {
 
 	return s.dispatchControllingCommand(token, callsign,
 		func(ctrl *av.Controller, ac *av.Aircraft) []av.RadioTransmission {
-			return ac.SaySpeed()
+			return ac.SaySpeed(s.rand)
 		})
 }
 
@@ -3001,7 +3083,7 @@ func (s *Sim) SayAltitude(token, callsign string) error {
 
 	return s.dispatchControllingCommand(token, callsign,
 		func(ctrl *av.Controller, ac *av.Aircraft) []av.RadioTransmission {
-			return ac.SayAltitude()
+			return ac.SayAltitude(s.rand)
 		})
 }
 
@@ -3021,7 +3103,7 @@ func (s *Sim) ExpediteDescent(token, callsign string) error {
 
 	return s.dispatchControllingCommand(token, callsign,
 		func(ctrl *av.Controller, ac *av.Aircraft) []av.RadioTransmission {
-			return ac.ExpediteDescent()
+			return ac.ExpediteDescent(s.rand)
 		})
 }
 
@@ -3031,7 +3113,7 @@ func (s *Sim) ExpediteClimb(token, callsign string) error {
 
 	return s.dispatchControllingCommand(token, callsign,
 		func(ctrl *av.Controller, ac *av.Aircraft) []av.RadioTransmission {
-			return ac.ExpediteClimb()
+			return ac.ExpediteClimb(s.rand)
 		})
 }
 
@@ -3041,7 +3123,45 @@ func (s *Sim) DirectFix(token, callsign, fix string) error {
 
 	return s.dispatchControllingCommand(token, callsign,
 		func(ctrl *av.Controller, ac *av.Aircraft) []av.RadioTransmission {
-			return ac.DirectFix(fix)
+			return ac.DirectFix(fix, s.SimTime, s.rand)
+		})
+}
+
+// RepositionAircraft moves an aircraft to a fix at the given altitude
+// (negative to keep its current altitude) and heading (zero to keep its
+// current heading); only instructors may do so.
+func (s *Sim) RepositionAircraft(token, callsign, fix string, alt, hdg int) error {
+	s.mu.Lock(s.lg)
+	defer s.mu.Unlock(s.lg)
+
+	fix = strings.ToUpper(fix)
+	p, ok := s.State.Locate(fix)
+	if !ok {
+		return ErrLocationNotFound
+	}
+	if hdg < 0 || hdg > 360 {
+		return av.ErrInvalidHeading
+	}
+
+	return s.dispatchCommand(token, callsign,
+		func(ctrl *av.Controller, ac *av.Aircraft) error {
+			if !s.privilegedTCW(ctrl.Id()) {
+				return ErrNotInstructor
+			}
+			if float32(alt) > ac.Nav.Perf.Ceiling {
+				return av.ErrInvalidAltitude
+			}
+			return nil
+		},
+		func(ctrl *av.Controller, ac *av.Aircraft) []av.RadioTransmission {
+			if alt < 0 {
+				alt = int(ac.Altitude())
+			}
+			s.lg.Info("reposition_aircraft", slog.String("callsign", ac.Callsign),
+				slog.String("controller", ctrl.Id()), slog.String("fix", fix),
+				slog.Int("altitude", alt), slog.Int("heading", hdg))
+			ac.Nav.Reposition(fix, p, float32(alt), float32(hdg))
+			return nil
 		})
 }
 
@@ -3081,7 +3201,7 @@ func (s *Sim) AtFixCleared(token, callsign, fix, approach string) error {
 
 	return s.dispatchControllingCommand(token, callsign,
 		func(ctrl *av.Controller, ac *av.Aircraft) []av.RadioTransmission {
-			return ac.AtFixCleared(fix, approach)
+			return ac.AtFixCleared(fix, approach, s.rand)
 		})
 }
 
@@ -3099,7 +3219,7 @@ func (s *Sim) ExpectApproach(token, callsign, approach string) error {
 
 	return s.dispatchControllingCommand(token, callsign,
 		func(ctrl *av.Controller, ac *av.Aircraft) []av.RadioTransmission {
-			return ac.ExpectApproach(approach, ap, s.lg)
+			return ac.ExpectApproach(approach, ap, s.rand, s.lg)
 		})
 }
 
@@ -3123,7 +3243,7 @@ func (s *Sim) InterceptLocalizer(token, callsign string) error {
 
 	return s.dispatchControllingCommand(token, callsign,
 		func(ctrl *av.Controller, ac *av.Aircraft) []av.RadioTransmission {
-			return ac.InterceptLocalizer()
+			return ac.InterceptLocalizer(s.rand)
 		})
 }
 
@@ -3143,7 +3263,7 @@ func (s *Sim) ClimbViaSID(token, callsign string) error {
 
 	return s.dispatchControllingCommand(token, callsign,
 		func(ctrl *av.Controller, ac *av.Aircraft) []av.RadioTransmission {
-			return ac.ClimbViaSID()
+			return ac.ClimbViaSID(s.SimTime, s.rand)
 		})
 }
 
@@ -3153,7 +3273,7 @@ func (s *Sim) DescendViaSTAR(token, callsign string) error {
 
 	return s.dispatchControllingCommand(token, callsign,
 		func(ctrl *av.Controller, ac *av.Aircraft) []av.RadioTransmission {
-			return ac.DescendViaSTAR()
+			return ac.DescendViaSTAR(s.SimTime, s.rand)
 		})
 }
 
@@ -3163,7 +3283,7 @@ func (s *Sim) GoAround(token, callsign string) error {
 
 	return s.dispatchControllingCommand(token, callsign,
 		func(ctrl *av.Controller, ac *av.Aircraft) []av.RadioTransmission {
-			resp := ac.GoAround()
+			resp := ac.GoAround(s.rand)
 			for i := range resp {
 				resp[i].Type = av.RadioTransmissionUnexpected
 			}
@@ -3252,6 +3372,15 @@ func uiEndDisable(b bool) {
 	}
 }
 
+// uiDisabledTooltip shows the given explanation of why the previous item
+// is disabled when the mouse is over it. Nothing is shown if the
+// explanation is empty.
+func uiDisabledTooltip(why string) {
+	if why != "" && imgui.IsItemHoveredV(imgui.HoveredFlagsAllowWhenDisabled) {
+		imgui.SetTooltip(why)
+	}
+}
+
 var badCallsigns map[string]interface{} = map[string]interface{}{
 	// 9/11
 	"AAL11":  nil,
@@ -3291,7 +3420,7 @@ var badCallsigns map[string]interface{} = map[string]interface{}{
 	"ICE001":  nil,
 }
 
-func (ss *State) sampleAircraft(al av.AirlineSpecifier, lg *log.Logger) (*av.Aircraft, string) {
+func (ss *State) sampleAircraft(al av.AirlineSpecifier, r *rand.Rand, lg *log.Logger) (*av.Aircraft, string) {
 	dbAirline, ok := av.DB.Airlines[al.ICAO]
 	if !ok {
 		// TODO: this should be caught at load validation time...
@@ -3305,7 +3434,7 @@ func (ss *State) sampleAircraft(al av.AirlineSpecifier, lg *log.Logger) (*av.Air
 	for _, ac := range al.Aircraft() {
 		// Reservoir sampling...
 		acCount += ac.Count
-		if rand.Float32() < float32(ac.Count)/float32(acCount) {
+		if r.Float32() < float32(ac.Count)/float32(acCount) {
 			aircraft = ac.ICAO
 		}
 	}
@@ -3323,7 +3452,7 @@ func (ss *State) sampleAircraft(al av.AirlineSpecifier, lg *log.Logger) (*av.Air
 	for {
 		format := "####"
 		if len(dbAirline.Callsign.CallsignFormats) > 0 {
-			idx := rand.SampleWeighted(dbAirline.Callsign.CallsignFormats,
+			idx := rand.SampleWeighted(r, dbAirline.Callsign.CallsignFormats,
 				func(f string) int {
 					if _, wt, ok := strings.Cut(f, "x"); ok { // we have a weight
 						if v, err := strconv.Atoi(wt); err == nil {
@@ -3344,12 +3473,12 @@ func (ss *State) sampleAircraft(al av.AirlineSpecifier, lg *log.Logger) (*av.Air
 			case '#':
 				if i == 0 {
 					// Don't start with a 0.
-					id += strconv.Itoa(1 + rand.Intn(9))
+					id += strconv.Itoa(1 + r.Intn(9))
 				} else {
-					id += strconv.Itoa(rand.Intn(10))
+					id += strconv.Itoa(r.Intn(10))
 				}
 			case '@':
-				id += string(rune('A' + rand.Intn(26)))
+				id += string(rune('A' + r.Intn(26)))
 			case 'x':
 				break loop
 			}
@@ -3364,7 +3493,7 @@ func (ss *State) sampleAircraft(al av.AirlineSpecifier, lg *log.Logger) (*av.Air
 		}
 	}
 
-	squawk := av.Squawk(rand.Intn(0o7000))
+	squawk := av.Squawk(r.Intn(0o7000))
 
 	acType := aircraft
 	if perf.WeightClass == "H" {
@@ -3388,11 +3517,11 @@ func (s *Sim) CreateArrival(arrivalGroup string, arrivalAirport string) (*av.Air
 }
 
 func (s *Sim) createArrivalNoLock(group string, arrivalAirport string) (*av.Aircraft, error) {
-	goAround := rand.Float32() < s.LaunchConfig.GoAroundRate
+	goAround := s.rand.Float32() < s.LaunchConfig.GoAroundRate
 
 	arrivals := s.State.InboundFlows[group].Arrivals
 	// Randomly sample from the arrivals that have a route to this airport.
-	idx := rand.SampleFiltered(arrivals, func(ar av.Arrival) bool {
+	idx := rand.SampleFiltered(s.rand, arrivals, func(ar av.Arrival) bool {
 		_, ok := ar.Airlines[arrivalAirport]
 		return ok
 	})
@@ -3403,8 +3532,8 @@ func (s *Sim) createArrivalNoLock(group string, arrivalAirport string) (*av.Airc
 	}
 	arr := arrivals[idx]
 
-	airline := rand.SampleSlice(arr.Airlines[arrivalAirport])
-	ac, acType := s.State.sampleAircraft(airline.AirlineSpecifier, s.lg)
+	airline := rand.SampleSlice(s.rand, arr.Airlines[arrivalAirport])
+	ac, acType := s.State.sampleAircraft(airline.AirlineSpecifier, s.rand, s.lg)
 	if ac == nil {
 		return nil, fmt.Errorf("unable to sample a valid aircraft")
 	}
@@ -3433,7 +3562,7 @@ func (s *Sim) createArrivalNoLock(group string, arrivalAirport string) (*av.Airc
 	}
 
 	if err := ac.InitializeArrival(s.State.Airports[arrivalAirport], &arr, arrivalController,
-		goAround, s.State.NmPerLongitude, s.State.MagneticVariation, s.lg); err != nil {
+		goAround, s.State.NmPerLongitude, s.State.MagneticVariation, s.rand, s.lg); err != nil {
 		return nil, err
 	}
 
@@ -3441,7 +3570,7 @@ func (s *Sim) createArrivalNoLock(group string, arrivalAirport string) (*av.Airc
 	if !ok {
 		return nil, ErrUnknownControllerFacility
 	}
-	s.State.ERAMComputers.AddArrival(ac, facility, s.State.STARSFacilityAdaptation, s.SimTime)
+	s.State.ERAMComputers.AddArrival(ac, facility, s.State.STARSFacilityAdaptation, s.SimTime, s.rand)
 
 	return ac, nil
 }
@@ -3468,7 +3597,7 @@ func (s *Sim) createDepartureNoLock(departureAirport, runway, category string) (
 	rwy := &s.State.DepartureRunways[idx]
 
 	// Sample uniformly, minding the category, if specified
-	idx = rand.SampleFiltered(ap.Departures,
+	idx = rand.SampleFiltered(s.rand, ap.Departures,
 		func(d av.Departure) bool {
 			_, ok := rwy.ExitRoutes[d.Exit] // make sure the runway handles the exit
 			return ok && (rwy.Category == "" || rwy.Category == ap.ExitCategories[d.Exit])
@@ -3480,8 +3609,8 @@ func (s *Sim) createDepartureNoLock(departureAirport, runway, category string) (
 	}
 	dep := &ap.Departures[idx]
 
-	airline := rand.SampleSlice(dep.Airlines)
-	ac, acType := s.State.sampleAircraft(airline.AirlineSpecifier, s.lg)
+	airline := rand.SampleSlice(s.rand, dep.Airlines)
+	ac, acType := s.State.sampleAircraft(airline.AirlineSpecifier, s.rand, s.lg)
 	if ac == nil {
 		return nil, fmt.Errorf("unable to sample a valid aircraft")
 	}
@@ -3490,7 +3619,7 @@ func (s *Sim) createDepartureNoLock(departureAirport, runway, category string) (
 	exitRoute := rwy.ExitRoutes[dep.Exit]
 	if err := ac.InitializeDeparture(ap, departureAirport, dep, runway, *exitRoute,
 		s.State.NmPerLongitude, s.State.MagneticVariation, s.State.Scratchpads,
-		s.State.PrimaryController, s.State.MultiControllers, s.lg); err != nil {
+		s.State.PrimaryController, s.State.MultiControllers, s.rand, s.lg); err != nil {
 		return nil, err
 	}
 
@@ -3509,10 +3638,10 @@ func (s *Sim) CreateOverflight(group string) (*av.Aircraft, error) {
 func (s *Sim) createOverflightNoLock(group string) (*av.Aircraft, error) {
 	overflights := s.State.InboundFlows[group].Overflights
 	// Randomly sample an overflight
-	of := rand.SampleSlice(overflights)
+	of := rand.SampleSlice(s.rand, overflights)
 
-	airline := rand.SampleSlice(of.Airlines)
-	ac, acType := s.State.sampleAircraft(airline.AirlineSpecifier, s.lg)
+	airline := rand.SampleSlice(s.rand, of.Airlines)
+	ac, acType := s.State.sampleAircraft(airline.AirlineSpecifier, s.rand, s.lg)
 	if ac == nil {
 		return nil, fmt.Errorf("unable to sample a valid aircraft")
 	}
@@ -3539,7 +3668,8 @@ func (s *Sim) createOverflightNoLock(group string) (*av.Aircraft, error) {
 		}
 	}
 
-	if err := ac.InitializeOverflight(&of, controller, s.State.NmPerLongitude, s.State.MagneticVariation, s.lg); err != nil {
+	if err := ac.InitializeOverflight(&of, controller, s.State.NmPerLongitude, s.State.MagneticVariation,
+		s.rand, s.lg); err != nil {
 		return nil, err
 	}
 
@@ -3549,7 +3679,7 @@ func (s *Sim) createOverflightNoLock(group string) (*av.Aircraft, error) {
 			if !ok {
 				return nil, ErrUnknownControllerFacility
 			}
-		    s.State.ERAMComputers.AddArrival(ac, facility, s.State.STARSFacilityAdaptation, s.SimTime)
+		    s.State.ERAMComputers.AddArrival(ac, facility, s.State.STARSFacilityAdaptation, s.SimTime, s.rand)
 	*/
 
 	return ac, nil
@@ -3620,7 +3750,7 @@ type FutureControllerContact struct {
 }
 
 func (s *Sim) enqueueControllerContact(callsign, tcp string) {
-	wait := time.Duration(5+rand.Intn(10)) * time.Second
+	wait := time.Duration(5+s.rand.Intn(10)) * time.Second
 	s.FutureControllerContacts = append(s.FutureControllerContacts,
 		FutureControllerContact{Callsign: callsign, TCP: tcp, Time: s.SimTime.Add(wait)})
 }
@@ -3631,7 +3761,7 @@ type FutureOnCourse struct {
 }
 
 func (s *Sim) enqueueDepartOnCourse(callsign string) {
-	wait := time.Duration(10+rand.Intn(15)) * time.Second
+	wait := time.Duration(10+s.rand.Intn(15)) * time.Second
 	s.FutureOnCourse = append(s.FutureOnCourse,
 		FutureOnCourse{Callsign: callsign, Time: s.SimTime.Add(wait)})
 }
@@ -3668,7 +3798,7 @@ func (s *Sim) processEnqueued() {
 				if ac, ok := s.State.Aircraft[oc.Callsign]; ok {
 					s.lg.Info("departing on course", slog.String("callsign", ac.Callsign),
 						slog.Int("final_altitude", ac.FlightPlan.Altitude))
-					ac.DepartOnCourse(s.lg)
+					ac.DepartOnCourse(s.SimTime, s.rand, s.lg)
 				}
 				return false
 			}
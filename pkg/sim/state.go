@@ -16,7 +16,6 @@ import (
 	av "github.com/mmp/vice/pkg/aviation"
 	"github.com/mmp/vice/pkg/log"
 	"github.com/mmp/vice/pkg/math"
-	"github.com/mmp/vice/pkg/rand"
 	"github.com/mmp/vice/pkg/util"
 
 	"github.com/brunoga/deep"
@@ -65,6 +64,7 @@ type State struct {
 	STARSFacilityAdaptation  STARSFacilityAdaptation
 	UserRestrictionAreas     []RestrictionArea
 	Instructors              map[string]bool
+	FailedRadarSites         map[string]bool // set by instructors for training
 
 	ControllerVideoMaps        []string
 	ControllerDefaultVideoMaps []string
@@ -170,10 +170,10 @@ func newState(selectedSplit string, liveWeather bool, isLocal bool, s *Sim, sg *
 	}
 
 	// Make some fake METARs; slightly different for all airports.
-	alt := 2980 + rand.Intn(40)
+	alt := 2980 + s.rand.Intn(40)
 
 	fakeMETAR := func(icao string) {
-		spd := ss.Wind.Speed - 3 + rand.Int31n(6)
+		spd := ss.Wind.Speed - 3 + s.rand.Int31n(6)
 		var wind string
 		if spd < 0 {
 			wind = "00000KT"
@@ -181,9 +181,9 @@ func newState(selectedSplit string, liveWeather bool, isLocal bool, s *Sim, sg *
 			wind = fmt.Sprintf("VRB%02dKT", spd)
 		} else {
 			dir := 10 * ((ss.Wind.Direction + 5) / 10)
-			dir += [3]int32{-10, 0, 10}[rand.Intn(3)]
+			dir += [3]int32{-10, 0, 10}[s.rand.Intn(3)]
 			wind = fmt.Sprintf("%03d%02d", dir, spd)
-			gst := ss.Wind.Gust - 3 + rand.Int31n(6)
+			gst := ss.Wind.Gust - 3 + s.rand.Int31n(6)
 			if gst-ss.Wind.Speed > 5 {
 				wind += fmt.Sprintf("G%02d", gst)
 			}
@@ -194,22 +194,25 @@ func newState(selectedSplit string, liveWeather bool, isLocal bool, s *Sim, sg *
 		ss.METAR[icao] = &av.METAR{
 			AirportICAO: icao,
 			Wind:        wind,
-			Altimeter:   fmt.Sprintf("A%d", alt-2+rand.Intn(4)),
+			Altimeter:   fmt.Sprintf("A%d", alt-2+s.rand.Intn(4)),
 		}
 	}
 
 	realMETAR := func(icao []string) {
-		metar, err := getWeather(icao...)
+		// Use the cache so that the weather matches what was shown when
+		// the sim was configured.
+		metar, err := WeatherCache.Fetch(icao...)
 		if err != nil {
 			lg.Errorf("%s: error getting weather: %+v", strings.Join(icao, ", "), err)
 		}
 
-		for i := range metar {
+		for ap, m := range metar {
 			// Just provide the stuff that the STARS display shows
-			ss.METAR[metar[i].IcaoId] = &av.METAR{
-				AirportICAO: metar[i].IcaoId,
-				Wind:        metar[i].getWindInfo(),
-				Altimeter:   fmt.Sprintf("A%d", int(metar[i].getAltimeter()*100)),
+			ss.METAR[ap] = &av.METAR{
+				AirportICAO: ap,
+				Wind:        windText(m.Wind),
+				Altimeter:   altimeterText(m),
+				Raw:         m.Raw,
 			}
 		}
 	}
@@ -294,6 +297,64 @@ func (ss *State) Locate(s string) (math.Point2LL, bool) {
 	return math.Point2LL{}, false
 }
 
+// LocateExtended resolves s, which may be a fix, airport, navaid,
+// airport/runway, or latitude-longitude (e.g., 4012N07345W), returning
+// the point and a label suitable for display. ErrLocationAmbiguous is
+// returned if s names more than one place in the aviation database and
+// the scenario doesn't define it.
+func (ss *State) LocateExtended(s string) (math.Point2LL, string, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	withName := func(name string) string {
+		return strings.TrimSpace(s + " " + strings.ToUpper(name))
+	}
+
+	// Scenario definitions take precedence and are never ambiguous.
+	if ap, ok := ss.Airports[s]; ok {
+		if dbap, ok := av.DB.Airports[s]; ok {
+			return ap.Location, withName(dbap.Name), nil
+		}
+		return ap.Location, s, nil
+	} else if p, ok := ss.Fixes[s]; ok {
+		return p, s, nil
+	}
+
+	type match struct {
+		p     math.Point2LL
+		label string
+	}
+	var matches []match
+	if n, ok := av.DB.Navaids[s]; ok {
+		matches = append(matches, match{n.Location, withName(n.Name)})
+	}
+	if ap, ok := av.DB.Airports[s]; ok {
+		matches = append(matches, match{ap.Location, withName(ap.Name)})
+	}
+	if f, ok := av.DB.Fixes[s]; ok {
+		matches = append(matches, match{f.Location, s})
+	}
+	if len(matches) > 0 {
+		// An airport and a navaid on the field with the same identifier
+		// aren't ambiguous, so allow a little slop.
+		for _, m := range matches[1:] {
+			if math.NMDistance2LL(m.p, matches[0].p) > 2 {
+				return math.Point2LL{}, "", ErrLocationAmbiguous
+			}
+		}
+		return matches[0].p, matches[0].label, nil
+	}
+
+	if p, err := math.ParseLatLong([]byte(s)); err == nil {
+		return p, s, nil
+	} else if ap, rwy, ok := strings.Cut(s, "/"); ok {
+		if dbap, ok := av.DB.Airports[ap]; ok {
+			if idx := slices.IndexFunc(dbap.Runways, func(r av.Runway) bool { return r.Id == rwy }); idx != -1 {
+				return dbap.Runways[idx].Threshold, s, nil
+			}
+		}
+	}
+	return math.Point2LL{}, "", ErrLocationNotFound
+}
+
 func (ss *State) GetConsolidatedPositions(id string) []string {
 	var cons []string
 
@@ -496,6 +557,12 @@ func (ss *State) ERAMComputer() *ERAMComputer {
 	return eram
 }
 
+// RadarSiteFailed returns true if an instructor has marked the specified
+// radar site as failed.
+func (ss *State) RadarSiteFailed(id string) bool {
+	return ss.FailedRadarSites[id]
+}
+
 func (ss *State) AmInstructor() bool {
 	_, ok := ss.Instructors[ss.PrimaryController]
 	return ok
@@ -0,0 +1,73 @@
+// pkg/sim/state_test.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package sim
+
+import (
+	"errors"
+	"testing"
+
+	av "github.com/mmp/vice/pkg/aviation"
+	"github.com/mmp/vice/pkg/math"
+)
+
+func TestLocateExtended(t *testing.T) {
+	jfk := math.Point2LL{-73.7789, 40.6398}
+	saved := av.DB
+	defer func() { av.DB = saved }()
+	av.DB = &av.StaticDatabase{
+		Airports: map[string]av.FAAAirport{
+			"KJFK": av.FAAAirport{Id: "KJFK", Name: "John F Kennedy Intl", Location: jfk,
+				Runways: []av.Runway{av.Runway{Id: "31L", Threshold: math.Point2LL{-73.7540, 40.6315}}}},
+			"KSFO": av.FAAAirport{Id: "KSFO", Name: "San Francisco Intl", Location: math.Point2LL{-122.375, 37.619}},
+		},
+		Navaids: map[string]av.Navaid{
+			"JFK": av.Navaid{Id: "JFK", Name: "Kennedy", Location: math.Point2LL{-73.7714, 40.6329}},
+			// Same identifier as the fix below, far away
+			"DUP": av.Navaid{Id: "DUP", Name: "Duplicate", Location: math.Point2LL{-100, 35}},
+		},
+		Fixes: map[string]av.Fix{
+			"MERIT": av.Fix{Id: "MERIT", Location: math.Point2LL{-73.3, 41.2}},
+			"DUP":   av.Fix{Id: "DUP", Location: math.Point2LL{-73, 41}},
+			// Same identifier as the airport, at the same place
+			"KSFO": av.Fix{Id: "KSFO", Location: math.Point2LL{-122.375, 37.619}},
+		},
+	}
+
+	ss := &State{
+		Airports: map[string]*av.Airport{"KJFK": &av.Airport{Location: jfk}},
+		Fixes:    map[string]math.Point2LL{"DUP": math.Point2LL{-74, 40}},
+	}
+
+	type testcase struct {
+		s     string
+		p     math.Point2LL
+		label string
+		err   error
+	}
+	for _, tc := range []testcase{
+		{s: "kjfk", p: jfk, label: "KJFK JOHN F KENNEDY INTL"},                            // scenario airport
+		{s: "KSFO", p: math.Point2LL{-122.375, 37.619}, label: "KSFO SAN FRANCISCO INTL"}, // database airport and colocated fix
+		{s: "JFK", p: math.Point2LL{-73.7714, 40.6329}, label: "JFK KENNEDY"},             // navaid
+		{s: "MERIT", p: math.Point2LL{-73.3, 41.2}, label: "MERIT"},                       // fix
+		{s: "DUP", p: math.Point2LL{-74, 40}, label: "DUP"},                               // scenario fix overrides the database
+		{s: "4012N07345W", p: math.Point2LL{-73.75, 40.2}, label: "4012N07345W"},          // latlong
+		{s: "KJFK/31L", p: math.Point2LL{-73.7540, 40.6315}, label: "KJFK/31L"},           // runway
+		{s: "KJFK/4R", err: ErrLocationNotFound},
+		{s: "NOPE", err: ErrLocationNotFound},
+	} {
+		p, label, err := ss.LocateExtended(tc.s)
+		if !errors.Is(err, tc.err) {
+			t.Errorf("%s: got error %v, expected %v", tc.s, err, tc.err)
+		} else if p != tc.p || label != tc.label {
+			t.Errorf("%s: got %v %q, expected %v %q", tc.s, p, label, tc.p, tc.label)
+		}
+	}
+
+	// Without the scenario's definition, DUP is ambiguous.
+	delete(ss.Fixes, "DUP")
+	if _, _, err := ss.LocateExtended("DUP"); !errors.Is(err, ErrLocationAmbiguous) {
+		t.Errorf("DUP: got error %v, expected %v", err, ErrLocationAmbiguous)
+	}
+}
@@ -6,6 +6,10 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
+
+	av "github.com/mmp/vice/pkg/aviation"
+	"github.com/mmp/vice/pkg/wx"
 )
 
 type METAR struct {
@@ -36,7 +40,7 @@ type METAR struct {
 	//Snow       *float64  `json:"snow"` // Snow depth in inches
 	//VertVis    *int  `json:"vertVis"` // Vertical visibility in feet
 	//MetarType  string       `json:"metarType"`
-	//RawMETAR string `json:"rawOb"` // Raw text of observation
+	RawMETAR string `json:"rawOb"` // Raw text of observation
 	//MostRecent int          `json:"mostRecent"`
 	//Lat        float64      `json:"lat"`
 	//Lon        float64      `json:"lon"`
@@ -57,45 +61,109 @@ const vrb = -1
 
 // GetWindDirection returns the wind direction in degrees or VRB for variable winds.
 func (m METAR) GetWindDirection() int {
-	if windDir, ok := m.Wdir.(int); ok {
-		return windDir
+	// JSON numbers are decoded as float64.
+	if windDir, ok := m.Wdir.(float64); ok {
+		return int(windDir)
 	} else {
 		return vrb
 	}
 }
 
-// getWindInfo returns the wind direction and speed in METAR text format.
-func (m METAR) getWindInfo() string {
-	if m.Wspd <= 0 {
-		return "00000KT"
+// getAltimeter returns the altimeter setting in inches Hg
+func (m METAR) getAltimeter() float64 {
+	// Conversion formula (hectoPascal to Inch of Mercury): 29.92 * (hpa / 1013.2)
+	return 0.02953 * m.Altim
+}
+
+// decode returns the decoded report, falling back to the wind and
+// altimeter from the JSON if the raw text can't be parsed.
+func (m METAR) decode() wx.METAR {
+	if d, err := wx.ParseMETAR(m.RawMETAR); err == nil {
+		return d
 	}
+	return wx.METAR{
+		Raw:          m.RawMETAR,
+		Station:      m.IcaoId,
+		Wind:         wx.Wind{Direction: m.GetWindDirection(), Speed: m.Wspd, Gust: m.Wgst},
+		WindReported: true,
+		Altimeter:    float32(m.getAltimeter()),
+	}
+}
 
-	if dir, ok := m.Wdir.(int); !ok {
-		return fmt.Sprintf("VRB%02dKT", m.Wspd)
-	} else {
-		wind := fmt.Sprintf("%03d%02d", dir, m.Wspd)
+// windFromMETAR returns the wind reported in the METAR.
+func windFromMETAR(m wx.METAR) av.Wind {
+	return av.Wind{
+		Direction: int32(m.Wind.Direction),
+		Speed:     int32(m.Wind.Speed),
+		Gust:      int32(m.Wind.Gust),
+	}
+}
+
+// windText returns the wind in METAR text format.
+func windText(w wx.Wind) string {
+	if w.Calm() {
+		return "00000KT"
+	} else if w.Variable() {
+		return fmt.Sprintf("VRB%02dKT", w.Speed)
+	}
 
-		// According to Federal Meteorological Handbook No. 1 (FCM-H1-2019)
-		//   Gusts are indicated by rapid fluctuations in wind speed
-		//   with a variation of 10 knots or more between peaks and lulls.
-		// The Aviation Weather Center reports gust values according to the above or revised definitions.
-		if m.Wgst > 0 {
-			wind += fmt.Sprintf("G%02d", m.Wgst)
-		}
+	wind := fmt.Sprintf("%03d%02d", w.Direction, w.Speed)
 
-		return wind + "KT"
+	// According to Federal Meteorological Handbook No. 1 (FCM-H1-2019)
+	//   Gusts are indicated by rapid fluctuations in wind speed
+	//   with a variation of 10 knots or more between peaks and lulls.
+	// The Aviation Weather Center reports gust values according to the above or revised definitions.
+	if w.Gust > 0 {
+		wind += fmt.Sprintf("G%02d", w.Gust)
 	}
+
+	return wind + "KT"
 }
 
-// getAltimeter returns the altimeter setting in inches Hg
-func (m METAR) getAltimeter() float64 {
-	// Conversion formula (hectoPascal to Inch of Mercury): 29.92 * (hpa / 1013.2)
-	return 0.02953 * m.Altim
+// altimeterText returns the altimeter setting in METAR text format.
+func altimeterText(m wx.METAR) string {
+	return fmt.Sprintf("A%d", int(m.Altimeter*100+0.5))
 }
 
 const aviationWeatherCenterDataApi = `https://aviationweather.gov/api/data/metar?ids=%s&format=json`
 
-func getWeather(icao ...string) ([]METAR, error) {
+// liveWeatherAvailable reports whether live weather can be used for the
+// given airport. If it can't, the returned string explains why.
+func liveWeatherAvailable(airport string, online bool) (bool, string) {
+	if airport == "KAAC" {
+		return false, "Live weather is not available for fictional airports."
+	} else if !online {
+		return false, "Live weather is not available when the vice server can't be reached."
+	} else if err := WeatherCache.Err(airport); err != nil {
+		return false, "Unable to fetch weather: " + err.Error()
+	}
+	return true, ""
+}
+
+// How long fetched weather is used before it is requested again.
+const weatherCacheTTL = 15 * time.Minute
+
+// WeatherCache holds live weather so that the weather shown when a sim is
+// being configured and the weather the sim is created with come from the
+// same request.
+var WeatherCache = wx.NewCache(weatherCacheTTL, getWeather)
+
+func getWeather(icao ...string) ([]wx.METAR, error) {
+	metar, err := fetchWeather(icao...)
+
+	var decoded []wx.METAR
+	for _, m := range metar {
+		decoded = append(decoded, m.decode())
+	}
+	return decoded, err
+}
+
+// weatherClient is used to fetch weather; it has a timeout so that a
+// stalled request doesn't leave the airports' cache entries pending
+// indefinitely.
+var weatherClient = &http.Client{Timeout: 15 * time.Second}
+
+func fetchWeather(icao ...string) ([]METAR, error) {
 	var query string
 	if len(icao) == 1 {
 		query = icao[0]
@@ -105,7 +173,7 @@ func getWeather(icao ...string) ([]METAR, error) {
 
 	requestUrl := fmt.Sprintf(aviationWeatherCenterDataApi, query)
 
-	res, err := http.Get(requestUrl)
+	res, err := weatherClient.Get(requestUrl)
 	if err != nil {
 		return nil, err
 	}
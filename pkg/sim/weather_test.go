@@ -0,0 +1,45 @@
+// pkg/sim/weather_test.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package sim
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mmp/vice/pkg/wx"
+)
+
+func TestLiveWeatherAvailable(t *testing.T) {
+	saved := WeatherCache
+	defer func() { WeatherCache = saved }()
+	WeatherCache = wx.NewCache(time.Hour, func(icao ...string) ([]wx.METAR, error) {
+		if icao[0] == "KLGA" {
+			return nil, errors.New("connection refused")
+		}
+		return []wx.METAR{{Station: icao[0]}}, nil
+	})
+
+	if ok, why := liveWeatherAvailable("KJFK", true); !ok || why != "" {
+		t.Errorf("expected live weather to be available, got %q", why)
+	}
+	if ok, why := liveWeatherAvailable("KAAC", true); ok || why == "" {
+		t.Errorf("expected live weather to be unavailable for KAAC")
+	}
+	if ok, why := liveWeatherAvailable("KJFK", false); ok || why == "" {
+		t.Errorf("expected live weather to be unavailable when offline")
+	}
+
+	// A fetch error should be reported, but only for that airport.
+	WeatherCache.Fetch("KJFK")
+	WeatherCache.Fetch("KLGA")
+	if ok, why := liveWeatherAvailable("KLGA", true); ok || !strings.Contains(why, "connection refused") {
+		t.Errorf("expected fetch error to be reported, got %q", why)
+	}
+	if ok, why := liveWeatherAvailable("KJFK", true); !ok {
+		t.Errorf("expected live weather to be available for KJFK, got %q", why)
+	}
+}
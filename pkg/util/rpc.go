@@ -95,8 +95,20 @@ func MakeLoggingServerCodec(label string, c rpc.ServerCodec, lg *log.Logger) *Lo
 	return &LoggingServerCodec{ServerCodec: c, lg: lg, label: label}
 }
 
+// rpcRequestsTotal counts the RPC requests received by LoggingServerCodecs.
+var rpcRequestsTotal atomic.Int64
+
+// GetLoggedRPCRequests returns the total number of RPC requests received
+// by LoggingServerCodecs.
+func GetLoggedRPCRequests() int64 {
+	return rpcRequestsTotal.Load()
+}
+
 func (c *LoggingServerCodec) ReadRequestHeader(r *rpc.Request) error {
 	err := c.ServerCodec.ReadRequestHeader(r)
+	if err == nil {
+		rpcRequestsTotal.Add(1)
+	}
 	c.lg.Debug("server: rpc request", slog.String("label", c.label),
 		slog.String("service_method", r.ServiceMethod),
 		slog.Any("error", err))
@@ -0,0 +1,245 @@
+// pkg/wx/atis.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package wx
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// DefaultATISTemplate is the text/template used to generate ATIS
+// broadcasts if a facility doesn't specify its own. It is executed with
+// an ATISData.
+const DefaultATISTemplate = `{{.Airport}} INFORMATION {{.Code}}. {{with .METAR.TimeText}}{{.}}. {{end}}
+{{if .METAR.WindReported}}WIND {{.METAR.WindText}}. {{end}}{{if .METAR.VisibilityReported}}VISIBILITY {{.METAR.VisibilityText}}. {{end}}
+{{with .METAR.WeatherText}}{{.}}. {{end}}{{if .METAR.SkyReported}}{{.METAR.SkyText}}. {{end}}
+{{if .METAR.TemperatureReported}}TEMPERATURE {{.METAR.TemperatureText}}. {{end}}{{if .METAR.DewpointReported}}DEWPOINT {{.METAR.DewpointText}}. {{end}}
+{{if .METAR.Altimeter}}ALTIMETER {{.METAR.AltimeterText}}. {{end}}
+{{with .ArrivalRunways}}LANDING RUNWAY {{join . " AND "}}. {{end}}{{with .DepartureRunways}}DEPARTING RUNWAY {{join . " AND "}}. {{end}}
+{{range .Notices}}{{.}}. {{end}}
+ADVISE ON INITIAL CONTACT YOU HAVE INFORMATION {{.Code}}.`
+
+// ATISData provides the information available to ATIS templates.
+type ATISData struct {
+	Airport          string // name of the airport as it is spoken
+	Code             string // ATIS letter
+	METAR            METAR
+	ArrivalRunways   []string
+	DepartureRunways []string
+	Notices          []string // NOTAMs and other information
+}
+
+// ParseATISTemplate parses the given ATIS template and checks that it can
+// be executed.
+func ParseATISTemplate(tmpl string) (*template.Template, error) {
+	t, err := template.New("atis").Funcs(template.FuncMap{"join": strings.Join}).Parse(tmpl)
+	if err != nil {
+		return nil, err
+	}
+	// Make sure that it only refers to fields and methods that exist by
+	// executing it with representative data.
+	var b strings.Builder
+	if err := t.Execute(&b, sampleATISData()); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func sampleATISData() ATISData {
+	m, _ := ParseMETAR("KJFK 121851Z 31018G27KT 280V340 10SM -RA FEW050 BKN250 08/M07 A2998 RMK AO2")
+	return ATISData{
+		Airport:          "KENNEDY",
+		Code:             "A",
+		METAR:            m,
+		ArrivalRunways:   []string{"31R", "31L"},
+		DepartureRunways: []string{"31L", "4L"},
+		Notices:          []string{"TAXIWAY B CLOSED"},
+	}
+}
+
+// GenerateATIS returns the ATIS broadcast given by the template, which
+// should have been returned by ParseATISTemplate.
+func GenerateATIS(t *template.Template, data ATISData) (string, error) {
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		return "", err
+	}
+	// Cleanup whitespace from the template and from empty fields.
+	return strings.Join(strings.Fields(b.String()), " "), nil
+}
+
+// NextATISCode returns the ATIS letter that follows the given one; "A" is
+// returned if it is empty or "Z".
+func NextATISCode(code string) string {
+	if len(code) != 1 || code[0] < 'A' || code[0] >= 'Z' {
+		return "A"
+	}
+	return string(code[0] + 1)
+}
+
+///////////////////////////////////////////////////////////////////////////
+// METAR text for ATIS broadcasts
+
+// TimeText returns the observation time or an empty string if it wasn't
+// given.
+func (m METAR) TimeText() string {
+	if m.Day == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%02d%02d ZULU", m.Hour, m.Minute)
+}
+
+func (m METAR) WindText() string {
+	w := m.Wind
+	if w.Calm() {
+		return "CALM"
+	}
+
+	s := fmt.Sprintf("%03d AT %d", w.Direction, w.Speed)
+	if w.Variable() {
+		s = fmt.Sprintf("VARIABLE AT %d", w.Speed)
+	}
+	if w.Gust > w.Speed {
+		s += fmt.Sprintf(" GUST %d", w.Gust)
+	}
+	if w.VariableFrom != w.VariableTo {
+		s += fmt.Sprintf(", VARIABLE BETWEEN %03d AND %03d", w.VariableFrom, w.VariableTo)
+	}
+	return s
+}
+
+func (m METAR) VisibilityText() string {
+	if !m.VisibilityReported {
+		return "MISSING"
+	} else if m.CAVOK {
+		return "10 KILOMETERS OR MORE"
+	}
+
+	whole := int(m.Visibility)
+	frac := m.Visibility - float32(whole)
+	var s string
+	if frac > 0 {
+		// Reported fractions are 1/16, 1/8, 3/16, 1/4, ... 7/8.
+		n := int(frac*16 + 0.5)
+		d := 16
+		for n%2 == 0 && d > 1 {
+			n, d = n/2, d/2
+		}
+		s = fmt.Sprintf("%d/%d", n, d)
+		if whole > 0 {
+			s = fmt.Sprintf("%d %s", whole, s)
+		}
+	} else {
+		s = fmt.Sprintf("%d", whole)
+	}
+
+	if m.VisibilityLessThan {
+		s = "LESS THAN " + s
+	}
+	return s
+}
+
+var weatherCodes = []struct{ code, text string }{
+	{"MI", "SHALLOW"}, {"PR", "PARTIAL"}, {"BC", "PATCHES"},
+	{"DR", "LOW DRIFTING"}, {"BL", "BLOWING"}, {"SH", "SHOWERS"}, {"TS", "THUNDERSTORM"},
+	{"FZ", "FREEZING"},
+	{"DZ", "DRIZZLE"}, {"RA", "RAIN"}, {"SN", "SNOW"}, {"SG", "SNOW GRAINS"},
+	{"IC", "ICE CRYSTALS"}, {"PL", "ICE PELLETS"}, {"GR", "HAIL"}, {"GS", "SMALL HAIL"},
+	{"UP", "UNKNOWN PRECIPITATION"}, {"BR", "MIST"}, {"FG", "FOG"}, {"FU", "SMOKE"},
+	{"VA", "VOLCANIC ASH"}, {"DU", "DUST"}, {"SA", "SAND"}, {"HZ", "HAZE"}, {"PY", "SPRAY"},
+	{"PO", "DUST WHIRLS"}, {"SQ", "SQUALLS"}, {"FC", "FUNNEL CLOUD"}, {"SS", "SANDSTORM"},
+	{"DS", "DUSTSTORM"},
+}
+
+// WeatherText returns the present weather in words, e.g. "LIGHT RAIN,
+// MIST" for "-RA BR", or an empty string if none was reported.
+func (m METAR) WeatherText() string {
+	var all []string
+	for _, w := range m.Weather {
+		var words []string
+		if rest, ok := strings.CutPrefix(w, "-"); ok {
+			words, w = append(words, "LIGHT"), rest
+		} else if rest, ok := strings.CutPrefix(w, "+"); ok {
+			words, w = append(words, "HEAVY"), rest
+		}
+
+		var vicinity bool
+		if rest, ok := strings.CutPrefix(w, "VC"); ok {
+			vicinity, w = true, rest
+		}
+		for len(w) >= 2 {
+			for _, c := range weatherCodes {
+				if w[:2] == c.code {
+					words = append(words, c.text)
+					break
+				}
+			}
+			w = w[2:]
+		}
+		if vicinity {
+			words = append(words, "IN THE VICINITY")
+		}
+
+		all = append(all, strings.Join(words, " "))
+	}
+	return strings.Join(all, ", ")
+}
+
+func (m METAR) SkyText() string {
+	if m.CAVOK {
+		return "CEILING AND VISIBILITY OK"
+	} else if m.VerticalVisibility != 0 {
+		return fmt.Sprintf("INDEFINITE CEILING %d", m.VerticalVisibility)
+	} else if len(m.Clouds) == 0 {
+		return "SKY CLEAR"
+	}
+
+	ceiling, _ := m.Ceiling()
+	var layers []string
+	for _, l := range m.Clouds {
+		s := ""
+		if l.Height == ceiling && (l.Coverage == "BKN" || l.Coverage == "OVC") {
+			s = "CEILING "
+		}
+		switch l.Coverage {
+		case "FEW":
+			s += fmt.Sprintf("FEW CLOUDS AT %d", l.Height)
+		case "SCT":
+			s += fmt.Sprintf("%d SCATTERED", l.Height)
+		case "BKN":
+			s += fmt.Sprintf("%d BROKEN", l.Height)
+		case "OVC":
+			s += fmt.Sprintf("%d OVERCAST", l.Height)
+		}
+		switch l.Type {
+		case "CB":
+			s += " CUMULONIMBUS"
+		case "TCU":
+			s += " TOWERING CUMULUS"
+		}
+		layers = append(layers, s)
+	}
+	return strings.Join(layers, ", ")
+}
+
+func (m METAR) TemperatureText() string {
+	return formatATISTemp(m.Temperature)
+}
+
+func (m METAR) DewpointText() string {
+	return formatATISTemp(m.Dewpoint)
+}
+
+func formatATISTemp(t int) string {
+	if t < 0 {
+		return fmt.Sprintf("MINUS %d", -t)
+	}
+	return fmt.Sprintf("%d", t)
+}
+
+func (m METAR) AltimeterText() string {
+	return fmt.Sprintf("%04d", int(m.Altimeter*100+0.5))
+}
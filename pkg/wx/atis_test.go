@@ -0,0 +1,100 @@
+// pkg/wx/atis_test.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package wx
+
+import (
+	"testing"
+)
+
+func TestGenerateATIS(t *testing.T) {
+	tmpl, err := ParseATISTemplate(DefaultATISTemplate)
+	if err != nil {
+		t.Fatalf("default template: %v", err)
+	}
+
+	for _, test := range []struct {
+		metar string
+		data  ATISData
+		atis  string
+	}{
+		{
+			metar: "KJFK 121851Z 31018G27KT 10SM FEW050 BKN250 08/M07 A2998 RMK AO2",
+			data: ATISData{Airport: "KENNEDY", Code: "D", ArrivalRunways: []string{"31R"},
+				DepartureRunways: []string{"31L", "4L"}, Notices: []string{"TAXIWAY B CLOSED"}},
+			atis: "KENNEDY INFORMATION D. 1851 ZULU. WIND 310 AT 18 GUST 27. VISIBILITY 10. " +
+				"FEW CLOUDS AT 5000, CEILING 25000 BROKEN. TEMPERATURE 8. DEWPOINT MINUS 7. ALTIMETER 2998. " +
+				"LANDING RUNWAY 31R. DEPARTING RUNWAY 31L AND 4L. TAXIWAY B CLOSED. " +
+				"ADVISE ON INITIAL CONTACT YOU HAVE INFORMATION D.",
+		},
+		{
+			metar: "KBOS 030454Z VRB03KT 210V280 1 1/2SM -SN BR VV008 M02/M03 A2972",
+			data:  ATISData{Airport: "BOSTON", Code: "Z"},
+			atis: "BOSTON INFORMATION Z. 0454 ZULU. WIND VARIABLE AT 3, VARIABLE BETWEEN 210 AND 280. " +
+				"VISIBILITY 1 1/2. LIGHT SNOW, MIST. INDEFINITE CEILING 800. TEMPERATURE MINUS 2. " +
+				"DEWPOINT MINUS 3. ALTIMETER 2972. ADVISE ON INITIAL CONTACT YOU HAVE INFORMATION Z.",
+		},
+		{
+			// No time, calm wind, missing visibility and altimeter, TS in the vicinity
+			metar: "KAAC 00000KT VCTS SCT030CB 25/",
+			data:  ATISData{Airport: "ACME", Code: "A"},
+			atis: "ACME INFORMATION A. WIND CALM. THUNDERSTORM IN THE VICINITY. " +
+				"3000 SCATTERED CUMULONIMBUS. TEMPERATURE 25. ADVISE ON INITIAL CONTACT YOU HAVE INFORMATION A.",
+		},
+		{
+			// Synthetic weather: only the wind and altimeter are given
+			metar: "KAAC 27005KT A2992",
+			data:  ATISData{Airport: "ACME", Code: "B", ArrivalRunways: []string{"27"}},
+			atis: "ACME INFORMATION B. WIND 270 AT 5. ALTIMETER 2992. LANDING RUNWAY 27. " +
+				"ADVISE ON INITIAL CONTACT YOU HAVE INFORMATION B.",
+		},
+		{
+			// Missing wind and visibility from an automated station
+			metar: "KSBY 121853Z AUTO /////KT ////SM CLR 12/11 A3001",
+			data:  ATISData{Airport: "SAN LUIS", Code: "C"},
+			atis: "SAN LUIS INFORMATION C. 1853 ZULU. SKY CLEAR. TEMPERATURE 12. DEWPOINT 11. " +
+				"ALTIMETER 3001. ADVISE ON INITIAL CONTACT YOU HAVE INFORMATION C.",
+		},
+		{
+			metar: "EGLL 121850Z 24015KT CAVOK 12/06 Q1013",
+			data:  ATISData{Airport: "HEATHROW", Code: "K"},
+			atis: "HEATHROW INFORMATION K. 1850 ZULU. WIND 240 AT 15. VISIBILITY 10 KILOMETERS OR MORE. " +
+				"CEILING AND VISIBILITY OK. TEMPERATURE 12. DEWPOINT 6. ALTIMETER 2991. " +
+				"ADVISE ON INITIAL CONTACT YOU HAVE INFORMATION K.",
+		},
+	} {
+		m, err := ParseMETAR(test.metar)
+		if err != nil {
+			t.Fatalf("%s: %v", test.metar, err)
+		}
+		test.data.METAR = m
+		if atis, err := GenerateATIS(tmpl, test.data); err != nil {
+			t.Errorf("%s: %v", test.metar, err)
+		} else if atis != test.atis {
+			t.Errorf("%s: got ATIS\n%q, expected\n%q", test.metar, atis, test.atis)
+		}
+	}
+
+	if _, err := ParseATISTemplate("{{.Airport}} {{.Ceiling}}"); err == nil {
+		t.Errorf("expected error for template with unknown field")
+	}
+	if tmpl, err := ParseATISTemplate("{{.Airport}} INFO {{.Code}} ALTIMETER {{.METAR.AltimeterText}}"); err != nil {
+		t.Errorf("custom template: %v", err)
+	} else if atis, _ := GenerateATIS(tmpl, ATISData{Airport: "X", Code: "B", METAR: METAR{Altimeter: 30.01}}); atis != "X INFO B ALTIMETER 3001" {
+		t.Errorf("custom template gave %q", atis)
+	}
+	if tmpl, err := ParseATISTemplate("LANDING {{index .ArrivalRunways 0}}"); err != nil {
+		t.Errorf("template with index: %v", err)
+	} else if atis, _ := GenerateATIS(tmpl, ATISData{ArrivalRunways: []string{"22L"}}); atis != "LANDING 22L" {
+		t.Errorf("template with index gave %q", atis)
+	}
+}
+
+func TestNextATISCode(t *testing.T) {
+	for _, test := range [][2]string{{"", "A"}, {"A", "B"}, {"Y", "Z"}, {"Z", "A"}, {"7", "A"}} {
+		if next := NextATISCode(test[0]); next != test[1] {
+			t.Errorf("NextATISCode(%q) = %q, expected %q", test[0], next, test[1])
+		}
+	}
+}
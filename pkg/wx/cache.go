@@ -0,0 +1,196 @@
+// pkg/wx/cache.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package wx
+
+import (
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mmp/vice/pkg/log"
+)
+
+// Cache caches METARs by airport ICAO code so that everything that shows
+// an airport's weather uses the same report. Concurrent requests for the
+// same airport are coalesced into a single fetch.
+type Cache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]*cacheEntry
+	fetch   func(icao ...string) ([]METAR, error)
+}
+
+type cacheEntry struct {
+	metar   METAR
+	found   bool // false if the airport doesn't report weather
+	fetched time.Time
+	pending bool          // a fetch is in progress
+	done    chan struct{} // closed when that fetch finishes
+	err     error         // set if the fetch failed
+}
+
+// How long to wait before automatically trying again after a failed
+// fetch.
+const cacheRetryDelay = time.Minute
+
+// valid returns whether the entry can be used rather than fetching the
+// weather again; c.mu must be held.
+func (c *Cache) valid(e *cacheEntry) bool {
+	if e.err != nil {
+		return time.Since(e.fetched) < cacheRetryDelay
+	}
+	return time.Since(e.fetched) < c.ttl
+}
+
+// NewCache returns a Cache that uses the provided function to fetch
+// weather; it should return the METARs for the given airports, using
+// METAR.Station to identify them. Fetched weather is used for the
+// specified amount of time before it is requested again.
+func NewCache(ttl time.Duration, fetch func(icao ...string) ([]METAR, error)) *Cache {
+	return &Cache{
+		ttl:     ttl,
+		entries: make(map[string]*cacheEntry),
+		fetch:   fetch,
+	}
+}
+
+// Get returns the cached weather for the airport without waiting for it
+// to be fetched; if it isn't available, false is returned and a request
+// is issued for it if one isn't already in progress. After a failed
+// request, another isn't issued until a minute has passed or Retry is
+// called.
+func (c *Cache) Get(icao string, lg *log.Logger) (METAR, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[icao]; ok && (e.pending || c.valid(e)) {
+		return e.metar, !e.pending && e.found
+	}
+
+	fetch := map[string]*cacheEntry{icao: c.reserve(icao)}
+	go func() {
+		if _, err := c.fetchAndStore(fetch); err != nil {
+			lg.Errorf("%s: error getting weather: %v", icao, err)
+		}
+	}()
+	return METAR{}, false
+}
+
+// Fetch returns the weather for the given airports, fetching any that
+// aren't in the cache. Airports that don't report weather are not
+// included in the returned map.
+func (c *Cache) Fetch(icao ...string) (map[string]METAR, error) {
+	result := make(map[string]METAR)
+	waiting := make(map[string]*cacheEntry)
+	fetch := make(map[string]*cacheEntry)
+
+	c.mu.Lock()
+	for _, ap := range icao {
+		if e, ok := c.entries[ap]; ok && e.pending {
+			waiting[ap] = e
+		} else if ok && e.err == nil && c.valid(e) {
+			if e.found {
+				result[ap] = e.metar
+			}
+		} else {
+			fetch[ap] = c.reserve(ap)
+		}
+	}
+	c.mu.Unlock()
+
+	var err error
+	if len(fetch) > 0 {
+		var fetched map[string]METAR
+		fetched, err = c.fetchAndStore(fetch)
+		for ap, m := range fetched {
+			result[ap] = m
+		}
+	}
+
+	for ap, e := range waiting {
+		<-e.done
+		if e.err != nil {
+			if err == nil {
+				err = e.err
+			}
+		} else if e.found {
+			result[ap] = e.metar
+		}
+	}
+
+	return result, err
+}
+
+// reserve records that the weather for the airport is being fetched and
+// returns the entry that will hold it; c.mu must be held.
+func (c *Cache) reserve(icao string) *cacheEntry {
+	e := &cacheEntry{pending: true, done: make(chan struct{})}
+	c.entries[icao] = e
+	return e
+}
+
+// fetchAndStore fetches the weather for the airports of the given reserved
+// entries and fills them in.
+func (c *Cache) fetchAndStore(entries map[string]*cacheEntry) (map[string]METAR, error) {
+	metar, err := c.fetch(slices.Sorted(maps.Keys(entries))...)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	result := make(map[string]METAR)
+	for _, m := range metar {
+		result[m.Station] = m
+	}
+
+	now := time.Now()
+	for ap, e := range entries {
+		if err != nil {
+			e.err = err
+		} else {
+			e.metar, e.found = result[ap]
+		}
+		e.fetched = now
+		e.pending = false
+		close(e.done)
+	}
+
+	return result, err
+}
+
+// Err returns the error from the most recent attempt to fetch the
+// airport's weather, or nil if it succeeded or hasn't finished.
+func (c *Cache) Err(icao string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[icao]; ok && !e.pending {
+		return e.err
+	}
+	return nil
+}
+
+// Retry issues a new request for the airport's weather if the last one
+// failed.
+func (c *Cache) Retry(icao string, lg *log.Logger) {
+	c.mu.Lock()
+	if e, ok := c.entries[icao]; ok && !e.pending && e.err != nil {
+		delete(c.entries, icao)
+	}
+	c.mu.Unlock()
+
+	c.Get(icao, lg)
+}
+
+// Clear discards all cached weather. It doesn't wait for fetches in
+// progress: their entries are dropped from the cache, so callers already
+// waiting on them still get the result, but subsequent requests issue a
+// new fetch.
+func (c *Cache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	clear(c.entries)
+}
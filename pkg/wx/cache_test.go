@@ -0,0 +1,186 @@
+// pkg/wx/cache_test.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package wx
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	var mu sync.Mutex
+	var requests [][]string
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	fetch := func(icao ...string) ([]METAR, error) {
+		mu.Lock()
+		requests = append(requests, icao)
+		mu.Unlock()
+		started <- struct{}{}
+		<-release
+
+		var m []METAR
+		for _, ap := range icao {
+			if ap != "KXXX" { // doesn't report weather
+				m = append(m, METAR{Station: ap, Wind: Wind{Direction: 270, Speed: 10}, Altimeter: 29.92})
+			}
+		}
+		return m, nil
+	}
+	c := NewCache(time.Hour, fetch)
+
+	// While KJFK is being fetched, Get shouldn't issue another request and
+	// Fetch should wait for the first one to finish.
+	if _, ok := c.Get("KJFK", nil); ok {
+		t.Errorf("expected no weather before it was fetched")
+	}
+	<-started
+	if _, ok := c.Get("KJFK", nil); ok {
+		t.Errorf("expected no weather while it's being fetched")
+	}
+	done := make(chan map[string]METAR)
+	go func() {
+		m, err := c.Fetch("KJFK", "KXXX")
+		if err != nil {
+			t.Errorf("Fetch: %v", err)
+		}
+		done <- m
+	}()
+	<-started // KXXX
+	release <- struct{}{}
+	release <- struct{}{}
+
+	m := <-done
+	if len(m) != 1 || m["KJFK"].Wind.Speed != 10 {
+		t.Errorf("Fetch returned %+v, expected KJFK's weather", m)
+	}
+	if len(requests) != 2 || !slices.Equal(requests[0], []string{"KJFK"}) || !slices.Equal(requests[1], []string{"KXXX"}) {
+		t.Errorf("unexpected weather requests %v", requests)
+	}
+
+	// Everything should now come from the cache, including the fact that
+	// KXXX doesn't report weather.
+	if w, ok := c.Get("KJFK", nil); !ok || w.Wind.Speed != 10 {
+		t.Errorf("expected cached weather for KJFK")
+	}
+	if _, ok := c.Get("KXXX", nil); ok {
+		t.Errorf("expected no weather for KXXX")
+	}
+	if m, _ := c.Fetch("KJFK", "KXXX"); len(m) != 1 || len(requests) != 2 {
+		t.Errorf("expected cached weather, got %+v after %d requests", m, len(requests))
+	}
+
+	// Expired entries are fetched again.
+	c.entries["KJFK"].fetched = time.Now().Add(-2 * time.Hour)
+	go func() { <-started; release <- struct{}{} }()
+	if m, _ := c.Fetch("KJFK"); len(m) != 1 || len(requests) != 3 {
+		t.Errorf("expected KJFK to be fetched again, got %+v after %d requests", m, len(requests))
+	}
+}
+
+func TestCacheErrors(t *testing.T) {
+	var requests int
+	var fail bool
+	fetch := func(icao ...string) ([]METAR, error) {
+		requests++
+		if fail {
+			return nil, errors.New("connection refused")
+		}
+		var m []METAR
+		for _, ap := range icao {
+			m = append(m, METAR{Station: ap})
+		}
+		return m, nil
+	}
+	c := NewCache(time.Hour, fetch)
+
+	if _, err := c.Fetch("KJFK"); err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	fail = true
+	if _, err := c.Fetch("KLGA"); err == nil {
+		t.Errorf("expected an error fetching KLGA")
+	}
+
+	// The error should only be associated with KLGA.
+	if err := c.Err("KLGA"); err == nil {
+		t.Errorf("expected an error for KLGA")
+	}
+	if err := c.Err("KJFK"); err != nil {
+		t.Errorf("unexpected error for KJFK: %v", err)
+	}
+	if _, ok := c.Get("KJFK", nil); !ok {
+		t.Errorf("expected cached weather for KJFK")
+	}
+
+	// Get shouldn't immediately request it again after the failure...
+	if _, ok := c.Get("KLGA", nil); ok || requests != 2 {
+		t.Errorf("unexpected weather request after a failure: %d requests", requests)
+	}
+
+	// ...but Retry and Fetch should.
+	fail = false
+	if m, err := c.Fetch("KLGA"); err != nil || len(m) != 1 || requests != 3 {
+		t.Errorf("expected KLGA to be fetched again: %v %+v", err, m)
+	}
+	if err := c.Err("KLGA"); err != nil {
+		t.Errorf("unexpected error for KLGA after fetching it: %v", err)
+	}
+}
+
+func TestCacheClear(t *testing.T) {
+	var mu sync.Mutex
+	var requests int
+	release := make(chan struct{})
+	fetch := func(icao ...string) ([]METAR, error) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		<-release
+		return []METAR{{Station: icao[0]}}, nil
+	}
+	c := NewCache(time.Hour, fetch)
+
+	done := make(chan struct{})
+	go func() {
+		if m, err := c.Fetch("KJFK"); err != nil || len(m) != 1 {
+			t.Errorf("Fetch: %+v %v", m, err)
+		}
+		close(done)
+	}()
+	for {
+		mu.Lock()
+		n := requests
+		mu.Unlock()
+		if n == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Clear shouldn't wait for the fetch in progress...
+	c.Clear()
+	if _, ok := c.Get("KJFK", nil); ok {
+		t.Errorf("expected no weather after Clear")
+	}
+
+	// ...which should still complete for the caller waiting on it, while
+	// the Get above issued a new request.
+	close(release)
+	<-done
+	for {
+		if _, ok := c.Get("KJFK", nil); ok {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if requests != 2 {
+		t.Errorf("expected 2 requests, got %d", requests)
+	}
+}
@@ -0,0 +1,312 @@
+// pkg/wx/metar.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+// Package wx decodes weather reports.
+package wx
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrEmptyMETAR        = errors.New("Empty METAR")
+	ErrInvalidStation    = errors.New("Invalid station identifier")
+	ErrInvalidTime       = errors.New("Invalid observation time")
+	ErrInvalidVisibility = errors.New("Invalid visibility")
+	ErrInvalidWind       = errors.New("Invalid wind")
+)
+
+// METAR holds the decoded fields of a METAR or SPECI report.
+type METAR struct {
+	Raw     string
+	Station string
+	// Observation time (UTC); the day of the month is not associated with
+	// a particular month or year.
+	Day, Hour, Minute int
+	Auto              bool
+	Corrected         bool
+
+	Wind         Wind
+	WindReported bool
+
+	// Prevailing visibility in statute miles. If VisibilityLessThan is
+	// set, the actual visibility is less than the reported value (e.g.,
+	// M1/4SM).
+	Visibility         float32
+	VisibilityLessThan bool
+	VisibilityReported bool
+	// CAVOK: ceiling and visibility OK.
+	CAVOK bool
+
+	// Present weather, e.g. "-RA", "+TSRA", "BR".
+	Weather []string
+
+	Clouds []CloudLayer
+	// SkyReported is set if the sky condition was given, including as
+	// clear or CAVOK.
+	SkyReported bool
+	// Vertical visibility in feet for an indefinite ceiling, or 0.
+	VerticalVisibility int
+
+	// Degrees Celsius.
+	Temperature, Dewpoint int
+	TemperatureReported   bool
+	DewpointReported      bool
+
+	// Inches of mercury; 0 if the altimeter setting was not reported.
+	Altimeter float32
+
+	Remarks string
+}
+
+type Wind struct {
+	// Degrees true, or -1 if the wind is variable.
+	Direction int
+	// Knots.
+	Speed, Gust int
+	// Range of directions if the wind direction is varying, e.g. for
+	// 240V300; both are zero otherwise.
+	VariableFrom, VariableTo int
+}
+
+func (w Wind) Calm() bool {
+	return w.Speed == 0
+}
+
+func (w Wind) Variable() bool {
+	return w.Direction == -1
+}
+
+type CloudLayer struct {
+	// FEW, SCT, BKN, or OVC.
+	Coverage string
+	// Feet above ground level.
+	Height int
+	// CB or TCU if reported, otherwise empty.
+	Type string
+}
+
+// Ceiling returns the height of the ceiling in feet above ground level: the
+// lowest broken or overcast layer or the vertical visibility. false is
+// returned if there is no ceiling.
+func (m METAR) Ceiling() (int, bool) {
+	if m.VerticalVisibility != 0 {
+		return m.VerticalVisibility, true
+	}
+	for _, l := range m.Clouds {
+		if l.Coverage == "BKN" || l.Coverage == "OVC" {
+			return l.Height, true
+		}
+	}
+	return 0, false
+}
+
+var (
+	reTime       = regexp.MustCompile(`^(\d{2})(\d{2})(\d{2})Z$`)
+	reWind       = regexp.MustCompile(`^(\d{3}|VRB)(\d{2,3})(?:G(\d{2,3}))?(KT|MPS)$`)
+	reWindVar    = regexp.MustCompile(`^(\d{3})V(\d{3})$`)
+	reVisibility = regexp.MustCompile(`^(M|P)?(\d+|\d/\d{1,2})SM$`)
+	reWhole      = regexp.MustCompile(`^\d$`)
+	reMeters     = regexp.MustCompile(`^\d{4}$`)
+	reRVR        = regexp.MustCompile(`^R\d{2}[LRC]?/`)
+	reWeather    = regexp.MustCompile(`^(?:\+|-|VC)?(?:MI|PR|BC|DR|BL|SH|TS|FZ)?(?:DZ|RA|SN|SG|IC|PL|GR|GS|UP|BR|FG|FU|VA|DU|SA|HZ|PY|PO|SQ|FC|SS|DS)*$`)
+	reCloud      = regexp.MustCompile(`^(FEW|SCT|BKN|OVC)(\d{3})(CB|TCU)?$`)
+	reVV         = regexp.MustCompile(`^VV(\d{3})$`)
+	reTemp       = regexp.MustCompile(`^(M?\d{2})/(M?\d{2})?$`)
+	reAltimeter  = regexp.MustCompile(`^([AQ])(\d{4})$`)
+)
+
+// ParseMETAR decodes the given METAR. Unrecognized groups in the body of
+// the report are ignored, as is any trend forecast. Groups that are
+// replaced with slashes, as automated stations do for values they were
+// unable to measure, are treated as not reported.
+func ParseMETAR(s string) (METAR, error) {
+	m := METAR{Raw: strings.TrimSpace(s)}
+
+	tokens := strings.Fields(m.Raw)
+	if len(tokens) > 0 && (tokens[0] == "METAR" || tokens[0] == "SPECI") {
+		tokens = tokens[1:]
+	}
+	if len(tokens) == 0 {
+		return METAR{}, ErrEmptyMETAR
+	}
+
+	m.Station = tokens[0]
+	if len(m.Station) != 4 {
+		return METAR{}, fmt.Errorf("%s: %w", m.Station, ErrInvalidStation)
+	}
+	tokens = tokens[1:]
+
+	if len(tokens) > 0 && strings.HasSuffix(tokens[0], "Z") {
+		match := reTime.FindStringSubmatch(tokens[0])
+		if match == nil {
+			return METAR{}, fmt.Errorf("%s: %w", tokens[0], ErrInvalidTime)
+		}
+		m.Day, m.Hour, m.Minute = atoi(match[1]), atoi(match[2]), atoi(match[3])
+		tokens = tokens[1:]
+	}
+
+	for len(tokens) > 0 {
+		tok := tokens[0]
+		tokens = tokens[1:]
+
+		switch {
+		case tok == "RMK":
+			m.Remarks = strings.Join(tokens, " ")
+			return m, nil
+
+		case tok == "AUTO":
+			m.Auto = true
+
+		case tok == "COR":
+			m.Corrected = true
+
+		case tok == "NOSIG" || tok == "BECMG" || tok == "TEMPO":
+			// Skip the trend forecast.
+			for len(tokens) > 0 && tokens[0] != "RMK" {
+				tokens = tokens[1:]
+			}
+
+		case strings.Contains(tok, "//"):
+			// Missing, e.g. "/////KT", "////SM", or "A////".
+
+		case strings.HasSuffix(tok, "KT") || strings.HasSuffix(tok, "MPS"):
+			w, err := parseWind(tok)
+			if err != nil {
+				return METAR{}, err
+			}
+			m.Wind, m.WindReported = w, true
+
+		case reWindVar.MatchString(tok):
+			match := reWindVar.FindStringSubmatch(tok)
+			m.Wind.VariableFrom, m.Wind.VariableTo = atoi(match[1]), atoi(match[2])
+
+		case tok == "CAVOK":
+			m.CAVOK, m.SkyReported = true, true
+			m.Visibility, m.VisibilityReported = metersToSM(10000), true
+
+		case reWhole.MatchString(tok) && len(tokens) > 0 && strings.HasSuffix(tokens[0], "SM"):
+			// Whole and fractional statute miles, e.g. "1 1/2SM"
+			v, err := parseVisibility(tokens[0])
+			if err != nil {
+				return METAR{}, err
+			}
+			m.Visibility, m.VisibilityReported = float32(atoi(tok))+v, true
+			tokens = tokens[1:]
+
+		case strings.HasSuffix(tok, "SM"):
+			v, err := parseVisibility(tok)
+			if err != nil {
+				return METAR{}, err
+			}
+			m.Visibility, m.VisibilityReported = v, true
+			m.VisibilityLessThan = strings.HasPrefix(tok, "M")
+
+		case reMeters.MatchString(tok) && !m.VisibilityReported:
+			// Visibility in meters, with 9999 meaning 10km or more.
+			meters := atoi(tok)
+			if meters == 9999 {
+				meters = 10000
+			}
+			m.Visibility, m.VisibilityReported = metersToSM(meters), true
+
+		case reRVR.MatchString(tok):
+			// Runway visual range isn't decoded.
+
+		case tok == "SKC" || tok == "CLR" || tok == "NSC" || tok == "NCD":
+			// No clouds.
+			m.SkyReported = true
+
+		case reCloud.MatchString(tok):
+			match := reCloud.FindStringSubmatch(tok)
+			m.Clouds = append(m.Clouds, CloudLayer{Coverage: match[1], Height: 100 * atoi(match[2]), Type: match[3]})
+			m.SkyReported = true
+
+		case reVV.MatchString(tok):
+			m.VerticalVisibility = 100 * atoi(reVV.FindStringSubmatch(tok)[1])
+			m.SkyReported = true
+
+		case reTemp.MatchString(tok):
+			match := reTemp.FindStringSubmatch(tok)
+			m.Temperature, m.TemperatureReported = parseTemp(match[1]), true
+			if match[2] != "" {
+				m.Dewpoint, m.DewpointReported = parseTemp(match[2]), true
+			}
+
+		case reAltimeter.MatchString(tok):
+			match := reAltimeter.FindStringSubmatch(tok)
+			if match[1] == "A" {
+				m.Altimeter = float32(atoi(match[2])) / 100
+			} else {
+				// QNH in hectopascals
+				m.Altimeter = float32(atoi(match[2])) * 0.02953
+			}
+
+		case reWeather.MatchString(tok):
+			m.Weather = append(m.Weather, tok)
+		}
+	}
+
+	return m, nil
+}
+
+func parseWind(s string) (Wind, error) {
+	match := reWind.FindStringSubmatch(s)
+	if match == nil {
+		return Wind{}, fmt.Errorf("%s: %w", s, ErrInvalidWind)
+	}
+
+	var w Wind
+	if match[1] == "VRB" {
+		w.Direction = -1
+	} else {
+		w.Direction = atoi(match[1])
+	}
+	w.Speed = atoi(match[2])
+	if match[3] != "" {
+		w.Gust = atoi(match[3])
+	}
+	if match[4] == "MPS" {
+		w.Speed = int(float32(w.Speed)*1.94384 + 0.5)
+		w.Gust = int(float32(w.Gust)*1.94384 + 0.5)
+	}
+	return w, nil
+}
+
+func parseVisibility(s string) (float32, error) {
+	match := reVisibility.FindStringSubmatch(s)
+	if match == nil {
+		return 0, fmt.Errorf("%s: %w", s, ErrInvalidVisibility)
+	}
+	if num, denom, ok := strings.Cut(match[2], "/"); ok {
+		d := atoi(denom)
+		if d == 0 {
+			return 0, fmt.Errorf("%s: %w", s, ErrInvalidVisibility)
+		}
+		return float32(atoi(num)) / float32(d), nil
+	}
+	return float32(atoi(match[2])), nil
+}
+
+func parseTemp(s string) int {
+	if t, ok := strings.CutPrefix(s, "M"); ok {
+		return -atoi(t)
+	}
+	return atoi(s)
+}
+
+func metersToSM(m int) float32 {
+	return float32(m) / 1609.344
+}
+
+// atoi should only be called with strings that have been matched as
+// digits.
+func atoi(s string) int {
+	v, _ := strconv.Atoi(s)
+	return v
+}
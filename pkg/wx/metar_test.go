@@ -0,0 +1,137 @@
+// pkg/wx/metar_test.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package wx
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestParseMETAR(t *testing.T) {
+	type testcase struct {
+		metar string
+		check func(m METAR) bool
+	}
+	for _, test := range []testcase{
+		{
+			metar: "KJFK 121851Z 31018G27KT 10SM FEW050 SCT250 08/M07 A2998 RMK AO2 PK WND 30034/1802 SLP151 T00781067",
+			check: func(m METAR) bool {
+				return m.Station == "KJFK" && m.Day == 12 && m.Hour == 18 && m.Minute == 51 &&
+					m.Wind == Wind{Direction: 310, Speed: 18, Gust: 27} &&
+					m.Visibility == 10 && m.VisibilityReported && !m.VisibilityLessThan &&
+					slices.Equal(m.Clouds, []CloudLayer{{Coverage: "FEW", Height: 5000}, {Coverage: "SCT", Height: 25000}}) &&
+					m.Temperature == 8 && m.Dewpoint == -7 && m.TemperatureReported && m.DewpointReported &&
+					m.Altimeter == 29.98 &&
+					m.Remarks == "AO2 PK WND 30034/1802 SLP151 T00781067"
+			},
+		},
+		{
+			// Variable wind, low visibility with a fraction, weather, vertical visibility.
+			metar: "METAR KBOS 030454Z VRB03KT 1 1/2SM -SN BR VV008 M02/M03 A2972 RMK AO2",
+			check: func(m METAR) bool {
+				c, ok := m.Ceiling()
+				return m.Station == "KBOS" && m.Wind.Variable() && m.Wind.Speed == 3 &&
+					m.Visibility == 1.5 && slices.Equal(m.Weather, []string{"-SN", "BR"}) &&
+					m.VerticalVisibility == 800 && ok && c == 800 &&
+					m.Temperature == -2 && m.Dewpoint == -3 && m.Altimeter == 29.72
+			},
+		},
+		{
+			// Calm wind, less than 1/4 mile, freezing fog, indefinite ceiling; AUTO
+			metar: "KSFO 150756Z AUTO 00000KT M1/4SM R28L/0600V1000FT FZFG OVC001 01/01 A3012 RMK AO2",
+			check: func(m METAR) bool {
+				c, ok := m.Ceiling()
+				return m.Auto && m.Wind.Calm() && m.Visibility == 0.25 && m.VisibilityLessThan &&
+					slices.Equal(m.Weather, []string{"FZFG"}) && ok && c == 100
+			},
+		},
+		{
+			// Varying wind direction, thunderstorms, CB layer
+			metar: "KDFW 221953Z 24012G22KT 210V280 3SM +TSRA BKN030CB OVC080 24/21 A2990",
+			check: func(m METAR) bool {
+				c, ok := m.Ceiling()
+				return m.Wind == Wind{Direction: 240, Speed: 12, Gust: 22, VariableFrom: 210, VariableTo: 280} &&
+					m.Visibility == 3 && slices.Equal(m.Weather, []string{"+TSRA"}) &&
+					m.Clouds[0] == CloudLayer{Coverage: "BKN", Height: 3000, Type: "CB"} &&
+					ok && c == 3000 && m.Remarks == ""
+			},
+		},
+		{
+			// International: meters, CAVOK, QNH, trend forecast
+			metar: "EGLL 121850Z 24015KT CAVOK 12/06 Q1013 NOSIG",
+			check: func(m METAR) bool {
+				_, ceiling := m.Ceiling()
+				return m.Station == "EGLL" && m.CAVOK && !ceiling && m.Visibility > 6 &&
+					math.Abs(float64(m.Altimeter)-29.91) < 0.01 && m.Temperature == 12
+			},
+		},
+		{
+			metar: "LFPG 121900Z 05004MPS 9999 -RA BKN012 OVC020 10/09 Q0998 TEMPO 4000 RA",
+			check: func(m METAR) bool {
+				c, ok := m.Ceiling()
+				return m.Wind.Speed == 8 && m.Visibility > 6 && ok && c == 1200 &&
+					slices.Equal(m.Weather, []string{"-RA"})
+			},
+		},
+		{
+			// Missing altimeter and dewpoint, clear skies
+			metar: "KAAC 121851Z 27005KT 10SM CLR 30/",
+			check: func(m METAR) bool {
+				_, ceiling := m.Ceiling()
+				return m.Altimeter == 0 && m.TemperatureReported && !m.DewpointReported &&
+					len(m.Clouds) == 0 && !ceiling
+			},
+		},
+		{
+			// No time or visibility
+			metar: "KPHL 18010KT A3001",
+			check: func(m METAR) bool {
+				return m.Wind.Direction == 180 && m.WindReported && !m.VisibilityReported && m.Altimeter == 30.01
+			},
+		},
+		{
+			// AUTO report with missing wind, visibility, and altimeter
+			metar: "KSBY 121853Z AUTO /////KT ////SM OVC008 12/11 A////",
+			check: func(m METAR) bool {
+				c, ok := m.Ceiling()
+				return m.Auto && !m.WindReported && m.Wind == Wind{} && !m.VisibilityReported &&
+					m.Altimeter == 0 && ok && c == 800 && m.Temperature == 12 && m.Dewpoint == 11
+			},
+		},
+		{
+			// Missing wind direction and temperature/dewpoint
+			metar: "KSBY 121853Z AUTO ///05KT 10SM CLR ///// A2992",
+			check: func(m METAR) bool {
+				return !m.WindReported && m.Visibility == 10 && !m.TemperatureReported &&
+					!m.DewpointReported && m.Altimeter == 29.92
+			},
+		},
+	} {
+		m, err := ParseMETAR(test.metar)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.metar, err)
+		} else if !test.check(m) {
+			t.Errorf("%s: unexpected decoded METAR %+v", test.metar, m)
+		}
+	}
+
+	for _, test := range []struct {
+		metar string
+		err   error
+	}{
+		{metar: "", err: ErrEmptyMETAR},
+		{metar: "METAR", err: ErrEmptyMETAR},
+		{metar: "JFK 121851Z 31018KT", err: ErrInvalidStation},
+		{metar: "KJFK 1218Z 31018KT", err: ErrInvalidTime},
+		{metar: "KJFK 121851Z 3101KT", err: ErrInvalidWind},
+		{metar: "KJFK 121851Z 31018KT 1/0SM", err: ErrInvalidVisibility},
+	} {
+		if _, err := ParseMETAR(test.metar); !errors.Is(err, test.err) {
+			t.Errorf("%q: got error %v, expected %v", test.metar, err, test.err)
+		}
+	}
+}
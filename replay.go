@@ -0,0 +1,169 @@
+// replay.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/mmp/vice/pkg/log"
+	"github.com/mmp/vice/pkg/renderer"
+	"github.com/mmp/vice/pkg/sim"
+
+	"github.com/mmp/imgui-go/v4"
+)
+
+var replaySpeeds = []float32{0.5, 1, 2, 4, 8}
+
+// ReplayController drives a sim started from a session recording (via
+// -replay), allowing it to be paused, stepped, sped up or slowed down,
+// and moved to a particular time.
+type ReplayController struct {
+	filename string
+	rec      *sim.Recording
+	// load starts a new local sim and returns its client; it is used to
+	// start the replay and to seek backward, which requires restarting
+	// from the recorded initial state.
+	load func(*sim.Sim) *sim.ControlClient
+
+	speed  int // index into replaySpeeds
+	target string
+	// seekTime is the sim time that the current seek is headed to; the
+	// sim runs forward asynchronously, so it's used to report progress.
+	seekTime time.Time
+	err      string
+	lg       *log.Logger
+}
+
+func NewReplayController(filename string, rec *sim.Recording, load func(*sim.Sim) *sim.ControlClient,
+	lg *log.Logger) *ReplayController {
+	return &ReplayController{
+		filename: filename,
+		rec:      rec,
+		load:     load,
+		speed:    1,
+		lg:       lg,
+	}
+}
+
+// Start starts replaying the recording from its beginning, returning the
+// client for the new sim.
+func (rc *ReplayController) Start() (*sim.ControlClient, error) {
+	// The sim is handed off to the server when it's loaded, so read the
+	// file each time to get a fresh copy.
+	rec, err := sim.LoadRecording(rc.filename)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the sim from running until the replay has been set up.
+	paused := rec.Sim.Paused
+	rec.Sim.Paused = true
+
+	c := rc.load(rec.Sim)
+	if c == nil {
+		return nil, errors.New("unable to load the recorded sim")
+	}
+	if err := c.StartReplay(rec, paused); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Seek moves the replay to the given target, as accepted by
+// sim.Recording.ResolveTarget.
+func (rc *ReplayController) Seek(c *sim.ControlClient, target string) {
+	t, err := rc.rec.ResolveTarget(target)
+	if err != nil {
+		rc.err = err.Error()
+		return
+	}
+	rc.err = ""
+
+	if t.Before(c.CurrentTime()) {
+		// We can only run the sim forward, so start over from the
+		// beginning of the recording.
+		rate := c.GetSimRate()
+		if c, err = rc.Start(); err != nil {
+			rc.err = err.Error()
+			return
+		}
+		c.SetSimRate(rate)
+	}
+
+	if d := t.Sub(c.CurrentTime()); d >= time.Second {
+		rc.lg.Infof("%s: seeking to %s", rc.filename, target)
+		rc.seekTime = t
+		c.AdvanceTime(d, nil, func(err error) {
+			rc.err = err.Error()
+			rc.seekTime = time.Time{}
+		})
+	}
+}
+
+func (rc *ReplayController) DrawWindow(c *sim.ControlClient) {
+	imgui.BeginV("Replay", nil, imgui.WindowFlagsAlwaysAutoResize)
+
+	start := rc.rec.SimStartTime()
+	elapsed := c.CurrentTime().Sub(start).Truncate(time.Second)
+	imgui.Text(fmt.Sprintf("%s: %s", filepath.Base(rc.filename), elapsed))
+	if !rc.seekTime.IsZero() {
+		if c.CurrentTime().Before(rc.seekTime) {
+			imgui.SameLine()
+			imgui.Text(fmt.Sprintf("(seeking to %s)", rc.seekTime.Sub(start)))
+		} else {
+			rc.seekTime = time.Time{}
+		}
+	}
+
+	if c.SimIsPaused {
+		if imgui.Button(renderer.FontAwesomeIconPlayCircle) {
+			c.ToggleSimPause()
+		}
+		if imgui.IsItemHovered() {
+			imgui.SetTooltip("Resume replay")
+		}
+	} else {
+		if imgui.Button(renderer.FontAwesomeIconPauseCircle) {
+			c.ToggleSimPause()
+		}
+		if imgui.IsItemHovered() {
+			imgui.SetTooltip("Pause replay")
+		}
+	}
+	for _, step := range []time.Duration{time.Second, 10 * time.Second, time.Minute} {
+		imgui.SameLine()
+		if imgui.Button("+" + step.String()) {
+			c.AdvanceTime(step, nil, func(err error) { rc.err = err.Error() })
+		}
+	}
+
+	imgui.Text("Speed:")
+	for i, s := range replaySpeeds {
+		imgui.SameLine()
+		if imgui.RadioButtonInt(fmt.Sprintf("%gx", s), &rc.speed, i) {
+			c.SetSimRate(s)
+		}
+	}
+
+	imgui.InputTextV("##target", &rc.target, 0, nil)
+	if imgui.IsItemHovered() {
+		imgui.SetTooltip("Seconds, MM:SS, or HH:MM:SS from the start of the recording, or until:CALLSIGN")
+	}
+	imgui.SameLine()
+	if imgui.Button("Go") {
+		rc.Seek(c, rc.target)
+	}
+
+	if rc.err != "" {
+		imgui.PushStyleColor(imgui.StyleColorText, imgui.Vec4{1, .2, .2, 1})
+		imgui.Text(rc.err)
+		imgui.PopStyleColor()
+	}
+
+	imgui.End()
+}
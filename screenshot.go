@@ -0,0 +1,141 @@
+// screenshot.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/mmp/vice/pkg/log"
+	"github.com/mmp/vice/pkg/platform"
+	"github.com/mmp/vice/pkg/renderer"
+)
+
+// readFramebuffer returns the contents of the framebuffer. It should be
+// called after the frame has been drawn but before it's displayed.
+func readFramebuffer(r renderer.Renderer, p platform.Platform) (*image.RGBA, error) {
+	// Use the framebuffer resolution, which may be larger than the
+	// window size with high-DPI displays.
+	fb := p.FramebufferSize()
+	w, h := int(fb[0]), int(fb[1])
+	if w <= 0 || h <= 0 {
+		return nil, fmt.Errorf("invalid framebuffer size %dx%d", w, h)
+	}
+	return renderer.ReadImage(r, 0, 0, w, h), nil
+}
+
+// saveScreenshot saves the contents of the framebuffer as a PNG in the
+// given directory, returning the path to the file.
+func saveScreenshot(r renderer.Renderer, p platform.Platform, dir string) (string, error) {
+	img, err := readFramebuffer(r, p)
+	if err != nil {
+		return "", err
+	}
+
+	fn := filepath.Join(dir, "vice-screenshot-"+time.Now().Format("2006-01-02-150405")+".png")
+	return fn, renderer.WritePNG(fn, img)
+}
+
+func uiSaveScreenshot(r renderer.Renderer, p platform.Platform, lg *log.Logger) {
+	if fn, err := saveScreenshot(r, p, lg.LogDir); err != nil {
+		ShowErrorDialog(p, lg, "Unable to save screenshot: %v", err)
+	} else {
+		lg.Infof("saved screenshot to %s", fn)
+		uiShowModalDialog(NewModalDialogBox(&MessageModalClient{
+			title:   "Screenshot Saved",
+			message: "The screenshot was saved to " + fn,
+		}, p), false)
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////
+// TimelapseRecorder
+
+// TimelapseRecorder periodically saves the contents of the framebuffer to
+// a sequence of numbered PNG files, which can then be assembled into a
+// video.
+type TimelapseRecorder struct {
+	Dir       string
+	Frames    int
+	interval  time.Duration
+	maxFrames int
+	lastFrame time.Time
+	// Set while a frame is being written; frames are skipped rather than
+	// stalling drawing if writing one takes longer than the interval.
+	writing atomic.Bool
+}
+
+// NewTimelapseRecorder creates a new timestamped directory for the frames
+// inside dir and returns a TimelapseRecorder that will save at most
+// maxFrames frames there.
+func NewTimelapseRecorder(dir string, interval time.Duration, maxFrames int) (*TimelapseRecorder, error) {
+	dir = filepath.Join(dir, "vice-timelapse-"+time.Now().Format("2006-01-02-150405"))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &TimelapseRecorder{
+		Dir:       dir,
+		interval:  interval,
+		maxFrames: maxFrames,
+	}, nil
+}
+
+// Update should be called each frame after it has been drawn but before
+// it is displayed; it saves the frame if enough time has passed since the
+// last one. It returns true once the maximum number of frames have been
+// saved.
+func (t *TimelapseRecorder) Update(r renderer.Renderer, p platform.Platform, lg *log.Logger) bool {
+	if t.Frames >= t.maxFrames {
+		return true
+	}
+	if time.Since(t.lastFrame) < t.interval || t.writing.Load() {
+		return false
+	}
+
+	img, err := readFramebuffer(r, p)
+	if err != nil {
+		lg.Errorf("timelapse: %v", err)
+		return false
+	}
+	t.lastFrame = time.Now()
+	t.Frames++
+
+	// Encoding a PNG is slow enough that it's done in the background.
+	fn := filepath.Join(t.Dir, fmt.Sprintf("frame-%05d.png", t.Frames))
+	t.writing.Store(true)
+	go func() {
+		defer t.writing.Store(false)
+		if err := renderer.WritePNG(fn, img); err != nil {
+			lg.Errorf("%s: %v", fn, err)
+		}
+	}()
+
+	return t.Frames >= t.maxFrames
+}
+
+func uiStartTimelapse(config *Config, p platform.Platform, lg *log.Logger) {
+	interval := time.Duration(config.TimelapseIntervalSeconds) * time.Second
+	if tr, err := NewTimelapseRecorder(lg.LogDir, interval, config.TimelapseMaxFrames); err != nil {
+		ShowErrorDialog(p, lg, "Unable to start timelapse recording: %v", err)
+	} else {
+		lg.Infof("recording timelapse to %s", tr.Dir)
+		ui.timelapse = tr
+	}
+}
+
+func uiStopTimelapse(p platform.Platform, lg *log.Logger) {
+	tr := ui.timelapse
+	ui.timelapse = nil
+
+	lg.Infof("saved %d timelapse frames to %s", tr.Frames, tr.Dir)
+	uiShowModalDialog(NewModalDialogBox(&MessageModalClient{
+		title:   "Timelapse Recording Finished",
+		message: fmt.Sprintf("%d frames were saved to %s", tr.Frames, tr.Dir),
+	}, p), false)
+}
@@ -9,8 +9,9 @@ import (
 	"runtime"
 	"time"
 
-	"github.com/mmp/vice/pkg/log"
+	"github.com/mmp/vice/pkg/panes"
 	"github.com/mmp/vice/pkg/renderer"
+	"github.com/mmp/vice/pkg/sim"
 )
 
 // Stats collects a few statistics related to rendering and time spent in
@@ -20,11 +21,64 @@ type Stats struct {
 	drawUI    renderer.RendererStats
 	startTime time.Time
 	redraws   int
+
+	// Exponentially-weighted averages of the time between frames and the
+	// time spent generating each frame, not including waiting for vsync.
+	lastFrame     time.Time
+	frameInterval time.Duration
+	drawTime      time.Duration
+	// Set when Config.AdaptiveQuality is enabled and frames have been
+	// taking longer than frameTimeBudget to draw.
+	reducedQuality bool
+}
+
+const (
+	// If frames take longer than this to draw and Config.AdaptiveQuality
+	// is set, expensive drawing is reduced until the draw time drops below
+	// half of it.
+	frameTimeBudget = 33 * time.Millisecond
+	// Weight given to the latest frame in the running averages.
+	frameTimeWeight = 0.05
+)
+
+// UpdateFrameTime should be called after each frame is drawn with the
+// time it took to generate it. It returns whether reduced quality
+// rendering should be used for the next frame.
+func (stats *Stats) UpdateFrameTime(draw time.Duration, adaptive bool) bool {
+	now := time.Now()
+	if !stats.lastFrame.IsZero() {
+		avg := func(a, d time.Duration) time.Duration {
+			return time.Duration((1-frameTimeWeight)*float64(a) + frameTimeWeight*float64(d))
+		}
+		stats.frameInterval = avg(stats.frameInterval, now.Sub(stats.lastFrame))
+		stats.drawTime = avg(stats.drawTime, draw)
+	} else {
+		stats.drawTime = draw
+	}
+	stats.lastFrame = now
+
+	if !adaptive {
+		stats.reducedQuality = false
+	} else if !stats.reducedQuality && stats.drawTime > frameTimeBudget {
+		stats.reducedQuality = true
+	} else if stats.reducedQuality && stats.drawTime < frameTimeBudget/2 {
+		stats.reducedQuality = false
+	}
+	return stats.reducedQuality
+}
+
+// FPS returns the current number of frames drawn per second.
+func (stats Stats) FPS() float64 {
+	if stats.frameInterval == 0 {
+		return 0
+	}
+	return 1 / stats.frameInterval.Seconds()
 }
 
 var startupMallocs uint64
 
-func (stats Stats) LogValue(lg *log.Logger) slog.Value {
+// implements slog.LogValuer
+func (stats Stats) LogValue() slog.Value {
 	var mem runtime.MemStats
 	runtime.ReadMemStats(&mem)
 
@@ -32,14 +86,30 @@ func (stats Stats) LogValue(lg *log.Logger) slog.Value {
 		startupMallocs = mem.Mallocs
 	}
 
-	elapsed := time.Since(lg.Start).Seconds()
+	elapsed := time.Since(stats.startTime).Seconds()
 	mallocsPerSecond := float64(mem.Mallocs-startupMallocs) / elapsed
 
 	return slog.GroupValue(
-		slog.Float64("redraws_per_second", float64(stats.redraws)/time.Since(stats.startTime).Seconds()),
+		slog.Float64("redraws_per_second", float64(stats.redraws)/elapsed),
+		slog.Float64("fps", stats.FPS()),
+		slog.Duration("draw_time", stats.drawTime),
+		slog.Bool("reduced_quality", stats.reducedQuality),
 		slog.Float64("mallocs_per_second", mallocsPerSecond),
 		slog.Int64("active_mallocs", int64(mem.Mallocs-mem.Frees)),
 		slog.Int64("memory_in_use", int64(mem.HeapAlloc)),
 		slog.Any("draw_panes", stats.drawPanes),
-		slog.Any("draw_ui", stats.drawUI))
+		slog.Any("draw_ui", stats.drawUI),
+		slog.Any("rpc", sim.GetRPCTimingStats()))
+}
+
+// paneStats returns the statistics reported by the panes in the given
+// display hierarchy.
+func paneStats(root *panes.DisplayNode) slog.Value {
+	var attrs []slog.Attr
+	root.VisitPanes(func(p panes.Pane) {
+		if r, ok := p.(panes.StatsReporter); ok {
+			attrs = append(attrs, r.Stats()...)
+		}
+	})
+	return slog.GroupValue(attrs...)
 }
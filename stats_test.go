@@ -0,0 +1,41 @@
+// stats_test.go
+// Copyright(c) 2022-2024 vice contributors, licensed under the GNU Public License, Version 3.
+// SPDX: GPL-3.0-only
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestStatsLogValue(t *testing.T) {
+	stats := Stats{startTime: time.Now().Add(-10 * time.Second), redraws: 600}
+	for range 10 {
+		stats.UpdateFrameTime(40*time.Millisecond, true)
+	}
+
+	var buf bytes.Buffer
+	lg := slog.New(slog.NewJSONHandler(&buf, nil))
+	lg.Info("performance", slog.Any("stats", stats))
+
+	var entry struct {
+		Stats map[string]any `json:"stats"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("%s: %v", buf.String(), err)
+	}
+
+	for _, key := range []string{"redraws_per_second", "fps", "draw_time", "reduced_quality", "memory_in_use",
+		"draw_panes", "draw_ui"} {
+		if _, ok := entry.Stats[key]; !ok {
+			t.Errorf("%q missing from logged stats: %s", key, buf.String())
+		}
+	}
+	if rq, ok := entry.Stats["reduced_quality"].(bool); !ok || !rq {
+		t.Errorf("expected reduced_quality to be true: %s", buf.String())
+	}
+}
@@ -53,12 +53,20 @@ var (
 		newReleaseDialogChan chan *NewReleaseModalClient
 
 		launchControlWindow  *LaunchControlWindow
+		replay               *ReplayController
+		layoutName           string
 		missingPrimaryDialog *ModalDialogBox
 
 		// Scenario routes to draw on the scope
 		showSettings      bool
 		showScenarioInfo  bool
 		showLaunchControl bool
+
+		// Set when the screenshot button is clicked or Print Screen is
+		// pressed; the screenshot is saved once the frame has been drawn.
+		takeScreenshot bool
+		// Non-nil while a timelapse is being recorded.
+		timelapse *TimelapseRecorder
 	}
 
 	//go:embed icons/tower-256x256.png
@@ -314,7 +322,8 @@ func uiInit(r renderer.Renderer, p platform.Platform, config *Config, es *sim.Ev
 		imgui.CurrentStyle().ScaleAllSizes(p.DPIScale())
 	}
 
-	ui.font = renderer.GetFont(renderer.FontIdentifier{Name: "Roboto Regular", Size: config.UIFontSize})
+	ui.font = renderer.GetClosestFont(renderer.FontIdentifier{Name: "Roboto Regular", Size: config.UIFontSize})
+	config.UIFontSize = ui.font.Size
 	ui.aboutFont = renderer.GetFont(renderer.FontIdentifier{Name: "Roboto Regular", Size: 18})
 	ui.aboutFontSmall = renderer.GetFont(renderer.FontIdentifier{Name: "Roboto Regular", Size: 14})
 	ui.eventsSubscription = es.Subscribe()
@@ -490,6 +499,25 @@ func uiDraw(mgr *sim.ConnectionManager, config *Config, p platform.Platform, r r
 			imgui.SetTooltip("Display online vice documentation")
 		}
 
+		if imgui.Button(renderer.FontAwesomeIconCamera) || p.GetKeyboard().WasPressed(platform.KeyPrintScreen) {
+			ui.takeScreenshot = true
+		}
+		// Don't include the tooltip in the screenshot.
+		if imgui.IsItemHovered() && !ui.takeScreenshot {
+			imgui.SetTooltip("Save a screenshot (Print Screen)")
+		}
+
+		if ui.timelapse != nil {
+			imgui.PushStyleColor(imgui.StyleColorText, imgui.Vec4{.9, 0, 0, 1})
+			if imgui.Button(renderer.FontAwesomeIconStopCircle) {
+				uiStopTimelapse(p, lg)
+			}
+			imgui.PopStyleColor()
+			if imgui.IsItemHovered() && ui.timelapse != nil {
+				imgui.SetTooltip(fmt.Sprintf("Stop recording timelapse (%d frames saved)", ui.timelapse.Frames))
+			}
+		}
+
 		width, _ := ui.font.BoundText(renderer.FontAwesomeIconInfoCircle, 0)
 		imgui.SetCursorPos(imgui.Vec2{p.DisplaySize()[0] - float32(6*width+15), 0})
 		if imgui.Button(renderer.FontAwesomeIconInfoCircle) {
@@ -516,7 +544,7 @@ func uiDraw(mgr *sim.ConnectionManager, config *Config, p platform.Platform, r r
 	ui.menuBarHeight = imgui.CursorPos().Y - 1
 
 	if controlClient != nil {
-		uiDrawSettingsWindow(controlClient, config, p)
+		uiDrawSettingsWindow(controlClient, config, p, r, eventStream, lg)
 
 		if ui.showScenarioInfo {
 			ui.showScenarioInfo = controlClient.DrawScenarioInfoWindow(lg)
@@ -524,6 +552,10 @@ func uiDraw(mgr *sim.ConnectionManager, config *Config, p platform.Platform, r r
 
 		uiDrawMissingPrimaryDialog(mgr, controlClient, p)
 
+		if ui.replay != nil {
+			ui.replay.DrawWindow(controlClient)
+		}
+
 		if ui.showLaunchControl {
 			if ui.launchControlWindow == nil {
 				ui.launchControlWindow = MakeLaunchControlWindow(controlClient, lg)
@@ -533,7 +565,14 @@ func uiDraw(mgr *sim.ConnectionManager, config *Config, p platform.Platform, r r
 	}
 
 	for _, event := range ui.eventsSubscription.Get() {
-		if event.Type == sim.ServerBroadcastMessageEvent {
+		switch event.Type {
+		case sim.ServerBroadcastMessageEvent:
+			uiShowModalDialog(NewModalDialogBox(&BroadcastModalDialog{Message: event.Message}, p), false)
+		case sim.ErrorMessageEvent:
+			ShowErrorDialog(p, lg, "%s", event.Message)
+		case sim.ServerShutdownEvent:
+			// Save now, while we're still signed in to the sim.
+			config.SaveIfChanged(r, p, controlClient, mgr.ClientIsLocal(), lg)
 			uiShowModalDialog(NewModalDialogBox(&BroadcastModalDialog{Message: event.Message}, p), false)
 		}
 	}
@@ -1690,8 +1729,8 @@ func uiDrawKeyboardWindow(c *sim.ControlClient, config *Config) {
 
 	imgui.Separator()
 
-	fixedFont := renderer.GetFont(renderer.FontIdentifier{Name: "Roboto Mono", Size: config.UIFontSize})
-	italicFont := renderer.GetFont(renderer.FontIdentifier{Name: "Roboto Mono Italic", Size: config.UIFontSize})
+	fixedFont := renderer.GetClosestFont(renderer.FontIdentifier{Name: "Roboto Mono", Size: config.UIFontSize})
+	italicFont := renderer.GetClosestFont(renderer.FontIdentifier{Name: "Roboto Mono Italic", Size: config.UIFontSize})
 
 	// Tighten up the line spacing
 	spc := style.ItemSpacing()
@@ -1957,7 +1996,8 @@ func uiDrawMissingPrimaryDialog(mgr *sim.ConnectionManager, c *sim.ControlClient
 	}
 }
 
-func uiDrawSettingsWindow(c *sim.ControlClient, config *Config, p platform.Platform) {
+func uiDrawSettingsWindow(c *sim.ControlClient, config *Config, p platform.Platform, r renderer.Renderer,
+	eventStream *sim.EventStream, lg *log.Logger) {
 	if !ui.showSettings {
 		return
 	}
@@ -1968,6 +2008,29 @@ func uiDrawSettingsWindow(c *sim.ControlClient, config *Config, p platform.Platf
 		c.SetSimRate(c.SimRate)
 	}
 
+	idleTimeout := int32(config.IdleTimeoutMinutes)
+	if imgui.SliderInt("Idle timeout (minutes, 0 = never)", &idleTimeout, 0, 120) {
+		config.IdleTimeoutMinutes = int(idleTimeout)
+	}
+	if config.IdleTimeoutMinutes > 0 {
+		imgui.Text("When idle:")
+		imgui.SameLine()
+		idleAction := util.Select(config.IdleDisconnect, 1, 0)
+		if imgui.RadioButtonInt("Pause (single-controller sims only)", &idleAction, 0) {
+			config.IdleDisconnect = false
+		}
+		imgui.SameLine()
+		if imgui.RadioButtonInt("Disconnect", &idleAction, 1) {
+			config.IdleDisconnect = true
+		}
+	}
+
+	imgui.Checkbox("Reduce drawing detail when the display is slow", &config.AdaptiveQuality)
+	if imgui.IsItemHovered() {
+		imgui.SetTooltip("Fewer history tracks are drawn and weather crossfades are skipped if\n" +
+			"drawing takes too long, until performance recovers.")
+	}
+
 	update := !config.InhibitDiscordActivity.Load()
 	imgui.Checkbox("Update Discord activity status", &update)
 	config.InhibitDiscordActivity.Store(!update)
@@ -2009,6 +2072,65 @@ func uiDrawSettingsWindow(c *sim.ControlClient, config *Config, p platform.Platf
 		}
 	}
 
+	if imgui.CollapsingHeader("Timelapse Recording") {
+		interval := int32(config.TimelapseIntervalSeconds)
+		if imgui.SliderInt("Seconds between frames", &interval, 1, 60) {
+			config.TimelapseIntervalSeconds = int(interval)
+		}
+		maxFrames := int32(config.TimelapseMaxFrames)
+		if imgui.SliderInt("Maximum number of frames", &maxFrames, 10, 10000) {
+			config.TimelapseMaxFrames = int(maxFrames)
+		}
+
+		if ui.timelapse == nil {
+			if imgui.Button("Start recording") {
+				uiStartTimelapse(config, p, lg)
+			}
+		} else {
+			if imgui.Button("Stop recording") {
+				uiStopTimelapse(p, lg)
+			} else {
+				imgui.SameLine()
+				imgui.Text(fmt.Sprintf("%d frames saved", ui.timelapse.Frames))
+			}
+		}
+	}
+
+	if imgui.CollapsingHeader("Layout") {
+		useLayout := func(layout *panes.DisplayNode) {
+			if err := config.UseLayout(layout, c, r, p, eventStream, lg); err != nil {
+				ShowErrorDialog(p, lg, "Unable to change layout: %v", err)
+			}
+		}
+
+		if imgui.Button("Reset to default layout") {
+			useLayout(nil)
+		}
+
+		imgui.InputTextV("##layoutname", &ui.layoutName, 0, nil)
+		imgui.SameLine()
+		uiStartDisable(ui.layoutName == "")
+		if imgui.Button("Save current layout") && ui.layoutName != "" {
+			if err := config.SaveLayout(ui.layoutName); err != nil {
+				ShowErrorDialog(p, lg, "Unable to save layout: %v", err)
+			}
+			ui.layoutName = ""
+		}
+		uiEndDisable(ui.layoutName == "")
+
+		for _, name := range util.SortedMapKeys(config.Layouts) {
+			if imgui.Button("Use##" + name) {
+				useLayout(config.Layouts[name])
+			}
+			imgui.SameLine()
+			if imgui.Button(renderer.FontAwesomeIconTrash + "##" + name) {
+				delete(config.Layouts, name)
+			}
+			imgui.SameLine()
+			imgui.Text(name)
+		}
+	}
+
 	config.DisplayRoot.VisitPanes(func(pane panes.Pane) {
 		if draw, ok := pane.(panes.UIDrawer); ok {
 			if imgui.CollapsingHeader(draw.DisplayName()) {